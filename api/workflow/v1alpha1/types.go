@@ -19,6 +19,24 @@ const (
 	CRDFullName  string = CRDPlural + "." + CRDGroup
 )
 
+// WorkflowTemplate CRD constants
+const (
+	WorkflowTemplateCRDKind      string = "WorkflowTemplate"
+	WorkflowTemplateCRDSingular  string = "workflowtemplate"
+	WorkflowTemplateCRDPlural    string = "workflowtemplates"
+	WorkflowTemplateCRDShortName string = "wftmpl"
+	WorkflowTemplateCRDFullName  string = WorkflowTemplateCRDPlural + "." + CRDGroup
+)
+
+// CronWorkflow CRD constants
+const (
+	CronWorkflowCRDKind      string = "CronWorkflow"
+	CronWorkflowCRDSingular  string = "cronworkflow"
+	CronWorkflowCRDPlural    string = "cronworkflows"
+	CronWorkflowCRDShortName string = "cronwf"
+	CronWorkflowCRDFullName  string = CronWorkflowCRDPlural + "." + CRDGroup
+)
+
 // NodePhase is a label for the condition of a node at the current time.
 type NodePhase string
 
@@ -31,6 +49,72 @@ const (
 	NodeError     NodePhase = "Error"
 )
 
+// ShutdownStrategy is the strategy with which a running workflow is shut down
+type ShutdownStrategy string
+
+// Workflow shutdown strategies
+const (
+	// ShutdownStrategyTerminate kills the running pods of the workflow and fails their nodes immediately.
+	// The onExit handler is not run.
+	ShutdownStrategyTerminate ShutdownStrategy = "Terminate"
+	// ShutdownStrategyStop stops the scheduling of new steps, but lets the running ones finish.
+	// The onExit handler is run.
+	ShutdownStrategyStop ShutdownStrategy = "Stop"
+)
+
+// FailureType classifies the failure of a workflow by the reasons its nodes failed, e.g. to alert on the failures
+// caused by the infrastructure running workflows separately from the failures of user code
+type FailureType string
+
+// Workflow failure types
+const (
+	// FailureTypeUser indicates the workflow failed because of its spec or user code, e.g. a container exited
+	// with a non-zero exit code or exceeded its memory limit
+	FailureTypeUser FailureType = "User"
+	// FailureTypeInfrastructure indicates the workflow failed only because of the infrastructure running it,
+	// e.g. its artifacts could not be loaded or saved, or its pods were evicted
+	FailureTypeInfrastructure FailureType = "Infrastructure"
+)
+
+// NodeReason is a machine-readable code for the reason a node failed or errored. Unlike the node
+// message, the values are stable, and can be relied upon by consumers of the workflow status.
+type NodeReason string
+
+// Node failure reasons
+const (
+	// NodeReasonExitCode indicates a container of the node exited with a non-zero exit code
+	NodeReasonExitCode NodeReason = "ExitCode"
+	// NodeReasonArtifactLoadError indicates the input artifacts of the node could not be loaded
+	NodeReasonArtifactLoadError NodeReason = "ArtifactLoadError"
+	// NodeReasonArtifactSaveError indicates the outputs of the node could not be saved
+	NodeReasonArtifactSaveError NodeReason = "ArtifactSaveError"
+	// NodeReasonOOMKilled indicates a container of the node was killed for exceeding its memory limit
+	NodeReasonOOMKilled NodeReason = "OOMKilled"
+	// NodeReasonTimeout indicates the node exceeded its own or the workflow's active deadline
+	NodeReasonTimeout NodeReason = "Timeout"
+	// NodeReasonTerminated indicates the node was failed because the workflow was terminated
+	NodeReasonTerminated NodeReason = "Terminated"
+	// NodeReasonNodeLimitExceeded indicates the node was failed because the workflow exceeded the maximum
+	// number of nodes of a workflow
+	NodeReasonNodeLimitExceeded NodeReason = "NodeLimitExceeded"
+	// NodeReasonEvicted indicates the pod of the node was evicted from its kubernetes node
+	NodeReasonEvicted NodeReason = "Evicted"
+	// NodeReasonPodFailed indicates kubernetes failed the pod of the node (e.g. the kubernetes node was lost)
+	NodeReasonPodFailed NodeReason = "PodFailed"
+	// NodeReasonPodUnknown indicates the state of the pod of the node remained unknown (e.g. its kubernetes
+	// node was unreachable) for too long
+	NodeReasonPodUnknown NodeReason = "PodUnknown"
+	// NodeReasonMainContainerNotFound indicates the executor could not locate the main container of the pod of the node
+	NodeReasonMainContainerNotFound NodeReason = "MainContainerNotFound"
+	// NodeReasonContainerRestarted indicates a container of the pod of the node was restarted, since the
+	// restartPolicy of the pod was changed from Never (e.g. by an admission controller)
+	NodeReasonContainerRestarted NodeReason = "ContainerRestarted"
+	// NodeReasonFailureCondition indicates the failure condition of the resource of a resource template was met
+	NodeReasonFailureCondition NodeReason = "FailureCondition"
+	// NodeReasonUnknown indicates the pod of the node failed for an unknown reason
+	NodeReasonUnknown NodeReason = "Unknown"
+)
+
 // Create a Rest client with the new CRD Schema
 var SchemeGroupVersion = schema.GroupVersion{Group: CRDGroup, Version: CRDVersion}
 
@@ -48,6 +132,88 @@ type WorkflowList struct {
 	Items           []Workflow `json:"items"`
 }
 
+// WorkflowTemplate is the definition of our CRD WorkflowTemplate class. It holds templates which
+// are referenced by the steps of workflows in the same namespace (see TemplateRef).
+type WorkflowTemplate struct {
+	metav1.TypeMeta   `json:",inline,squash"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              WorkflowTemplateSpec `json:"spec"`
+}
+
+type WorkflowTemplateList struct {
+	metav1.TypeMeta `json:",inline,squash"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []WorkflowTemplate `json:"items"`
+}
+
+// WorkflowTemplateSpec is the spec of a WorkflowTemplate
+type WorkflowTemplateSpec struct {
+	// Templates are the templates which may be referenced by workflows. Steps templates may refer to
+	// the other templates of the WorkflowTemplate by name.
+	Templates []Template `json:"templates"`
+}
+
+// CronWorkflow is the definition of our CRD CronWorkflow class. The controller creates a workflow of its
+// workflowSpec at each time of its schedule.
+type CronWorkflow struct {
+	metav1.TypeMeta   `json:",inline,squash"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              CronWorkflowSpec   `json:"spec"`
+	Status            CronWorkflowStatus `json:"status,omitempty"`
+}
+
+type CronWorkflowList struct {
+	metav1.TypeMeta `json:",inline,squash"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []CronWorkflow `json:"items"`
+}
+
+// ConcurrencyPolicy is the policy of a CronWorkflow whose workflow is scheduled while earlier ones still run
+type ConcurrencyPolicy string
+
+// CronWorkflow concurrency policies
+const (
+	// ConcurrencyPolicyAllow runs the workflows of a CronWorkflow concurrently
+	ConcurrencyPolicyAllow ConcurrencyPolicy = "Allow"
+	// ConcurrencyPolicyForbid skips a scheduled workflow while an earlier workflow is still running
+	ConcurrencyPolicyForbid ConcurrencyPolicy = "Forbid"
+	// ConcurrencyPolicyReplace terminates the running workflows before starting the scheduled workflow
+	ConcurrencyPolicyReplace ConcurrencyPolicy = "Replace"
+)
+
+// CronWorkflowSpec is the spec of a CronWorkflow
+type CronWorkflowSpec struct {
+	// Schedule is a cron schedule of five fields (minute, hour, day of month, month and day of week), e.g.
+	// '0 2 * * *', or a descriptor such as @daily or @every 1h30m
+	Schedule string `json:"schedule"`
+
+	// Timezone is the IANA name of the time zone of the schedule (e.g. America/Los_Angeles). Defaults to the
+	// time zone of the controller (usually UTC)
+	Timezone string `json:"timezone,omitempty"`
+
+	// ConcurrencyPolicy is the policy (Allow, Forbid or Replace) applied when a workflow is scheduled while
+	// earlier workflows of the CronWorkflow still run. Defaults to Allow
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+
+	// StartingDeadlineSeconds is the deadline, after its scheduled time, for starting a workflow which
+	// missed its schedule (e.g. while the controller was down). Of the missed schedules, only the most
+	// recent workflow is started. When omitted, a missed workflow is started regardless of its delay, but
+	// no workflow is started if more than 100 schedules were missed.
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
+
+	// Suspend stops the scheduling of workflows. The workflows already started are not affected.
+	Suspend bool `json:"suspend,omitempty"`
+
+	// WorkflowSpec is the spec of the workflows created by the CronWorkflow
+	WorkflowSpec WorkflowSpec `json:"workflowSpec"`
+}
+
+// CronWorkflowStatus is the status of a CronWorkflow
+type CronWorkflowStatus struct {
+	// LastScheduledTime is the scheduled time of the last workflow which was started, or skipped
+	LastScheduledTime *metav1.Time `json:"lastScheduledTime,omitempty"`
+}
+
 type WorkflowSpec struct {
 	Templates            []Template                    `json:"templates"`
 	Entrypoint           string                        `json:"entrypoint"`
@@ -55,9 +221,68 @@ type WorkflowSpec struct {
 	Volumes              []apiv1.Volume                `json:"volumes,omitempty"`
 	VolumeClaimTemplates []apiv1.PersistentVolumeClaim `json:"volumeClaimTemplates,omitempty"`
 
+	// OnExit is a template reference which is invoked at the end of the workflow, irrespective of
+	// the success, failure, or error of the entrypoint. The template is supplied the input parameters
+	// 'status' and 'message', containing the final phase and message of the entrypoint.
+	OnExit string `json:"onExit,omitempty"`
+
 	// NodeSelector is a selector which will cause all pods of the workflow
 	// to be scheduled on the selected node(s)
 	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Parallelism limits the max total parallel pods that can execute at the same time in a workflow.
+	// Overrides the parallelism configured in the controller.
+	Parallelism *int64 `json:"parallelism,omitempty"`
+
+	// ActiveDeadlineSeconds is the duration in seconds relative to the workflow start time which the
+	// workflow is allowed to run before the controller fails its running nodes and stops scheduling new steps
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+
+	// TTLSecondsAfterFinished limits the lifetime of a workflow which has finished execution.
+	// Once the workflow has been finished for this many seconds, the controller deletes it.
+	// If unset, the workflow is never deleted by the controller.
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+
+	// Suspend will suspend the workflow and prevent execution of any future steps in the workflow.
+	// Pods which are already running are unaffected. Set to false (or remove) to resume the workflow.
+	Suspend *bool `json:"suspend,omitempty"`
+
+	// Shutdown will shut down a running workflow with the given strategy (Terminate or Stop). Nodes which
+	// are not started because of the shutdown are failed, and so is the workflow.
+	Shutdown ShutdownStrategy `json:"shutdown,omitempty"`
+
+	// Priority is the priority of the workflow relative to others. Workflows with a higher priority
+	// are operated on by the controller first, when it is under load. Defaults to 0.
+	Priority *int32 `json:"priority,omitempty"`
+
+	// PodMetadata are labels and annotations applied to all pods of the workflow.
+	// Overrides the pod metadata configured in the controller.
+	PodMetadata *Metadata `json:"podMetadata,omitempty"`
+
+	// ServiceAccountName is the service account which the pods of the workflow run as.
+	// Overrides the service account configured in the controller.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Outputs are the outputs of the workflow, resolved into status.outputs once its entrypoint completes. They
+	// reference the outputs of the steps or tasks of the entrypoint template: the values of parameters may contain
+	// references (e.g. "{{steps.generate.outputs.result}}"), and artifacts reference an output artifact with from
+	// (e.g. "{{tasks.build.outputs.artifacts.binary}}"). Outputs of steps or tasks which did not succeed are omitted.
+	Outputs *Outputs `json:"outputs,omitempty"`
+
+	// ExecutorImage is the executor image of the pods of the workflow, e.g. to canary a new executor.
+	// Overrides the executor image configured in the controller, and must be one of its allowedExecutorImages.
+	ExecutorImage string `json:"executorImage,omitempty"`
+
+	// ArtifactRepository is the artifact repository in which the outputs of the workflow are stored.
+	// Overrides the artifact repository configured in the controller. Any secrets it references
+	// must exist in the workflow's namespace.
+	ArtifactRepository *ArtifactRepository `json:"artifactRepository,omitempty"`
+}
+
+// Metadata are labels and annotations to apply to an object
+type Metadata struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 type Template struct {
@@ -69,26 +294,108 @@ type Template struct {
 	// run on the selected node(s). Overrides the selector set at the workflow level.
 	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
 
-	// Deamon will allow a workflow to proceed to the next step so long as the container reaches readiness
+	// Deamon will allow a workflow to proceed to the next step so long as the container reaches readiness.
+	// Like all workflow pods, daemon pods are never restarted: a daemon which exits is no longer daemoned.
+	// Daemon pods are killed once their steps complete, and deleted if still running once the workflow completes
+	// (e.g. after exceeding its activeDeadlineSeconds).
 	Daemon *bool `json:"daemon,omitempty"`
 
 	// Workflow fields
 	Steps [][]WorkflowStep `json:"steps,omitempty"`
 
+	// DAG template subtype which runs tasks according to their dependencies
+	DAG *DAGTemplate `json:"dag,omitempty"`
+
 	// Container
 	Container *apiv1.Container `json:"container,omitempty"`
 
 	// Script
 	Script *Script `json:"script,omitempty"`
 
+	// Suspend template subtype which suspends the workflow when reached
+	Suspend *SuspendTemplate `json:"suspend,omitempty"`
+
+	// Resource template subtype which creates or applies a Kubernetes resource
+	Resource *ResourceTemplate `json:"resource,omitempty"`
+
 	// Sidecar containers
 	Sidecars []Sidecar `json:"sidecars,omitempty"`
 
+	// IgnoreSidecarFailures will consider the step successful when the main container succeeded,
+	// even if one or more sidecars exited with a non-zero exit code. Defaults to false.
+	IgnoreSidecarFailures *bool `json:"ignoreSidecarFailures,omitempty"`
+
+	// RetryStrategy describes how to retry a container or script template when it fails
+	RetryStrategy *RetryStrategy `json:"retryStrategy,omitempty"`
+
+	// ActiveDeadlineSeconds is the duration in seconds relative to the pod start time which the
+	// step is allowed to run before it is killed and failed. Only valid for container and script templates.
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+
 	// Location in which all files related to the step will be stored (logs, artifacts, etc...).
 	// Can be overridden by individual items in Outputs. If omitted, will use the default
 	// artifact repository location configured in the controller, appended with the
 	// <workflowname>/<nodename> in the key.
 	ArchiveLocation *ArtifactLocation `json:"archiveLocation,omitempty"`
+
+	// ArchiveLogs will save the logs of the main container to the archive location, as the output
+	// artifact named main-logs. Only valid for container and script templates. If omitted, defaults
+	// to the archiveLogs setting of the artifact repository.
+	ArchiveLogs *bool `json:"archiveLogs,omitempty"`
+
+	// Memoize caches the outputs of a successful container or script template under a key, so that
+	// later executions with the same key reuse the outputs instead of running a pod
+	Memoize *Memoize `json:"memoize,omitempty"`
+}
+
+// Memoize configures the caching of the outputs of a template
+type Memoize struct {
+	// Key is the cache key of the outputs. It is typically derived from the template's inputs
+	// (e.g. "{{inputs.parameters.message}}"). Must be a valid config map key after substitution.
+	Key string `json:"key"`
+
+	// Cache is the cache in which the outputs are stored
+	Cache MemoizationCache `json:"cache"`
+}
+
+// MemoizationCache is the storage of memoized outputs
+type MemoizationCache struct {
+	// ConfigMap is the config map, in the namespace of the workflow, in which the outputs are stored.
+	// It is created if it does not exist. The oldest outputs are evicted once the config map is full.
+	ConfigMap *apiv1.LocalObjectReference `json:"configMap,omitempty"`
+}
+
+// MemoizationStatus records the use of the cache by a memoized node
+type MemoizationStatus struct {
+	// Hit indicates the outputs of the node were found in the cache, and no pod was run
+	Hit bool `json:"hit"`
+
+	// Key is the cache key of the node's outputs
+	Key string `json:"key"`
+
+	// CacheName is the name of the config map storing the outputs
+	CacheName string `json:"cacheName"`
+}
+
+// RetryStrategy provides controls on how to retry a workflow step
+type RetryStrategy struct {
+	// Limit is the maximum number of attempts when retrying a failed step (not including the first attempt)
+	Limit *int32 `json:"limit,omitempty"`
+
+	// Backoff is a backoff strategy to use between retry attempts. If omitted, retries occur immediately.
+	Backoff *Backoff `json:"backoff,omitempty"`
+}
+
+// Backoff is an exponential backoff between retry attempts
+type Backoff struct {
+	// Duration is the wait before the first retry, as a duration string (e.g. 10s)
+	Duration string `json:"duration,omitempty"`
+
+	// Factor is the multiplier applied to the duration after each retry. Defaults to 2
+	Factor *int32 `json:"factor,omitempty"`
+
+	// MaxDuration is the maximum wait between retries, as a duration string (e.g. 5m)
+	MaxDuration string `json:"maxDuration,omitempty"`
 }
 
 // Inputs are the mechanism for passing parameters, artifacts, volumes from one template to another
@@ -103,6 +410,20 @@ type Parameter struct {
 	Value   *string `json:"value,omitempty"`
 	Default *string `json:"default,omitempty"`
 	Path    string  `json:"path,omitempty"`
+
+	// ValueFrom reads the value of a workflow argument, or the default of an input parameter, from a key of a
+	// configmap or secret in the workflow's namespace. The value of a configmap key is read when the workflow is
+	// started, and then replaces the reference in the workflow. The value of a secret key is never stored in the
+	// workflow: the parameter is substituted with a reference to an environment variable of the main container,
+	// whose value is the secret key, and which Kubernetes expands in the command, args and env of the container.
+	ValueFrom *ValueFrom `json:"valueFrom,omitempty"`
+}
+
+// ValueFrom describes a location from which to read the value of a parameter. Exactly one of the references
+// must be set.
+type ValueFrom struct {
+	ConfigMapKeyRef *apiv1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	SecretKeyRef    *apiv1.SecretKeySelector    `json:"secretKeyRef,omitempty"`
 }
 
 // Artifact indicates an artifact to place at a specified path
@@ -128,9 +449,11 @@ type Artifact struct {
 // It is also used to describe the location of multiple artifacts such as the archive location
 // of a single workflow step, which the executor will use as a default location to store its files.
 type ArtifactLocation struct {
-	S3   *S3Artifact   `json:"s3,omitempty"`
-	Git  *GitArtifact  `json:"git,omitempty"`
-	HTTP *HTTPArtifact `json:"http,omitempty"`
+	S3        *S3Artifact        `json:"s3,omitempty"`
+	Git       *GitArtifact       `json:"git,omitempty"`
+	HTTP      *HTTPArtifact      `json:"http,omitempty"`
+	AzureBlob *AzureBlobArtifact `json:"azureBlob,omitempty"`
+	HDFS      *HDFSArtifact      `json:"hdfs,omitempty"`
 }
 
 type Outputs struct {
@@ -149,6 +472,66 @@ type WorkflowStep struct {
 	WithItems []Item    `json:"withItems,omitempty"`
 	WithParam string    `json:"withParam,omitempty"`
 	When      string    `json:"when,omitempty"`
+
+	// TemplateRef refers to a template of a WorkflowTemplate, instead of a template of the workflow.
+	// The controller inlines the referenced template into the workflow when the workflow starts.
+	TemplateRef *TemplateRef `json:"templateRef,omitempty"`
+
+	// ContinueOn makes the following steps run despite the failure or error of the step, e.g. for
+	// best-effort steps. The step group of such a step succeeds if its other steps succeed.
+	ContinueOn *ContinueOn `json:"continueOn,omitempty"`
+}
+
+// ContinueOn selects the unsuccessful phases of a step which do not fail its step group
+type ContinueOn struct {
+	// Error continues on the error of the step (e.g. its pod was evicted, or its artifacts failed to load)
+	Error bool `json:"error,omitempty"`
+	// Failed continues on the failure of the step (e.g. its main container exited with a non-zero code)
+	Failed bool `json:"failed,omitempty"`
+}
+
+// ShouldContinue returns whether or not a step completed in the given phase does not fail its step group
+func (c *ContinueOn) ShouldContinue(phase NodePhase) bool {
+	if c == nil {
+		return false
+	}
+	return (phase == NodeError && c.Error) || (phase == NodeFailed && c.Failed)
+}
+
+// DAGTemplate is a template subtype for directed acyclic graph templates
+type DAGTemplate struct {
+	// Tasks are the tasks of the DAG
+	Tasks []DAGTask `json:"tasks"`
+}
+
+// DAGTask is a single task of a DAG template. A task is started once all of its dependencies
+// have completed successfully.
+type DAGTask struct {
+	// Name is the name of the task, unique within the DAG
+	Name string `json:"name"`
+
+	// Template is the name of the template which is executed by the task
+	Template string `json:"template"`
+
+	// Arguments are the arguments to the template. They may refer to the outputs of the task's
+	// dependencies (e.g. "{{tasks.<name>.outputs.parameters.<param>}}").
+	Arguments Arguments `json:"arguments,omitempty"`
+
+	// Dependencies are the names of the tasks which must succeed before this task is started
+	Dependencies []string `json:"dependencies,omitempty"`
+
+	// When is an expression deciding whether or not the task is executed, once its dependencies succeeded
+	// (e.g. "{{tasks.<name>.outputs.result}} == heads"). A task which is not executed is skipped, which
+	// does not prevent the tasks depending on it from being executed.
+	When string `json:"when,omitempty"`
+}
+
+// TemplateRef is a reference to a template of a WorkflowTemplate
+type TemplateRef struct {
+	// Name is the name of the WorkflowTemplate, in the namespace of the workflow
+	Name string `json:"name"`
+	// Template is the name of the template within the WorkflowTemplate
+	Template string `json:"template"`
 }
 
 // Item expands a single workflow step into multiple parallel steps
@@ -198,9 +581,34 @@ type WorkflowStatus struct {
 	// Nodes is a mapping between a node ID and the node's status.
 	Nodes map[string]NodeStatus `json:"nodes"`
 
+	// Progress is the number of completed nodes, out of the nodes of the workflow, as completed/total (e.g. 7/12).
+	// Only the nodes which do the work of the workflow (i.e. of pods, resources or skipped steps) are counted,
+	// not the steps, step group and DAG nodes containing them. Since nodes are created as the workflow proceeds,
+	// the total grows until the workflow completes.
+	Progress string `json:"progress,omitempty"`
+
+	// FailureType classifies the failure of a workflow which completed Failed or Error
+	FailureType FailureType `json:"failureType,omitempty"`
+
+	// Outputs are the resolved outputs of the workflow declared in spec.outputs
+	Outputs *Outputs `json:"outputs,omitempty"`
+
 	// PersistentVolumeClaims tracks all PVCs that were created as part of the workflow.
 	// The contents of this list are drained at the end of the workflow.
 	PersistentVolumeClaims []apiv1.Volume `json:"persistentVolumeClaims,omitempty"`
+
+	// PhaseTransitions records the time of each change of the workflow phase, in order.
+	// A workflow is pending from its creation until its first transition (to Running).
+	PhaseTransitions []PhaseTransition `json:"phaseTransitions,omitempty"`
+}
+
+// PhaseTransition is a change of the phase of a workflow
+type PhaseTransition struct {
+	// Phase is the phase the workflow transitioned to
+	Phase NodePhase `json:"phase"`
+
+	// Time at which the workflow transitioned to the phase
+	Time metav1.Time `json:"time"`
 }
 
 type NodeStatus struct {
@@ -219,6 +627,9 @@ type NodeStatus struct {
 	// A human readable message indicating details about why the node is in this condition.
 	Message string `json:"message,omitempty"`
 
+	// Reason is a machine-readable code for the reason the node failed or errored
+	Reason NodeReason `json:"reason,omitempty"`
+
 	// Time at which this node started
 	StartedAt metav1.Time `json:"startedAt,omitempty"`
 
@@ -228,6 +639,9 @@ type NodeStatus struct {
 	// PodIP captures the IP of the pod for daemoned steps
 	PodIP string `json:"podIP,omitempty"`
 
+	// HostNodeName is the name of the kubernetes node the pod of the node was scheduled to
+	HostNodeName string `json:"hostNodeName,omitempty"`
+
 	// Daemoned tracks whether or not this node was daemoned and need to be terminated
 	Daemoned *bool `json:"daemoned,omitempty"`
 
@@ -236,6 +650,12 @@ type NodeStatus struct {
 
 	// Children is a list of child node IDs
 	Children []string `json:"children,omitempty"`
+
+	// MemoizationStatus records the cache lookup of a node of a memoized template
+	MemoizationStatus *MemoizationStatus `json:"memoizationStatus,omitempty"`
+
+	// ResourceRef references the resource created or applied by a node of a resource template
+	ResourceRef *apiv1.ObjectReference `json:"resourceRef,omitempty"`
 }
 
 func (n NodeStatus) String() string {
@@ -263,10 +683,52 @@ func (n NodeStatus) Successful() bool {
 	return n.Phase == NodeSucceeded || n.Phase == NodeSkipped
 }
 
+// ArtifactRepository represents a artifact repository in which a controller will store its artifacts
+type ArtifactRepository struct {
+	S3        *S3ArtifactRepository        `json:"s3,omitempty"`
+	AzureBlob *AzureBlobArtifactRepository `json:"azureBlob,omitempty"`
+	HDFS      *HDFSArtifactRepository      `json:"hdfs,omitempty"`
+	// Future artifact repository support here
+
+	// ArchiveLogs enables archiving the main container logs of all steps. Can be overridden by templates.
+	ArchiveLogs *bool `json:"archiveLogs,omitempty"`
+}
+
+// S3ArtifactRepository defines the controller configuration for an S3 artifact repository
+type S3ArtifactRepository struct {
+	S3Bucket `json:",inline,squash"`
+
+	// KeyPrefix is prefix used as part of the bucket key in which the controller will store artifacts.
+	// It may reference {{workflow.name}}, {{workflow.namespace}} and {{workflow.uid}}.
+	KeyPrefix string `json:"keyPrefix,omitempty"`
+}
+
+// AzureBlobArtifactRepository defines the controller configuration for an Azure Blob Storage artifact repository
+type AzureBlobArtifactRepository struct {
+	AzureBlobContainer `json:",inline,squash"`
+
+	// KeyPrefix is prefix used as part of the blob name in which the controller will store artifacts.
+	// It may reference {{workflow.name}}, {{workflow.namespace}} and {{workflow.uid}}.
+	KeyPrefix string `json:"keyPrefix,omitempty"`
+}
+
+// HDFSArtifactRepository defines the controller configuration for an HDFS artifact repository
+type HDFSArtifactRepository struct {
+	HDFSConfig `json:",inline,squash"`
+
+	// Path is the absolute directory in HDFS under which the controller will store artifacts
+	Path string `json:"path"`
+}
+
+// S3Bucket contains the access information required for interfacing with an S3 bucket
 type S3Bucket struct {
-	Endpoint        string                  `json:"endpoint"`
-	Bucket          string                  `json:"bucket"`
-	Region          string                  `json:"region,omitempty"`
+	// Endpoint is the hostname (and optional port) of AWS S3 or an S3 compatible service such as MinIO.
+	// Endpoints other than AWS and GCS are accessed using path-style addressing.
+	Endpoint string `json:"endpoint"`
+	Bucket   string `json:"bucket"`
+	// Region is the bucket region. Optional, since it is discovered from the bucket location if omitted.
+	Region string `json:"region,omitempty"`
+	// Insecure will access the endpoint over http instead of https
 	Insecure        *bool                   `json:"insecure,omitempty"`
 	AccessKeySecret apiv1.SecretKeySelector `json:"accessKeySecret"`
 	SecretKeySecret apiv1.SecretKeySelector `json:"secretKeySecret"`
@@ -277,6 +739,44 @@ type S3Artifact struct {
 	Key      string `json:"key"`
 }
 
+// AzureBlobContainer contains the access information for interfacing with an Azure Blob Storage container
+type AzureBlobContainer struct {
+	// Endpoint is the blob service endpoint of the storage account (e.g. https://myaccount.blob.core.windows.net)
+	Endpoint         string                  `json:"endpoint"`
+	Container        string                  `json:"container"`
+	AccountKeySecret apiv1.SecretKeySelector `json:"accountKeySecret"`
+}
+
+type AzureBlobArtifact struct {
+	AzureBlobContainer `json:",inline,squash"`
+	Blob               string `json:"blob"`
+}
+
+// HDFSConfig contains the access information required for interfacing with HDFS over WebHDFS
+type HDFSConfig struct {
+	// Addresses are the WebHDFS addresses (host:port) of the name nodes. With HA name nodes,
+	// addresses are tried in order until one which is active is found.
+	Addresses []string `json:"addresses"`
+
+	// HDFSUser is the user to access HDFS as, when the cluster uses simple authentication
+	HDFSUser string `json:"hdfsUser,omitempty"`
+
+	// Insecure will access WebHDFS over http instead of https
+	Insecure *bool `json:"insecure,omitempty"`
+
+	// KrbDelegationTokenSecret is a secret containing a HDFS delegation token (e.g. obtained with
+	// `hdfs fetchdt` after a kinit), used to access clusters secured with Kerberos
+	KrbDelegationTokenSecret *apiv1.SecretKeySelector `json:"krbDelegationTokenSecret,omitempty"`
+}
+
+// HDFSArtifact is the location of an HDFS artifact
+type HDFSArtifact struct {
+	HDFSConfig `json:",inline,squash"`
+
+	// Path is the absolute file path in HDFS
+	Path string `json:"path"`
+}
+
 type GitArtifact struct {
 	Repo           string                   `json:"repo"`
 	Revision       string                   `json:"revision,omitempty"`
@@ -284,15 +784,69 @@ type GitArtifact struct {
 	PasswordSecret *apiv1.SecretKeySelector `json:"passwordSecret,omitempty"`
 }
 
+// HTTPArtifact is the location of an input artifact served over HTTP(S)
 type HTTPArtifact struct {
 	URL string `json:"url"`
+
+	// Headers are additional headers sent with the request, e.g. for authentication
+	Headers []HTTPHeader `json:"headers,omitempty"`
+}
+
+// HTTPHeader is a header sent with the request of an HTTP artifact. The value is either specified
+// literally, or read from a secret in the workflow's namespace.
+type HTTPHeader struct {
+	Name        string                   `json:"name"`
+	Value       string                   `json:"value,omitempty"`
+	ValueSecret *apiv1.SecretKeySelector `json:"valueSecret,omitempty"`
 }
 
-// Script is a template subtype to enable scripting through code steps
+// Script is a template subtype to enable scripting through code steps. The source is written to a file by
+// the init container, which the main container executes using the command (the interpreter, e.g. python).
+// The stdout of the script is captured as the result output of the template.
 type Script struct {
-	Image   string   `json:"image"`
+	// Image is the image of the main container, which provides the interpreter
+	Image string `json:"image"`
+	// Command is the interpreter, which is passed the path of the source file as its last argument
 	Command []string `json:"command"`
-	Source  string   `json:"source"`
+	// Source is the inline source of the script
+	Source string `json:"source"`
+}
+
+// SuspendTemplate is a template subtype to suspend a workflow at a certain point, e.g. for a human approval.
+// When reached, the node succeeds and spec.suspend of the workflow is set, which prevents any further
+// steps from running until the workflow is resumed.
+type SuspendTemplate struct {
+}
+
+// Resource actions
+const (
+	// ResourceActionCreate creates the resource, failing if it already exists
+	ResourceActionCreate = "create"
+	// ResourceActionApply creates the resource, or patches it (as a JSON merge patch) if it already exists
+	ResourceActionApply = "apply"
+)
+
+// ResourceTemplate is a template subtype which creates or applies a Kubernetes resource (e.g. a Job or a
+// custom resource). The node runs until the success or failure condition of the resource is met.
+type ResourceTemplate struct {
+	// Action is the action performed on the resource (create or apply). Defaults to create
+	Action string `json:"action,omitempty"`
+
+	// Manifest is the manifest of the resource, in YAML or JSON. The resource is created in the namespace
+	// of the workflow, unless the manifest specifies one.
+	Manifest string `json:"manifest"`
+
+	// SetOwnerReference sets the workflow as the owner of the resource, so that the resource is deleted
+	// with the workflow
+	SetOwnerReference bool `json:"setOwnerReference,omitempty"`
+
+	// SuccessCondition and FailureCondition are comma separated lists of requirements on the fields of the
+	// resource, which are all met for the condition to be met. A requirement compares a field, given by its
+	// dot separated path, to a value using ==, !=, <, <=, > or >= (e.g. status.succeeded > 0), or is the
+	// path of a boolean field. A requirement on a missing field is not met. Without a success condition,
+	// the node succeeds once the resource is created or applied.
+	SuccessCondition string `json:"successCondition,omitempty"`
+	FailureCondition string `json:"failureCondition,omitempty"`
 }
 
 func (in *Inputs) GetArtifactByName(name string) *Artifact {
@@ -345,6 +899,6 @@ func (args *Arguments) GetParameterByName(name string) *Parameter {
 }
 
 // HasLocation whether or not an artifact has a location defined
-func (a *Artifact) HasLocation() bool {
-	return a.S3 != nil || a.Git != nil || a.HTTP != nil
+func (a *ArtifactLocation) HasLocation() bool {
+	return a.S3 != nil || a.Git != nil || a.HTTP != nil || a.AzureBlob != nil || a.HDFS != nil
 }
@@ -34,6 +34,58 @@ func (wfl *WorkflowList) DeepCopyObject() runtime.Object {
 	return &copy
 }
 
+func (wftmpl *WorkflowTemplate) DeepCopyObject() runtime.Object {
+	wftmplBytes, err := json.Marshal(wftmpl)
+	if err != nil {
+		panic(err)
+	}
+	var copy WorkflowTemplate
+	err = json.Unmarshal(wftmplBytes, &copy)
+	if err != nil {
+		panic(err)
+	}
+	return &copy
+}
+
+func (wftmpll *WorkflowTemplateList) DeepCopyObject() runtime.Object {
+	wftmpllBytes, err := json.Marshal(wftmpll)
+	if err != nil {
+		panic(err)
+	}
+	var copy WorkflowTemplateList
+	err = json.Unmarshal(wftmpllBytes, &copy)
+	if err != nil {
+		panic(err)
+	}
+	return &copy
+}
+
+func (cwf *CronWorkflow) DeepCopyObject() runtime.Object {
+	cwfBytes, err := json.Marshal(cwf)
+	if err != nil {
+		panic(err)
+	}
+	var copy CronWorkflow
+	err = json.Unmarshal(cwfBytes, &copy)
+	if err != nil {
+		panic(err)
+	}
+	return &copy
+}
+
+func (cwfl *CronWorkflowList) DeepCopyObject() runtime.Object {
+	cwflBytes, err := json.Marshal(cwfl)
+	if err != nil {
+		panic(err)
+	}
+	var copy CronWorkflowList
+	err = json.Unmarshal(cwflBytes, &copy)
+	if err != nil {
+		panic(err)
+	}
+	return &copy
+}
+
 func (wf *Workflow) GetTemplate(name string) *Template {
 	for _, t := range wf.Spec.Templates {
 		if t.Name == name {
@@ -43,6 +95,16 @@ func (wf *Workflow) GetTemplate(name string) *Template {
 	return nil
 }
 
+// GetTemplate returns the template of the given name in the WorkflowTemplate, or nil if it does not exist
+func (wftmpl *WorkflowTemplate) GetTemplate(name string) *Template {
+	for _, t := range wftmpl.Spec.Templates {
+		if t.Name == name {
+			return &t
+		}
+	}
+	return nil
+}
+
 // NodeID creates a deterministic node ID based on a node name
 func (wf *Workflow) NodeID(name string) string {
 	if name == wf.ObjectMeta.Name {
@@ -66,6 +128,19 @@ func (t *Template) DeepCopy() *Template {
 	return &copy
 }
 
+func (s *WorkflowSpec) DeepCopy() *WorkflowSpec {
+	bytes, err := json.Marshal(s)
+	if err != nil {
+		panic(err)
+	}
+	var copy WorkflowSpec
+	err = json.Unmarshal(bytes, &copy)
+	if err != nil {
+		panic(err)
+	}
+	return &copy
+}
+
 func (s *WorkflowStep) DeepCopy() *WorkflowStep {
 	bytes, err := json.Marshal(s)
 	if err != nil {
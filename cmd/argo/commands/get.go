@@ -11,6 +11,7 @@ import (
 	"time"
 
 	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/argoproj/argo/workflow/common"
 	humanize "github.com/dustin/go-humanize"
 	"github.com/ghodss/yaml"
 	"github.com/spf13/cobra"
@@ -87,6 +88,12 @@ func printWorkflow(wf *wfv1.Workflow) {
 		}
 		fmt.Printf(fmtStr, "Duration:", humanizeDuration(duration))
 	}
+	if wf.Status.Progress != "" {
+		fmt.Printf(fmtStr, "Progress:", wf.Status.Progress)
+	}
+	if wf.Status.FailureType != "" {
+		fmt.Printf(fmtStr, "Failure Type:", wf.Status.FailureType)
+	}
 
 	if len(wf.Spec.Arguments.Parameters) > 0 {
 		fmt.Printf(fmtStr, "Parameters:", "")
@@ -98,6 +105,13 @@ func printWorkflow(wf *wfv1.Workflow) {
 		}
 	}
 
+	if wf.Status.Outputs != nil && len(wf.Status.Outputs.Parameters) > 0 {
+		fmt.Printf(fmtStr, "Outputs:", "")
+		for _, param := range wf.Status.Outputs.Parameters {
+			fmt.Printf(fmtStr, "  "+param.Name+":", *param.Value)
+		}
+	}
+
 	if wf.Status.Nodes != nil {
 		node, ok := wf.Status.Nodes[wf.ObjectMeta.Name]
 		if ok {
@@ -110,6 +124,10 @@ func printWorkflow(wf *wfv1.Workflow) {
 				fmt.Fprintf(w, "%s\tPODNAME\tMESSAGE\n", ansiFormat("STEP", FgDefault))
 			}
 			printNodeTree(w, wf, node, 0, " ", " ")
+			onExitNode, ok := wf.Status.Nodes[wf.NodeID(common.OnExitNodeName(wf.ObjectMeta.Name))]
+			if ok {
+				printNodeTree(w, wf, onExitNode, 0, " ", " ")
+			}
 			w.Flush()
 		}
 	}
@@ -133,6 +151,11 @@ func printNodeTree(w *tabwriter.Writer, wf *wfv1.Workflow, node wfv1.NodeStatus,
 		fmt.Fprintf(w, "%s%s\t%s\t%s\n", args...)
 	}
 
+	if isDAGNode(wf, node) {
+		printDAGTasks(w, wf, node, depth, childPrefix)
+		return
+	}
+
 	// If the node has children, the node is a workflow template and
 	// node.Children prepresent a list of parallel steps. We skip
 	// a generation when recursing since the children nodes of workflow
@@ -189,6 +212,36 @@ func printNodeTree(w *tabwriter.Writer, wf *wfv1.Workflow, node wfv1.NodeStatus,
 	}
 }
 
+// isDAGNode returns whether or not the node is the node of a DAG template, whose children are its
+// tasks (named <node>.<task>), rather than step groups (named <node>[<index>])
+func isDAGNode(wf *wfv1.Workflow, node wfv1.NodeStatus) bool {
+	for _, childNodeID := range node.Children {
+		if childNode, ok := wf.Status.Nodes[childNodeID]; ok {
+			return strings.HasPrefix(childNode.Name, node.Name+".")
+		}
+	}
+	return false
+}
+
+// printDAGTasks prints the task nodes of a DAG node, in the order they were started
+func printDAGTasks(w *tabwriter.Writer, wf *wfv1.Workflow, node wfv1.NodeStatus, depth int, childPrefix string) {
+	var taskNodes []wfv1.NodeStatus
+	for _, childNodeID := range node.Children {
+		if childNode, ok := wf.Status.Nodes[childNodeID]; ok {
+			taskNodes = append(taskNodes, childNode)
+		}
+	}
+	for i, taskNode := range taskNodes {
+		part, subp := "├-", "| "
+		if i == len(taskNodes)-1 {
+			part, subp = "└-", "  "
+		}
+		// Remove DAG name from being displayed
+		taskNode.Name = strings.TrimPrefix(taskNode.Name, node.Name+".")
+		printNodeTree(w, wf, taskNode, depth+1, childPrefix+part, childPrefix+subp)
+	}
+}
+
 func getArtifactsString(node wfv1.NodeStatus) string {
 	if node.Outputs == nil {
 		return ""
@@ -18,6 +18,7 @@ import (
 	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	apierr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -69,13 +70,13 @@ func Install(cmd *cobra.Command, args InstallFlags) {
 			args.ServiceAccount = seviceAccountName
 		}
 	}
-	installConfigMap(clientset, args)
+	wfConfig := installConfigMap(clientset, args)
 	if args.ServiceAccount == "" {
 		fmt.Printf("Using default service account for deployments\n")
 	} else {
 		fmt.Printf("Using service account '%s' for deployments\n", args.ServiceAccount)
 	}
-	installController(clientset, args)
+	installController(clientset, args, wfConfig)
 	installUi(clientset, args)
 }
 
@@ -175,7 +176,7 @@ func kubernetesVersionCheck(clientset *kubernetes.Clientset) {
 	fmt.Printf("Proceeding with Kubernetes version %v\n", serverVersion)
 }
 
-func installConfigMap(clientset *kubernetes.Clientset, args InstallFlags) {
+func installConfigMap(clientset *kubernetes.Clientset, args InstallFlags) controller.WorkflowControllerConfig {
 	cmClient := clientset.CoreV1().ConfigMaps(args.Namespace)
 	var wfConfig controller.WorkflowControllerConfig
 
@@ -211,9 +212,11 @@ func installConfigMap(clientset *kubernetes.Clientset, args InstallFlags) {
 	if err != nil {
 		log.Fatalf("Failed to load controller configuration: %v", err)
 	}
+	return wfConfig
 }
 
-func installController(clientset *kubernetes.Clientset, args InstallFlags) {
+func installController(clientset *kubernetes.Clientset, args InstallFlags, wfConfig controller.WorkflowControllerConfig) {
+	healthPort := intstr.FromInt(wfConfig.GetHealthPort())
 	deploymentsClient := clientset.AppsV1beta2().Deployments(args.Namespace)
 	controllerDeployment := appsv1beta2.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -239,6 +242,19 @@ func installController(clientset *kubernetes.Clientset, args InstallFlags) {
 							Image:   args.ControllerImage,
 							Command: []string{"workflow-controller"},
 							Args:    []string{"--configmap", args.ConfigMap},
+							LivenessProbe: &apiv1.Probe{
+								Handler: apiv1.Handler{
+									HTTPGet: &apiv1.HTTPGetAction{Path: "/healthz", Port: healthPort},
+								},
+								InitialDelaySeconds: 10,
+								PeriodSeconds:       10,
+							},
+							ReadinessProbe: &apiv1.Probe{
+								Handler: apiv1.Handler{
+									HTTPGet: &apiv1.HTTPGetAction{Path: "/readyz", Port: healthPort},
+								},
+								PeriodSeconds: 5,
+							},
 							Env: []apiv1.EnvVar{
 								apiv1.EnvVar{
 									Name: common.EnvVarNamespace,
@@ -355,4 +371,22 @@ func installCRD(clientset *kubernetes.Clientset) {
 	} else {
 		fmt.Printf("CustomResourceDefinition '%s' created\n", result.GetObjectMeta().GetName())
 	}
+	result, err = workflowclient.CreateWorkflowTemplateCustomResourceDefinition(apiextensionsclientset)
+	if err != nil {
+		if !apierr.IsAlreadyExists(err) {
+			log.Fatalf("Failed to create CustomResourceDefinition: %v", err)
+		}
+		fmt.Printf("CustomResourceDefinition '%s' already exists\n", wfv1.WorkflowTemplateCRDFullName)
+	} else {
+		fmt.Printf("CustomResourceDefinition '%s' created\n", result.GetObjectMeta().GetName())
+	}
+	result, err = workflowclient.CreateCronWorkflowCustomResourceDefinition(apiextensionsclientset)
+	if err != nil {
+		if !apierr.IsAlreadyExists(err) {
+			log.Fatalf("Failed to create CustomResourceDefinition: %v", err)
+		}
+		fmt.Printf("CustomResourceDefinition '%s' already exists\n", wfv1.CronWorkflowCRDFullName)
+	} else {
+		fmt.Printf("CustomResourceDefinition '%s' created\n", result.GetObjectMeta().GetName())
+	}
 }
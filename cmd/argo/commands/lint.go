@@ -83,13 +83,22 @@ func lintYAMLFile(filePath string) error {
 	}
 	var wf wfv1.Workflow
 	err = yaml.Unmarshal(body, &wf)
-	if wf.Kind != "" && wf.Kind != wfv1.CRDKind {
+	if wf.Kind != "" && wf.Kind != wfv1.CRDKind && wf.Kind != wfv1.CronWorkflowCRDKind {
 		return nil
 	}
 	if err != nil {
 		return errors.Errorf(errors.CodeBadRequest, "Failed to parse %s: %v", filePath, err)
 	}
-	err = common.ValidateWorkflow(&wf)
+	if wf.Kind == wfv1.CronWorkflowCRDKind {
+		var cwf wfv1.CronWorkflow
+		err = yaml.Unmarshal(body, &cwf)
+		if err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "Failed to parse %s: %v", filePath, err)
+		}
+		err = common.ValidateCronWorkflow(&cwf)
+	} else {
+		err = common.ValidateWorkflow(&wf)
+	}
 	if err != nil {
 		argoErr, ok := err.(errors.ArgoError)
 		var errMsg string
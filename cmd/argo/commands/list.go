@@ -75,6 +75,9 @@ func listWorkflows(cmd *cobra.Command, args []string) {
 }
 
 func worklowStatus(wf *wfv1.Workflow) wfv1.NodePhase {
+	if wf.Status.Phase == wfv1.NodeRunning && wf.Spec.Suspend != nil && *wf.Spec.Suspend {
+		return "Running (Suspended)"
+	}
 	if wf.Status.Phase != "" {
 		return wf.Status.Phase
 	}
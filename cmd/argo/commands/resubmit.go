@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"log"
+	"os"
+
+	"github.com/argoproj/argo/workflow/common"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.AddCommand(resubmitCmd)
+}
+
+var resubmitCmd = &cobra.Command{
+	Use:   "resubmit WORKFLOW",
+	Short: "resubmit a completed workflow, resuming it from its failed steps",
+	Run:   resubmitWorkflowCmd,
+}
+
+func resubmitWorkflowCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.HelpFunc()(cmd, args)
+		os.Exit(1)
+	}
+	wfClient = InitWorkflowClient()
+	wf, err := wfClient.GetWorkflow(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	newWF, err := common.FormulateResubmitWorkflow(wf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	created, err := wfClient.CreateWorkflow(newWF)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printWorkflow(created)
+}
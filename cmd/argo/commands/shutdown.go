@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/util/retry"
+)
+
+func init() {
+	RootCmd.AddCommand(terminateCmd)
+	RootCmd.AddCommand(stopCmd)
+}
+
+var terminateCmd = &cobra.Command{
+	Use:   "terminate WORKFLOW",
+	Short: "terminate a workflow, killing its running steps without running its exit handler",
+	Run:   terminateWorkflowCmd,
+}
+
+var stopCmd = &cobra.Command{
+	Use:   "stop WORKFLOW",
+	Short: "stop a workflow, letting its running steps finish and running its exit handler",
+	Run:   stopWorkflowCmd,
+}
+
+func terminateWorkflowCmd(cmd *cobra.Command, args []string) {
+	shutdownWorkflowCmd(cmd, args, wfv1.ShutdownStrategyTerminate, "terminated")
+}
+
+func stopWorkflowCmd(cmd *cobra.Command, args []string) {
+	shutdownWorkflowCmd(cmd, args, wfv1.ShutdownStrategyStop, "stopped")
+}
+
+func shutdownWorkflowCmd(cmd *cobra.Command, args []string, strategy wfv1.ShutdownStrategy, action string) {
+	if len(args) == 0 {
+		cmd.HelpFunc()(cmd, args)
+		os.Exit(1)
+	}
+	wfClient = InitWorkflowClient()
+	for _, wfName := range args {
+		setShutdown(wfName, strategy)
+		fmt.Printf("Workflow '%s' %s\n", wfName, action)
+	}
+}
+
+// setShutdown updates spec.shutdown of a workflow, retrying on conflicting updates by the controller
+func setShutdown(wfName string, strategy wfv1.ShutdownStrategy) {
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		wf, err := wfClient.GetWorkflow(wfName)
+		if err != nil {
+			return err
+		}
+		wf.Spec.Shutdown = strategy
+		_, err = wfClient.UpdateWorkflow(wf)
+		return err
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -19,11 +19,13 @@ func init() {
 	RootCmd.AddCommand(submitCmd)
 	submitCmd.Flags().StringVar(&submitArgs.entrypoint, "entrypoint", "", "override entrypoint")
 	submitCmd.Flags().StringSliceVarP(&submitArgs.parameters, "parameter", "p", []string{}, "pass an input parameter")
+	submitCmd.Flags().StringVar(&submitArgs.instanceID, "instanceid", "", "submit with a specific controller's instance id label")
 }
 
 type submitFlags struct {
 	entrypoint string   // --entrypoint
 	parameters []string // --parameter
+	instanceID string   // --instanceid
 }
 
 var submitArgs submitFlags
@@ -75,6 +77,12 @@ func SubmitWorkflows(cmd *cobra.Command, args []string) {
 			if submitArgs.entrypoint != "" {
 				wf.Spec.Entrypoint = submitArgs.entrypoint
 			}
+			if submitArgs.instanceID != "" {
+				if wf.ObjectMeta.Labels == nil {
+					wf.ObjectMeta.Labels = make(map[string]string)
+				}
+				wf.ObjectMeta.Labels[common.LabelKeyControllerInstanceID] = submitArgs.instanceID
+			}
 			if len(submitArgs.parameters) > 0 {
 				newParams := make([]wfv1.Parameter, 0)
 				passedParams := make(map[string]bool)
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/util/retry"
+)
+
+func init() {
+	RootCmd.AddCommand(suspendCmd)
+	RootCmd.AddCommand(resumeCmd)
+}
+
+var suspendCmd = &cobra.Command{
+	Use:   "suspend WORKFLOW",
+	Short: "suspend a workflow, preventing any further steps from running",
+	Run:   suspendWorkflowCmd,
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume WORKFLOW",
+	Short: "resume a suspended workflow",
+	Run:   resumeWorkflowCmd,
+}
+
+func suspendWorkflowCmd(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		cmd.HelpFunc()(cmd, args)
+		os.Exit(1)
+	}
+	wfClient = InitWorkflowClient()
+	for _, wfName := range args {
+		setSuspend(wfName, true)
+		fmt.Printf("Workflow '%s' suspended\n", wfName)
+	}
+}
+
+func resumeWorkflowCmd(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		cmd.HelpFunc()(cmd, args)
+		os.Exit(1)
+	}
+	wfClient = InitWorkflowClient()
+	for _, wfName := range args {
+		setSuspend(wfName, false)
+		fmt.Printf("Workflow '%s' resumed\n", wfName)
+	}
+}
+
+// setSuspend updates spec.suspend of a workflow, retrying on conflicting updates by the controller
+func setSuspend(wfName string, suspend bool) {
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		wf, err := wfClient.GetWorkflow(wfName)
+		if err != nil {
+			return err
+		}
+		if suspend {
+			wf.Spec.Suspend = &suspend
+		} else {
+			wf.Spec.Suspend = nil
+		}
+		_, err = wfClient.UpdateWorkflow(wf)
+		return err
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
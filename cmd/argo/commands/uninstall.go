@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"time"
 
 	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
 	workflowclient "github.com/argoproj/argo/workflow/client"
@@ -11,6 +12,7 @@ import (
 	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	apierr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
 )
 
 func init() {
@@ -19,6 +21,7 @@ func init() {
 	uninstallCmd.Flags().StringVar(&uninstallArgs.uiName, "ui-name", common.DefaultUiDeploymentName, "name of ui deployment")
 	uninstallCmd.Flags().StringVar(&uninstallArgs.configMap, "configmap", common.DefaultConfigMapName(common.DefaultControllerDeploymentName), "name of configmap to uninstall")
 	uninstallCmd.Flags().StringVar(&uninstallArgs.namespace, "install-namespace", common.DefaultControllerNamespace, "uninstall from a specific namespace")
+	uninstallCmd.Flags().DurationVar(&uninstallArgs.workflowDeletionTimeout, "workflow-deletion-timeout", 2*time.Minute, "time to wait for the controller to clean up the deleted workflows")
 }
 
 type uninstallFlags struct {
@@ -26,6 +29,8 @@ type uninstallFlags struct {
 	uiName         string // --ui-name
 	configMap      string // --configmap
 	namespace      string // --install-namespace
+
+	workflowDeletionTimeout time.Duration // --workflow-deletion-timeout
 }
 
 var uninstallArgs uninstallFlags
@@ -39,6 +44,10 @@ var uninstallCmd = &cobra.Command{
 func uninstall(cmd *cobra.Command, args []string) {
 	clientset = initKubeClient()
 	fmt.Printf("Uninstalling from namespace '%s'\n", uninstallArgs.namespace)
+	// Delete the workflows while the controller is still running, so that it deletes their pods and removes
+	// their finalizers. Otherwise the deletion of the workflow CRD would wait on the finalizers indefinitely.
+	deleteAllWorkflows(uninstallArgs.workflowDeletionTimeout)
+
 	// Delete the deployment
 	deploymentsClient := clientset.AppsV1beta2().Deployments(uninstallArgs.namespace)
 	deletePolicy := metav1.DeletePropagationForeground
@@ -66,7 +75,7 @@ func uninstall(cmd *cobra.Command, args []string) {
 		fmt.Printf("ConfigMap '%s' deleted\n", uninstallArgs.configMap)
 	}
 
-	// Delete the workflow CRD
+	// Delete the workflow and workflow template CRDs
 	apiextensionsclientset, err := apiextensionsclient.NewForConfig(restConfig)
 	if err != nil {
 		log.Fatalf("%+v", err)
@@ -80,6 +89,24 @@ func uninstall(cmd *cobra.Command, args []string) {
 	} else {
 		fmt.Printf("CustomResourceDefinition '%s' deleted\n", wfv1.CRDFullName)
 	}
+	err = workflowclient.DeleteWorkflowTemplateCustomResourceDefinition(apiextensionsclientset)
+	if err != nil {
+		if !apierr.IsNotFound(err) {
+			log.Fatalf("Failed to delete CustomResourceDefinition '%s': %v", wfv1.WorkflowTemplateCRDFullName, err)
+		}
+		fmt.Printf("CustomResourceDefinition '%s' not found\n", wfv1.WorkflowTemplateCRDFullName)
+	} else {
+		fmt.Printf("CustomResourceDefinition '%s' deleted\n", wfv1.WorkflowTemplateCRDFullName)
+	}
+	err = workflowclient.DeleteCronWorkflowCustomResourceDefinition(apiextensionsclientset)
+	if err != nil {
+		if !apierr.IsNotFound(err) {
+			log.Fatalf("Failed to delete CustomResourceDefinition '%s': %v", wfv1.CronWorkflowCRDFullName, err)
+		}
+		fmt.Printf("CustomResourceDefinition '%s' not found\n", wfv1.CronWorkflowCRDFullName)
+	} else {
+		fmt.Printf("CustomResourceDefinition '%s' deleted\n", wfv1.CronWorkflowCRDFullName)
+	}
 
 	// Delete role binding
 	if err := clientset.RbacV1beta1().ClusterRoleBindings().Delete(ArgoClusterRole, &metav1.DeleteOptions{}); err != nil {
@@ -101,3 +128,71 @@ func uninstall(cmd *cobra.Command, args []string) {
 		fmt.Printf("ServiceAccount '%s' deleted\n", ArgoServiceAccount)
 	}
 }
+
+// deleteAllWorkflows deletes the workflows of all namespaces, and waits for the controller to finalize them.
+// The finalizers of workflows which are not finalized within the timeout (e.g. since the controller is not
+// running) are removed, leaving their pods behind.
+func deleteAllWorkflows(timeout time.Duration) {
+	restClient, scheme, err := workflowclient.NewRESTClient(restConfig)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	allClient := workflowclient.NewWorkflowClient(restClient, scheme, metav1.NamespaceAll)
+	wfList, err := allClient.ListWorkflows(metav1.ListOptions{})
+	if err != nil {
+		if apierr.IsNotFound(err) {
+			// the workflow CRD is not installed
+			return
+		}
+		log.Fatalf("Failed to list workflows: %v", err)
+	}
+	if len(wfList.Items) == 0 {
+		return
+	}
+	for _, wf := range wfList.Items {
+		nsClient := workflowclient.NewWorkflowClient(restClient, scheme, wf.ObjectMeta.Namespace)
+		err = nsClient.DeleteWorkflow(wf.ObjectMeta.Name, &metav1.DeleteOptions{})
+		if err != nil && !apierr.IsNotFound(err) {
+			log.Fatalf("Failed to delete workflow '%s' in namespace '%s': %v", wf.ObjectMeta.Name, wf.ObjectMeta.Namespace, err)
+		}
+	}
+	fmt.Printf("Waiting for %d workflows to be deleted\n", len(wfList.Items))
+
+	deadline := time.Now().Add(timeout)
+	for {
+		wfList, err = allClient.ListWorkflows(metav1.ListOptions{})
+		if err != nil {
+			log.Fatalf("Failed to list workflows: %v", err)
+		}
+		if len(wfList.Items) == 0 {
+			fmt.Printf("Workflows deleted\n")
+			return
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(2 * time.Second)
+	}
+	log.Warnf("%d workflows were not deleted within %v. Removing their finalizers: their pods must be deleted manually", len(wfList.Items), timeout)
+	for _, wf := range wfList.Items {
+		nsClient := workflowclient.NewWorkflowClient(restClient, scheme, wf.ObjectMeta.Namespace)
+		err = retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+			latest, err := nsClient.GetWorkflow(wf.ObjectMeta.Name)
+			if err != nil {
+				return err
+			}
+			finalizers := make([]string, 0, len(latest.ObjectMeta.Finalizers))
+			for _, f := range latest.ObjectMeta.Finalizers {
+				if f != common.FinalizerPodCleanup {
+					finalizers = append(finalizers, f)
+				}
+			}
+			latest.ObjectMeta.Finalizers = finalizers
+			_, err = nsClient.UpdateWorkflow(latest)
+			return err
+		})
+		if err != nil && !apierr.IsNotFound(err) {
+			log.Fatalf("Failed to remove the finalizer of workflow '%s' in namespace '%s': %v", wf.ObjectMeta.Name, wf.ObjectMeta.Namespace, err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 
 	"github.com/argoproj/argo/workflow/common"
+	"github.com/argoproj/argo/workflow/executor"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -22,6 +23,12 @@ func waitContainer(cmd *cobra.Command, args []string) {
 	wfExecutor := initExecutor()
 	// Wait for main container to complete and kill sidecars
 	err := wfExecutor.Wait()
+	if err == executor.ErrMainContainerNotFound {
+		// no artifacts can be saved, and the controller reports the exit code
+		_ = wfExecutor.AddAnnotation(common.AnnotationKeyNodeMessage, err.Error())
+		log.Errorf("Error waiting on main container, %+v", err)
+		os.Exit(common.ExitCodeMainContainerNotFound)
+	}
 	if err != nil {
 		_ = wfExecutor.AddAnnotation(common.AnnotationKeyNodeMessage, err.Error())
 		log.Errorf("Error waiting on main container to be ready, %+v", err)
@@ -31,6 +38,11 @@ func waitContainer(cmd *cobra.Command, args []string) {
 		_ = wfExecutor.AddAnnotation(common.AnnotationKeyNodeMessage, err.Error())
 		log.Fatalf("Error saving output artifacts, %+v", err)
 	}
+	err = wfExecutor.SaveLogs()
+	if err != nil {
+		_ = wfExecutor.AddAnnotation(common.AnnotationKeyNodeMessage, err.Error())
+		log.Fatalf("Error saving logs, %+v", err)
+	}
 	// Saving output parameters
 	err = wfExecutor.SaveParameters()
 	if err != nil {
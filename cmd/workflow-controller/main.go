@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/argoproj/argo/util/cmd"
 	workflowclient "github.com/argoproj/argo/workflow/client"
@@ -31,6 +33,8 @@ var RootCmd = &cobra.Command{
 type rootFlags struct {
 	kubeConfig string // --kubeconfig
 	configMap  string // --configmap
+	configFile string // --config-file
+	logFormat  string // --log-format
 }
 
 var (
@@ -42,6 +46,21 @@ func init() {
 
 	RootCmd.Flags().StringVar(&rootArgs.kubeConfig, "kubeconfig", "", "Kubernetes config (used when running outside of cluster)")
 	RootCmd.Flags().StringVar(&rootArgs.configMap, "configmap", common.DefaultConfigMapName(common.DefaultControllerDeploymentName), "Name of K8s configmap to retrieve workflow controller configuration")
+	RootCmd.Flags().StringVar(&rootArgs.configFile, "config-file", "", "Path of a file to read the workflow controller configuration from, instead of the configmap (e.g. when running outside of cluster)")
+	RootCmd.Flags().StringVar(&rootArgs.logFormat, "log-format", os.Getenv(common.EnvVarLogFormat), "Log format: text or json (default text)")
+}
+
+// setLogFormat configures the logrus formatter from the log format flag
+func setLogFormat(format string) error {
+	switch format {
+	case "", "text":
+		log.SetFormatter(&log.TextFormatter{})
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return fmt.Errorf("unknown log format '%s'. Must be one of: text, json", format)
+	}
+	return nil
 }
 
 // GetClientConfig return rest config, if path not specified, assume in cluster config
@@ -60,6 +79,11 @@ func main() {
 }
 
 func Run(cmd *cobra.Command, args []string) {
+	err := setLogFormat(rootArgs.logFormat)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+
 	config, err := GetClientConfig(rootArgs.kubeConfig)
 	if err != nil {
 		log.Fatalf("%+v", err)
@@ -77,17 +101,39 @@ func Run(cmd *cobra.Command, args []string) {
 	if err != nil && !apierrors.IsAlreadyExists(err) {
 		log.Fatalf("%+v", err)
 	}
+	log.Infof("Creating WorkflowTemplate CRD")
+	_, err = workflowclient.CreateWorkflowTemplateCustomResourceDefinition(apiextensionsclientset)
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		log.Fatalf("%+v", err)
+	}
+	log.Infof("Creating CronWorkflow CRD")
+	_, err = workflowclient.CreateCronWorkflowCustomResourceDefinition(apiextensionsclientset)
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		log.Fatalf("%+v", err)
+	}
 
 	// start a controller on instances of our custom resource
 	wfController := controller.NewWorkflowController(config, rootArgs.configMap)
+	wfController.ConfigFile = rootArgs.configFile
 	err = wfController.ResyncConfig()
 	if err != nil {
 		log.Fatalf("%+v", err)
 	}
 
-	ctx, _ := context.WithCancel(context.Background())
-	go wfController.Run(ctx)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancel the controller context upon SIGTERM/SIGINT so that queued updates are drained before exit
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT)
+		sig := <-sigs
+		log.Infof("Received %s signal", sig)
+		cancel()
+	}()
 
-	// Wait forever
-	select {}
+	err = wfController.Run(ctx)
+	if err != nil && err != context.Canceled {
+		log.Fatalf("%+v", err)
+	}
 }
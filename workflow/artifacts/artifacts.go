@@ -1,7 +1,11 @@
 package executor
 
 import (
+	"fmt"
+
 	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/argoproj/argo/errors"
+	apiv1 "k8s.io/api/core/v1"
 )
 
 // ArtifactDriver is the interface for loading and saving of artifacts
@@ -11,4 +15,62 @@ type ArtifactDriver interface {
 
 	// Save uploads the path to artifact destination
 	Save(path string, outputArtifact *wfv1.Artifact) error
+
+	// Delete removes the artifact from its location. Deleting an artifact which does not exist is not an error.
+	Delete(artifact *wfv1.Artifact) error
+}
+
+// SecretGetter returns the value of a key of a secret in the namespace of the workflow
+type SecretGetter func(selector apiv1.SecretKeySelector) (string, error)
+
+// Driver registers a kind of artifact location (e.g. s3) and the artifact driver for it. Drivers whose
+// location can also be configured as an artifact repository set the repository functions.
+type Driver struct {
+	// Name is the name of the location, e.g. s3
+	Name string
+	// HasLocation returns whether the location is of this kind
+	HasLocation func(loc *wfv1.ArtifactLocation) bool
+	// New instantiates the artifact driver for an artifact, resolving the secrets it references with getSecret
+	New func(art *wfv1.Artifact, getSecret SecretGetter) (ArtifactDriver, error)
+
+	// HasRepository returns whether the artifact repository is of this kind
+	HasRepository func(repo *wfv1.ArtifactRepository) bool
+	// ArchiveLocation returns the location of the outputs of a pod of the workflow in the artifact repository
+	ArchiveLocation func(repo *wfv1.ArtifactRepository, wf *wfv1.Workflow, podName string) (*wfv1.ArtifactLocation, error)
+	// RepositorySecrets returns the secrets referenced by the artifact repository
+	RepositorySecrets func(repo *wfv1.ArtifactRepository) []apiv1.SecretKeySelector
+}
+
+// drivers are the registered drivers, in order of precedence
+var drivers []Driver
+
+// RegisterDriver registers a driver. Drivers registered first take precedence when an artifact or an artifact
+// repository has several locations. Panics if a driver of the same name is already registered.
+func RegisterDriver(driver Driver) {
+	for _, d := range drivers {
+		if d.Name == driver.Name {
+			panic(fmt.Sprintf("artifact driver %s is already registered", driver.Name))
+		}
+	}
+	drivers = append(drivers, driver)
+}
+
+// NewDriver instantiates the artifact driver for the location of an artifact
+func NewDriver(art *wfv1.Artifact, getSecret SecretGetter) (ArtifactDriver, error) {
+	for _, d := range drivers {
+		if d.HasLocation(&art.ArtifactLocation) {
+			return d.New(art, getSecret)
+		}
+	}
+	return nil, errors.Errorf(errors.CodeBadRequest, "Unsupported artifact driver for %s", art.Name)
+}
+
+// RepositoryDriver returns the driver of an artifact repository, or nil if the repository is not configured
+func RepositoryDriver(repo *wfv1.ArtifactRepository) *Driver {
+	for i := range drivers {
+		if drivers[i].HasRepository != nil && drivers[i].HasRepository(repo) {
+			return &drivers[i]
+		}
+	}
+	return nil
 }
@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"testing"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/argoproj/argo/workflow/artifacts/hdfs"
+	"github.com/argoproj/argo/workflow/artifacts/s3"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func secretSelector(name, key string) apiv1.SecretKeySelector {
+	return apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: name}, Key: key}
+}
+
+func TestNewDriver(t *testing.T) {
+	getSecret := func(selector apiv1.SecretKeySelector) (string, error) {
+		return selector.Name + "/" + selector.Key, nil
+	}
+	art := &wfv1.Artifact{Name: "art"}
+	art.S3 = &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{
+		Endpoint:        "minio:9000",
+		AccessKeySecret: secretSelector("my-minio-cred", "accesskey"),
+		SecretKeySecret: secretSelector("my-minio-cred", "secretkey"),
+	}}
+	driver, err := NewDriver(art, getSecret)
+	if assert.Nil(t, err) {
+		s3Driver, ok := driver.(*s3.S3ArtifactDriver)
+		if assert.True(t, ok) {
+			assert.Equal(t, "my-minio-cred/accesskey", s3Driver.AccessKey)
+			assert.Equal(t, "my-minio-cred/secretkey", s3Driver.SecretKey)
+			assert.True(t, s3Driver.Secure)
+		}
+	}
+
+	art = &wfv1.Artifact{Name: "art"}
+	art.HDFS = &wfv1.HDFSArtifact{Path: "/tmp/art"}
+	driver, err = NewDriver(art, getSecret)
+	if assert.Nil(t, err) {
+		_, ok := driver.(*hdfs.HDFSArtifactDriver)
+		assert.True(t, ok)
+	}
+
+	_, err = NewDriver(&wfv1.Artifact{Name: "art"}, getSecret)
+	assert.NotNil(t, err)
+}
+
+func TestRepositoryDriver(t *testing.T) {
+	assert.Nil(t, RepositoryDriver(&wfv1.ArtifactRepository{}))
+
+	wf := &wfv1.Workflow{ObjectMeta: metav1.ObjectMeta{Name: "artifact-passing-abcde", Namespace: "argo"}}
+	repo := &wfv1.ArtifactRepository{
+		S3: &wfv1.S3ArtifactRepository{
+			S3Bucket:  wfv1.S3Bucket{Endpoint: "minio:9000", Bucket: "my-bucket"},
+			KeyPrefix: "{{workflow.namespace}}/artifacts",
+		},
+		HDFS: &wfv1.HDFSArtifactRepository{Path: "/artifacts"},
+	}
+	// s3 takes precedence over hdfs
+	driver := RepositoryDriver(repo)
+	if assert.NotNil(t, driver) {
+		assert.Equal(t, "s3", driver.Name)
+		loc, err := driver.ArchiveLocation(repo, wf, "artifact-passing-abcde-123")
+		if assert.Nil(t, err) && assert.NotNil(t, loc.S3) {
+			assert.Equal(t, "my-bucket", loc.S3.Bucket)
+			assert.Equal(t, "argo/artifacts/artifact-passing-abcde/artifact-passing-abcde-123", loc.S3.Key)
+		}
+	}
+
+	repo.S3 = nil
+	driver = RepositoryDriver(repo)
+	if assert.NotNil(t, driver) {
+		assert.Equal(t, "hdfs", driver.Name)
+		assert.Empty(t, driver.RepositorySecrets(repo))
+		loc, err := driver.ArchiveLocation(repo, wf, "artifact-passing-abcde-123")
+		if assert.Nil(t, err) && assert.NotNil(t, loc.HDFS) {
+			assert.Equal(t, "/artifacts/artifact-passing-abcde/artifact-passing-abcde-123", loc.HDFS.Path)
+		}
+	}
+}
+
+func TestRegisterDriverTwice(t *testing.T) {
+	assert.Panics(t, func() {
+		RegisterDriver(Driver{Name: "s3"})
+	})
+}
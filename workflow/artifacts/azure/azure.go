@@ -0,0 +1,246 @@
+package azure
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/argoproj/argo/errors"
+	"github.com/argoproj/argo/workflow/common"
+	log "github.com/sirupsen/logrus"
+)
+
+// azureStorageAPIVersion is the version of the Blob service REST API used by the driver
+const azureStorageAPIVersion = "2017-04-17"
+
+// azureBlockSize is the size of the blocks in which files are uploaded. A single Put Blob request is limited
+// to 256MB, and a Put Block request to 100MB, in this version of the API.
+var azureBlockSize int64 = 64 * 1024 * 1024
+
+// httpClient is the client of the requests to the blob service. Transfers fail once stalled for the timeout.
+var httpClient = common.NewHTTPClient(5 * time.Minute)
+
+// AzureBlobArtifactDriver is a driver for Azure Blob Storage
+type AzureBlobArtifactDriver struct {
+	AccountKey string
+}
+
+// Load downloads artifacts from an Azure Blob Storage container
+func (driver *AzureBlobArtifactDriver) Load(inputArtifact *wfv1.Artifact, path string) error {
+	log.Infof("Loading from azure blob (endpoint: %s, container: %s, blob: %s) to %s",
+		inputArtifact.AzureBlob.Endpoint, inputArtifact.AzureBlob.Container, inputArtifact.AzureBlob.Blob, path)
+	req, err := driver.newRequest("GET", inputArtifact.AzureBlob, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp, err := driver.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.InternalErrorf("azure blob GET %s returned status: %s", req.URL, resp.Status)
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer out.Close()
+	_, err = io.Copy(out, resp.Body)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	return nil
+}
+
+// Save uploads the path to an Azure Blob Storage container as a block blob
+func (driver *AzureBlobArtifactDriver) Save(path string, outputArtifact *wfv1.Artifact) error {
+	log.Infof("Saving from %s to azure blob (endpoint: %s, container: %s, blob: %s)",
+		path, outputArtifact.AzureBlob.Endpoint, outputArtifact.AzureBlob.Container, outputArtifact.AzureBlob.Blob)
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	if stat.Size() <= azureBlockSize {
+		req, err := driver.newRequest("PUT", outputArtifact.AzureBlob, nil, file, stat.Size())
+		if err != nil {
+			return err
+		}
+		req.Header.Set("x-ms-blob-type", "BlockBlob")
+		req.Header.Set("Content-Type", "application/gzip")
+		return driver.put(req)
+	}
+
+	// Larger files are uploaded as uncommitted blocks, which are then committed by the block list
+	blockList := azureBlockList{}
+	for offset := int64(0); offset < stat.Size(); offset += azureBlockSize {
+		size := azureBlockSize
+		if offset+size > stat.Size() {
+			size = stat.Size() - offset
+		}
+		// Block IDs must be of the same length within a blob
+		blockID := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%010d", len(blockList.Latest))))
+		query := url.Values{"comp": {"block"}, "blockid": {blockID}}
+		req, err := driver.newRequest("PUT", outputArtifact.AzureBlob, query, io.NewSectionReader(file, offset, size), size)
+		if err != nil {
+			return err
+		}
+		err = driver.put(req)
+		if err != nil {
+			return err
+		}
+		blockList.Latest = append(blockList.Latest, blockID)
+	}
+	body, err := xml.Marshal(blockList)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	body = append([]byte(xml.Header), body...)
+	req, err := driver.newRequest("PUT", outputArtifact.AzureBlob, url.Values{"comp": {"blocklist"}}, bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-ms-blob-content-type", "application/gzip")
+	return driver.put(req)
+}
+
+// azureBlockList is the body of a Put Block List request
+type azureBlockList struct {
+	XMLName xml.Name `xml:"BlockList"`
+	Latest  []string `xml:"Latest"`
+}
+
+// put sends a signed PUT request, which the blob service answers with 201 Created
+func (driver *AzureBlobArtifactDriver) put(req *http.Request) error {
+	resp, err := driver.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return errors.InternalErrorf("azure blob PUT %s returned status: %s", req.URL, resp.Status)
+	}
+	return nil
+}
+
+// Delete removes the blob from an Azure Blob Storage container
+func (driver *AzureBlobArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	log.Infof("Deleting from azure blob (endpoint: %s, container: %s, blob: %s)",
+		artifact.AzureBlob.Endpoint, artifact.AzureBlob.Container, artifact.AzureBlob.Blob)
+	req, err := driver.newRequest("DELETE", artifact.AzureBlob, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp, err := driver.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNotFound {
+		return errors.InternalErrorf("azure blob DELETE %s returned status: %s", req.URL, resp.Status)
+	}
+	return nil
+}
+
+// newRequest creates an unsigned request against the blob
+func (driver *AzureBlobArtifactDriver) newRequest(method string, blob *wfv1.AzureBlobArtifact, query url.Values, body io.Reader, contentLength int64) (*http.Request, error) {
+	endpoint, err := url.Parse(blob.Endpoint)
+	if err != nil {
+		return nil, errors.InternalWrapError(err)
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, errors.Errorf(errors.CodeBadRequest, "azure blob endpoint '%s' must be a URL (e.g. https://myaccount.blob.core.windows.net)", blob.Endpoint)
+	}
+	endpoint.Path = fmt.Sprintf("/%s/%s", blob.Container, strings.TrimPrefix(blob.Blob, "/"))
+	endpoint.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(method, endpoint.String(), body)
+	if err != nil {
+		return nil, errors.InternalWrapError(err)
+	}
+	req.ContentLength = contentLength
+	return req, nil
+}
+
+// do signs the request using the storage account shared key, and sends it
+func (driver *AzureBlobArtifactDriver) do(req *http.Request) (*http.Response, error) {
+	// The storage account name is the first label of the blob service hostname
+	account := strings.Split(req.URL.Hostname(), ".")[0]
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureStorageAPIVersion)
+	signature, err := driver.sign(req, account)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("SharedKey %s:%s", account, signature))
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, errors.InternalWrapError(err)
+	}
+	return resp, nil
+}
+
+// sign computes the Shared Key signature of a request as described in:
+// https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key
+func (driver *AzureBlobArtifactDriver) sign(req *http.Request, account string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(driver.AccountKey)
+	if err != nil {
+		return "", errors.Errorf(errors.CodeBadRequest, "azure account key is not valid base64: %v", err)
+	}
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = fmt.Sprintf("%d", req.ContentLength)
+	}
+	msHeaders := make([]string, 0)
+	for name, vals := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-ms-") {
+			msHeaders = append(msHeaders, fmt.Sprintf("%s:%s", name, strings.Join(vals, ",")))
+		}
+	}
+	sort.Strings(msHeaders)
+	resource := fmt.Sprintf("/%s%s", account, req.URL.EscapedPath())
+	// The query parameters are part of the canonicalized resource, sorted by their lowercase name
+	queryParams := make([]string, 0)
+	for name, vals := range req.URL.Query() {
+		vals = append([]string{}, vals...)
+		sort.Strings(vals)
+		queryParams = append(queryParams, fmt.Sprintf("\n%s:%s", strings.ToLower(name), strings.Join(vals, ",")))
+	}
+	sort.Strings(queryParams)
+	stringToSign := strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date (x-ms-date is used instead)
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+		strings.Join(msHeaders, "\n"),
+		resource + strings.Join(queryParams, ""),
+	}, "\n")
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
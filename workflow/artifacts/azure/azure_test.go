@@ -0,0 +1,88 @@
+package azure
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBlobService records the blocks and blobs put to it
+type fakeBlobService struct {
+	lock   sync.Mutex
+	blocks map[string]string
+	blobs  map[string]string
+}
+
+func (s *fakeBlobService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if r.Method != "PUT" || !strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey ") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	switch r.URL.Query().Get("comp") {
+	case "block":
+		s.blocks[r.URL.Query().Get("blockid")] = string(body)
+	case "blocklist":
+		blockList := azureBlockList{}
+		err := xml.Unmarshal(body, &blockList)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		content := ""
+		for _, blockID := range blockList.Latest {
+			content += s.blocks[blockID]
+		}
+		s.blobs[r.URL.Path] = content
+	default:
+		if r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.blobs[r.URL.Path] = string(body)
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func saveFile(t *testing.T, content string) map[string]string {
+	service := &fakeBlobService{blocks: make(map[string]string), blobs: make(map[string]string)}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	file, err := ioutil.TempFile("", "azure-test")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(content)
+	assert.NoError(t, err)
+	file.Close()
+
+	driver := &AzureBlobArtifactDriver{AccountKey: base64.StdEncoding.EncodeToString([]byte("key"))}
+	art := &wfv1.Artifact{AzureBlob: &wfv1.AzureBlobArtifact{Endpoint: server.URL, Container: "artifacts", Blob: "out.tgz"}}
+	err = driver.Save(file.Name(), art)
+	assert.NoError(t, err)
+	return service.blobs
+}
+
+func TestSave(t *testing.T) {
+	defer func(blockSize int64) { azureBlockSize = blockSize }(azureBlockSize)
+	azureBlockSize = 4
+
+	// a file of at most one block is put as a whole
+	blobs := saveFile(t, "abcd")
+	assert.Equal(t, map[string]string{"/artifacts/out.tgz": "abcd"}, blobs)
+
+	// larger files are put in blocks, which are committed in order
+	blobs = saveFile(t, "abcdefghij")
+	assert.Equal(t, map[string]string{"/artifacts/out.tgz": "abcdefghij"}, blobs)
+}
@@ -0,0 +1,161 @@
+package executor
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/argoproj/argo/workflow/artifacts/azure"
+	"github.com/argoproj/argo/workflow/artifacts/git"
+	"github.com/argoproj/argo/workflow/artifacts/hdfs"
+	"github.com/argoproj/argo/workflow/artifacts/http"
+	"github.com/argoproj/argo/workflow/artifacts/s3"
+	"github.com/argoproj/argo/workflow/common"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// The built-in drivers. Artifacts of the outputs of pods are stored in the artifact repository using the
+// following formula: <repo_key_prefix>/<worflow_name>/<pod_name>/<artifact_name>.tgz
+// (e.g. myworkflowartifacts/argo-wf-fhljp/argo-wf-fhljp-123291312382/src.tgz)
+// The key prefix may reference workflow variables (e.g. {{workflow.namespace}}/myworkflowartifacts)
+func init() {
+	RegisterDriver(Driver{
+		Name:        "s3",
+		HasLocation: func(loc *wfv1.ArtifactLocation) bool { return loc.S3 != nil },
+		New:         newS3Driver,
+		HasRepository: func(repo *wfv1.ArtifactRepository) bool {
+			return repo.S3 != nil
+		},
+		ArchiveLocation: func(repo *wfv1.ArtifactRepository, wf *wfv1.Workflow, podName string) (*wfv1.ArtifactLocation, error) {
+			key, err := archiveKey(repo.S3.KeyPrefix, wf, podName)
+			if err != nil {
+				return nil, err
+			}
+			return &wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: repo.S3.S3Bucket, Key: key}}, nil
+		},
+		RepositorySecrets: func(repo *wfv1.ArtifactRepository) []apiv1.SecretKeySelector {
+			return []apiv1.SecretKeySelector{repo.S3.AccessKeySecret, repo.S3.SecretKeySecret}
+		},
+	})
+	RegisterDriver(Driver{
+		Name:        "azureBlob",
+		HasLocation: func(loc *wfv1.ArtifactLocation) bool { return loc.AzureBlob != nil },
+		New:         newAzureBlobDriver,
+		HasRepository: func(repo *wfv1.ArtifactRepository) bool {
+			return repo.AzureBlob != nil
+		},
+		ArchiveLocation: func(repo *wfv1.ArtifactRepository, wf *wfv1.Workflow, podName string) (*wfv1.ArtifactLocation, error) {
+			blob, err := archiveKey(repo.AzureBlob.KeyPrefix, wf, podName)
+			if err != nil {
+				return nil, err
+			}
+			return &wfv1.ArtifactLocation{AzureBlob: &wfv1.AzureBlobArtifact{AzureBlobContainer: repo.AzureBlob.AzureBlobContainer, Blob: blob}}, nil
+		},
+		RepositorySecrets: func(repo *wfv1.ArtifactRepository) []apiv1.SecretKeySelector {
+			return []apiv1.SecretKeySelector{repo.AzureBlob.AccountKeySecret}
+		},
+	})
+	RegisterDriver(Driver{
+		Name:        "hdfs",
+		HasLocation: func(loc *wfv1.ArtifactLocation) bool { return loc.HDFS != nil },
+		New:         newHDFSDriver,
+		HasRepository: func(repo *wfv1.ArtifactRepository) bool {
+			return repo.HDFS != nil
+		},
+		ArchiveLocation: func(repo *wfv1.ArtifactRepository, wf *wfv1.Workflow, podName string) (*wfv1.ArtifactLocation, error) {
+			artPath := path.Join(repo.HDFS.Path, wf.ObjectMeta.Name, podName)
+			return &wfv1.ArtifactLocation{HDFS: &wfv1.HDFSArtifact{HDFSConfig: repo.HDFS.HDFSConfig, Path: artPath}}, nil
+		},
+		RepositorySecrets: func(repo *wfv1.ArtifactRepository) []apiv1.SecretKeySelector {
+			if repo.HDFS.KrbDelegationTokenSecret == nil {
+				return nil
+			}
+			return []apiv1.SecretKeySelector{*repo.HDFS.KrbDelegationTokenSecret}
+		},
+	})
+	RegisterDriver(Driver{
+		Name:        "http",
+		HasLocation: func(loc *wfv1.ArtifactLocation) bool { return loc.HTTP != nil },
+		New:         newHTTPDriver,
+	})
+	RegisterDriver(Driver{
+		Name:        "git",
+		HasLocation: func(loc *wfv1.ArtifactLocation) bool { return loc.Git != nil },
+		New: func(art *wfv1.Artifact, getSecret SecretGetter) (ArtifactDriver, error) {
+			return &git.GitArtifactDriver{}, nil
+		},
+	})
+}
+
+// archiveKey returns the key under which the outputs of a pod of the workflow are stored, given the key prefix
+// of the artifact repository
+func archiveKey(keyPrefix string, wf *wfv1.Workflow, podName string) (string, error) {
+	keyPrefix, err := common.ResolveArtifactKeyPrefix(keyPrefix, wf)
+	if err != nil {
+		return "", err
+	}
+	if keyPrefix != "" {
+		keyPrefix += "/"
+	}
+	return fmt.Sprintf("%s%s/%s", keyPrefix, wf.ObjectMeta.Name, podName), nil
+}
+
+func newS3Driver(art *wfv1.Artifact, getSecret SecretGetter) (ArtifactDriver, error) {
+	accessKey, err := getSecret(art.S3.AccessKeySecret)
+	if err != nil {
+		return nil, err
+	}
+	secretKey, err := getSecret(art.S3.SecretKeySecret)
+	if err != nil {
+		return nil, err
+	}
+	driver := s3.S3ArtifactDriver{
+		Endpoint:  art.S3.Endpoint,
+		Region:    art.S3.Region,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Secure:    art.S3.Insecure == nil || *art.S3.Insecure == false,
+	}
+	return &driver, nil
+}
+
+func newAzureBlobDriver(art *wfv1.Artifact, getSecret SecretGetter) (ArtifactDriver, error) {
+	accountKey, err := getSecret(art.AzureBlob.AccountKeySecret)
+	if err != nil {
+		return nil, err
+	}
+	driver := azure.AzureBlobArtifactDriver{
+		AccountKey: accountKey,
+	}
+	return &driver, nil
+}
+
+func newHDFSDriver(art *wfv1.Artifact, getSecret SecretGetter) (ArtifactDriver, error) {
+	driver := hdfs.HDFSArtifactDriver{}
+	if art.HDFS.KrbDelegationTokenSecret != nil {
+		token, err := getSecret(*art.HDFS.KrbDelegationTokenSecret)
+		if err != nil {
+			return nil, err
+		}
+		driver.DelegationToken = strings.TrimSpace(token)
+	}
+	return &driver, nil
+}
+
+func newHTTPDriver(art *wfv1.Artifact, getSecret SecretGetter) (ArtifactDriver, error) {
+	driver := http.HTTPArtifactDriver{}
+	for _, header := range art.HTTP.Headers {
+		value := header.Value
+		if header.ValueSecret != nil {
+			var err error
+			value, err = getSecret(*header.ValueSecret)
+			if err != nil {
+				return nil, err
+			}
+			value = strings.TrimSpace(value)
+		}
+		driver.Headers = append(driver.Headers, http.Header{Name: header.Name, Value: value})
+	}
+	return &driver, nil
+}
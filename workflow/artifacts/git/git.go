@@ -28,3 +28,7 @@ func (g *GitArtifactDriver) Load(inputArtifact *wfv1.Artifact, path string) erro
 func (g *GitArtifactDriver) Save(path string, outputArtifact *wfv1.Artifact) error {
 	return errors.Errorf(errors.CodeBadRequest, "Git output artifacts unsupported")
 }
+
+func (g *GitArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	return errors.Errorf(errors.CodeBadRequest, "Deleting Git artifacts unsupported")
+}
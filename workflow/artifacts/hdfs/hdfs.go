@@ -0,0 +1,168 @@
+package hdfs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/argoproj/argo/errors"
+	"github.com/argoproj/argo/workflow/common"
+	log "github.com/sirupsen/logrus"
+)
+
+// webHDFSPathPrefix is the path prefix of the WebHDFS REST API
+const webHDFSPathPrefix = "/webhdfs/v1"
+
+// httpClient is the client of the requests to the name and data nodes. Transfers fail once stalled for the timeout.
+var httpClient = common.NewHTTPClient(5 * time.Minute)
+
+// HDFSArtifactDriver is a driver for HDFS, using the WebHDFS REST API
+type HDFSArtifactDriver struct {
+	// DelegationToken is the delegation token used to access Kerberos secured clusters (optional)
+	DelegationToken string
+}
+
+// Load downloads artifacts from HDFS
+func (driver *HDFSArtifactDriver) Load(inputArtifact *wfv1.Artifact, path string) error {
+	hdfsArt := inputArtifact.HDFS
+	log.Infof("Loading from hdfs (addresses: %v, path: %s) to %s", hdfsArt.Addresses, hdfsArt.Path, path)
+	// The name node redirects the OPEN to a data node, which the client follows
+	resp, err := driver.doNameNodeRequest("GET", hdfsArt, url.Values{"op": {"OPEN"}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.InternalErrorf("hdfs OPEN %s returned status: %s", hdfsArt.Path, resp.Status)
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer out.Close()
+	_, err = io.Copy(out, resp.Body)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	return nil
+}
+
+// Save uploads the path to HDFS, overwriting any existing file
+func (driver *HDFSArtifactDriver) Save(path string, outputArtifact *wfv1.Artifact) error {
+	hdfsArt := outputArtifact.HDFS
+	log.Infof("Saving from %s to hdfs (addresses: %v, path: %s)", path, hdfsArt.Addresses, hdfsArt.Path)
+	// CREATE is a two step operation. The name node responds with a redirect to a data node,
+	// to which the file content is then sent.
+	resp, err := driver.doNameNodeRequest("PUT", hdfsArt, url.Values{"op": {"CREATE"}, "overwrite": {"true"}})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTemporaryRedirect {
+		return errors.InternalErrorf("hdfs CREATE %s returned status: %s", hdfsArt.Path, resp.Status)
+	}
+	dataNodeURL := resp.Header.Get("Location")
+	if dataNodeURL == "" {
+		return errors.InternalErrorf("hdfs CREATE %s did not redirect to a data node", hdfsArt.Path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	req, err := http.NewRequest("PUT", dataNodeURL, file)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	req.ContentLength = stat.Size()
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err = httpClient.Do(req)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return errors.InternalErrorf("hdfs CREATE %s on data node returned status: %s", hdfsArt.Path, resp.Status)
+	}
+	return nil
+}
+
+// Delete removes the artifact's file from HDFS. The name node reports a missing file as not deleted,
+// rather than as an error.
+func (driver *HDFSArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	hdfsArt := artifact.HDFS
+	log.Infof("Deleting from hdfs (addresses: %v, path: %s)", hdfsArt.Addresses, hdfsArt.Path)
+	resp, err := driver.doNameNodeRequest("DELETE", hdfsArt, url.Values{"op": {"DELETE"}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.InternalErrorf("hdfs DELETE %s returned status: %s", hdfsArt.Path, resp.Status)
+	}
+	return nil
+}
+
+// doNameNodeRequest issues a WebHDFS request to the name nodes, trying each address in order
+// until one responds without a standby error (i.e. the active name node of an HA cluster).
+// Redirects to data nodes are not followed.
+func (driver *HDFSArtifactDriver) doNameNodeRequest(method string, hdfsArt *wfv1.HDFSArtifact, query url.Values) (*http.Response, error) {
+	if len(hdfsArt.Addresses) == 0 {
+		return nil, errors.Errorf(errors.CodeBadRequest, "hdfs addresses are required")
+	}
+	if hdfsArt.HDFSUser != "" {
+		query.Set("user.name", hdfsArt.HDFSUser)
+	}
+	if driver.DelegationToken != "" {
+		query.Set("delegation", driver.DelegationToken)
+	}
+	scheme := "https"
+	if hdfsArt.Insecure != nil && *hdfsArt.Insecure {
+		scheme = "http"
+	}
+	client := &http.Client{
+		Transport: httpClient.Transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if method == "PUT" {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+	var lastErr error
+	for _, address := range hdfsArt.Addresses {
+		u := url.URL{
+			Scheme:   scheme,
+			Host:     address,
+			Path:     webHDFSPathPrefix + hdfsArt.Path,
+			RawQuery: query.Encode(),
+		}
+		req, err := http.NewRequest(method, u.String(), nil)
+		if err != nil {
+			return nil, errors.InternalWrapError(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Warnf("hdfs request to %s failed: %v", address, err)
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode == http.StatusForbidden {
+			// A standby name node responds with 403 (StandbyException)
+			resp.Body.Close()
+			lastErr = fmt.Errorf("%s returned status: %s", address, resp.Status)
+			continue
+		}
+		return resp, nil
+	}
+	return nil, errors.InternalWrapError(lastErr)
+}
@@ -1,20 +1,62 @@
 package http
 
 import (
+	"io"
+	"net/http"
+	"os"
+
 	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
 	"github.com/argoproj/argo/errors"
-	"github.com/argoproj/argo/workflow/common"
+	log "github.com/sirupsen/logrus"
 )
 
 // HTTPArtifactDriver is the artifact driver for a HTTP URL
-type HTTPArtifactDriver struct{}
+type HTTPArtifactDriver struct {
+	// Headers are sent with the request, with any values from secrets already resolved
+	Headers []Header
+}
+
+// Header is a resolved header of an HTTP artifact request
+type Header struct {
+	Name  string
+	Value string
+}
 
 // Load download artifacts from an HTTP URL
 func (h *HTTPArtifactDriver) Load(inputArtifact *wfv1.Artifact, path string) error {
-	// Download the file to a local file path
-	return common.RunCommand("curl", "-sS", "-L", "-o", path, inputArtifact.HTTP.URL)
+	log.Infof("Loading from %s to %s", inputArtifact.HTTP.URL, path)
+	req, err := http.NewRequest("GET", inputArtifact.HTTP.URL, nil)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	for _, header := range h.Headers {
+		req.Header.Add(header.Name, header.Value)
+	}
+	// Redirects are followed
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.InternalErrorf("GET %s returned status: %s", inputArtifact.HTTP.URL, resp.Status)
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer out.Close()
+	_, err = io.Copy(out, resp.Body)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	return nil
 }
 
 func (h *HTTPArtifactDriver) Save(path string, outputArtifact *wfv1.Artifact) error {
 	return errors.Errorf(errors.CodeBadRequest, "HTTP output artifacts unsupported")
 }
+
+func (h *HTTPArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	return errors.Errorf(errors.CodeBadRequest, "Deleting HTTP artifacts unsupported")
+}
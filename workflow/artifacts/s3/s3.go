@@ -1,23 +1,38 @@
 package s3
 
 import (
+	"strings"
+
 	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
 	"github.com/argoproj/argo/errors"
 	minio "github.com/minio/minio-go"
 	log "github.com/sirupsen/logrus"
 )
 
-// S3ArtifactDriver is a driver for AWS S3
+// S3ArtifactDriver is a driver for AWS S3 and S3 compatible storage (e.g. MinIO).
+// Requests to endpoints other than AWS and GCS use path-style addressing.
 type S3ArtifactDriver struct {
 	Endpoint  string
+	Region    string
 	Secure    bool
 	AccessKey string
 	SecretKey string
 }
 
 // newMinioClient instantiates a new minio client object.
+// An http:// or https:// scheme in the endpoint is stripped, and takes precedence over Secure.
 func (s3Driver *S3ArtifactDriver) newMinioClient() (*minio.Client, error) {
-	minioClient, err := minio.New(s3Driver.Endpoint, s3Driver.AccessKey, s3Driver.SecretKey, s3Driver.Secure)
+	endpoint := s3Driver.Endpoint
+	secure := s3Driver.Secure
+	if strings.HasPrefix(endpoint, "http://") {
+		endpoint = strings.TrimPrefix(endpoint, "http://")
+		secure = false
+	} else if strings.HasPrefix(endpoint, "https://") {
+		endpoint = strings.TrimPrefix(endpoint, "https://")
+		secure = true
+	}
+	// When region is empty, it is discovered using a bucket location request
+	minioClient, err := minio.NewWithRegion(endpoint, s3Driver.AccessKey, s3Driver.SecretKey, secure, s3Driver.Region)
 	if err != nil {
 		return nil, errors.InternalWrapError(err)
 	}
@@ -54,3 +69,17 @@ func (s3Driver *S3ArtifactDriver) Save(path string, outputArtifact *wfv1.Artifac
 	}
 	return nil
 }
+
+// Delete removes the artifact's object from S3 compliant storage
+func (s3Driver *S3ArtifactDriver) Delete(artifact *wfv1.Artifact) error {
+	minioClient, err := s3Driver.newMinioClient()
+	if err != nil {
+		return err
+	}
+	log.Infof("Deleting from s3 (endpoint: %s, bucket: %s, key: %s)", artifact.S3.Endpoint, artifact.S3.Bucket, artifact.S3.Key)
+	err = minioClient.RemoveObject(artifact.S3.Bucket, artifact.S3.Key)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	return nil
+}
@@ -14,20 +14,44 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 )
 
+// CreateCustomResourceDefinition creates the Workflow CRD
 func CreateCustomResourceDefinition(clientset apiextensionsclient.Interface) (*apiextensionsv1beta1.CustomResourceDefinition, error) {
+	return createCustomResourceDefinition(clientset, wfv1.CRDFullName, apiextensionsv1beta1.CustomResourceDefinitionNames{
+		Plural:     wfv1.CRDPlural,
+		Kind:       wfv1.CRDKind,
+		ShortNames: []string{wfv1.CRDShortName},
+	})
+}
+
+// CreateWorkflowTemplateCustomResourceDefinition creates the WorkflowTemplate CRD
+func CreateWorkflowTemplateCustomResourceDefinition(clientset apiextensionsclient.Interface) (*apiextensionsv1beta1.CustomResourceDefinition, error) {
+	return createCustomResourceDefinition(clientset, wfv1.WorkflowTemplateCRDFullName, apiextensionsv1beta1.CustomResourceDefinitionNames{
+		Plural:     wfv1.WorkflowTemplateCRDPlural,
+		Kind:       wfv1.WorkflowTemplateCRDKind,
+		ShortNames: []string{wfv1.WorkflowTemplateCRDShortName},
+	})
+}
+
+// CreateCronWorkflowCustomResourceDefinition creates the CronWorkflow CRD
+func CreateCronWorkflowCustomResourceDefinition(clientset apiextensionsclient.Interface) (*apiextensionsv1beta1.CustomResourceDefinition, error) {
+	return createCustomResourceDefinition(clientset, wfv1.CronWorkflowCRDFullName, apiextensionsv1beta1.CustomResourceDefinitionNames{
+		Plural:     wfv1.CronWorkflowCRDPlural,
+		Kind:       wfv1.CronWorkflowCRDKind,
+		ShortNames: []string{wfv1.CronWorkflowCRDShortName},
+	})
+}
+
+// createCustomResourceDefinition creates a namespaced CRD in the argoproj.io group, and waits for it to be established
+func createCustomResourceDefinition(clientset apiextensionsclient.Interface, fullName string, names apiextensionsv1beta1.CustomResourceDefinitionNames) (*apiextensionsv1beta1.CustomResourceDefinition, error) {
 	crd := &apiextensionsv1beta1.CustomResourceDefinition{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: wfv1.CRDFullName,
+			Name: fullName,
 		},
 		Spec: apiextensionsv1beta1.CustomResourceDefinitionSpec{
 			Group:   wfv1.CRDGroup,
 			Version: wfv1.SchemeGroupVersion.Version,
 			Scope:   apiextensionsv1beta1.NamespaceScoped,
-			Names: apiextensionsv1beta1.CustomResourceDefinitionNames{
-				Plural:     wfv1.CRDPlural,
-				Kind:       wfv1.CRDKind,
-				ShortNames: []string{wfv1.CRDShortName},
-			},
+			Names:   names,
 		},
 	}
 
@@ -38,7 +62,7 @@ func CreateCustomResourceDefinition(clientset apiextensionsclient.Interface) (*a
 
 	// wait for CRD being established
 	err = wait.Poll(500*time.Millisecond, 60*time.Second, func() (bool, error) {
-		crd, err = clientset.Apiextensions().CustomResourceDefinitions().Get(wfv1.CRDFullName, metav1.GetOptions{})
+		crd, err = clientset.Apiextensions().CustomResourceDefinitions().Get(fullName, metav1.GetOptions{})
 		if err != nil {
 			return false, err
 		}
@@ -57,7 +81,7 @@ func CreateCustomResourceDefinition(clientset apiextensionsclient.Interface) (*a
 		return false, err
 	})
 	if err != nil {
-		deleteErr := clientset.Apiextensions().CustomResourceDefinitions().Delete(wfv1.CRDFullName, nil)
+		deleteErr := clientset.Apiextensions().CustomResourceDefinitions().Delete(fullName, nil)
 		if deleteErr != nil {
 			return nil, errors.NewAggregate([]error{err, deleteErr})
 		}
@@ -71,3 +95,15 @@ func DeleteCustomResourceDefinition(clientset apiextensionsclient.Interface) err
 	crdClient := clientset.Apiextensions().CustomResourceDefinitions()
 	return crdClient.Delete(wfv1.CRDFullName, nil)
 }
+
+// DeleteWorkflowTemplateCustomResourceDefinition deletes the WorkflowTemplate CRD
+func DeleteWorkflowTemplateCustomResourceDefinition(clientset apiextensionsclient.Interface) error {
+	crdClient := clientset.Apiextensions().CustomResourceDefinitions()
+	return crdClient.Delete(wfv1.WorkflowTemplateCRDFullName, nil)
+}
+
+// DeleteCronWorkflowCustomResourceDefinition deletes the CronWorkflow CRD
+func DeleteCronWorkflowCustomResourceDefinition(clientset apiextensionsclient.Interface) error {
+	crdClient := clientset.Apiextensions().CustomResourceDefinitions()
+	return crdClient.Delete(wfv1.CronWorkflowCRDFullName, nil)
+}
@@ -27,6 +27,9 @@ const (
 	InitContainerName = "init"
 	WaitContainerName = "wait"
 
+	// MainLogsArtifactName is the name of the output artifact of the archived logs of the main container
+	MainLogsArtifactName = "main-logs"
+
 	// PodMetadataVolumeName is the volume name defined in a workflow pod spec to expose pod metadata via downward API
 	PodMetadataVolumeName = "podmetadata"
 
@@ -44,7 +47,8 @@ const (
 	// DockerSockVolumeName is the volume name for the /var/run/docker.sock host path volume
 	DockerSockVolumeName = "docker-sock"
 
-	// AnnotationKeyNodeName is the pod metadata annotation key containing the workflow node name
+	// AnnotationKeyNodeName is the pod (and resource template resource) metadata annotation key containing the
+	// workflow node name
 	AnnotationKeyNodeName = wfv1.CRDFullName + "/node-name"
 	// AnnotationKeyNodeMessage is the pod metadata annotation key the executor will use to
 	// communicate errors encountered by the executor during artifact load/save, etc...
@@ -59,8 +63,25 @@ const (
 	LabelKeyCompleted = wfv1.CRDFullName + "/completed"
 	// LabelKeyWorkflow is the pod metadata label to indicate the associated workflow name
 	LabelKeyWorkflow = wfv1.CRDFullName + "/workflow"
+	// LabelKeyControllerInstanceID is the label of workflows, and the pods of workflows, which are processed
+	// by a specific controller instance (see the controller's instanceID config)
+	LabelKeyControllerInstanceID = wfv1.CRDFullName + "/controller-instanceid"
 	// LabelKeyPhase is a label applied to workflows to indicate the current phase of the workflow (for filtering purposes)
 	LabelKeyPhase = wfv1.CRDFullName + "/phase"
+	// LabelKeyFailureType is a label applied to workflows which failed or errored, to indicate the type of the
+	// failure (for filtering purposes)
+	LabelKeyFailureType = wfv1.CRDFullName + "/failure-type"
+	// LabelKeyCronWorkflow is the label of workflows indicating the CronWorkflow which created them
+	LabelKeyCronWorkflow = wfv1.CRDFullName + "/cron-workflow"
+
+	// FinalizerPodCleanup is the finalizer added to running workflows. It ensures the pods of a workflow
+	// which is deleted mid-run are deleted by the controller before the workflow itself is removed.
+	FinalizerPodCleanup = wfv1.CRDFullName + "/pod-cleanup"
+
+	// OnExitParamStatus and OnExitParamMessage are the names of the input parameters supplied to the
+	// onExit template, containing the final phase and message of the workflow's entrypoint
+	OnExitParamStatus  = "status"
+	OnExitParamMessage = "message"
 
 	// ExecutorArtifactBaseDir is the base directory in the init container in which artifacts will be copied to.
 	// Each artifact will be named according to its input name (e.g: /argo/inputs/artifacts/CODE)
@@ -76,6 +97,11 @@ const (
 	// ScriptTemplateSourcePath is the path which init will write the source file to and the main container will execute
 	ScriptTemplateSourcePath = "/argo/script/source"
 
+	// ExitCodeMainContainerNotFound is the exit code of the wait container when the main container cannot be
+	// found in the status of its pod. Distinct from the exit codes of the executor's other failures (1), and
+	// of panics (2), so that the controller can report it.
+	ExitCodeMainContainerNotFound = 3
+
 	// Various environment variables containing pod information exposed to the executor container(s)
 
 	// EnvVarHostIP contains the host IP which the container is executing on.
@@ -88,4 +114,6 @@ const (
 	EnvVarPodName = "ARGO_POD_NAME"
 	// EnvVarNamespace contains the namespace of the pod (currently unused)
 	EnvVarNamespace = "ARGO_NAMESPACE"
+	// EnvVarLogFormat sets the log format (text or json) of the controller when the --log-format flag is omitted
+	EnvVarLogFormat = "ARGO_LOG_FORMAT"
 )
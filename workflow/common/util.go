@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -21,6 +24,7 @@ import (
 	apiv1 "k8s.io/api/core/v1"
 	apierr "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/remotecommand"
@@ -113,6 +117,20 @@ func DefaultConfigMapName(controllerName string) string {
 	return fmt.Sprintf("%s-configmap", controllerName)
 }
 
+// SecretParameterEnvVarName returns the name of the environment variable of the main container whose value is the
+// secret key of a parameter (see Parameter.ValueFrom)
+func SecretParameterEnvVarName(ref *apiv1.SecretKeySelector) string {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s\x00%s", ref.Name, ref.Key)
+	return fmt.Sprintf("ARGO_SECRET_%x", h.Sum32())
+}
+
+// SecretParameterPlaceholder returns the value substituted for a parameter whose value is a secret key: a reference
+// to its environment variable, which Kubernetes expands in the command, args and env of the main container
+func SecretParameterPlaceholder(ref *apiv1.SecretKeySelector) string {
+	return fmt.Sprintf("$(%s)", SecretParameterEnvVarName(ref))
+}
+
 // ProcessArgs sets in the inputs, the values either passed via arguments, or the hardwired values
 // It also substitutes parameters in the template from the arguments
 func ProcessArgs(tmpl *wfv1.Template, args wfv1.Arguments, validateOnly bool) (*wfv1.Template, error) {
@@ -125,12 +143,23 @@ func ProcessArgs(tmpl *wfv1.Template, args wfv1.Arguments, validateOnly bool) (*
 		if inParam.Default != nil {
 			// first set to default value
 			inParam.Value = inParam.Default
+		} else if inParam.ValueFrom != nil && inParam.ValueFrom.SecretKeyRef != nil {
+			placeholder := SecretParameterPlaceholder(inParam.ValueFrom.SecretKeyRef)
+			inParam.Value = &placeholder
 		}
 		// overwrite value from argument (if supplied)
 		argParam := args.GetParameterByName(inParam.Name)
 		if argParam != nil && argParam.Value != nil {
 			newValue := *argParam.Value
 			inParam.Value = &newValue
+		} else if argParam != nil && argParam.ValueFrom != nil && argParam.ValueFrom.SecretKeyRef != nil {
+			placeholder := SecretParameterPlaceholder(argParam.ValueFrom.SecretKeyRef)
+			inParam.Value = &placeholder
+		}
+		if inParam.Value == nil && validateOnly && (inParam.ValueFrom != nil || argParam != nil && argParam.ValueFrom != nil) {
+			// the value is read from a configmap when the workflow is started
+			placeholder := ""
+			inParam.Value = &placeholder
 		}
 		if inParam.Value == nil {
 			return nil, errors.Errorf(errors.CodeBadRequest, "inputs.parameters.%s was not supplied", inParam.Name)
@@ -218,6 +247,260 @@ func Replace(fstTmpl *fasttemplate.Template, replaceMap map[string]string, allow
 	return replacedTmpl, nil
 }
 
+// ResolveArtifactKeyPrefix substitutes the workflow variables referenced by the key prefix of an artifact
+// repository, i.e. {{workflow.name}}, {{workflow.namespace}} and {{workflow.uid}}. A prefix without
+// variables is returned as is.
+func ResolveArtifactKeyPrefix(keyPrefix string, wf *wfv1.Workflow) (string, error) {
+	if !strings.Contains(keyPrefix, "{{") {
+		return keyPrefix, nil
+	}
+	replaceMap := map[string]string{
+		"workflow.name":      wf.ObjectMeta.Name,
+		"workflow.namespace": wf.ObjectMeta.Namespace,
+		"workflow.uid":       string(wf.ObjectMeta.UID),
+	}
+	fstTmpl := fasttemplate.New(keyPrefix, "{{", "}}")
+	return Replace(fstTmpl, replaceMap, false)
+}
+
+// OnExitNodeName returns the name of the node of a workflow's onExit handler
+func OnExitNodeName(wfName string) string {
+	return fmt.Sprintf("%s.onExit", wfName)
+}
+
+// GetOnExitArguments returns the arguments supplied to a workflow's onExit template, given the
+// final phase and message of the entrypoint
+func GetOnExitArguments(phase wfv1.NodePhase, message string) wfv1.Arguments {
+	status := string(phase)
+	return wfv1.Arguments{
+		Parameters: []wfv1.Parameter{
+			{Name: OnExitParamStatus, Value: &status},
+			{Name: OnExitParamMessage, Value: &message},
+		},
+	}
+}
+
+// WorkflowTemplateGetter returns the WorkflowTemplate of the given name in the given namespace
+type WorkflowTemplateGetter func(namespace string, name string) (*wfv1.WorkflowTemplate, error)
+
+// InlinedTemplateName returns the name under which a template of a WorkflowTemplate is inlined into a workflow
+func InlinedTemplateName(ref *wfv1.TemplateRef) string {
+	return fmt.Sprintf("%s.%s", ref.Name, ref.Template)
+}
+
+// InlineTemplateRefs replaces the template references of the workflow's steps with copies of the referenced
+// templates, so that the workflow no longer depends on the WorkflowTemplates once it has started. Templates
+// of a WorkflowTemplate referred to by inlined steps templates are inlined as well.
+func InlineTemplateRefs(wf *wfv1.Workflow, getWorkflowTemplate WorkflowTemplateGetter) error {
+	// NOTE: the templates slice grows as templates are inlined, which are then checked for references themselves
+	for i := 0; i < len(wf.Spec.Templates); i++ {
+		for j := range wf.Spec.Templates[i].Steps {
+			for k := range wf.Spec.Templates[i].Steps[j] {
+				step := &wf.Spec.Templates[i].Steps[j][k]
+				if step.TemplateRef == nil {
+					continue
+				}
+				name := InlinedTemplateName(step.TemplateRef)
+				if wf.GetTemplate(name) == nil {
+					tmpl, err := getTemplateRef(wf.ObjectMeta.Namespace, step.TemplateRef, getWorkflowTemplate)
+					if err != nil {
+						return err
+					}
+					wf.Spec.Templates = append(wf.Spec.Templates, *tmpl)
+				}
+				step.Template = name
+				step.TemplateRef = nil
+			}
+		}
+	}
+	return nil
+}
+
+// getTemplateRef returns a copy of a referenced template, renamed to its inlined name. Steps of the template
+// referring to other templates by name are turned into references to the same WorkflowTemplate.
+func getTemplateRef(namespace string, ref *wfv1.TemplateRef, getWorkflowTemplate WorkflowTemplateGetter) (*wfv1.Template, error) {
+	wftmpl, err := getWorkflowTemplate(namespace, ref.Name)
+	if err != nil {
+		return nil, err
+	}
+	tmpl := wftmpl.GetTemplate(ref.Template)
+	if tmpl == nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "template '%s' not found in WorkflowTemplate '%s'", ref.Template, ref.Name)
+	}
+	tmpl = tmpl.DeepCopy()
+	tmpl.Name = InlinedTemplateName(ref)
+	for i := range tmpl.Steps {
+		for j := range tmpl.Steps[i] {
+			step := &tmpl.Steps[i][j]
+			if step.TemplateRef == nil {
+				step.TemplateRef = &wfv1.TemplateRef{Name: ref.Name, Template: step.Template}
+				step.Template = ""
+			}
+		}
+	}
+	return tmpl, nil
+}
+
+// GetRetryBackoff returns the wait before the given retry (starting from 1) of a step with a retry strategy.
+// The wait grows exponentially by the backoff factor, and is capped by the backoff max duration.
+func GetRetryBackoff(strategy *wfv1.RetryStrategy, retry int) (time.Duration, error) {
+	if strategy == nil || strategy.Backoff == nil || strategy.Backoff.Duration == "" {
+		return 0, nil
+	}
+	backoff := strategy.Backoff
+	wait, err := time.ParseDuration(backoff.Duration)
+	if err != nil {
+		return 0, errors.Errorf(errors.CodeBadRequest, "retryStrategy.backoff.duration '%s' is invalid: %v", backoff.Duration, err)
+	}
+	var maxWait time.Duration
+	if backoff.MaxDuration != "" {
+		maxWait, err = time.ParseDuration(backoff.MaxDuration)
+		if err != nil {
+			return 0, errors.Errorf(errors.CodeBadRequest, "retryStrategy.backoff.maxDuration '%s' is invalid: %v", backoff.MaxDuration, err)
+		}
+	}
+	factor := int32(2)
+	if backoff.Factor != nil {
+		factor = *backoff.Factor
+	}
+	for i := 1; i < retry; i++ {
+		wait *= time.Duration(factor)
+		if maxWait > 0 && wait > maxWait {
+			break
+		}
+	}
+	if maxWait > 0 && wait > maxWait {
+		wait = maxWait
+	}
+	return wait, nil
+}
+
+// SortDAGTasks returns the tasks of a DAG in topological order, such that a task follows all of its
+// dependencies. Tasks which do not depend on each other keep their declared order. Returns an error
+// if a dependency is undefined, or the dependencies form a cycle.
+func SortDAGTasks(dag *wfv1.DAGTemplate) ([]wfv1.DAGTask, error) {
+	tasks := make(map[string]bool)
+	for _, task := range dag.Tasks {
+		tasks[task.Name] = true
+	}
+	for _, task := range dag.Tasks {
+		for _, dep := range task.Dependencies {
+			if !tasks[dep] {
+				return nil, errors.Errorf(errors.CodeBadRequest, "dag.tasks.%s dependency '%s' undefined", task.Name, dep)
+			}
+		}
+	}
+	sorted := make([]wfv1.DAGTask, 0, len(dag.Tasks))
+	done := make(map[string]bool)
+	for len(sorted) < len(dag.Tasks) {
+		progressed := false
+		for _, task := range dag.Tasks {
+			if done[task.Name] {
+				continue
+			}
+			ready := true
+			for _, dep := range task.Dependencies {
+				if !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				sorted = append(sorted, task)
+				done[task.Name] = true
+				progressed = true
+			}
+		}
+		if !progressed {
+			var cyclic []string
+			for _, task := range dag.Tasks {
+				if !done[task.Name] {
+					cyclic = append(cyclic, task.Name)
+				}
+			}
+			return nil, errors.Errorf(errors.CodeBadRequest, "dag.tasks contain a dependency cycle among: %s", strings.Join(cyclic, ", "))
+		}
+	}
+	return sorted, nil
+}
+
+// FormulateResubmitWorkflow returns a copy of a completed workflow, to be submitted in order to resume the
+// workflow from its failure. The nodes which succeeded, along with their descendants, are preserved (renamed
+// after the new workflow), so that the controller skips them and resolves references to their outputs. The
+// other nodes are cleared so that they are executed again, as is the onExit handler.
+func FormulateResubmitWorkflow(wf *wfv1.Workflow) (*wfv1.Workflow, error) {
+	switch wf.Status.Phase {
+	case wfv1.NodeSucceeded, wfv1.NodeFailed, wfv1.NodeError:
+	default:
+		return nil, errors.Errorf(errors.CodeBadRequest, "workflow '%s' has not completed", wf.ObjectMeta.Name)
+	}
+	if len(wf.Spec.VolumeClaimTemplates) > 0 {
+		// the volumes written by the preserved nodes were deleted along with the workflow's PVCs
+		return nil, errors.Errorf(errors.CodeBadRequest, "workflow '%s' cannot be resumed since its volumeClaimTemplates were deleted", wf.ObjectMeta.Name)
+	}
+	wf = wf.DeepCopyObject().(*wfv1.Workflow)
+	newWF := wfv1.Workflow{
+		TypeMeta: wf.TypeMeta,
+		Spec:     wf.Spec,
+	}
+	generateName := wf.ObjectMeta.GenerateName
+	if generateName == "" {
+		generateName = wf.ObjectMeta.Name + "-"
+	}
+	// The name must be known in advance (rather than generated by the API server), since it is part of the
+	// names and IDs of the nodes
+	newWF.ObjectMeta.Name = generateName + rand.String(5)
+	newWF.ObjectMeta.GenerateName = generateName
+	newWF.ObjectMeta.Namespace = wf.ObjectMeta.Namespace
+	newWF.ObjectMeta.Annotations = wf.ObjectMeta.Annotations
+	for key, val := range wf.ObjectMeta.Labels {
+		if key == LabelKeyCompleted || key == LabelKeyPhase {
+			continue
+		}
+		if newWF.ObjectMeta.Labels == nil {
+			newWF.ObjectMeta.Labels = make(map[string]string)
+		}
+		newWF.ObjectMeta.Labels[key] = val
+	}
+	newWF.Spec.Shutdown = ""
+	newWF.Spec.Suspend = nil
+
+	// preserved holds the IDs of the succeeded nodes and their descendants
+	preserved := make(map[string]bool)
+	var preserve func(nodeID string)
+	preserve = func(nodeID string) {
+		if preserved[nodeID] {
+			return
+		}
+		preserved[nodeID] = true
+		for _, childID := range wf.Status.Nodes[nodeID].Children {
+			preserve(childID)
+		}
+	}
+	onExitNodeID := wf.NodeID(OnExitNodeName(wf.ObjectMeta.Name))
+	for nodeID, node := range wf.Status.Nodes {
+		if node.Successful() && nodeID != onExitNodeID {
+			preserve(nodeID)
+		}
+	}
+	newNodeIDs := make(map[string]string)
+	for nodeID := range preserved {
+		node := wf.Status.Nodes[nodeID]
+		newNodeIDs[nodeID] = newWF.NodeID(newWF.ObjectMeta.Name + strings.TrimPrefix(node.Name, wf.ObjectMeta.Name))
+	}
+	newWF.Status.Nodes = make(map[string]wfv1.NodeStatus)
+	for nodeID, newNodeID := range newNodeIDs {
+		node := wf.Status.Nodes[nodeID]
+		node.ID = newNodeID
+		node.Name = newWF.ObjectMeta.Name + strings.TrimPrefix(node.Name, wf.ObjectMeta.Name)
+		for i, childID := range node.Children {
+			node.Children[i] = newNodeIDs[childID]
+		}
+		newWF.Status.Nodes[newNodeID] = node
+	}
+	return &newWF, nil
+}
+
 func RunCommand(name string, arg ...string) error {
 	cmd := exec.Command(name, arg...)
 	log.Info(cmd.Args)
@@ -232,16 +515,61 @@ func RunCommand(name string, arg ...string) error {
 
 const patchRetries = 5
 
-func AddPodAnnotation(c *kubernetes.Clientset, podName, namespace, key, value string) error {
+// NewHTTPClient returns an HTTP client for transferring artifacts, whose connections time out when no data is
+// read or written for the idle timeout. Unlike an overall request timeout, this bounds stalled transfers without
+// limiting the size of the artifacts.
+func NewHTTPClient(idleTimeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			Dial: func(network, addr string) (net.Conn, error) {
+				conn, err := dialer.Dial(network, addr)
+				if err != nil {
+					return nil, err
+				}
+				return &idleTimeoutConn{Conn: conn, timeout: idleTimeout}, nil
+			},
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: idleTimeout,
+			ExpectContinueTimeout: 1 * time.Second,
+			IdleConnTimeout:       90 * time.Second,
+		},
+	}
+}
+
+// idleTimeoutConn is a connection whose reads and writes fail once no data was transferred for the timeout
+type idleTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleTimeoutConn) Read(b []byte) (int, error) {
+	err := c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	if err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *idleTimeoutConn) Write(b []byte) (int, error) {
+	err := c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	if err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
+
+func AddPodAnnotation(c kubernetes.Interface, podName, namespace, key, value string) error {
 	return addPodMetadata(c, "annotations", podName, namespace, key, value)
 }
 
-func AddPodLabel(c *kubernetes.Clientset, podName, namespace, key, value string) error {
+func AddPodLabel(c kubernetes.Interface, podName, namespace, key, value string) error {
 	return addPodMetadata(c, "labels", podName, namespace, key, value)
 }
 
 // addPodMetadata is helper to either add a pod label or annotation to the pod
-func addPodMetadata(c *kubernetes.Clientset, field, podName, namespace, key, value string) error {
+func addPodMetadata(c kubernetes.Interface, field, podName, namespace, key, value string) error {
 	metadata := map[string]interface{}{
 		"metadata": map[string]interface{}{
 			field: map[string]string{
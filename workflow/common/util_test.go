@@ -0,0 +1,135 @@
+package common
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newFailedWorkflow returns a workflow of two step groups, whose first step succeeded and whose second step failed
+func newFailedWorkflow() *wfv1.Workflow {
+	wf := &wfv1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:         "steps-abcde",
+			GenerateName: "steps-",
+			Namespace:    "argo",
+			Labels: map[string]string{
+				LabelKeyCompleted:            "true",
+				LabelKeyPhase:                string(wfv1.NodeFailed),
+				LabelKeyControllerInstanceID: "test",
+			},
+		},
+		Spec:   wfv1.WorkflowSpec{Entrypoint: "steps", OnExit: "exit-handler"},
+		Status: wfv1.WorkflowStatus{Phase: wfv1.NodeFailed},
+	}
+	result := "hello"
+	nodes := []wfv1.NodeStatus{
+		{Name: "steps-abcde", Phase: wfv1.NodeFailed},
+		{Name: "steps-abcde[0]", Phase: wfv1.NodeSucceeded},
+		{Name: "steps-abcde[0].A", Phase: wfv1.NodeSucceeded, Outputs: &wfv1.Outputs{Result: &result}},
+		{Name: "steps-abcde[1]", Phase: wfv1.NodeFailed},
+		{Name: "steps-abcde[1].B", Phase: wfv1.NodeFailed},
+		{Name: OnExitNodeName("steps-abcde"), Phase: wfv1.NodeSucceeded},
+	}
+	wf.Status.Nodes = make(map[string]wfv1.NodeStatus)
+	for _, node := range nodes {
+		node.ID = wf.NodeID(node.Name)
+		wf.Status.Nodes[node.ID] = node
+	}
+	addChild := func(parent, child string) {
+		node := wf.Status.Nodes[wf.NodeID(parent)]
+		node.Children = append(node.Children, wf.NodeID(child))
+		wf.Status.Nodes[node.ID] = node
+	}
+	addChild("steps-abcde", "steps-abcde[0]")
+	addChild("steps-abcde", "steps-abcde[1]")
+	addChild("steps-abcde[0]", "steps-abcde[0].A")
+	addChild("steps-abcde[1]", "steps-abcde[1].B")
+	return wf
+}
+
+func TestFormulateResubmitWorkflow(t *testing.T) {
+	wf := newFailedWorkflow()
+	newWF, err := FormulateResubmitWorkflow(wf)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, strings.HasPrefix(newWF.ObjectMeta.Name, "steps-"))
+	assert.NotEqual(t, wf.ObjectMeta.Name, newWF.ObjectMeta.Name)
+	assert.Equal(t, map[string]string{LabelKeyControllerInstanceID: "test"}, newWF.ObjectMeta.Labels)
+	assert.Equal(t, wfv1.NodePhase(""), newWF.Status.Phase)
+
+	// only the succeeded step group and its step are preserved
+	assert.Len(t, newWF.Status.Nodes, 2)
+	sgNode, ok := newWF.Status.Nodes[newWF.NodeID(newWF.ObjectMeta.Name+"[0]")]
+	if assert.True(t, ok) {
+		assert.Equal(t, newWF.ObjectMeta.Name+"[0]", sgNode.Name)
+		assert.Equal(t, []string{newWF.NodeID(newWF.ObjectMeta.Name + "[0].A")}, sgNode.Children)
+	}
+	stepNode, ok := newWF.Status.Nodes[newWF.NodeID(newWF.ObjectMeta.Name+"[0].A")]
+	if assert.True(t, ok) {
+		assert.Equal(t, wfv1.NodeSucceeded, stepNode.Phase)
+		assert.Equal(t, "hello", *stepNode.Outputs.Result)
+	}
+
+	// the original workflow is not modified
+	assert.Len(t, wf.Status.Nodes, 6)
+	assert.Equal(t, "true", wf.ObjectMeta.Labels[LabelKeyCompleted])
+}
+
+func TestFormulateResubmitRunningWorkflow(t *testing.T) {
+	wf := newFailedWorkflow()
+	wf.Status.Phase = wfv1.NodeRunning
+	_, err := FormulateResubmitWorkflow(wf)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "has not completed")
+	}
+}
+
+func TestResolveArtifactKeyPrefix(t *testing.T) {
+	wf := newFailedWorkflow()
+	keyPrefix, err := ResolveArtifactKeyPrefix("artifacts/{{workflow.namespace}}/{{workflow.name}}", wf)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "artifacts/argo/steps-abcde", keyPrefix)
+	}
+	keyPrefix, err = ResolveArtifactKeyPrefix("artifacts", wf)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "artifacts", keyPrefix)
+	}
+	_, err = ResolveArtifactKeyPrefix("artifacts/{{workflow.status}}", wf)
+	assert.NotNil(t, err)
+}
+
+func TestNewHTTPClientIdleTimeout(t *testing.T) {
+	stall := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-stall
+	}))
+	defer server.Close()
+	defer close(stall)
+
+	resp, err := NewHTTPClient(100 * time.Millisecond).Get(server.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	// reading a body which stalls fails once no data was read for the idle timeout
+	done := make(chan error, 1)
+	go func() {
+		_, err := ioutil.ReadAll(resp.Body)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("reading a stalled body did not time out")
+	}
+}
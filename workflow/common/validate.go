@@ -4,12 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/url"
 	"reflect"
 	"strings"
+	"time"
 
 	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
 	"github.com/argoproj/argo/errors"
+	"github.com/robfig/cron"
 	"github.com/valyala/fasttemplate"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 // wfValidationCtx is the context for validating a workflow spec
@@ -35,11 +39,152 @@ func ValidateWorkflow(wf *wfv1.Workflow) error {
 	if ctx.wf.Spec.Entrypoint == "" {
 		return errors.New(errors.CodeBadRequest, "spec.entrypoint is required")
 	}
+	if ctx.wf.Spec.Parallelism != nil && *ctx.wf.Spec.Parallelism <= 0 {
+		return errors.Errorf(errors.CodeBadRequest, "spec.parallelism must be a positive integer")
+	}
+	if ctx.wf.Spec.ActiveDeadlineSeconds != nil && *ctx.wf.Spec.ActiveDeadlineSeconds <= 0 {
+		return errors.Errorf(errors.CodeBadRequest, "spec.activeDeadlineSeconds must be a positive integer")
+	}
+	if ctx.wf.Spec.TTLSecondsAfterFinished != nil && *ctx.wf.Spec.TTLSecondsAfterFinished < 0 {
+		return errors.Errorf(errors.CodeBadRequest, "spec.ttlSecondsAfterFinished must not be negative")
+	}
+	if ctx.wf.Spec.ServiceAccountName != "" {
+		if errs := validation.IsDNS1123Subdomain(ctx.wf.Spec.ServiceAccountName); len(errs) > 0 {
+			return errors.Errorf(errors.CodeBadRequest, "spec.serviceAccountName '%s' is invalid: %s", ctx.wf.Spec.ServiceAccountName, strings.Join(errs, ", "))
+		}
+	}
+	switch ctx.wf.Spec.Shutdown {
+	case "", wfv1.ShutdownStrategyTerminate, wfv1.ShutdownStrategyStop:
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "spec.shutdown '%s' is invalid. Must be one of: %s, %s",
+			ctx.wf.Spec.Shutdown, wfv1.ShutdownStrategyTerminate, wfv1.ShutdownStrategyStop)
+	}
+	if ctx.wf.Spec.ArtifactRepository != nil {
+		err = ValidateArtifactRepository("spec.artifactRepository", ctx.wf.Spec.ArtifactRepository)
+		if err != nil {
+			return err
+		}
+	}
+	err = validateArguments("spec.arguments.", ctx.wf.Spec.Arguments)
+	if err != nil {
+		return err
+	}
+	for _, param := range ctx.wf.Spec.Arguments.Parameters {
+		if param.ValueFrom == nil {
+			continue
+		}
+		if param.Value != nil {
+			return errors.Errorf(errors.CodeBadRequest, "spec.arguments.parameters.%s value and valueFrom are mutually exclusive", param.Name)
+		}
+		err = validateValueFrom(fmt.Sprintf("spec.arguments.parameters.%s", param.Name), param.ValueFrom)
+		if err != nil {
+			return err
+		}
+	}
 	entryTmpl := ctx.wf.GetTemplate(ctx.wf.Spec.Entrypoint)
 	if entryTmpl == nil {
 		return errors.Errorf(errors.CodeBadRequest, "spec.entrypoint template '%s' undefined", ctx.wf.Spec.Entrypoint)
 	}
-	return ctx.validateTemplate(entryTmpl, ctx.wf.Spec.Arguments)
+	err = ctx.validateTemplate(entryTmpl, ctx.wf.Spec.Arguments)
+	if err != nil {
+		return err
+	}
+	if ctx.wf.Spec.Outputs != nil {
+		err = ctx.validateWorkflowOutputs(entryTmpl)
+		if err != nil {
+			return err
+		}
+	}
+	if ctx.wf.Spec.OnExit != "" {
+		exitTmpl := ctx.wf.GetTemplate(ctx.wf.Spec.OnExit)
+		if exitTmpl == nil {
+			return errors.Errorf(errors.CodeBadRequest, "spec.onExit template '%s' undefined", ctx.wf.Spec.OnExit)
+		}
+		err = ctx.validateTemplate(exitTmpl, GetOnExitArguments(wfv1.NodeSucceeded, ""))
+		if err != nil {
+			return err
+		}
+	}
+	// The templates which are not reachable from the entrypoint or onExit are not otherwise validated, so that
+	// at least the names within them are verified
+	for _, tmpl := range ctx.wf.Spec.Templates {
+		if _, ok := ctx.results[tmpl.Name]; ok {
+			continue
+		}
+		err = validateUniqueNames(&tmpl)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateCronWorkflow validates the schedule of a CronWorkflow, and the spec of its workflows
+func ValidateCronWorkflow(cwf *wfv1.CronWorkflow) error {
+	_, err := cron.ParseStandard(cwf.Spec.Schedule)
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "spec.schedule '%s' is invalid: %v", cwf.Spec.Schedule, err)
+	}
+	_, err = time.LoadLocation(cwf.Spec.Timezone)
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "spec.timezone '%s' is invalid: %v", cwf.Spec.Timezone, err)
+	}
+	switch cwf.Spec.ConcurrencyPolicy {
+	case "", wfv1.ConcurrencyPolicyAllow, wfv1.ConcurrencyPolicyForbid, wfv1.ConcurrencyPolicyReplace:
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "spec.concurrencyPolicy '%s' must be %s, %s or %s", cwf.Spec.ConcurrencyPolicy,
+			wfv1.ConcurrencyPolicyAllow, wfv1.ConcurrencyPolicyForbid, wfv1.ConcurrencyPolicyReplace)
+	}
+	if cwf.Spec.StartingDeadlineSeconds != nil && *cwf.Spec.StartingDeadlineSeconds < 0 {
+		return errors.Errorf(errors.CodeBadRequest, "spec.startingDeadlineSeconds must not be negative")
+	}
+	wf := wfv1.Workflow{
+		ObjectMeta: cwf.ObjectMeta,
+		Spec:       cwf.Spec.WorkflowSpec,
+	}
+	err = ValidateWorkflow(&wf)
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "spec.workflowSpec: %s", err.Error())
+	}
+	return nil
+}
+
+// validateUniqueNames verifies the names of the inputs, outputs, steps and tasks of a template are unique and non-empty
+func validateUniqueNames(tmpl *wfv1.Template) error {
+	fields := []struct {
+		name  string
+		slice interface{}
+	}{
+		{"inputs.parameters", tmpl.Inputs.Parameters},
+		{"inputs.artifacts", tmpl.Inputs.Artifacts},
+		{"outputs.parameters", tmpl.Outputs.Parameters},
+		{"outputs.artifacts", tmpl.Outputs.Artifacts},
+	}
+	for _, field := range fields {
+		err := VerifyUniqueNonEmptyNames(field.slice)
+		if err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "template '%s' %s%s", tmpl.Name, field.name, err.Error())
+		}
+	}
+	if tmpl.DAG != nil {
+		err := VerifyUniqueNonEmptyNames(tmpl.DAG.Tasks)
+		if err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "template '%s' dag.tasks%s", tmpl.Name, err.Error())
+		}
+	}
+	stepNames := make(map[string]bool)
+	for i, stepGroup := range tmpl.Steps {
+		for _, step := range stepGroup {
+			if step.Name == "" {
+				return errors.Errorf(errors.CodeBadRequest, "template '%s' steps[%d].name is required", tmpl.Name, i)
+			}
+			if stepNames[step.Name] {
+				return errors.Errorf(errors.CodeBadRequest, "template '%s' steps[%d].%s name is not unique", tmpl.Name, i, step.Name)
+			}
+			stepNames[step.Name] = true
+		}
+	}
+	return nil
 }
 
 func (ctx *wfValidationCtx) validateTemplate(tmpl *wfv1.Template, args wfv1.Arguments) error {
@@ -49,10 +194,22 @@ func (ctx *wfValidationCtx) validateTemplate(tmpl *wfv1.Template, args wfv1.Argu
 		return nil
 	}
 	if tmpl.Name == "" {
-		errors.Errorf(errors.CodeBadRequest, "template names are required")
+		return errors.Errorf(errors.CodeBadRequest, "template names are required")
 	}
 	ctx.results[tmpl.Name] = validationResult{}
-	_, err := ProcessArgs(tmpl, args, true)
+	err := validateTemplateType(tmpl)
+	if err != nil {
+		return err
+	}
+	err = validateRetryStrategy(tmpl)
+	if err != nil {
+		return err
+	}
+	err = validateActiveDeadlineSeconds(tmpl)
+	if err != nil {
+		return err
+	}
+	_, err = ProcessArgs(tmpl, args, true)
 	if err != nil {
 		return err
 	}
@@ -60,10 +217,12 @@ func (ctx *wfValidationCtx) validateTemplate(tmpl *wfv1.Template, args wfv1.Argu
 	if err != nil {
 		return err
 	}
-	if tmpl.Steps == nil {
-		err = validateLeaf(scope, tmpl)
-	} else {
+	if tmpl.Steps != nil {
 		err = ctx.validateSteps(scope, tmpl)
+	} else if tmpl.DAG != nil {
+		err = ctx.validateDAG(scope, tmpl)
+	} else {
+		err = validateLeaf(scope, tmpl)
 	}
 	if err != nil {
 		return err
@@ -73,6 +232,264 @@ func (ctx *wfValidationCtx) validateTemplate(tmpl *wfv1.Template, args wfv1.Argu
 	if err != nil {
 		return err
 	}
+	err = validateMemoize(tmpl)
+	if err != nil {
+		return err
+	}
+	err = validateResource(tmpl)
+	if err != nil {
+		return err
+	}
+	err = validateScript(tmpl)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateScript validates the script of a script template
+func validateScript(tmpl *wfv1.Template) error {
+	if tmpl.Script == nil {
+		return nil
+	}
+	if tmpl.Script.Image == "" {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' script.image is required", tmpl.Name)
+	}
+	if len(tmpl.Script.Command) == 0 {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' script.command is required (e.g. [python])", tmpl.Name)
+	}
+	if strings.TrimSpace(tmpl.Script.Source) == "" {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' script.source is required", tmpl.Name)
+	}
+	return nil
+}
+
+// validateResource validates the resource of a resource template. The manifest itself is parsed by the
+// controller once its variables are substituted.
+func validateResource(tmpl *wfv1.Template) error {
+	if tmpl.Resource == nil {
+		return nil
+	}
+	switch tmpl.Resource.Action {
+	case "", wfv1.ResourceActionCreate, wfv1.ResourceActionApply:
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' resource.action '%s' is invalid. Must be one of: %s, %s",
+			tmpl.Name, tmpl.Resource.Action, wfv1.ResourceActionCreate, wfv1.ResourceActionApply)
+	}
+	if strings.TrimSpace(tmpl.Resource.Manifest) == "" {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' resource.manifest is required", tmpl.Name)
+	}
+	if tmpl.Resource.FailureCondition != "" && tmpl.Resource.SuccessCondition == "" {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' resource.failureCondition requires a successCondition", tmpl.Name)
+	}
+	if len(tmpl.Inputs.Artifacts) > 0 {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' inputs.artifacts are not supported by resource templates", tmpl.Name)
+	}
+	if len(tmpl.Outputs.Parameters) > 0 || len(tmpl.Outputs.Artifacts) > 0 {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' outputs are not supported by resource templates", tmpl.Name)
+	}
+	return nil
+}
+
+// validateMemoize validates the memoization of a template
+func validateMemoize(tmpl *wfv1.Template) error {
+	if tmpl.Memoize == nil {
+		return nil
+	}
+	if tmpl.Container == nil && tmpl.Script == nil {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' memoize only valid in container/script templates", tmpl.Name)
+	}
+	if tmpl.Memoize.Key == "" {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' memoize.key is required", tmpl.Name)
+	}
+	if tmpl.Memoize.Cache.ConfigMap == nil || tmpl.Memoize.Cache.ConfigMap.Name == "" {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' memoize.cache.configMap.name is required", tmpl.Name)
+	}
+	return nil
+}
+
+// validateTemplateType verifies a template specifies exactly one of container, steps, dag, script, suspend, or resource
+func validateTemplateType(tmpl *wfv1.Template) error {
+	numTypes := 0
+	for _, isType := range []bool{tmpl.Container != nil, tmpl.Steps != nil, tmpl.DAG != nil, tmpl.Script != nil, tmpl.Suspend != nil, tmpl.Resource != nil} {
+		if isType {
+			numTypes++
+		}
+	}
+	switch numTypes {
+	case 0:
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' type is unknown (one of container, steps, dag, script, suspend, or resource is required)", tmpl.Name)
+	case 1:
+		return nil
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' can only specify one of container, steps, dag, script, suspend, or resource", tmpl.Name)
+	}
+}
+
+// validateRetryStrategy verifies the retry strategy of a template
+func validateRetryStrategy(tmpl *wfv1.Template) error {
+	if tmpl.RetryStrategy == nil {
+		return nil
+	}
+	if tmpl.Container == nil && tmpl.Script == nil {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' retryStrategy only valid in container/script templates", tmpl.Name)
+	}
+	if tmpl.RetryStrategy.Limit != nil && *tmpl.RetryStrategy.Limit < 0 {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' retryStrategy.limit must not be negative", tmpl.Name)
+	}
+	if backoff := tmpl.RetryStrategy.Backoff; backoff != nil && backoff.Factor != nil && *backoff.Factor < 1 {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' retryStrategy.backoff.factor must be a positive integer", tmpl.Name)
+	}
+	_, err := GetRetryBackoff(tmpl.RetryStrategy, 1)
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' %s", tmpl.Name, err.Error())
+	}
+	return nil
+}
+
+// validateActiveDeadlineSeconds verifies the activeDeadlineSeconds of a template
+func validateActiveDeadlineSeconds(tmpl *wfv1.Template) error {
+	if tmpl.ActiveDeadlineSeconds == nil {
+		return nil
+	}
+	if tmpl.Container == nil && tmpl.Script == nil {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' activeDeadlineSeconds only valid in container/script templates", tmpl.Name)
+	}
+	if *tmpl.ActiveDeadlineSeconds <= 0 {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' activeDeadlineSeconds must be a positive integer", tmpl.Name)
+	}
+	return nil
+}
+
+// validateArguments verifies the names of parameters and artifacts in arguments are unique and non-empty
+func validateArguments(prefix string, args wfv1.Arguments) error {
+	err := VerifyUniqueNonEmptyNames(args.Parameters)
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%sparameters%s", prefix, err.Error())
+	}
+	err = VerifyUniqueNonEmptyNames(args.Artifacts)
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "%sartifacts%s", prefix, err.Error())
+	}
+	return nil
+}
+
+// validateStepArguments verifies the arguments of a step are wired to inputs of the step's template
+func validateStepArguments(prefix string, args wfv1.Arguments, tmpl *wfv1.Template) error {
+	err := validateArguments(prefix+".arguments.", args)
+	if err != nil {
+		return err
+	}
+	for _, param := range args.Parameters {
+		if tmpl.Inputs.GetParameterByName(param.Name) == nil {
+			return errors.Errorf(errors.CodeBadRequest, "%s.arguments.parameters.%s is not an input parameter of template '%s'", prefix, param.Name, tmpl.Name)
+		}
+		if param.ValueFrom != nil {
+			return errors.Errorf(errors.CodeBadRequest, "%s.arguments.parameters.%s.valueFrom is only valid in spec.arguments and inputs", prefix, param.Name)
+		}
+	}
+	for _, art := range args.Artifacts {
+		if tmpl.Inputs.GetArtifactByName(art.Name) == nil {
+			return errors.Errorf(errors.CodeBadRequest, "%s.arguments.artifacts.%s is not an input artifact of template '%s'", prefix, art.Name, tmpl.Name)
+		}
+		if art.From != "" {
+			err = validateArtifactFrom(art.From)
+			if err != nil {
+				return errors.Errorf(errors.CodeBadRequest, "%s.arguments.artifacts.%s.from %s", prefix, art.Name, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// validateWorkflowOutputs verifies the outputs of the workflow reference the outputs of the steps or tasks of the
+// entrypoint template
+func (ctx *wfValidationCtx) validateWorkflowOutputs(entryTmpl *wfv1.Template) error {
+	outputs := ctx.wf.Spec.Outputs
+	err := VerifyUniqueNonEmptyNames(outputs.Parameters)
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "spec.outputs.parameters%s", err.Error())
+	}
+	err = VerifyUniqueNonEmptyNames(outputs.Artifacts)
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "spec.outputs.artifacts%s", err.Error())
+	}
+	if outputs.Result != nil {
+		return errors.Errorf(errors.CodeBadRequest, "spec.outputs.result is not supported. Use a parameter referencing the result of a step or task")
+	}
+	scope := make(map[string]interface{})
+	for _, stepGroup := range entryTmpl.Steps {
+		for _, step := range stepGroup {
+			if len(step.WithItems) > 0 || step.WithParam != "" {
+				ctx.addAggregatedOutputsToScope(step.Template, fmt.Sprintf("steps.%s", step.Name), scope)
+			} else {
+				ctx.addOutputsToScope(step.Template, fmt.Sprintf("steps.%s", step.Name), scope)
+			}
+		}
+	}
+	if entryTmpl.DAG != nil {
+		for _, task := range entryTmpl.DAG.Tasks {
+			ctx.addOutputsToScope(task.Template, fmt.Sprintf("tasks.%s", task.Name), scope)
+		}
+	}
+	for _, param := range outputs.Parameters {
+		if param.Value == nil {
+			return errors.Errorf(errors.CodeBadRequest, "spec.outputs.parameters.%s.value is required", param.Name)
+		}
+		err = resolveAllVariables(scope, *param.Value)
+		if err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "spec.outputs.parameters.%s.value %s", param.Name, err.Error())
+		}
+	}
+	for _, art := range outputs.Artifacts {
+		if art.From == "" {
+			return errors.Errorf(errors.CodeBadRequest, "spec.outputs.artifacts.%s.from is required", art.Name)
+		}
+		err = validateArtifactFrom(art.From)
+		if err == nil {
+			err = resolveAllVariables(scope, art.From)
+		}
+		if err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "spec.outputs.artifacts.%s.from %s", art.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+// validateValueFrom verifies the valueFrom of a parameter references a key of either a configmap or a secret
+func validateValueFrom(errPrefix string, valueFrom *wfv1.ValueFrom) error {
+	var name, key string
+	switch {
+	case valueFrom.ConfigMapKeyRef != nil && valueFrom.SecretKeyRef != nil:
+		return errors.Errorf(errors.CodeBadRequest, "%s.valueFrom configMapKeyRef and secretKeyRef are mutually exclusive", errPrefix)
+	case valueFrom.ConfigMapKeyRef != nil:
+		name, key = valueFrom.ConfigMapKeyRef.Name, valueFrom.ConfigMapKeyRef.Key
+	case valueFrom.SecretKeyRef != nil:
+		name, key = valueFrom.SecretKeyRef.Name, valueFrom.SecretKeyRef.Key
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "%s.valueFrom must specify configMapKeyRef or secretKeyRef", errPrefix)
+	}
+	if name == "" || key == "" {
+		return errors.Errorf(errors.CodeBadRequest, "%s.valueFrom requires the name and key of the configmap or secret", errPrefix)
+	}
+	return nil
+}
+
+// validateArtifactFrom verifies the from of an argument artifact is a single reference to an artifact, i.e. to
+// an input artifact of the template, or to an output artifact of a step or task. Whether the referenced
+// artifact exists is verified when resolving the variables of the step or task.
+func validateArtifactFrom(from string) error {
+	ref := strings.TrimSuffix(strings.TrimPrefix(from, "{{"), "}}")
+	if ref == from || strings.Contains(ref, "{{") || strings.Contains(ref, "}}") {
+		return fmt.Errorf("'%s' must be a single artifact reference, e.g. {{steps.<name>.outputs.artifacts.<name>}}", from)
+	}
+	parts := strings.Split(ref, ".")
+	switch {
+	case len(parts) == 3 && parts[0] == "inputs" && parts[1] == "artifacts":
+	case len(parts) == 5 && (parts[0] == "steps" || parts[0] == "tasks") && parts[2] == "outputs" && parts[3] == "artifacts":
+	default:
+		return fmt.Errorf("'%s' is not an artifact reference", from)
+	}
 	return nil
 }
 
@@ -87,7 +504,18 @@ func validateInputs(tmpl *wfv1.Template) (map[string]interface{}, error) {
 	}
 	scope := make(map[string]interface{})
 	for _, param := range tmpl.Inputs.Parameters {
-		scope[fmt.Sprintf("inputs.parameters.%s", param.Name)] = true
+		paramRef := fmt.Sprintf("inputs.parameters.%s", param.Name)
+		scope[paramRef] = true
+		if param.ValueFrom == nil {
+			continue
+		}
+		if param.Default != nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "template '%s' %s default and valueFrom are mutually exclusive", tmpl.Name, paramRef)
+		}
+		err = validateValueFrom(fmt.Sprintf("template '%s' %s", tmpl.Name, paramRef), param.ValueFrom)
+		if err != nil {
+			return nil, err
+		}
 	}
 	isLeaf := tmpl.Container != nil || tmpl.Script != nil
 	for _, art := range tmpl.Inputs.Artifacts {
@@ -120,10 +548,99 @@ func validateArtifactLocation(errPrefix string, art wfv1.Artifact) error {
 			return errors.Errorf(errors.CodeBadRequest, "%s.git.repo is required", errPrefix)
 		}
 	}
+	if art.S3 != nil {
+		if art.S3.Endpoint == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.s3.endpoint is required", errPrefix)
+		}
+		if art.S3.Bucket == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.s3.bucket is required", errPrefix)
+		}
+	}
+	if art.AzureBlob != nil {
+		if art.AzureBlob.Container == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.azureBlob.container is required", errPrefix)
+		}
+		if art.AzureBlob.Blob == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.azureBlob.blob is required", errPrefix)
+		}
+	}
+	if art.HDFS != nil {
+		if len(art.HDFS.Addresses) == 0 {
+			return errors.Errorf(errors.CodeBadRequest, "%s.hdfs.addresses is required", errPrefix)
+		}
+		if !strings.HasPrefix(art.HDFS.Path, "/") {
+			return errors.Errorf(errors.CodeBadRequest, "%s.hdfs.path must be an absolute path", errPrefix)
+		}
+	}
+	if art.HTTP != nil {
+		err := validateHTTPArtifact(errPrefix, art.HTTP)
+		if err != nil {
+			return err
+		}
+	}
 	// TODO: validate other artifact locations
 	return nil
 }
 
+// validateHTTPArtifact verifies the URL and headers of an HTTP artifact
+func validateHTTPArtifact(errPrefix string, httpArt *wfv1.HTTPArtifact) error {
+	// URLs with variables are only known at runtime
+	if !strings.Contains(httpArt.URL, "{{") {
+		u, err := url.Parse(httpArt.URL)
+		if err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "%s.http.url '%s' is invalid: %v", errPrefix, httpArt.URL, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.http.url '%s' must be an http or https URL", errPrefix, httpArt.URL)
+		}
+		if u.Host == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.http.url '%s' does not specify a host", errPrefix, httpArt.URL)
+		}
+	}
+	for i, header := range httpArt.Headers {
+		if header.Name == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.http.headers[%d].name is required", errPrefix, i)
+		}
+		if header.Value != "" && header.ValueSecret != nil {
+			return errors.Errorf(errors.CodeBadRequest, "%s.http.headers[%d] can only specify one of value or valueSecret", errPrefix, i)
+		}
+	}
+	return nil
+}
+
+// ValidateArtifactRepository verifies an artifact repository has the fields required to store artifacts in it
+func ValidateArtifactRepository(errPrefix string, repo *wfv1.ArtifactRepository) error {
+	if repo.S3 != nil {
+		// NOTE: region is intentionally not required, to support S3 compatible services (e.g. MinIO)
+		if repo.S3.Endpoint == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.s3.endpoint is required", errPrefix)
+		}
+		if repo.S3.Bucket == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.s3.bucket is required", errPrefix)
+		}
+		if _, err := ResolveArtifactKeyPrefix(repo.S3.KeyPrefix, &wfv1.Workflow{}); err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "%s.s3.keyPrefix is invalid: %s", errPrefix, err.Error())
+		}
+	}
+	if repo.HDFS != nil {
+		if len(repo.HDFS.Addresses) == 0 {
+			return errors.Errorf(errors.CodeBadRequest, "%s.hdfs.addresses is required", errPrefix)
+		}
+		if !strings.HasPrefix(repo.HDFS.Path, "/") {
+			return errors.Errorf(errors.CodeBadRequest, "%s.hdfs.path must be an absolute path", errPrefix)
+		}
+	}
+	if repo.AzureBlob != nil {
+		if repo.AzureBlob.Container == "" {
+			return errors.Errorf(errors.CodeBadRequest, "%s.azureBlob.container is required", errPrefix)
+		}
+		if _, err := ResolveArtifactKeyPrefix(repo.AzureBlob.KeyPrefix, &wfv1.Workflow{}); err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "%s.azureBlob.keyPrefix is invalid: %s", errPrefix, err.Error())
+		}
+	}
+	return nil
+}
+
 // resolveAllVariables is a helper to ensure all {{variables}} are resolveable from current scope
 func resolveAllVariables(scope map[string]interface{}, tmplStr string) error {
 	var unresolvedErr error
@@ -136,6 +653,9 @@ func resolveAllVariables(scope map[string]interface{}, tmplStr string) error {
 			if (tag == "item" || strings.HasPrefix(tag, "item.")) && allowAllItemRefs {
 				// we are *probably* referencing a undetermined item using withParam
 				// NOTE: this is far from foolproof.
+			} else if parts := strings.SplitN(tag, ".", 3); len(parts) == 3 && parts[0] == "steps" && scope[fmt.Sprintf("steps.%s.*", parts[1])] != nil {
+				// we are referencing the outputs of a step whose template is in a WorkflowTemplate,
+				// which are unknown until the template is inlined
 			} else {
 				unresolvedErr = fmt.Errorf("failed to resolve {{%s}}", tag)
 			}
@@ -181,18 +701,113 @@ func (ctx *wfValidationCtx) validateSteps(scope map[string]interface{}, tmpl *wf
 			if err != nil {
 				return errors.Errorf(errors.CodeBadRequest, "template '%s' steps[%d].%s %s", tmpl.Name, i, step.Name, err.Error())
 			}
+			if step.TemplateRef != nil {
+				// the referenced template is validated once inlined by the controller
+				err = validateTemplateRef(&step)
+				if err != nil {
+					return errors.Errorf(errors.CodeBadRequest, "template '%s' steps[%d].%s %s", tmpl.Name, i, step.Name, err.Error())
+				}
+				continue
+			}
 			childTmpl := ctx.wf.GetTemplate(step.Template)
 			if childTmpl == nil {
 				return errors.Errorf(errors.CodeBadRequest, "template '%s' steps[%d].%s.template '%s' undefined", tmpl.Name, i, step.Name, step.Template)
 			}
+			err = validateStepArguments(fmt.Sprintf("template '%s' steps[%d].%s", tmpl.Name, i, step.Name), step.Arguments, childTmpl)
+			if err != nil {
+				return err
+			}
 			err = ctx.validateTemplate(childTmpl, step.Arguments)
 			if err != nil {
 				return err
 			}
 		}
 		for _, step := range stepGroup {
-			ctx.addOutputsToScope(step.Template, step.Name, scope)
+			if step.TemplateRef != nil {
+				// 'steps.<name>.*' is a magic placeholder value which resolveAllVariables() will look for
+				scope[fmt.Sprintf("steps.%s.*", step.Name)] = true
+				continue
+			}
+			if len(step.WithItems) > 0 || step.WithParam != "" {
+				ctx.addAggregatedOutputsToScope(step.Template, fmt.Sprintf("steps.%s", step.Name), scope)
+				continue
+			}
+			ctx.addOutputsToScope(step.Template, fmt.Sprintf("steps.%s", step.Name), scope)
+		}
+	}
+	return nil
+}
+
+// validateDAG validates the tasks of a DAG template. The arguments of a task may refer to the outputs of
+// the tasks it (transitively) depends on.
+func (ctx *wfValidationCtx) validateDAG(scope map[string]interface{}, tmpl *wfv1.Template) error {
+	if len(tmpl.DAG.Tasks) == 0 {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' dag.tasks is required", tmpl.Name)
+	}
+	err := VerifyUniqueNonEmptyNames(tmpl.DAG.Tasks)
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' dag.tasks%s", tmpl.Name, err.Error())
+	}
+	sorted, err := SortDAGTasks(tmpl.DAG)
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' %s", tmpl.Name, err.Error())
+	}
+	// ancestors holds the names of the tasks which each task depends on, directly or indirectly
+	ancestors := make(map[string]map[string]bool)
+	taskTemplates := make(map[string]string)
+	for _, task := range sorted {
+		prefix := fmt.Sprintf("template '%s' dag.tasks.%s", tmpl.Name, task.Name)
+		taskAncestors := make(map[string]bool)
+		for _, dep := range task.Dependencies {
+			taskAncestors[dep] = true
+			for ancestor := range ancestors[dep] {
+				taskAncestors[ancestor] = true
+			}
+		}
+		ancestors[task.Name] = taskAncestors
+		taskTemplates[task.Name] = task.Template
+
+		childTmpl := ctx.wf.GetTemplate(task.Template)
+		if childTmpl == nil {
+			return errors.Errorf(errors.CodeBadRequest, "%s.template '%s' undefined", prefix, task.Template)
+		}
+		taskScope := make(map[string]interface{})
+		for key, val := range scope {
+			taskScope[key] = val
+		}
+		for ancestor := range taskAncestors {
+			ctx.addOutputsToScope(taskTemplates[ancestor], fmt.Sprintf("tasks.%s", ancestor), taskScope)
+		}
+		taskBytes, err := json.Marshal(task)
+		if err != nil {
+			return errors.InternalWrapError(err)
+		}
+		err = resolveAllVariables(taskScope, string(taskBytes))
+		if err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "%s %s", prefix, err.Error())
 		}
+		err = validateStepArguments(prefix, task.Arguments, childTmpl)
+		if err != nil {
+			return err
+		}
+		err = ctx.validateTemplate(childTmpl, task.Arguments)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateTemplateRef verifies a step's reference to a template of a WorkflowTemplate
+func validateTemplateRef(step *wfv1.WorkflowStep) error {
+	if step.Template != "" {
+		return fmt.Errorf("only one of template or templateRef can be specified")
+	}
+	if step.TemplateRef.Name == "" {
+		return fmt.Errorf("templateRef.name is required")
+	}
+	if step.TemplateRef.Template == "" {
+		return fmt.Errorf("templateRef.template is required")
 	}
 	return nil
 }
@@ -219,19 +834,34 @@ func addItemsToScope(step *wfv1.WorkflowStep, scope map[string]interface{}) erro
 	return nil
 }
 
-func (ctx *wfValidationCtx) addOutputsToScope(templateName string, stepName string, scope map[string]interface{}) {
+// addOutputsToScope adds the outputs of a step or task to the scope, under the given prefix
+// (i.e. 'steps.<name>' or 'tasks.<name>')
+func (ctx *wfValidationCtx) addOutputsToScope(templateName string, prefix string, scope map[string]interface{}) {
 	tmpl := ctx.wf.GetTemplate(templateName)
 	if tmpl.Daemon != nil && *tmpl.Daemon {
-		scope[fmt.Sprintf("steps.%s.ip", stepName)] = true
+		scope[fmt.Sprintf("%s.ip", prefix)] = true
 	}
 	if tmpl.Script != nil {
-		scope[fmt.Sprintf("steps.%s.outputs.result", stepName)] = true
+		scope[fmt.Sprintf("%s.outputs.result", prefix)] = true
 	}
 	for _, param := range tmpl.Outputs.Parameters {
-		scope[fmt.Sprintf("steps.%s.outputs.parameters.%s", stepName, param.Name)] = true
+		scope[fmt.Sprintf("%s.outputs.parameters.%s", prefix, param.Name)] = true
 	}
 	for _, art := range tmpl.Outputs.Artifacts {
-		scope[fmt.Sprintf("steps.%s.outputs.artifacts.%s", stepName, art.Name)] = true
+		scope[fmt.Sprintf("%s.outputs.artifacts.%s", prefix, art.Name)] = true
+	}
+}
+
+// addAggregatedOutputsToScope adds the outputs of a step expanded using withItems or withParam to the scope.
+// Only the result and the output parameters are aggregated (as JSON lists) from the expanded steps, so their
+// artifacts and IPs may not be referenced.
+func (ctx *wfValidationCtx) addAggregatedOutputsToScope(templateName string, prefix string, scope map[string]interface{}) {
+	tmpl := ctx.wf.GetTemplate(templateName)
+	if tmpl.Script != nil {
+		scope[fmt.Sprintf("%s.outputs.result", prefix)] = true
+	}
+	for _, param := range tmpl.Outputs.Parameters {
+		scope[fmt.Sprintf("%s.outputs.parameters.%s", prefix, param.Name)] = true
 	}
 }
 
@@ -245,8 +875,20 @@ func validateOutputs(tmpl *wfv1.Template) error {
 		return errors.Errorf(errors.CodeBadRequest, "template '%s' outputs.artifacts%s", tmpl.Name, err.Error())
 	}
 
+	for _, param := range tmpl.Outputs.Parameters {
+		if param.ValueFrom != nil {
+			return errors.Errorf(errors.CodeBadRequest, "template '%s' outputs.parameters.%s.valueFrom is only valid in spec.arguments and inputs. Use path to read an output parameter from a file", tmpl.Name, param.Name)
+		}
+	}
+
 	isLeaf := tmpl.Container != nil || tmpl.Script != nil
+	if tmpl.ArchiveLogs != nil && !isLeaf {
+		return errors.Errorf(errors.CodeBadRequest, "template '%s' archiveLogs only valid in container/script templates", tmpl.Name)
+	}
 	for _, art := range tmpl.Outputs.Artifacts {
+		if art.Name == MainLogsArtifactName && tmpl.ArchiveLogs != nil && *tmpl.ArchiveLogs {
+			return errors.Errorf(errors.CodeBadRequest, "template '%s' outputs.artifacts.%s is reserved for the archived logs", tmpl.Name, art.Name)
+		}
 		artRef := fmt.Sprintf("outputs.artifacts.%s", art.Name)
 		if isLeaf {
 			if art.Path == "" {
@@ -260,6 +902,9 @@ func validateOutputs(tmpl *wfv1.Template) error {
 		if art.From != "" {
 			return errors.Errorf(errors.CodeBadRequest, "template '%s' %s.from only valid in arguments", tmpl.Name, artRef)
 		}
+		if art.HTTP != nil {
+			return errors.Errorf(errors.CodeBadRequest, "template '%s' %s.http is only supported for input artifacts", tmpl.Name, artRef)
+		}
 	}
 	return nil
 }
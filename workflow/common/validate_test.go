@@ -1,11 +1,14 @@
 package common
 
 import (
+	"io/ioutil"
+	"strings"
 	"testing"
 
 	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
 	"github.com/ghodss/yaml"
 	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
 )
 
 func validate(yamlStr string) error {
@@ -184,3 +187,825 @@ func TestUnsatisfiedParam(t *testing.T) {
 		assert.Contains(t, err.Error(), "not supplied")
 	}
 }
+
+var invalidActiveDeadline = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: hello-world-
+spec:
+  entrypoint: whalesay
+  activeDeadlineSeconds: 0
+  templates:
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+`
+
+func TestInvalidActiveDeadline(t *testing.T) {
+	err := validate(invalidActiveDeadline)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "activeDeadlineSeconds")
+	}
+}
+
+var unknownArgParam = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: hello-world-
+spec:
+  entrypoint: entry
+  templates:
+  - name: entry
+    steps:
+    - - name: hello
+        template: whalesay
+        arguments:
+          parameters:
+          - name: unknown
+            value: hello
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+`
+
+func TestUnknownArgument(t *testing.T) {
+	err := validate(unknownArgParam)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "is not an input parameter")
+	}
+}
+
+var multipleTemplateTypes = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: hello-world-
+spec:
+  entrypoint: whalesay
+  templates:
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+    script:
+      image: python:alpine3.6
+      source: print("hello")
+`
+
+func TestTemplateType(t *testing.T) {
+	err := validate(multipleTemplateTypes)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "only specify one of")
+	}
+}
+
+var invalidRetryBackoff = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: hello-world-
+spec:
+  entrypoint: whalesay
+  templates:
+  - name: whalesay
+    retryStrategy:
+      limit: 3
+      backoff:
+        duration: notaduration
+    container:
+      image: docker/whalesay:latest
+`
+
+func TestInvalidRetryStrategy(t *testing.T) {
+	err := validate(invalidRetryBackoff)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "retryStrategy.backoff.duration")
+	}
+}
+
+var stepsActiveDeadline = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: steps-deadline-
+spec:
+  entrypoint: steps
+  templates:
+  - name: steps
+    activeDeadlineSeconds: 10
+    steps:
+    - - name: hello
+        template: whalesay
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+`
+
+func TestTemplateActiveDeadline(t *testing.T) {
+	err := validate(stepsActiveDeadline)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "activeDeadlineSeconds only valid")
+	}
+}
+
+var undefinedOnExit = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: exit-handler-
+spec:
+  entrypoint: whalesay
+  onExit: cleanup
+  templates:
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+`
+
+func TestUndefinedOnExit(t *testing.T) {
+	err := validate(undefinedOnExit)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "spec.onExit template 'cleanup' undefined")
+	}
+}
+
+var artifactRepositoryNoBucket = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: artifact-repository-
+spec:
+  entrypoint: whalesay
+  artifactRepository:
+    s3:
+      endpoint: s3.amazonaws.com
+  templates:
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+`
+
+func TestArtifactRepositoryNoBucket(t *testing.T) {
+	err := validate(artifactRepositoryNoBucket)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "spec.artifactRepository.s3.bucket is required")
+	}
+}
+
+var artifactRepositoryInvalidKeyPrefix = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: artifact-repository-
+spec:
+  entrypoint: whalesay
+  artifactRepository:
+    s3:
+      endpoint: s3.amazonaws.com
+      bucket: my-bucket
+      keyPrefix: "{{workflow.namespace}}/{{workflow.labels}}"
+  templates:
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+`
+
+func TestArtifactRepositoryInvalidKeyPrefix(t *testing.T) {
+	err := validate(artifactRepositoryInvalidKeyPrefix)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "spec.artifactRepository.s3.keyPrefix is invalid: failed to resolve {{workflow.labels}}")
+	}
+}
+
+var templateRefOutputs = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: template-ref-
+spec:
+  entrypoint: steps
+  templates:
+  - name: steps
+    steps:
+    - - name: generate
+        templateRef:
+          name: my-templates
+          template: gen-random-int
+    - - name: print
+        template: whalesay
+        arguments:
+          parameters:
+          - name: message
+            value: "{{steps.generate.outputs.result}}"
+  - name: whalesay
+    inputs:
+      parameters:
+      - name: message
+    container:
+      image: docker/whalesay:latest
+      args: ["{{inputs.parameters.message}}"]
+`
+
+var templateRefAndTemplate = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: template-ref-
+spec:
+  entrypoint: steps
+  templates:
+  - name: steps
+    steps:
+    - - name: hello
+        template: whalesay
+        templateRef:
+          name: my-templates
+          template: whalesay
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+`
+
+func TestTemplateRef(t *testing.T) {
+	err := validate(templateRefOutputs)
+	assert.Nil(t, err)
+	err = validate(templateRefAndTemplate)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "only one of template or templateRef")
+	}
+}
+
+var invalidHTTPArtifactURL = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: input-artifact-http-
+spec:
+  entrypoint: http-artifact-example
+  templates:
+  - name: http-artifact-example
+    inputs:
+      artifacts:
+      - name: kubectl
+        path: /bin/kubectl
+        http:
+          url: ftp://example.com/kubectl
+    container:
+      image: debian:9.1
+`
+
+func TestInvalidHTTPArtifactURL(t *testing.T) {
+	err := validate(invalidHTTPArtifactURL)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "must be an http or https URL")
+	}
+}
+
+var archiveLogsOnSteps = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: archive-logs-
+spec:
+  entrypoint: steps
+  templates:
+  - name: steps
+    archiveLogs: true
+    steps:
+    - - name: whalesay
+        template: whalesay
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+`
+
+func TestArchiveLogsOnSteps(t *testing.T) {
+	err := validate(archiveLogsOnSteps)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "archiveLogs only valid in container/script templates")
+	}
+}
+
+var memoizeNoCache = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: memoize-
+spec:
+  entrypoint: whalesay
+  arguments:
+    parameters:
+    - name: message
+      value: hello
+  templates:
+  - name: whalesay
+    inputs:
+      parameters:
+      - name: message
+    memoize:
+      key: "{{inputs.parameters.message}}"
+    container:
+      image: docker/whalesay:latest
+      args: ["{{inputs.parameters.message}}"]
+`
+
+func TestMemoizeNoCache(t *testing.T) {
+	err := validate(memoizeNoCache)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "memoize.cache.configMap.name is required")
+	}
+}
+
+func TestMemoizeExample(t *testing.T) {
+	yamlBytes, err := ioutil.ReadFile("../../examples/memoize.yaml")
+	assert.Nil(t, err)
+	err = validate(string(yamlBytes))
+	assert.Nil(t, err)
+
+	// the memoized outputs must include the output parameter
+	var wf wfv1.Workflow
+	err = yaml.Unmarshal(yamlBytes, &wf)
+	assert.Nil(t, err)
+	tmpl := wf.GetTemplate("whalesay")
+	if assert.NotNil(t, tmpl) && assert.Len(t, tmpl.Outputs.Parameters, 1) {
+		assert.Equal(t, "/tmp/message.txt", tmpl.Outputs.Parameters[0].Path)
+	}
+}
+
+var invalidShutdown = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: invalid-shutdown-
+spec:
+  entrypoint: whalesay
+  shutdown: Abort
+  templates:
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+`
+
+func TestInvalidShutdown(t *testing.T) {
+	err := validate(invalidShutdown)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "spec.shutdown 'Abort' is invalid")
+	}
+}
+
+var dagCycle = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: dag-cycle-
+spec:
+  entrypoint: dag
+  templates:
+  - name: dag
+    dag:
+      tasks:
+      - name: A
+        template: whalesay
+      - name: B
+        dependencies: [A, C]
+        template: whalesay
+      - name: C
+        dependencies: [B]
+        template: whalesay
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+`
+
+func TestDAGCycle(t *testing.T) {
+	err := validate(dagCycle)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "dependency cycle among: B, C")
+	}
+}
+
+var dagUnresolvedOutput = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: dag-unresolved-
+spec:
+  entrypoint: dag
+  templates:
+  - name: dag
+    dag:
+      tasks:
+      - name: A
+        template: generate
+      - name: B
+        template: print
+        arguments:
+          parameters:
+          - name: message
+            value: "{{tasks.A.outputs.parameters.message}}"
+  - name: generate
+    container:
+      image: alpine:3.7
+      command: [sh, -c, "echo hello > /tmp/message"]
+    outputs:
+      parameters:
+      - name: message
+        path: /tmp/message
+  - name: print
+    inputs:
+      parameters:
+      - name: message
+    container:
+      image: alpine:3.7
+      command: [echo, "{{inputs.parameters.message}}"]
+`
+
+func TestDAGUnresolvedOutput(t *testing.T) {
+	// B does not depend on A, so the outputs of A are not in its scope
+	err := validate(dagUnresolvedOutput)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "failed to resolve {{tasks.A.outputs.parameters.message}}")
+	}
+	err = validate(strings.Replace(dagUnresolvedOutput, "template: print", "template: print\n        dependencies: [A]", 1))
+	assert.Nil(t, err)
+}
+
+var invalidServiceAccountName = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: invalid-service-account-
+spec:
+  entrypoint: whalesay
+  serviceAccountName: Not_Valid
+  templates:
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+`
+
+func TestInvalidServiceAccountName(t *testing.T) {
+	err := validate(invalidServiceAccountName)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "spec.serviceAccountName 'Not_Valid' is invalid")
+	}
+}
+
+var aggregatedOutputs = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: aggregated-outputs-
+spec:
+  entrypoint: loop
+  templates:
+  - name: loop
+    steps:
+    - - name: generate
+        template: generate
+        withItems: [1, 2, 3]
+    - - name: print
+        template: print
+        arguments:
+          parameters:
+          - name: message
+            value: "{{steps.generate.outputs.parameters.message}}"
+  - name: generate
+    container:
+      image: alpine:3.7
+      command: [sh, -c, "echo hello > /tmp/message"]
+    outputs:
+      parameters:
+      - name: message
+        path: /tmp/message
+      artifacts:
+      - name: message
+        path: /tmp/message
+  - name: print
+    inputs:
+      parameters:
+      - name: message
+    container:
+      image: alpine:3.7
+      command: [echo, "{{inputs.parameters.message}}"]
+`
+
+func TestAggregatedOutputs(t *testing.T) {
+	err := validate(aggregatedOutputs)
+	assert.Nil(t, err)
+	// the artifacts of expanded steps are not aggregated
+	err = validate(strings.Replace(aggregatedOutputs, "outputs.parameters.message", "outputs.artifacts.message", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "failed to resolve {{steps.generate.outputs.artifacts.message}}")
+	}
+}
+
+var invalidResourceAction = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: resource-
+spec:
+  entrypoint: job
+  templates:
+  - name: job
+    resource:
+      action: delete
+      manifest: |
+        apiVersion: batch/v1
+        kind: Job
+        metadata:
+          generateName: job-
+`
+
+var resourceFailureConditionOnly = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: resource-
+spec:
+  entrypoint: job
+  templates:
+  - name: job
+    resource:
+      failureCondition: status.failed > 0
+      manifest: |
+        apiVersion: batch/v1
+        kind: Job
+        metadata:
+          generateName: job-
+`
+
+func TestResourceTemplate(t *testing.T) {
+	err := validate(invalidResourceAction)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "resource.action 'delete' is invalid")
+	}
+	err = validate(resourceFailureConditionOnly)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "resource.failureCondition requires a successCondition")
+	}
+}
+
+var artifactPassing = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: artifact-passing-
+spec:
+  entrypoint: artifact-example
+  templates:
+  - name: artifact-example
+    steps:
+    - - name: generate-artifact
+        template: whalesay
+    - - name: consume-artifact
+        template: print-message
+        arguments:
+          artifacts:
+          - name: message
+            from: "{{steps.generate-artifact.outputs.artifacts.hello-art}}"
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+      command: [sh, -c]
+      args: ["cowsay hello world | tee /tmp/hello_world.txt"]
+    outputs:
+      parameters:
+      - name: hello-param
+        path: /tmp/hello_world.txt
+      artifacts:
+      - name: hello-art
+        path: /tmp/hello_world.txt
+  - name: print-message
+    inputs:
+      artifacts:
+      - name: message
+        path: /tmp/message
+    container:
+      image: alpine:latest
+      command: [sh, -c]
+      args: ["cat /tmp/message"]
+`
+
+func TestArtifactFrom(t *testing.T) {
+	err := validate(artifactPassing)
+	assert.Nil(t, err)
+
+	err = validate(strings.Replace(artifactPassing, "artifacts.hello-art", "artifacts.unknown", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "failed to resolve {{steps.generate-artifact.outputs.artifacts.unknown}}")
+	}
+	err = validate(strings.Replace(artifactPassing, "artifacts.hello-art}}", "parameters.hello-param}}", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "'{{steps.generate-artifact.outputs.parameters.hello-param}}' is not an artifact reference")
+	}
+	err = validate(strings.Replace(artifactPassing, `"{{steps.generate-artifact.outputs.artifacts.hello-art}}"`, "steps.generate-artifact.outputs.artifacts.hello-art", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "steps[1].consume-artifact.arguments.artifacts.message.from 'steps.generate-artifact.outputs.artifacts.hello-art' must be a single artifact reference")
+	}
+}
+
+var scriptNoCommand = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: scripts-python-
+spec:
+  entrypoint: gen-random-int
+  templates:
+  - name: gen-random-int
+    script:
+      image: python:alpine3.6
+      source: |
+        import random
+        print(random.randint(1, 100))
+`
+
+func TestScriptTemplate(t *testing.T) {
+	err := validate(scriptNoCommand)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "template 'gen-random-int' script.command is required")
+	}
+	err = validate(strings.Replace(scriptNoCommand, "      source:", "      command: [python]\n      source:", 1))
+	assert.Nil(t, err)
+}
+
+var dupStepNames = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: steps-
+spec:
+  entrypoint: steps
+  templates:
+  - name: steps
+    steps:
+    - - name: hello
+        template: whalesay
+      - name: hello
+        template: whalesay
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+`
+
+var dupOutputNames = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: hello-world-
+spec:
+  entrypoint: whalesay
+  templates:
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+  - name: unused
+    container:
+      image: docker/whalesay:latest
+    outputs:
+      parameters:
+      - name: dup
+        path: /tmp/dup
+      - name: dup
+        path: /tmp/dup
+`
+
+func TestDuplicateStepAndOutputNames(t *testing.T) {
+	err := validate(dupStepNames)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "template 'steps' steps[0].hello name is not unique")
+	}
+	// templates which are not reachable from the entrypoint are verified as well
+	err = validate(dupOutputNames)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "template 'unused' outputs.parameters[1].name 'dup' is not unique")
+	}
+	err = validate(strings.Replace(dupStepNames, "template: whalesay\n      - name: hello", "template: whalesay\n      - name: hello2", 1))
+	assert.Nil(t, err)
+}
+
+var parameterValueFrom = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: value-from-
+spec:
+  entrypoint: whalesay
+  arguments:
+    parameters:
+    - name: message
+      valueFrom:
+        configMapKeyRef:
+          name: whalesay-config
+          key: message
+  templates:
+  - name: whalesay
+    inputs:
+      parameters:
+      - name: message
+      - name: greeting
+        valueFrom:
+          configMapKeyRef:
+            name: whalesay-config
+            key: greeting
+      - name: password
+        valueFrom:
+          secretKeyRef:
+            name: whalesay-secret
+            key: password
+    container:
+      image: docker/whalesay:latest
+      command: [cowsay, "{{inputs.parameters.greeting}}", "{{inputs.parameters.message}}", "{{inputs.parameters.password}}"]
+`
+
+func TestParameterValueFrom(t *testing.T) {
+	err := validate(parameterValueFrom)
+	assert.Nil(t, err)
+
+	err = validate(strings.Replace(parameterValueFrom, "      valueFrom:\n", "      value: hello\n      valueFrom:\n", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "spec.arguments.parameters.message value and valueFrom are mutually exclusive")
+	}
+	err = validate(strings.Replace(parameterValueFrom, "          key: message\n", "", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "spec.arguments.parameters.message.valueFrom requires the name and key of the configmap or secret")
+	}
+	err = validate(strings.Replace(parameterValueFrom, "          secretKeyRef:", "          configMapKeyRef:\n            name: whalesay-config\n            key: password\n          secretKeyRef:", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "template 'whalesay' inputs.parameters.password.valueFrom configMapKeyRef and secretKeyRef are mutually exclusive")
+	}
+}
+
+var workflowOutputs = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: workflow-outputs-
+spec:
+  entrypoint: main
+  outputs:
+    parameters:
+    - name: message
+      value: "{{steps.generate.outputs.parameters.message}}"
+    artifacts:
+    - name: hello-art
+      from: "{{steps.generate.outputs.artifacts.hello-art}}"
+  templates:
+  - name: main
+    steps:
+    - - name: generate
+        template: whalesay
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+      command: [sh, -c]
+      args: ["cowsay hello world | tee /tmp/hello_world.txt"]
+    outputs:
+      parameters:
+      - name: message
+        path: /tmp/hello_world.txt
+      artifacts:
+      - name: hello-art
+        path: /tmp/hello_world.txt
+`
+
+func TestWorkflowOutputs(t *testing.T) {
+	err := validate(workflowOutputs)
+	assert.Nil(t, err)
+
+	err = validate(strings.Replace(workflowOutputs, "{{steps.generate.outputs.parameters.message}}", "{{steps.unknown.outputs.parameters.message}}", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "spec.outputs.parameters.message.value failed to resolve {{steps.unknown.outputs.parameters.message}}")
+	}
+	err = validate(strings.Replace(workflowOutputs, "      value: \"{{steps.generate.outputs.parameters.message}}\"\n", "", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "spec.outputs.parameters.message.value is required")
+	}
+	err = validate(strings.Replace(workflowOutputs, "{{steps.generate.outputs.artifacts.hello-art}}", "{{steps.generate.outputs.artifacts.unknown}}", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "spec.outputs.artifacts.hello-art.from failed to resolve {{steps.generate.outputs.artifacts.unknown}}")
+	}
+}
+
+func TestValidateCronWorkflowSchedule(t *testing.T) {
+	cwf := &wfv1.CronWorkflow{Spec: wfv1.CronWorkflowSpec{
+		WorkflowSpec: wfv1.WorkflowSpec{Entrypoint: "whalesay", Templates: []wfv1.Template{{
+			Name:      "whalesay",
+			Container: &apiv1.Container{Image: "docker/whalesay:latest"},
+		}}},
+	}}
+	for _, schedule := range []string{"0 2 * * *", "*/15 * * * mon-fri", "@daily", "@every 1h30m"} {
+		cwf.Spec.Schedule = schedule
+		assert.Nil(t, ValidateCronWorkflow(cwf), schedule)
+	}
+	for _, schedule := range []string{"0 2 * *", "60 * * * *", "* * * foo *"} {
+		cwf.Spec.Schedule = schedule
+		err := ValidateCronWorkflow(cwf)
+		if assert.NotNil(t, err, schedule) {
+			assert.Contains(t, err.Error(), "spec.schedule")
+		}
+	}
+}
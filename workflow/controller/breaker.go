@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// apiCircuitBreaker tracks the consecutive errors of the API server upon the mutating requests of the
+// controller (creating pods and updating workflows). Once the configured number of errors is reached, the
+// breaker opens: workflows are no longer operated on, nor node updates applied, so that a degraded API server
+// is not further loaded by the controller's retries. Once the cooldown elapsed, the API server is probed
+// before the breaker closes again.
+type apiCircuitBreaker struct {
+	lock sync.Mutex
+	// failures is the number of consecutive API server errors
+	failures int
+	// openUntil is the end of the cooldown of the open breaker. Zero when the breaker is closed.
+	openUntil time.Time
+	// probing is whether the API server is being probed, so that only one probe runs at a time
+	probing bool
+}
+
+// apiProbeTimeout bounds the probe of the API server, which must not hang on an unresponsive API server
+const apiProbeTimeout = 10 * time.Second
+
+// apiCircuitWait returns how long the mutating operations of the controller must wait for the API circuit
+// breaker to close, or zero if they may proceed. Once the cooldown of the open breaker elapsed, the API server
+// is probed: the breaker closes if the probe succeeds, and its cooldown starts again otherwise. The probe runs
+// without holding the lock of the breaker, and the callers arriving meanwhile wait for up to the probe timeout.
+func (wfc *WorkflowController) apiCircuitWait() time.Duration {
+	b := &wfc.apiBreaker
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.openUntil.IsZero() {
+		return 0
+	}
+	config := wfc.getConfig()
+	if config.APIErrorThreshold == 0 {
+		// the breaker was disabled while open
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return 0
+	}
+	if wait := b.openUntil.Sub(time.Now()); wait > 0 {
+		return wait
+	}
+	if b.probing {
+		return apiProbeTimeout
+	}
+	b.probing = true
+	b.lock.Unlock()
+	err := wfc.probeAPIServer()
+	b.lock.Lock()
+	b.probing = false
+	if err != nil {
+		cooldown := apiErrorCooldown(config)
+		log.Warnf("API server probe failed, pausing the creation of pods and updates of workflows for another %v: %v", cooldown, err)
+		b.openUntil = time.Now().Add(cooldown)
+		return cooldown
+	}
+	log.Infof("API server probe succeeded, resuming the creation of pods and updates of workflows")
+	b.failures = 0
+	b.openUntil = time.Time{}
+	return 0
+}
+
+// recordAPIResult records the result of a mutating request of the controller to the API server, opening the
+// API circuit breaker once the configured number of consecutive API server errors is reached. Errors which
+// are not caused by the API server being unhealthy (e.g. conflicts) reset the count of errors.
+func (wfc *WorkflowController) recordAPIResult(err error) {
+	config := wfc.getConfig()
+	threshold := config.APIErrorThreshold
+	if threshold == 0 {
+		return
+	}
+	b := &wfc.apiBreaker
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if err == nil || !isAPIServerError(err) {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= threshold && b.openUntil.IsZero() {
+		cooldown := apiErrorCooldown(config)
+		log.Warnf("%d consecutive API server errors, pausing the creation of pods and updates of workflows for %v: %v", b.failures, cooldown, err)
+		b.openUntil = time.Now().Add(cooldown)
+	}
+}
+
+// apiErrorCooldown returns the cooldown of the API circuit breaker
+func apiErrorCooldown(config *WorkflowControllerConfig) time.Duration {
+	cooldown, err := config.getAPIErrorCooldown()
+	if err != nil {
+		log.Warnf("Failed to get API error cooldown: %v", err)
+		return defaultAPIErrorCooldown
+	}
+	return cooldown
+}
+
+// probeAPIServer checks whether the API server is responsive, by getting its version within the probe timeout
+func (wfc *WorkflowController) probeAPIServer() error {
+	if wfc.clientset == nil {
+		return nil
+	}
+	discovery := wfc.clientset.Discovery()
+	restClient := discovery.RESTClient()
+	if restClient == nil {
+		// the fake discovery client of the tests has no REST client
+		_, err := discovery.ServerVersion()
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), apiProbeTimeout)
+	defer cancel()
+	return restClient.Get().AbsPath("/version").Timeout(apiProbeTimeout).Context(ctx).Do().Error()
+}
+
+// isAPIServerError returns whether an error of a request is caused by the API server being unhealthy: a
+// server error, the API server throttling requests, or the API server being unreachable
+func isAPIServerError(err error) bool {
+	switch e := err.(type) {
+	case apierr.APIStatus:
+		code := e.Status().Code
+		return code >= http.StatusInternalServerError || code == http.StatusTooManyRequests
+	case net.Error:
+		return true
+	}
+	return false
+}
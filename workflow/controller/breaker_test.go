@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestIsAPIServerError(t *testing.T) {
+	gr := schema.GroupResource{Resource: "pods"}
+	assert.True(t, isAPIServerError(apierr.NewInternalError(fmt.Errorf("etcd unavailable"))))
+	assert.True(t, isAPIServerError(apierr.NewServerTimeout(gr, "create", 1)))
+	assert.True(t, isAPIServerError(apierr.NewTooManyRequests("throttled", 1)))
+	assert.True(t, isAPIServerError(&net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}))
+	assert.False(t, isAPIServerError(apierr.NewConflict(gr, "pod", fmt.Errorf("modified"))))
+	assert.False(t, isAPIServerError(apierr.NewAlreadyExists(gr, "pod")))
+	assert.False(t, isAPIServerError(fmt.Errorf("invalid manifest")))
+}
+
+func TestAPICircuitBreaker(t *testing.T) {
+	wfc := &WorkflowController{config: &WorkflowControllerConfig{APIErrorThreshold: 2, APIErrorCooldown: "1h"}}
+	serverErr := apierr.NewInternalError(fmt.Errorf("etcd unavailable"))
+
+	// errors which are not consecutive do not open the breaker
+	wfc.recordAPIResult(serverErr)
+	wfc.recordAPIResult(nil)
+	wfc.recordAPIResult(serverErr)
+	assert.Equal(t, time.Duration(0), wfc.apiCircuitWait())
+
+	wfc.recordAPIResult(serverErr)
+	wait := wfc.apiCircuitWait()
+	assert.True(t, wait > 59*time.Minute && wait <= time.Hour)
+
+	// the breaker closes once the cooldown elapsed and the probe succeeds
+	wfc.apiBreaker.openUntil = time.Now().Add(-time.Second)
+	assert.Equal(t, time.Duration(0), wfc.apiCircuitWait())
+	wfc.recordAPIResult(serverErr)
+	assert.Equal(t, time.Duration(0), wfc.apiCircuitWait())
+
+	// the breaker is disabled without a threshold
+	wfc.config.APIErrorThreshold = 0
+	for i := 0; i < 5; i++ {
+		wfc.recordAPIResult(serverErr)
+	}
+	assert.Equal(t, time.Duration(0), wfc.apiCircuitWait())
+}
+
+func TestAPICircuitBreakerProbe(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	probing := make(chan struct{})
+	release := make(chan struct{})
+	// the fake discovery client does not share the reactors of the fake clientset
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).PrependReactor("get", "version", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		probing <- struct{}{}
+		<-release
+		return true, nil, nil
+	})
+	wfc := &WorkflowController{clientset: clientset, config: &WorkflowControllerConfig{APIErrorThreshold: 1, APIErrorCooldown: "1h"}}
+	wfc.recordAPIResult(apierr.NewInternalError(fmt.Errorf("etcd unavailable")))
+	wfc.apiBreaker.openUntil = time.Now().Add(-time.Second)
+
+	result := make(chan time.Duration, 1)
+	go func() {
+		result <- wfc.apiCircuitWait()
+	}()
+	select {
+	case <-probing:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the API server was not probed")
+	}
+
+	// while the API server is probed, the breaker is not locked, and no other probe is started
+	assert.Equal(t, apiProbeTimeout, wfc.apiCircuitWait())
+	wfc.recordAPIResult(nil)
+
+	close(release)
+	select {
+	case wait := <-result:
+		assert.Equal(t, time.Duration(0), wait)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the probe of the API server did not complete")
+	}
+	assert.Equal(t, time.Duration(0), wfc.apiCircuitWait())
+}
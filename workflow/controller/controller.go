@@ -4,8 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"io/ioutil"
 	"os"
 	goruntime "runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
@@ -16,144 +22,832 @@ import (
 	gocache "github.com/patrickmn/go-cache"
 	log "github.com/sirupsen/logrus"
 	apiv1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
 )
 
 type WorkflowController struct {
 	// ConfigMap is the name of the config map in which to derive configuration of the controller from
 	ConfigMap string
+	// ConfigFile is the path of a file to read the configuration of the controller from, instead of
+	// the config map (e.g. when running the controller locally). The file is not watched for changes.
+	ConfigFile string
 	// namespace for config map
 	ConfigMapNS string
 	//WorkflowClient *workflowclient.WorkflowClient
-	Config WorkflowControllerConfig
+
+	// config is the controller config, which is replaced as a whole when it changes and never modified.
+	// Readers take a snapshot using getConfig, so that an operation sees a consistent config.
+	config     *WorkflowControllerConfig
+	configLock sync.RWMutex
 
 	restConfig *rest.Config
 	restClient *rest.RESTClient
 	scheme     *runtime.Scheme
-	clientset  *kubernetes.Clientset
-	wfUpdates  chan *wfv1.Workflow
-	podUpdates chan *apiv1.Pod
+	clientset  kubernetes.Interface
+
+	// dynamicClientPool provides the clients of the resources of resource templates
+	dynamicClientPool dynamic.ClientPool
+
+	// eventRecorder records events of workflows (e.g. their completion)
+	eventRecorder record.EventRecorder
+
+	// wfQueue and podQueue hold the namespace/name keys of workflows and pods needing processing.
+	// Keys are de-duplicated by the queue, and looked up in the informer stores when dequeued.
+	wfQueue  workqueue.RateLimitingInterface
+	podQueue workqueue.RateLimitingInterface
+	wfStore  cache.Store
+	podStore cache.Store
+
+	// wftmplStore is a cache of the WorkflowTemplates referenced by workflows
+	wftmplStore cache.Store
+
+	// cronWfStore is a cache of the CronWorkflows whose workflows are started by the controller
+	cronWfStore cache.Store
+
+	// nodeUpdateQueue holds the keys of workflows with pending node updates (see enqueueNodeUpdate)
+	nodeUpdateQueue workqueue.RateLimitingInterface
+	// pendingNodeUpdates are the node updates waiting to be applied, keyed by workflow key and pod name
+	pendingNodeUpdates     map[string]map[string]podNodeUpdate
+	pendingNodeUpdatesLock sync.Mutex
 
-	// completedPodCache an in-memory cache of completed pods names.
+	// ttlQueue holds the keys of completed workflows to be deleted upon expiry of their TTL
+	ttlQueue workqueue.RateLimitingInterface
+
+	// completedPodCache an in-memory cache of completed pods, keyed by pod (namespace/name).
 	// This is used to remember the fact that we marked a pod as completed.
 	// any future pod events from the watch can be ignored. This enables
 	// pod watch handler to quickly skip evaluation of duplicated pod entries
-	// in the pod channel.
+	// in the pod queue.
 	// Ideally this would have been prevented using completed=true label
 	// which we apply on a pod, but somehow it is possible for the informer
 	// to enqueue pods which are missing the label (depite having added it),
 	// thus, we record these pods temporarily in a TTL cache.
 	completedPodCache *gocache.Cache
+
+	// podUnknownCache records the time at which pods were first seen in the Unknown phase, keyed by pod
+	// namespace/name
+	podUnknownCache *gocache.Cache
+
+	// podStateCache is an in-memory cache of the last pod state applied to the workflow, keyed by
+	// pod namespace/name. Pod updates which would result in the same node state (e.g. resyncs, or
+	// changes to irrelevant pod fields) are skipped, avoiding redundant workflow get/update round-trips.
+	podStateCache *gocache.Cache
+
+	// operateSemaphore bounds the number of workflows being operated on concurrently. It is nil
+	// (unbounded) unless maxConcurrentOperations is configured
+	operateSemaphore chan struct{}
+
+	// metrics are the prometheus collectors updated by the controller
+	metrics *controllerMetrics
+
+	// apiBreaker pauses the mutating operations of the controller while the API server is unhealthy
+	apiBreaker apiCircuitBreaker
+
+	// configLoaded is set to 1 once the controller config is successfully loaded
+	configLoaded int32
+	// wfInformer and podInformer are the informers of the leader, reported by the readiness probe
+	wfInformer    cache.Controller
+	podInformer   cache.Controller
+	informersLock sync.Mutex
 }
 
 type WorkflowControllerConfig struct {
-	ExecutorImage      string             `json:"executorImage,omitempty"`
-	ArtifactRepository ArtifactRepository `json:"artifactRepository,omitempty"`
-	Namespace          string             `json:"namespace,omitempty"`
-	MatchLabels        map[string]string  `json:"matchLabels,omitempty"`
+	ExecutorImage      string                  `json:"executorImage,omitempty"`
+	ArtifactRepository wfv1.ArtifactRepository `json:"artifactRepository,omitempty"`
+
+	// AllowedExecutorImages are the executor images which workflows may use instead of the executorImage, with
+	// spec.executorImage (e.g. to canary a new executor on select workflows). When an image is removed, the
+	// workflows using it fall back to the executorImage for the pods they create from then on.
+	AllowedExecutorImages []string `json:"allowedExecutorImages,omitempty"`
+
+	// AllowedClusterScopedResources are the kinds of cluster-scoped resources which resource templates may create,
+	// as Kind.group (e.g. ClusterRole.rbac.authorization.k8s.io), or Kind for the core group (e.g. Namespace).
+	// Since the resources are created with the permissions of the controller, resource templates are otherwise
+	// restricted to namespaced resources in the namespace of their workflow.
+	AllowedClusterScopedResources []string `json:"allowedClusterScopedResources,omitempty"`
+
+	// ExecutorImagePullPolicy is the pull policy of the executor containers (Always, IfNotPresent, Never).
+	// When omitted, the Kubernetes default for the executor image applies.
+	ExecutorImagePullPolicy apiv1.PullPolicy `json:"executorImagePullPolicy,omitempty"`
+
+	// MainImagePullPolicy is the pull policy of the main containers of container and script templates which
+	// do not specify one, or of all main containers when ForceMainImagePullPolicy is set. When omitted, the
+	// Kubernetes default for the image applies.
+	MainImagePullPolicy      apiv1.PullPolicy `json:"mainImagePullPolicy,omitempty"`
+	ForceMainImagePullPolicy bool             `json:"forceMainImagePullPolicy,omitempty"`
+
+	// ImagePullSecrets are references to secrets in the workflow's namespace, attached to every pod
+	// created by the controller, for pulling the executor and user images from private registries
+	ImagePullSecrets []apiv1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// ServiceAccountName is the service account, in the workflow's namespace, which pods created by the
+	// controller run as. Can be overridden by the workflow's spec.serviceAccountName. When omitted, pods
+	// run as the default service account of the namespace.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// ExecutorResources are the resource requests and limits of the init and wait containers.
+	// When omitted, defaults to requests of 0.1 cpu/64Mi memory and limits of 0.5 cpu/512Mi memory.
+	ExecutorResources *apiv1.ResourceRequirements `json:"executorResources,omitempty"`
+
+	// PodMetadata are labels and annotations applied to all pods created by the controller
+	PodMetadata PodMetadataConfig `json:"podMetadata,omitempty"`
+
+	// NodeSelector, NodeAffinity and Tolerations are scheduling constraints applied to all pods created by
+	// the controller, e.g. to run workflows on dedicated nodes. Node selectors of the workflow or template
+	// take precedence over the keys of the default node selector.
+	NodeSelector map[string]string   `json:"nodeSelector,omitempty"`
+	NodeAffinity *apiv1.NodeAffinity `json:"nodeAffinity,omitempty"`
+	Tolerations  []apiv1.Toleration  `json:"tolerations,omitempty"`
+
+	// SecurityContext holds the default security contexts of the pods created by the controller and of their
+	// containers, e.g. to satisfy the pod security policies enforced by the cluster
+	SecurityContext *SecurityContextConfig `json:"securityContext,omitempty"`
+
+	// ContainerDefaults holds settings applied to all containers of the pods created by the controller
+	ContainerDefaults *ContainerDefaultsConfig `json:"containerDefaults,omitempty"`
+
+	// Namespace restricts the controller to operate on workflows (and their pods) in a single namespace.
+	// When empty, the controller watches workflows and pods across all namespaces. Note that cluster-wide
+	// operation requires the controller's service account be bound to a ClusterRole permitting it to
+	// get/list/watch/update workflows, and get/list/watch/create/delete pods (plus pods/exec for killing
+	// daemons and sidecars) in every namespace. Namespace-scoped operation only requires a Role with
+	// those permissions in the given namespace.
+	Namespace   string            `json:"namespace,omitempty"`
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+
+	// Maintenance pauses the creation of pods, e.g. during maintenance of the cluster. Workflows continue to be
+	// operated on, and the statuses of their existing pods to be tracked, but their pending steps are deferred
+	// until maintenance mode is turned off again, which can be done without restarting the controller.
+	Maintenance bool `json:"maintenance,omitempty"`
+
+	// InstanceID distinguishes multiple controllers in the same cluster. The controller only processes
+	// workflows labeled with its instance id (e.g. using `argo submit --instanceid`), and labels the
+	// pods it creates with it. A controller without an instance id ignores workflows which have one.
+	InstanceID string `json:"instanceID,omitempty"`
+
+	// WorkflowResyncPeriod is the resync period of the workflow informer, as a duration string (e.g. 20m).
+	// Defaults to 20m when unset.
+	WorkflowResyncPeriod string `json:"workflowResyncPeriod,omitempty"`
+
+	// PodResyncPeriod is the resync period of the workflow pod informer, as a duration string (e.g. 30m).
+	// Defaults to 30m when unset.
+	PodResyncPeriod string `json:"podResyncPeriod,omitempty"`
+
+	// PodPendingThreshold is the duration a pod may be Pending before the controller inspects it for
+	// the reason it is stuck (e.g. unschedulable), and surfaces the reason in the node message. Defaults to 5m
+	PodPendingThreshold string `json:"podPendingThreshold,omitempty"`
+
+	// PodFieldSelector is an additional field selector of the pods watched by the controller. By default,
+	// pods of all phases are watched. For instance, 'status.phase!=Pending' reduces the pod events handled
+	// by the controller, at the cost of not detecting pods stuck Pending (see podPendingThreshold).
+	PodFieldSelector string `json:"podFieldSelector,omitempty"`
+
+	// PodLabelSelector holds additional requirements of the label selector of the pods watched by the controller
+	// (e.g. 'team=ml,tier!=debug'), which are combined with the requirements the controller always applies:
+	// the pod is not yet completed, and matches the matchLabels and instanceID of the config. Like the
+	// podFieldSelector, it reduces the pod events handled by the controller in large namespaces. Pods not
+	// selected are not tracked, so it must select all the pods of the workflows operated on by the controller.
+	PodLabelSelector string `json:"podLabelSelector,omitempty"`
+
+	// PodUnknownThreshold is the duration a pod may be in the Unknown phase (e.g. its node lost contact with
+	// the cluster) before its node is errored. Until then, the node remains in its current phase with a warning
+	// message, since the pod often recovers. Defaults to 5m
+	PodUnknownThreshold string `json:"podUnknownThreshold,omitempty"`
+
+	// CompletedPodCacheTTL is the duration a completed pod is remembered by the controller, during which
+	// further updates of the pod are ignored, as a duration string (e.g. 1h). If a completed pod is
+	// evicted while the controller is still receiving its updates, the pod is needlessly reprocessed.
+	// Defaults to 1h
+	CompletedPodCacheTTL string `json:"completedPodCacheTTL,omitempty"`
+
+	// CompletedPodCacheCleanupInterval is the interval at which expired pods are deleted from the completed pod
+	// cache, as a duration string (e.g. 10m). Defaults to 10m
+	CompletedPodCacheCleanupInterval string `json:"completedPodCacheCleanupInterval,omitempty"`
+
+	// CompletedPodCacheMaxEntries bounds the number of completed pods remembered by the controller, to bound its
+	// memory usage in clusters completing many pods within the completedPodCacheTTL. Once the cache is full, the
+	// pods remembered the longest ago are evicted first. Unbounded when 0
+	CompletedPodCacheMaxEntries int `json:"completedPodCacheMaxEntries,omitempty"`
+
+	// MetricsPort is the port on which the controller exposes prometheus metrics. Defaults to 9090
+	MetricsPort int `json:"metricsPort,omitempty"`
+
+	// StatsInterval is the interval at which the controller logs its memory usage, number of goroutines and
+	// queue depths, as a duration string (e.g. 5m). Since these are also exposed as prometheus metrics, the
+	// logging can be disabled with 0. Defaults to 5m
+	StatsInterval string `json:"statsInterval,omitempty"`
+
+	// HealthPort is the port on which the controller serves the /healthz and /readyz probes. Defaults to 6060
+	HealthPort int `json:"healthPort,omitempty"`
+
+	// WorkflowWorkers is the number of goroutines concurrently operating on workflows. Defaults to 8
+	WorkflowWorkers int `json:"workflowWorkers,omitempty"`
+
+	// PodWorkers is the number of goroutines concurrently handling pod updates. Defaults to 8
+	PodWorkers int `json:"podWorkers,omitempty"`
+
+	// MaxConcurrentOperations limits the number of workflows operated on concurrently, independently of
+	// the number of workflow workers, to bound API server load and memory usage during bursts.
+	// Unlimited when zero
+	MaxConcurrentOperations int `json:"maxConcurrentOperations,omitempty"`
+
+	// WorkflowQueueSize and PodQueueSize are the expected capacity of the workflow and pod work queues.
+	// The queues are unbounded and never block, but a warning is logged when a queue's depth crosses
+	// 80% of its size, indicating the workers are not keeping up. Default to 10240 and 102400
+	WorkflowQueueSize int `json:"workflowQueueSize,omitempty"`
+	PodQueueSize      int `json:"podQueueSize,omitempty"`
+
+	// GetWorkflowRetries is the number of times getting a workflow to apply pod updates is retried, with
+	// exponential backoff, upon transient API server errors. Defaults to 5. Set to -1 to disable retries.
+	GetWorkflowRetries int `json:"getWorkflowRetries,omitempty"`
+
+	// Parallelism limits the max number of pods of a single workflow which may run simultaneously.
+	// Can be overridden by the workflow's spec.parallelism. Unlimited when zero.
+	Parallelism int64 `json:"parallelism,omitempty"`
+
+	// MaxWorkflowNodes limits the number of nodes of a single workflow, so that a runaway workflow (e.g. a
+	// withItems loop over a huge list) does not grow beyond the size limit of the API server's objects.
+	// A workflow which exceeds it is errored. Unlimited when zero.
+	MaxWorkflowNodes int `json:"maxWorkflowNodes,omitempty"`
+
+	// MaxOperateFailures is the number of consecutive times the operation of a workflow may fail before the
+	// workflow is errored and cleaned up. Failed operations are retried with an exponential backoff from 1s to 5m,
+	// so that the default of 10 failures spans about 8 minutes. Transient errors (conflicts, API server timeouts,
+	// throttling and connection errors) are retried without counting as failures.
+	MaxOperateFailures int `json:"maxOperateFailures,omitempty"`
+
+	// WaitContainerFailurePhase is the phase (Error or Failed) of the nodes whose pods failed because of the
+	// wait container (e.g. failing to save artifacts), whereas their main container succeeded. Defaults to Error
+	WaitContainerFailurePhase wfv1.NodePhase `json:"waitContainerFailurePhase,omitempty"`
+
+	// APIErrorThreshold is the number of consecutive API server errors (server errors, throttling or timeouts)
+	// upon creating pods and updating workflows, after which the controller pauses operating on workflows for
+	// the apiErrorCooldown, so as not to further load a degraded API server. The API server is probed before
+	// resuming. Disabled when zero.
+	APIErrorThreshold int `json:"apiErrorThreshold,omitempty"`
+
+	// APIErrorCooldown is the duration operations are paused once the apiErrorThreshold is reached, as a
+	// duration string (e.g. 30s). Defaults to 1m
+	APIErrorCooldown string `json:"apiErrorCooldown,omitempty"`
+
+	// PodGC is the strategy used to delete the pods of workflows (OnPodCompletion, OnWorkflowCompletion,
+	// OnWorkflowSuccess). When omitted, pods are never deleted by the controller.
+	PodGC PodGCStrategy `json:"podGC,omitempty"`
+
+	// DryRun runs the controller in a validation-only mode. Workflows and pods are evaluated as usual, but
+	// all mutating calls (creating or deleting pods and PVCs, updating workflows, labeling pods) are
+	// logged instead of performed. Since workflow status is never persisted, each workflow is evaluated
+	// from its initial state.
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// DisablePodCompletedLabel disables labeling completed pods with completed=true, which otherwise
+	// removes them from the controller's pod watch. Intended for debugging, so that late changes to
+	// completed pods remain observable. Completed pods are still remembered by the controller (see
+	// completedPodCacheTTL), and are reprocessed after a controller restart.
+	DisablePodCompletedLabel bool `json:"disablePodCompletedLabel,omitempty"`
+
+	// LeaderElection enables leader election amongst multiple controller replicas.
+	// When omitted, the controller assumes it is the only replica.
+	LeaderElection *LeaderElectionConfig `json:"leaderElection,omitempty"`
 }
 
 const (
-	workflowResyncPeriod = 20 * time.Minute
-	podResyncPeriod      = 30 * time.Minute
+	defaultWorkflowResyncPeriod = 20 * time.Minute
+	defaultPodResyncPeriod      = 30 * time.Minute
+
+	defaultPodPendingThreshold = 5 * time.Minute
+	defaultPodUnknownThreshold = 5 * time.Minute
+
+	defaultCompletedPodCacheTTL             = 1 * time.Hour
+	defaultCompletedPodCacheCleanupInterval = 10 * time.Minute
+
+	defaultStatsInterval = 5 * time.Minute
+
+	defaultAPIErrorCooldown = 1 * time.Minute
+
+	// defaultMaxOperateFailures is the default number of consecutive failed operations of a workflow
+	// before it is marked as errored
+	defaultMaxOperateFailures = 10
+	// operateRetryBaseDelay and operateRetryMaxDelay bound the exponential backoff of failed workflow operations
+	operateRetryBaseDelay = 1 * time.Second
+	operateRetryMaxDelay  = 5 * time.Minute
+	// operateRetryJitter is the maximum fraction of the backoff of a failed workflow operation added as jitter
+	operateRetryJitter = 0.1
+
+	defaultWorkflowWorkers = 8
+	defaultPodWorkers      = 8
+
+	defaultWorkflowQueueSize = 10240
+	defaultPodQueueSize      = 102400
+
+	// queueDepthWarningRatio is the ratio of a queue's size above which its depth is warned about
+	queueDepthWarningRatio = 0.8
+	// queueDepthCheckPeriod is the interval at which queue depths are checked
+	queueDepthCheckPeriod = 30 * time.Second
+
+	// shutdownDrainTimeout is the maximum time spent processing queued updates upon shutdown.
+	// Kept under the default k8s termination grace period (30s).
+	shutdownDrainTimeout = 20 * time.Second
 )
 
-// ArtifactRepository represents a artifact repository in which a controller will store its artifacts
-type ArtifactRepository struct {
-	S3 *S3ArtifactRepository `json:"s3,omitempty"`
-	// Future artifact repository support here
+// getWorkflowResyncPeriod returns the configured workflow resync period, or the default if unset
+func (c *WorkflowControllerConfig) getWorkflowResyncPeriod() (time.Duration, error) {
+	return parseConfigDuration("workflowResyncPeriod", c.WorkflowResyncPeriod, defaultWorkflowResyncPeriod)
+}
+
+// getPodResyncPeriod returns the configured pod resync period, or the default if unset
+func (c *WorkflowControllerConfig) getPodResyncPeriod() (time.Duration, error) {
+	return parseConfigDuration("podResyncPeriod", c.PodResyncPeriod, defaultPodResyncPeriod)
+}
+
+// getPodPendingThreshold returns the configured pod pending threshold, or the default if unset
+func (c *WorkflowControllerConfig) getPodPendingThreshold() (time.Duration, error) {
+	return parseConfigDuration("podPendingThreshold", c.PodPendingThreshold, defaultPodPendingThreshold)
+}
+
+// getPodUnknownThreshold returns the configured pod unknown threshold, or the default if unset
+func (c *WorkflowControllerConfig) getPodUnknownThreshold() (time.Duration, error) {
+	return parseConfigDuration("podUnknownThreshold", c.PodUnknownThreshold, defaultPodUnknownThreshold)
+}
+
+// getCompletedPodCacheTTL returns the configured completed pod cache TTL, or the default if unset
+func (c *WorkflowControllerConfig) getCompletedPodCacheTTL() (time.Duration, error) {
+	return parseConfigDuration("completedPodCacheTTL", c.CompletedPodCacheTTL, defaultCompletedPodCacheTTL)
+}
+
+// getCompletedPodCacheCleanupInterval returns the configured completed pod cache cleanup interval, or the default
+// if unset
+func (c *WorkflowControllerConfig) getCompletedPodCacheCleanupInterval() (time.Duration, error) {
+	interval, err := parseConfigDuration("completedPodCacheCleanupInterval", c.CompletedPodCacheCleanupInterval, defaultCompletedPodCacheCleanupInterval)
+	if err == nil && interval <= 0 {
+		return 0, errors.Errorf(errors.CodeBadRequest, "completedPodCacheCleanupInterval must be positive")
+	}
+	return interval, err
+}
+
+// getStatsInterval returns the configured stats logging interval, or the default if unset. Zero disables
+// stats logging.
+func (c *WorkflowControllerConfig) getStatsInterval() (time.Duration, error) {
+	return parseConfigDuration("statsInterval", c.StatsInterval, defaultStatsInterval)
+}
+
+// getAPIErrorCooldown returns the configured API error cooldown, or the default if unset
+func (c *WorkflowControllerConfig) getAPIErrorCooldown() (time.Duration, error) {
+	return parseConfigDuration("apiErrorCooldown", c.APIErrorCooldown, defaultAPIErrorCooldown)
 }
-type S3ArtifactRepository struct {
-	wfv1.S3Bucket `json:",inline,squash"`
 
-	// KeyPrefix is prefix used as part of the bucket key in which the controller will store artifacts.
-	KeyPrefix string `json:"keyPrefix,omitempty"`
+// parseConfigDuration is a helper to parse a duration string from the controller config
+func parseConfigDuration(field string, duration string, defaultDuration time.Duration) (time.Duration, error) {
+	if duration == "" {
+		return defaultDuration, nil
+	}
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return 0, errors.Errorf(errors.CodeBadRequest, "%s '%s' is not a valid duration: %v", field, duration, err)
+	}
+	if d < 0 {
+		return 0, errors.Errorf(errors.CodeBadRequest, "%s '%s' must not be negative", field, duration)
+	}
+	return d, nil
+}
+
+// PodMetadataConfig configures the labels and annotations of the pods created by the controller.
+// In order of precedence (lowest first), pods receive the static labels and annotations, those
+// propagated from the workflow, then the workflow's spec.podMetadata. Labels and annotations used
+// by the controller itself cannot be overridden. For instance, the annotation
+// 'sidecar.istio.io/inject: "false"' prevents the injection of the proxy of the Istio service mesh,
+// which does not exit by itself (see inferLingeringPodResult).
+type PodMetadataConfig struct {
+	// Labels and Annotations are applied to all pods
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// PropagateLabels and PropagateAnnotations are the keys of the labels and annotations which are
+	// copied from a workflow to its pods, when present on the workflow
+	PropagateLabels      []string `json:"propagateLabels,omitempty"`
+	PropagateAnnotations []string `json:"propagateAnnotations,omitempty"`
+}
+
+// SecurityContextConfig holds the default security contexts of the pods created by the controller
+type SecurityContextConfig struct {
+	// Pod is the security context of the pods (e.g. runAsNonRoot, runAsUser, fsGroup)
+	Pod *apiv1.PodSecurityContext `json:"pod,omitempty"`
+
+	// Container is the security context of the main and sidecar containers which do not specify their own
+	// (e.g. dropped capabilities, allowPrivilegeEscalation)
+	Container *apiv1.SecurityContext `json:"container,omitempty"`
+
+	// Executor is the security context of the init and wait containers injected by the controller (e.g.
+	// readOnlyRootFilesystem). Defaults to the container security context. Executor containers are never
+	// privileged, regardless of this setting.
+	Executor *apiv1.SecurityContext `json:"executor,omitempty"`
+}
+
+// ContainerDefaultsConfig holds the settings applied to all containers of the pods created by the controller
+type ContainerDefaultsConfig struct {
+	// Env are environment variables (e.g. HTTP_PROXY) added to the main, sidecar and executor containers. The
+	// variables of a container take precedence over the default variables of the same name.
+	Env []apiv1.EnvVar `json:"env,omitempty"`
 }
 
 // NewWorkflowController instantiates a new WorkflowController
 func NewWorkflowController(config *rest.Config, configMap string) *WorkflowController {
+	// the API calls of the clients are counted by the controller metrics
+	apiRequests := newAPIRequestsCounter()
+	clientConfig := instrumentConfig(config, apiRequests)
+
 	// make a new config for our extension's API group, using the first config as a baseline
-	clientset, err := kubernetes.NewForConfig(config)
+	clientset, err := kubernetes.NewForConfig(clientConfig)
 	if err != nil {
 		panic(err)
 	}
 
-	restClient, scheme, err := workflowclient.NewRESTClient(config)
+	restClient, scheme, err := workflowclient.NewRESTClient(clientConfig)
 	if err != nil {
 		panic(err)
 	}
 
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+
 	wfc := WorkflowController{
-		restClient:        restClient,
-		restConfig:        config,
-		clientset:         clientset,
-		scheme:            scheme,
-		ConfigMap:         configMap,
-		wfUpdates:         make(chan *wfv1.Workflow, 10240),
-		podUpdates:        make(chan *apiv1.Pod, 102400),
-		completedPodCache: gocache.New(1*time.Hour, 10*time.Minute),
+		restClient:         restClient,
+		restConfig:         config,
+		clientset:          clientset,
+		dynamicClientPool:  dynamic.NewDynamicClientPool(clientConfig),
+		scheme:             scheme,
+		ConfigMap:          configMap,
+		podQueue:           workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "pod_queue"),
+		ttlQueue:           workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "ttl_queue"),
+		nodeUpdateQueue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "node_update_queue"),
+		pendingNodeUpdates: make(map[string]map[string]podNodeUpdate),
+		completedPodCache:  gocache.New(defaultCompletedPodCacheTTL, 0), // cleaned up by runCompletedPodCacheCleanup
+		podStateCache:      gocache.New(1*time.Hour, 10*time.Minute),
+		podUnknownCache:    gocache.New(1*time.Hour, 10*time.Minute),
+		eventRecorder:      eventBroadcaster.NewRecorder(scheme, apiv1.EventSource{Component: "workflow-controller"}),
 	}
+	// workflows are operated on in order of priority
+	wfc.wfQueue = newPriorityQueue(wfc.getWorkflowPriority, newJitterRateLimiter(workqueue.NewItemExponentialFailureRateLimiter(operateRetryBaseDelay, operateRetryMaxDelay), operateRetryJitter))
+	wfc.metrics = newControllerMetrics(&wfc, apiRequests)
+	wfc.completedPodCache.OnEvicted(func(string, interface{}) {
+		wfc.metrics.completedPodCacheEvictions.Inc()
+	})
 	return &wfc
 }
 
 // Run starts an Workflow resource controller
 func (wfc *WorkflowController) Run(ctx context.Context) error {
-	wfc.StartStatsTicker(5 * time.Minute)
-
-	log.Info("Watch Workflow controller config map updates")
-	_, err := wfc.watchControllerConfigMap(ctx)
+	statsInterval, err := wfc.getConfig().getStatsInterval()
 	if err != nil {
-		log.Errorf("Failed to register watch for controller config map: %v", err)
-		return err
+		// the config is validated when loaded
+		statsInterval = defaultStatsInterval
+	}
+	if statsInterval > 0 {
+		wfc.StartStatsTicker(ctx, statsInterval)
 	}
+	wfc.runMetricsServer(ctx)
+	wfc.runHealthServer(ctx)
+	go wfc.runCompletedPodCacheCleanup(ctx)
 
+	if wfc.ConfigFile == "" {
+		log.Info("Watch Workflow controller config map updates")
+		_, err := wfc.watchControllerConfigMap(ctx)
+		if err != nil {
+			log.Errorf("Failed to register watch for controller config map: %v", err)
+			return err
+		}
+	}
+
+	if wfc.getConfig().LeaderElection != nil {
+		return wfc.runWithLeaderElection(ctx)
+	}
+	return wfc.runLeader(ctx)
+}
+
+// runLeader watches workflows and their pods, and operates on them until the context is done.
+// When leader election is enabled, this is only invoked by the elected leader.
+func (wfc *WorkflowController) runLeader(ctx context.Context) error {
+	if wfc.watchNamespace() == metav1.NamespaceAll {
+		log.Info("Watching workflows in all namespaces")
+	} else {
+		log.Infof("Watching workflows in namespace %s", wfc.watchNamespace())
+	}
+	config := wfc.getConfig()
+	if config.DryRun {
+		log.Warn("Running in dry-run mode. No pods will be created and no workflows will be updated")
+	}
 	log.Info("Watch Workflow objects")
 
 	// Watch Workflow objects
-	_, err = wfc.watchWorkflows(ctx)
+	wfInformer, err := wfc.watchWorkflows(ctx)
 	if err != nil {
 		log.Errorf("Failed to register watch for Workflow resource: %v", err)
 		return err
 	}
 
 	// Watch pods related to workflows
-	_, err = wfc.watchWorkflowPods(ctx)
+	podInformer, err := wfc.watchWorkflowPods(ctx)
 	if err != nil {
 		log.Errorf("Failed to register watch for Workflow resource: %v", err)
 		return err
 	}
+	wfc.setInformers(wfInformer, podInformer)
+	defer wfc.setInformers(nil, nil)
 
-	i := 0
-	for {
-		if i%100 == 0 {
-			// periodically print the channel sizes
-			i += 1
-			log.Infof("wfChan=%d/%d podChan=%d/%d", len(wfc.wfUpdates), cap(wfc.wfUpdates), len(wfc.podUpdates), cap(wfc.podUpdates))
-		}
-		select {
-		case wf := <-wfc.wfUpdates:
-			wfc.operateWorkflow(wf)
-		case pod := <-wfc.podUpdates:
-			wfc.handlePodUpdate(pod)
-		}
+	// Watch WorkflowTemplates, which must be cached before workflows referring to them are operated on
+	wftmplInformer := wfc.watchWorkflowTemplates(ctx)
+	cronWfInformer := wfc.watchCronWorkflows(ctx)
+
+	// Wait for the initial lists of the incomplete workflows and their pods, so that upon (re)start every
+	// incomplete workflow is reconciled once against the current state of its pods, as soon as the workers
+	// start, rather than its status remaining stale until the next resync
+	if !cache.WaitForCacheSync(ctx.Done(), wftmplInformer.HasSynced, cronWfInformer.HasSynced, wfInformer.HasSynced, podInformer.HasSynced) {
+		return ctx.Err()
+	}
+	log.Infof("Reconciling %d incomplete workflows", len(wfc.wfStore.ListKeys()))
+	wfc.requeueAllWorkflows()
+
+	wfc.runTTLController(ctx)
+	go wfc.monitorQueueDepths(ctx)
+	go wfc.runNodePhaseMetrics(ctx)
+	go wfc.runCronWorkflows(ctx)
+
+	workflowWorkers := config.WorkflowWorkers
+	if workflowWorkers <= 0 {
+		workflowWorkers = defaultWorkflowWorkers
+	}
+	podWorkers := config.PodWorkers
+	if podWorkers <= 0 {
+		podWorkers = defaultPodWorkers
+	}
+	if config.MaxConcurrentOperations > 0 {
+		log.Infof("Limiting concurrent workflow operations to %d", config.MaxConcurrentOperations)
+		wfc.operateSemaphore = make(chan struct{}, config.MaxConcurrentOperations)
+	} else {
+		wfc.operateSemaphore = nil
+	}
+	log.Infof("Starting %d workflow workers and %d pod workers", workflowWorkers, podWorkers)
+	var wg sync.WaitGroup
+	for i := 0; i < workflowWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for wfc.processNextWorkflowItem() {
+			}
+		}()
+	}
+	for i := 0; i < podWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for wfc.processNextPodItem() {
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for wfc.processNextNodeUpdate() {
+			}
+		}()
 	}
 
 	<-ctx.Done()
+	log.Infof("Workflow controller shutting down: %v", ctx.Err())
+	wfc.drainQueues(&wg, shutdownDrainTimeout)
 	return ctx.Err()
 }
 
-// ResyncConfig reloads the controller config from the configmap
+// getWorkflowPriority returns the priority of the workflow of the given key, used to order the workflow queue
+func (wfc *WorkflowController) getWorkflowPriority(key interface{}) int32 {
+	if wfc.wfStore == nil {
+		return 0
+	}
+	obj, exists, err := wfc.wfStore.GetByKey(key.(string))
+	if err != nil || !exists {
+		return 0
+	}
+	wf, ok := obj.(*wfv1.Workflow)
+	if !ok || wf.Spec.Priority == nil {
+		return 0
+	}
+	return *wf.Spec.Priority
+}
+
+// processNextWorkflowItem dequeues a single workflow key and operates on the workflow.
+// Returns false when the queue has been shut down.
+func (wfc *WorkflowController) processNextWorkflowItem() bool {
+	key, quit := wfc.wfQueue.Get()
+	if quit {
+		return false
+	}
+	defer wfc.wfQueue.Done(key)
+
+	obj, exists, err := wfc.wfStore.GetByKey(key.(string))
+	if err != nil {
+		log.Errorf("Failed to get workflow '%s' from informer index: %+v", key, err)
+		wfc.wfQueue.AddRateLimited(key)
+		return true
+	}
+	if !exists {
+		// workflow was deleted since it was enqueued
+		wfc.wfQueue.Forget(key)
+		return true
+	}
+	wf, ok := obj.(*wfv1.Workflow)
+	if !ok {
+		log.Warnf("Key '%s' in index is not a workflow", key)
+		wfc.wfQueue.Forget(key)
+		return true
+	}
+	if wait := wfc.apiCircuitWait(); wait > 0 {
+		// the workflow is operated on once the API server is healthy again
+		wfc.wfQueue.AddAfter(key, wait)
+		return true
+	}
+	wfc.acquireOperateSemaphore()
+	defer wfc.releaseOperateSemaphore()
+	err = wfc.operateWorkflow(wf)
+	if err != nil {
+		wfc.handleOperateFailure(key.(string), err)
+		return true
+	}
+	wfc.wfQueue.Forget(key)
+	return true
+}
+
+// handleOperateFailure retries the operation of a workflow which failed, with an exponentially increasing
+// (jittered) delay. Once the operation failed the maximum number of times in a row, the workflow is errored
+// and cleaned up, so that it is no longer retried. Transient errors are retried without being counted.
+func (wfc *WorkflowController) handleOperateFailure(key string, operateErr error) {
+	if isTransientError(operateErr) {
+		log.Warnf("Operation of workflow '%s' failed transiently, retrying: %v", key, operateErr)
+		wfc.wfQueue.AddAfter(key, operateRetryBaseDelay)
+		return
+	}
+	maxFailures := wfc.getConfig().MaxOperateFailures
+	if maxFailures == 0 {
+		maxFailures = defaultMaxOperateFailures
+	}
+	failures := wfc.wfQueue.NumRequeues(key) + 1
+	if failures < maxFailures {
+		log.Warnf("Operation of workflow '%s' failed %d time(s), retrying with backoff: %v", key, failures, operateErr)
+		wfc.wfQueue.AddRateLimited(key)
+		return
+	}
+	wfc.wfQueue.Forget(key)
+	log.Errorf("Operation of workflow '%s' failed %d times, erroring it: %v", key, failures, operateErr)
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		log.Errorf("Invalid workflow key '%s': %v", key, err)
+		return
+	}
+	wfClient := workflowclient.NewWorkflowClient(wfc.restClient, wfc.scheme, namespace)
+	var woc *wfOperationCtx
+	err = retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		wf, err := wfClient.GetWorkflow(name)
+		if err != nil {
+			return err
+		}
+		woc = newWorkflowOperationCtx(wf, wfc)
+		woc.abortWorkflow(errors.InternalErrorf("workflow could not be operated on after %d attempts: %v", failures, operateErr))
+		if woc.config.skipInDryRun(woc.log, "update of errored workflow") {
+			woc = nil
+			return nil
+		}
+		_, err = wfClient.UpdateWorkflow(woc.wf)
+		return err
+	})
+	wfc.recordAPIResult(err)
+	if err != nil {
+		if !apierr.IsNotFound(err) {
+			log.Errorf("Failed to error workflow '%s': %v", key, err)
+			wfc.wfQueue.AddRateLimited(key)
+		}
+		return
+	}
+	if woc == nil {
+		return
+	}
+	wfc.enqueueTTL(woc.wf)
+	if woc.wf.ObjectMeta.Labels[common.LabelKeyCompleted] == "true" {
+		woc.reportCompletion()
+	}
+	woc.requeue()
+}
+
+// isTransientError returns whether an error is expected to resolve by itself upon retrying, like a conflicting
+// update, or an API server which is briefly unavailable or throttling requests
+func isTransientError(err error) bool {
+	err = errors.Cause(err)
+	return apierr.IsConflict(err) || apierr.IsServerTimeout(err) || apierr.IsTimeout(err) || apierr.IsTooManyRequests(err) || isAPIServerError(err)
+}
+
+// acquireOperateSemaphore blocks until a workflow may be operated on, when concurrent operations are limited
+func (wfc *WorkflowController) acquireOperateSemaphore() {
+	if wfc.operateSemaphore != nil {
+		wfc.operateSemaphore <- struct{}{}
+	}
+}
+
+// releaseOperateSemaphore allows another workflow to be operated on, when concurrent operations are limited
+func (wfc *WorkflowController) releaseOperateSemaphore() {
+	if wfc.operateSemaphore != nil {
+		<-wfc.operateSemaphore
+	}
+}
+
+// processNextPodItem dequeues a single pod key and handles the pod update.
+// Returns false when the queue has been shut down.
+func (wfc *WorkflowController) processNextPodItem() bool {
+	key, quit := wfc.podQueue.Get()
+	if quit {
+		return false
+	}
+	defer wfc.podQueue.Done(key)
+
+	obj, exists, err := wfc.podStore.GetByKey(key.(string))
+	if err != nil {
+		log.Errorf("Failed to get pod '%s' from informer index: %+v", key, err)
+		wfc.podQueue.AddRateLimited(key)
+		return true
+	}
+	wfc.podQueue.Forget(key)
+	if !exists {
+		// pod was deleted since it was enqueued
+		return true
+	}
+	pod, ok := obj.(*apiv1.Pod)
+	if !ok {
+		log.Warnf("Key '%s' in index is not a pod", key)
+		return true
+	}
+	wfc.handlePodUpdate(pod)
+	return true
+}
+
+// drainQueues waits for the workers to process the keys remaining in the queues during shutdown,
+// then shuts down the queues. Gives up on the remaining keys if they could not be processed within the timeout.
+func (wfc *WorkflowController) drainQueues(wg *sync.WaitGroup, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	// Pending node updates are only added to the node update queue at the end of their batch window
+	for wfc.wfQueue.Len() > 0 || wfc.podQueue.Len() > 0 || wfc.countPendingNodeUpdates() > 0 {
+		if time.Now().After(deadline) {
+			log.Warnf("Timed out (%v) draining queues. Abandoning wfQueue=%d podQueue=%d pendingNodeUpdates=%d",
+				timeout, wfc.wfQueue.Len(), wfc.podQueue.Len(), wfc.countPendingNodeUpdates())
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	wfc.wfQueue.ShutDown()
+	wfc.podQueue.ShutDown()
+	wfc.nodeUpdateQueue.ShutDown()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Infof("Drained all workflow and pod updates")
+	case <-time.After(time.Until(deadline)):
+		log.Warnf("Timed out (%v) waiting for in-flight workflow and pod updates", timeout)
+	}
+}
+
+// ResyncConfig reloads the controller config from the config file if set, or otherwise from the configmap
 func (wfc *WorkflowController) ResyncConfig() error {
 	namespace, _ := os.LookupEnv(common.EnvVarNamespace)
 	if namespace == "" {
 		namespace = common.DefaultControllerNamespace
 	}
+	if wfc.ConfigFile != "" {
+		configBytes, err := ioutil.ReadFile(wfc.ConfigFile)
+		if err != nil {
+			return errors.InternalWrapError(err)
+		}
+		// the namespace is still needed for the resources of the controller (e.g. its leader election lock)
+		wfc.ConfigMapNS = namespace
+		// The file holds the config as stored under the key of the configmap, so that it is validated alike
+		return wfc.updateConfig(&apiv1.ConfigMap{
+			Data: map[string]string{common.WorkflowControllerConfigMapKey: string(configBytes)},
+		})
+	}
 	cmClient := wfc.clientset.CoreV1().ConfigMaps(namespace)
 	cm, err := cmClient.Get(wfc.ConfigMap, metav1.GetOptions{})
 	if err != nil {
@@ -163,36 +857,311 @@ func (wfc *WorkflowController) ResyncConfig() error {
 	return wfc.updateConfig(cm)
 }
 
+// updateConfig replaces the controller config with the config in the configmap. The config is only
+// replaced if it is entirely valid, otherwise an error is returned and the current config is kept.
 func (wfc *WorkflowController) updateConfig(cm *apiv1.ConfigMap) error {
+	config, err := wfc.parseConfig(cm)
+	if err != nil {
+		return err
+	}
+	log.Printf("workflow controller configuration from %s:\n%s", wfc.configSource(), cm.Data[common.WorkflowControllerConfigMapKey])
+	wasMaintenance := wfc.getConfig().Maintenance
+	wfc.configLock.Lock()
+	wfc.config = config
+	wfc.configLock.Unlock()
+	atomic.StoreInt32(&wfc.configLoaded, 1)
+	if config.Maintenance && !wasMaintenance {
+		log.Warnf("Entered maintenance mode: no pods will be created until maintenance is turned off in %s", wfc.configSource())
+	} else if !config.Maintenance && wasMaintenance {
+		log.Infof("Exited maintenance mode: resuming the creation of pods")
+		wfc.requeueAllWorkflows()
+	}
+	wfc.checkArtifactRepositorySecrets(config)
+	return nil
+}
+
+// getConfig returns the current controller config, which must not be modified. The default config is
+// returned until a config is loaded.
+func (wfc *WorkflowController) getConfig() *WorkflowControllerConfig {
+	wfc.configLock.RLock()
+	defer wfc.configLock.RUnlock()
+	if wfc.config == nil {
+		return &WorkflowControllerConfig{}
+	}
+	return wfc.config
+}
+
+// requeueAllWorkflows queues all incomplete workflows to be operated on, e.g. so that the pods deferred
+// during maintenance mode are created
+func (wfc *WorkflowController) requeueAllWorkflows() {
+	if wfc.wfStore == nil || wfc.wfQueue == nil {
+		// the workflows are not being watched yet
+		return
+	}
+	for _, key := range wfc.wfStore.ListKeys() {
+		wfc.wfQueue.Add(key)
+	}
+}
+
+// artifactRepositoryOf returns the artifact repository of a workflow: its spec.artifactRepository, which
+// overrides the artifact repository of the config as a whole, or otherwise that of the config
+func (c *WorkflowControllerConfig) artifactRepositoryOf(wf *wfv1.Workflow) *wfv1.ArtifactRepository {
+	if wf.Spec.ArtifactRepository != nil {
+		return wf.Spec.ArtifactRepository
+	}
+	return &c.ArtifactRepository
+}
+
+// GetArtifactRepository returns a copy of the effective artifact repository of a workflow, whose key
+// prefixes are resolved for the workflow, as used for the outputs of its pods. If the workflow is nil,
+// the artifact repository of the config is returned as is.
+func (wfc *WorkflowController) GetArtifactRepository(wf *wfv1.Workflow) (*wfv1.ArtifactRepository, error) {
+	config := wfc.getConfig()
+	repo := &config.ArtifactRepository
+	if wf != nil {
+		repo = config.artifactRepositoryOf(wf)
+	}
+	repoBytes, err := json.Marshal(repo)
+	if err != nil {
+		return nil, errors.InternalWrapError(err)
+	}
+	var repoCopy wfv1.ArtifactRepository
+	err = json.Unmarshal(repoBytes, &repoCopy)
+	if err != nil {
+		return nil, errors.InternalWrapError(err)
+	}
+	if wf == nil {
+		return &repoCopy, nil
+	}
+	if repoCopy.S3 != nil {
+		repoCopy.S3.KeyPrefix, err = common.ResolveArtifactKeyPrefix(repoCopy.S3.KeyPrefix, wf)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if repoCopy.AzureBlob != nil {
+		repoCopy.AzureBlob.KeyPrefix, err = common.ResolveArtifactKeyPrefix(repoCopy.AzureBlob.KeyPrefix, wf)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &repoCopy, nil
+}
+
+// checkArtifactRepositorySecrets verifies the secrets referenced by the configured artifact repository
+// exist, so that a misconfiguration is reported when the config is loaded rather than as artifact save
+// errors of workflows. Since the secrets are read in the namespace of each workflow, they are checked
+// in the namespace watched by the controller, or the controller's own namespace when watching all.
+// Missing secrets are logged but do not reject the config, since they may be created later.
+func (wfc *WorkflowController) checkArtifactRepositorySecrets(config *WorkflowControllerConfig) {
+	namespace := config.Namespace
+	if namespace == "" {
+		namespace = wfc.ConfigMapNS
+	}
+	if namespace == "" {
+		return
+	}
+	err := validateArtifactRepositorySecrets(wfc.clientset, namespace, "artifactRepository", &config.ArtifactRepository)
+	if err != nil {
+		log.Errorf("%s references an invalid artifact repository secret: %v", wfc.configSource(), err)
+	}
+}
+
+// isClusterScopedResourceAllowed returns whether resource templates may create cluster-scoped resources of the kind
+func (c *WorkflowControllerConfig) isClusterScopedResourceAllowed(groupKind schema.GroupKind) bool {
+	for _, allowed := range c.AllowedClusterScopedResources {
+		if allowed == groupKind.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// isExecutorImageAllowed returns whether workflows may use the executor image instead of the configured one
+func (c *WorkflowControllerConfig) isExecutorImageAllowed(image string) bool {
+	for _, allowed := range c.AllowedExecutorImages {
+		if allowed == image {
+			return true
+		}
+	}
+	return false
+}
+
+// validatePullPolicy verifies an image pull policy of the config is empty or valid
+func validatePullPolicy(field string, pullPolicy apiv1.PullPolicy) error {
+	switch pullPolicy {
+	case "", apiv1.PullAlways, apiv1.PullIfNotPresent, apiv1.PullNever:
+		return nil
+	}
+	return fmt.Errorf("%s '%s' is invalid. Must be one of: %s, %s, %s", field, pullPolicy, apiv1.PullAlways, apiv1.PullIfNotPresent, apiv1.PullNever)
+}
+
+// configSource describes where the controller config is read from, for messages
+func (wfc *WorkflowController) configSource() string {
+	if wfc.ConfigFile != "" {
+		return fmt.Sprintf("config file '%s'", wfc.ConfigFile)
+	}
+	return fmt.Sprintf("ConfigMap '%s'", wfc.ConfigMap)
+}
+
+// parseConfig unmarshals and validates the controller config in the configmap
+func (wfc *WorkflowController) parseConfig(cm *apiv1.ConfigMap) (*WorkflowControllerConfig, error) {
 	configStr, ok := cm.Data[common.WorkflowControllerConfigMapKey]
 	if !ok {
-		return errors.Errorf(errors.CodeBadRequest, "ConfigMap '%s' does not have key '%s'", wfc.ConfigMap, common.WorkflowControllerConfigMapKey)
+		return nil, errors.Errorf(errors.CodeBadRequest, "%s does not have key '%s'", wfc.configSource(), common.WorkflowControllerConfigMapKey)
 	}
 	var config WorkflowControllerConfig
 	err := yaml.Unmarshal([]byte(configStr), &config)
 	if err != nil {
-		return errors.InternalWrapError(err)
+		return nil, errors.InternalWrapError(err)
 	}
-	log.Printf("workflow controller configuration from %s:\n%s", wfc.ConfigMap, configStr)
 	if config.ExecutorImage == "" {
-		return errors.Errorf(errors.CodeBadRequest, "ConfigMap '%s' does not have executorImage", wfc.ConfigMap)
+		return nil, errors.Errorf(errors.CodeBadRequest, "%s does not have executorImage", wfc.configSource())
 	}
-	wfc.Config = config
-	return nil
+	err = validatePullPolicy("executorImagePullPolicy", config.ExecutorImagePullPolicy)
+	if err == nil {
+		err = validatePullPolicy("mainImagePullPolicy", config.MainImagePullPolicy)
+	}
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "%s %s", wfc.configSource(), err.Error())
+	}
+	if config.ForceMainImagePullPolicy && config.MainImagePullPolicy == "" {
+		return nil, errors.Errorf(errors.CodeBadRequest, "%s forceMainImagePullPolicy requires a mainImagePullPolicy", wfc.configSource())
+	}
+	_, err = config.getWorkflowResyncPeriod()
+	if err != nil {
+		return nil, err
+	}
+	_, err = config.getPodResyncPeriod()
+	if err != nil {
+		return nil, err
+	}
+	_, err = config.getPodPendingThreshold()
+	if err != nil {
+		return nil, err
+	}
+	_, err = config.getPodUnknownThreshold()
+	if err != nil {
+		return nil, err
+	}
+	_, err = config.getCompletedPodCacheTTL()
+	if err != nil {
+		return nil, err
+	}
+	_, err = config.getCompletedPodCacheCleanupInterval()
+	if err != nil {
+		return nil, err
+	}
+	if config.CompletedPodCacheMaxEntries < 0 {
+		return nil, errors.Errorf(errors.CodeBadRequest, "%s completedPodCacheMaxEntries must not be negative", wfc.configSource())
+	}
+	_, err = config.getStatsInterval()
+	if err != nil {
+		return nil, err
+	}
+	if config.PodFieldSelector != "" {
+		_, err = fields.ParseSelector(config.PodFieldSelector)
+		if err != nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "%s podFieldSelector '%s' is invalid: %v", wfc.configSource(), config.PodFieldSelector, err)
+		}
+	}
+	if config.PodLabelSelector != "" {
+		_, err = labels.Parse(config.PodLabelSelector)
+		if err != nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "%s podLabelSelector '%s' is invalid: %v", wfc.configSource(), config.PodLabelSelector, err)
+		}
+	}
+	if config.ServiceAccountName != "" {
+		if errs := validation.IsDNS1123Subdomain(config.ServiceAccountName); len(errs) > 0 {
+			return nil, errors.Errorf(errors.CodeBadRequest, "%s serviceAccountName '%s' is invalid: %s", wfc.configSource(), config.ServiceAccountName, strings.Join(errs, ", "))
+		}
+	}
+	if config.GetWorkflowRetries < -1 {
+		return nil, errors.Errorf(errors.CodeBadRequest, "%s getWorkflowRetries must not be less than -1", wfc.configSource())
+	}
+	if errs := validation.IsValidLabelValue(config.InstanceID); len(errs) > 0 {
+		return nil, errors.Errorf(errors.CodeBadRequest, "%s instanceID '%s' is invalid: %s", wfc.configSource(), config.InstanceID, strings.Join(errs, ", "))
+	}
+	if config.MaxOperateFailures < 0 {
+		return nil, errors.Errorf(errors.CodeBadRequest, "%s maxOperateFailures must not be negative", wfc.configSource())
+	}
+	if config.MaxConcurrentOperations < 0 {
+		return nil, errors.Errorf(errors.CodeBadRequest, "%s maxConcurrentOperations must not be negative", wfc.configSource())
+	}
+	if config.MaxWorkflowNodes < 0 {
+		return nil, errors.Errorf(errors.CodeBadRequest, "%s maxWorkflowNodes must not be negative", wfc.configSource())
+	}
+	if config.Parallelism < 0 {
+		return nil, errors.Errorf(errors.CodeBadRequest, "%s parallelism must not be negative", wfc.configSource())
+	}
+	switch config.WaitContainerFailurePhase {
+	case "", wfv1.NodeError, wfv1.NodeFailed:
+	default:
+		return nil, errors.Errorf(errors.CodeBadRequest, "%s waitContainerFailurePhase must be %s or %s", wfc.configSource(), wfv1.NodeError, wfv1.NodeFailed)
+	}
+	if config.APIErrorThreshold < 0 {
+		return nil, errors.Errorf(errors.CodeBadRequest, "%s apiErrorThreshold must not be negative", wfc.configSource())
+	}
+	_, err = config.getAPIErrorCooldown()
+	if err != nil {
+		return nil, err
+	}
+	err = validatePodGCStrategy(config.PodGC)
+	if err != nil {
+		return nil, err
+	}
+	err = common.ValidateArtifactRepository("artifactRepository", &config.ArtifactRepository)
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "%s %s", wfc.configSource(), err.Error())
+	}
+	return &config, nil
+}
+
+// skipInDryRun returns whether or not the controller is in dry-run mode, in which case the mutating
+// action described by format and args is logged to logCtx instead of being performed
+func (wfc *WorkflowController) skipInDryRun(logCtx log.FieldLogger, format string, args ...interface{}) bool {
+	return wfc.getConfig().skipInDryRun(logCtx, format, args...)
 }
 
-// addLabelSelectors adds label selectors from the workflow controller's config
-func (wfc *WorkflowController) addLabelSelectors(req *rest.Request) *rest.Request {
-	for label, labelVal := range wfc.Config.MatchLabels {
-		req = req.Param("labelSelector", fmt.Sprintf("%s=%s", label, labelVal))
+// skipInDryRun returns whether or not the config enables dry-run mode (see WorkflowController.skipInDryRun)
+func (c *WorkflowControllerConfig) skipInDryRun(logCtx log.FieldLogger, format string, args ...interface{}) bool {
+	if !c.DryRun {
+		return false
+	}
+	logCtx.Infof("Dry run: skipping "+format, args...)
+	return true
+}
+
+// watchNamespace returns the namespace in which workflows and pods are watched.
+// Returns metav1.NamespaceAll if the controller operates across all namespaces.
+func (wfc *WorkflowController) watchNamespace() string {
+	namespace := wfc.getConfig().Namespace
+	if namespace == "" {
+		return metav1.NamespaceAll
 	}
-	return req
+	return namespace
+}
+
+// labelSelector returns a label selector of the given requirements, along with the requirements from the
+// workflow controller's config: the match labels, and the controller instance id. A controller without an
+// instance id only selects objects which have no instance id label.
+func (wfc *WorkflowController) labelSelector(requirements ...string) string {
+	config := wfc.getConfig()
+	for label, labelVal := range config.MatchLabels {
+		requirements = append(requirements, fmt.Sprintf("%s=%s", label, labelVal))
+	}
+	if config.InstanceID != "" {
+		requirements = append(requirements, fmt.Sprintf("%s=%s", common.LabelKeyControllerInstanceID, config.InstanceID))
+	} else {
+		requirements = append(requirements, fmt.Sprintf("!%s", common.LabelKeyControllerInstanceID))
+	}
+	return strings.Join(requirements, ",")
 }
 
 func (wfc *WorkflowController) newWorkflowWatch() *cache.ListWatch {
 	c := wfc.restClient
 	resource := wfv1.CRDPlural
-	namespace := wfc.Config.Namespace
+	namespace := wfc.watchNamespace()
 	fieldSelector := fields.Everything()
 
 	listFunc := func(options metav1.ListOptions) (runtime.Object, error) {
@@ -200,9 +1169,8 @@ func (wfc *WorkflowController) newWorkflowWatch() *cache.ListWatch {
 		req := c.Get().
 			Namespace(namespace).
 			Resource(resource).
-			Param("labelSelector", fmt.Sprintf("%s notin (true)", common.LabelKeyCompleted)).
+			Param("labelSelector", wfc.labelSelector(fmt.Sprintf("%s notin (true)", common.LabelKeyCompleted))).
 			VersionedParams(&options, metav1.ParameterCodec)
-		req = wfc.addLabelSelectors(req)
 		return req.Do().Get()
 	}
 	watchFunc := func(options metav1.ListOptions) (watch.Interface, error) {
@@ -211,43 +1179,47 @@ func (wfc *WorkflowController) newWorkflowWatch() *cache.ListWatch {
 		req := c.Get().
 			Namespace(namespace).
 			Resource(resource).
-			Param("labelSelector", fmt.Sprintf("%s notin (true)", common.LabelKeyCompleted)).
+			Param("labelSelector", wfc.labelSelector(fmt.Sprintf("%s notin (true)", common.LabelKeyCompleted))).
 			VersionedParams(&options, metav1.ParameterCodec)
-		req = wfc.addLabelSelectors(req)
 		return req.Watch()
 	}
 	return &cache.ListWatch{ListFunc: listFunc, WatchFunc: watchFunc}
 }
 
 func (wfc *WorkflowController) watchWorkflows(ctx context.Context) (cache.Controller, error) {
+	resyncPeriod, err := wfc.getConfig().getWorkflowResyncPeriod()
+	if err != nil {
+		return nil, err
+	}
 	source := wfc.newWorkflowWatch()
-	_, controller := cache.NewInformer(
+	var controller cache.Controller
+	wfc.wfStore, controller = cache.NewInformer(
 		source,
 		&wfv1.Workflow{},
-		workflowResyncPeriod,
+		resyncPeriod,
 		cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
-				wf, ok := obj.(*wfv1.Workflow)
-				if ok {
-					wfc.wfUpdates <- wf
+				key, err := cache.MetaNamespaceKeyFunc(obj)
+				if err == nil {
+					wfc.wfQueue.Add(key)
 				} else {
-					log.Warn("Watch received unusable workflow")
+					log.Warnf("Watch received unusable workflow: %v", err)
 				}
 			},
 			UpdateFunc: func(old, new interface{}) {
-				wf, ok := new.(*wfv1.Workflow)
-				if ok {
-					wfc.wfUpdates <- wf
+				key, err := cache.MetaNamespaceKeyFunc(new)
+				if err == nil {
+					wfc.wfQueue.Add(key)
 				} else {
-					log.Warn("Watch received unusable workflow")
+					log.Warnf("Watch received unusable workflow: %v", err)
 				}
 			},
 			DeleteFunc: func(obj interface{}) {
-				wf, ok := obj.(*wfv1.Workflow)
-				if ok {
-					wfc.wfUpdates <- wf
+				key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+				if err == nil {
+					wfc.wfQueue.Add(key)
 				} else {
-					log.Warn("Watch received unusable workflow")
+					log.Warnf("Watch received unusable workflow: %v", err)
 				}
 			},
 		})
@@ -265,19 +1237,13 @@ func (wfc *WorkflowController) watchControllerConfigMap(ctx context.Context) (ca
 			AddFunc: func(obj interface{}) {
 				if cm, ok := obj.(*apiv1.ConfigMap); ok {
 					log.Infof("Detected ConfigMap update. Updating the controller config.")
-					err := wfc.updateConfig(cm)
-					if err != nil {
-						log.Errorf("Update of config failed due to: %v", err)
-					}
+					wfc.reloadConfig(cm)
 				}
 			},
 			UpdateFunc: func(old, new interface{}) {
 				if newCm, ok := new.(*apiv1.ConfigMap); ok {
 					log.Infof("Detected ConfigMap update. Updating the controller config.")
-					err := wfc.updateConfig(newCm)
-					if err != nil {
-						log.Errorf("Update of config failed due to: %v", err)
-					}
+					wfc.reloadConfig(newCm)
 				}
 			},
 		})
@@ -286,6 +1252,16 @@ func (wfc *WorkflowController) watchControllerConfigMap(ctx context.Context) (ca
 	return controller, nil
 }
 
+// reloadConfig updates the controller config upon a change to the configmap. An invalid config is
+// rejected, and the controller continues to run with the last valid config.
+func (wfc *WorkflowController) reloadConfig(cm *apiv1.ConfigMap) {
+	err := wfc.updateConfig(cm)
+	if err != nil {
+		wfc.metrics.configUpdateErrors.Inc()
+		log.Errorf("Rejected invalid config in ConfigMap '%s', continuing with the last valid config: %v", wfc.ConfigMap, err)
+	}
+}
+
 func (wfc *WorkflowController) newControllerConfigMapWatch() *cache.ListWatch {
 	c := wfc.clientset.Core().RESTClient()
 	resource := "configmaps"
@@ -315,64 +1291,85 @@ func (wfc *WorkflowController) newControllerConfigMapWatch() *cache.ListWatch {
 func (wfc *WorkflowController) newWorkflowPodWatch() *cache.ListWatch {
 	c := wfc.clientset.Core().RESTClient()
 	resource := "pods"
-	namespace := wfc.Config.Namespace
-	fieldSelector := fields.Everything()
+	namespace := wfc.watchNamespace()
 
 	listFunc := func(options metav1.ListOptions) (runtime.Object, error) {
-		options.FieldSelector = fieldSelector.String()
+		options.FieldSelector = wfc.podFieldSelector()
 		req := c.Get().
 			Namespace(namespace).
 			Resource(resource).
-			Param("labelSelector", fmt.Sprintf("%s=false", common.LabelKeyCompleted)).
-			Param("fieldSelector", "status.phase!=Pending").
+			Param("labelSelector", wfc.podLabelSelector()).
 			VersionedParams(&options, metav1.ParameterCodec)
-		req = wfc.addLabelSelectors(req)
 		return req.Do().Get()
 	}
 	watchFunc := func(options metav1.ListOptions) (watch.Interface, error) {
 		options.Watch = true
-		options.FieldSelector = fieldSelector.String()
+		options.FieldSelector = wfc.podFieldSelector()
 		req := c.Get().
 			Namespace(namespace).
 			Resource(resource).
-			Param("labelSelector", fmt.Sprintf("%s=false", common.LabelKeyCompleted)).
-			Param("fieldSelector", "status.phase!=Pending").
+			Param("labelSelector", wfc.podLabelSelector()).
 			VersionedParams(&options, metav1.ParameterCodec)
-		req = wfc.addLabelSelectors(req)
 		return req.Watch()
 	}
 	return &cache.ListWatch{ListFunc: listFunc, WatchFunc: watchFunc}
 }
 
+// podLabelSelector returns the label selector of the pod watch: the pods which are not yet completed, and
+// are selected by the label selector of the config (see labelSelector) and its podLabelSelector. Like the
+// field selector, it is evaluated upon each list and watch of the pods.
+func (wfc *WorkflowController) podLabelSelector() string {
+	requirements := []string{fmt.Sprintf("%s=false", common.LabelKeyCompleted)}
+	if podLabelSelector := wfc.getConfig().PodLabelSelector; podLabelSelector != "" {
+		requirements = append(requirements, podLabelSelector)
+	}
+	return wfc.labelSelector(requirements...)
+}
+
+// podFieldSelector returns the field selector of the pod watch. It is evaluated upon each list and
+// watch of the pods, so that it follows changes of the config.
+func (wfc *WorkflowController) podFieldSelector() string {
+	podFieldSelector := wfc.getConfig().PodFieldSelector
+	if podFieldSelector == "" {
+		return fields.Everything().String()
+	}
+	return podFieldSelector
+}
+
 func (wfc *WorkflowController) watchWorkflowPods(ctx context.Context) (cache.Controller, error) {
+	resyncPeriod, err := wfc.getConfig().getPodResyncPeriod()
+	if err != nil {
+		return nil, err
+	}
 	source := wfc.newWorkflowPodWatch()
-	_, controller := cache.NewInformer(
+	var controller cache.Controller
+	wfc.podStore, controller = cache.NewInformer(
 		source,
 		&apiv1.Pod{},
-		podResyncPeriod,
+		resyncPeriod,
 		cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
-				pod, ok := obj.(*apiv1.Pod)
-				if ok {
-					wfc.podUpdates <- pod
+				key, err := cache.MetaNamespaceKeyFunc(obj)
+				if err == nil {
+					wfc.podQueue.Add(key)
 				} else {
-					log.Warn("Watch received unusable pod")
+					log.Warnf("Watch received unusable pod: %v", err)
 				}
 			},
 			UpdateFunc: func(old, new interface{}) {
-				pod, ok := new.(*apiv1.Pod)
-				if ok {
-					wfc.podUpdates <- pod
+				key, err := cache.MetaNamespaceKeyFunc(new)
+				if err == nil {
+					wfc.podQueue.Add(key)
 				} else {
-					log.Warn("Watch received unusable pod")
+					log.Warnf("Watch received unusable pod: %v", err)
 				}
 			},
 			DeleteFunc: func(obj interface{}) {
-				pod, ok := obj.(*apiv1.Pod)
-				if ok {
-					wfc.podUpdates <- pod
+				key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+				if err == nil {
+					wfc.podQueue.Add(key)
 				} else {
-					log.Warn("Watch received unusable pod")
+					log.Warnf("Watch received unusable pod: %v", err)
 				}
 			},
 		})
@@ -380,131 +1377,304 @@ func (wfc *WorkflowController) watchWorkflowPods(ctx context.Context) (cache.Con
 	return controller, nil
 }
 
-// handlePodUpdate receives an update from a pod, and updates the status of the node in the workflow object accordingly
+// handlePodUpdate receives an update from a pod, and queues an update of the status of the node in the workflow
+// object accordingly. Updates of the nodes of a workflow are applied in batches (see enqueueNodeUpdate).
 // It is also responsible for unsetting the deamoned flag from a node status when it notices that a daemoned pod terminated.
 func (wfc *WorkflowController) handlePodUpdate(pod *apiv1.Pod) {
-	if _, ok := wfc.completedPodCache.Get(pod.ObjectMeta.Name); ok {
+	wfc.metrics.podUpdatesHandled.Inc()
+	podKey := fmt.Sprintf("%s/%s", pod.ObjectMeta.Namespace, pod.ObjectMeta.Name)
+	if _, ok := wfc.completedPodCache.Get(podKey); ok {
+		wfc.metrics.completedPodCacheHits.Inc()
 		return
 	}
+	wfc.metrics.completedPodCacheMisses.Inc()
 	if pod.Labels[common.LabelKeyCompleted] == "true" {
 		return
 	}
+	logCtx := log.WithFields(log.Fields{
+		"namespace": pod.ObjectMeta.Namespace,
+		"pod":       pod.ObjectMeta.Name,
+		"phase":     pod.Status.Phase,
+	})
 	workflowName, ok := pod.Labels[common.LabelKeyWorkflow]
 	if !ok {
 		// Ignore pods unrelated to workflow (this shouldn't happen unless the watch is setup incorrectly)
-		log.Warnf("watch returned pod unrelated to any workflow: %s", pod.ObjectMeta.Name)
+		logCtx.Warn("watch returned pod unrelated to any workflow")
 		return
 	}
+	logCtx = logCtx.WithField("workflow", workflowName)
 	var newPhase wfv1.NodePhase
 	var newDaemonStatus *bool
+	if pod.Status.Phase != apiv1.PodUnknown {
+		// the pod recovered, or was never Unknown
+		wfc.podUnknownCache.Delete(podKey)
+	}
+	var reason wfv1.NodeReason
 	var message string
+	// The node updates of Pending and Unknown pods depend on how long the pods have been in that phase
 	switch pod.Status.Phase {
 	case apiv1.PodPending:
-		// Should not get here unless the watch is setup incorrectly
-		log.Warnf("watch returned a Pending pod: %s", pod.ObjectMeta.Name)
-		return
-	case apiv1.PodSucceeded:
-		newPhase = wfv1.NodeSucceeded
-		f := false
-		newDaemonStatus = &f
-	case apiv1.PodFailed:
-		newPhase, newDaemonStatus, message = inferFailedReason(pod)
-	case apiv1.PodRunning:
-		tmplStr, ok := pod.Annotations[common.AnnotationKeyTemplate]
-		if !ok {
-			log.Warnf("%s missing template annotation", pod.ObjectMeta.Name)
+		message = wfc.inferPendingReason(pod)
+		if message == "" {
 			return
 		}
-		var tmpl wfv1.Template
-		err := json.Unmarshal([]byte(tmplStr), &tmpl)
-		if err != nil {
-			log.Warnf("%s template annotation unreadable: %v", pod.ObjectMeta.Name, err)
+		newPhase = wfv1.NodeRunning
+	case apiv1.PodUnknown:
+		newPhase, reason, message = wfc.inferUnknownReason(pod)
+		if message == "" {
 			return
 		}
-		if tmpl.Daemon == nil || !*tmpl.Daemon {
+	default:
+		newPhase, newDaemonStatus, reason, message = wfc.podToNodeUpdate(pod)
+		if newPhase == "" {
 			// incidental state change of a running pod. No need to inspect further
 			return
 		}
+	}
+
+	podState := getPodState(pod, newPhase, newDaemonStatus, reason, message)
+	if lastState, ok := wfc.podStateCache.Get(podKey); ok && lastState.(string) == podState {
+		wfc.metrics.podUpdatesSkipped.Inc()
+		logCtx.Debug("Skipping pod update: no change since last update")
+		return
+	}
+
+	wfc.enqueueNodeUpdate(podNodeUpdate{
+		pod:          pod,
+		workflowName: workflowName,
+		phase:        newPhase,
+		daemoned:     newDaemonStatus,
+		reason:       reason,
+		message:      message,
+		podKey:       podKey,
+		podState:     podState,
+	})
+}
+
+// podToNodeUpdate returns the node status inferred from a Running, Succeeded or Failed pod: the phase,
+// daemoned status, reason and message of the node. An empty phase is returned if the node needs no update
+// (i.e. a running pod which is not a ready daemon). Pending and Unknown pods are not handled, since their
+// node updates depend on how long the pods have been in that phase (see inferPendingReason and
+// inferUnknownReason).
+func (wfc *WorkflowController) podToNodeUpdate(pod *apiv1.Pod) (wfv1.NodePhase, *bool, wfv1.NodeReason, string) {
+	switch pod.Status.Phase {
+	case apiv1.PodSucceeded:
+		f := false
+		return wfv1.NodeSucceeded, &f, "", ""
+	case apiv1.PodFailed:
+		return wfc.inferFailedReason(pod)
+	case apiv1.PodRunning:
+		tmpl := getPodTemplate(pod)
+		if tmpl == nil || tmpl.Daemon == nil || !*tmpl.Daemon {
+			if phase, msg := inferRestartedContainer(pod); phase != "" {
+				f := false
+				return phase, &f, wfv1.NodeReasonContainerRestarted, msg
+			}
+			return wfc.inferLingeringPodResult(pod)
+		}
 		// pod is running and template is marked daemon. check if everything is ready
 		for _, ctrStatus := range pod.Status.ContainerStatuses {
 			if !ctrStatus.Ready {
-				return
+				return "", nil, "", ""
 			}
 		}
-		// proceed to mark node status as succeeded (and daemoned)
-		newPhase = wfv1.NodeSucceeded
+		// mark node status as succeeded (and daemoned)
+		log.Infof("Processing ready daemon pod %s", pod.ObjectMeta.Name)
 		t := true
-		newDaemonStatus = &t
-		log.Infof("Processing ready daemon pod: %v", pod.ObjectMeta.SelfLink)
+		return wfv1.NodeSucceeded, &t, "", ""
 	default:
-		log.Infof("Unexpected pod phase for %s: %s", pod.ObjectMeta.Name, pod.Status.Phase)
-		newPhase = wfv1.NodeError
+		log.Infof("Unexpected phase %s of pod %s", pod.Status.Phase, pod.ObjectMeta.Name)
+		return wfv1.NodeError, nil, "", ""
 	}
+}
+
+// inferRestartedContainer returns the phase and message of the node of a running pod, one of whose init, main
+// or wait containers was restarted. This only happens when the restartPolicy of the pod was changed from Never
+// (e.g. by an admission controller). The restart is reported as the failure of the container, rather than the
+// pod restarting its container indefinitely. Returns an empty phase if no container was restarted.
+func inferRestartedContainer(pod *apiv1.Pod) (wfv1.NodePhase, string) {
+	statuses := append(append([]apiv1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, ctr := range statuses {
+		if ctr.RestartCount == 0 {
+			continue
+		}
+		msg := fmt.Sprintf("%s container restarted (pod restartPolicy is %s, expected %s)", ctr.Name, pod.Spec.RestartPolicy, apiv1.RestartPolicyNever)
+		switch ctr.Name {
+		case common.MainContainerName:
+			return wfv1.NodeFailed, msg
+		case common.InitContainerName, common.WaitContainerName:
+			// like the failures of the executor containers
+			return wfv1.NodeError, msg
+		}
+	}
+	return "", ""
+}
+
+// inferLingeringPodResult returns the status of the node of a running pod whose main and wait containers
+// have terminated. Such a pod is only kept running by containers injected into it which do not exit by
+// themselves, and which the executor failed to kill (e.g. the proxy of a service mesh). The node completes
+// regardless, according to the main and wait containers. Returns an empty phase otherwise.
+func (wfc *WorkflowController) inferLingeringPodResult(pod *apiv1.Pod) (wfv1.NodePhase, *bool, wfv1.NodeReason, string) {
+	var mainCtr, waitCtr *apiv1.ContainerStatus
+	for i, ctr := range pod.Status.ContainerStatuses {
+		switch ctr.Name {
+		case common.MainContainerName:
+			mainCtr = &pod.Status.ContainerStatuses[i]
+		case common.WaitContainerName:
+			waitCtr = &pod.Status.ContainerStatuses[i]
+		}
+	}
+	if mainCtr == nil || waitCtr == nil || mainCtr.State.Terminated == nil || waitCtr.State.Terminated == nil {
+		return "", nil, "", ""
+	}
+	log.Infof("Main and wait containers of running pod %s terminated, ignoring its remaining containers", pod.ObjectMeta.Name)
+	if mainCtr.State.Terminated.ExitCode == 0 && waitCtr.State.Terminated.ExitCode == 0 {
+		f := false
+		return wfv1.NodeSucceeded, &f, "", ""
+	}
+	// the failure of the main or wait container takes precedence over the remaining containers
+	return wfc.inferFailedReason(pod)
+}
 
-	wfClient := workflowclient.NewWorkflowClient(wfc.restClient, wfc.scheme, pod.ObjectMeta.Namespace)
-	wf, err := wfClient.GetWorkflow(workflowName)
+// getPodState returns a hash of the state of a pod which is relevant to its workflow node: the pod's
+// identity, the node status inferred from the pod, the pod IP, the pod's host node, and the outputs reported by
+// the executor.
+func getPodState(pod *apiv1.Pod, newPhase wfv1.NodePhase, newDaemonStatus *bool, reason wfv1.NodeReason, message string) string {
+	h := fnv.New64a()
+	daemoned := newDaemonStatus != nil && *newDaemonStatus
+	fmt.Fprintf(h, "%s\x00%s\x00%v\x00%s\x00%s\x00%s\x00%s\x00", pod.ObjectMeta.UID, newPhase, daemoned, reason, message, pod.Status.PodIP, pod.Spec.NodeName)
+	_, _ = h.Write([]byte(pod.Annotations[common.AnnotationKeyOutputs]))
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
+// pendingMessagePrefix prefixes the node message of a pod stuck Pending
+const pendingMessagePrefix = "Pending: "
+
+// inferPendingReason examines a Pending pod and returns a message describing why it is stuck Pending.
+// Returns an empty string if the pod has not been Pending longer than the threshold, in which case the
+// pod is requeued for inspection once the threshold passes.
+func (wfc *WorkflowController) inferPendingReason(pod *apiv1.Pod) string {
+	threshold, err := wfc.getConfig().getPodPendingThreshold()
 	if err != nil {
-		log.Warnf("Failed to find workflow %s %+v", workflowName, err)
-		return
+		log.Warnf("Failed to get pod pending threshold: %v", err)
+		return ""
 	}
-	node, ok := wf.Status.Nodes[pod.Name]
-	if !ok {
-		log.Warnf("pod %s unassociated with workflow %s", pod.Name, workflowName)
-		return
+	key, err := cache.MetaNamespaceKeyFunc(pod)
+	if err != nil {
+		log.Warnf("Failed to get key of pod %s: %v", pod.ObjectMeta.Name, err)
+		return ""
 	}
-	updateNeeded := applyUpdates(pod, &node, newPhase, newDaemonStatus, message)
-	if !updateNeeded {
-		log.Infof("No workflow updated needed for node %s (pod phase: %s)", node, pod.Status.Phase)
-	} else {
-		wf.Status.Nodes[pod.Name] = node
-		_, err = wfClient.UpdateWorkflow(wf)
-		if err != nil {
-			log.Errorf("Failed to update %s status: %+v", pod.Name, err)
-			// if we fail to update the CRD state, we will need to rely on resync to catch up
-			return
+	// The pod is requeued since a stuck pod may not produce further events
+	pendingFor := time.Since(pod.ObjectMeta.CreationTimestamp.Time)
+	if pendingFor < threshold {
+		wfc.podQueue.AddAfter(key, threshold-pendingFor)
+		return ""
+	}
+	wfc.podQueue.AddAfter(key, threshold)
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == apiv1.PodScheduled && cond.Status == apiv1.ConditionFalse && cond.Reason == apiv1.PodReasonUnschedulable {
+			return fmt.Sprintf("%s%s: %s", pendingMessagePrefix, cond.Reason, cond.Message)
 		}
-		log.Infof("Updated %s", node)
 	}
-
-	if node.Completed() {
-		// If we get here, we need to decide whether or not to set the 'completed=true' label on the pod,
-		// which prevents the controller from seeing any pod updates for the rest of its existance.
-		// We only add the label if the pod is *not* daemoned, because we still rely on this pod watch
-		// for daemoned pods, in order to properly remove the daemoned status from the node when the pod
-		// terminates.
-		if !node.IsDaemoned() {
-			err = common.AddPodLabel(wfc.clientset, pod.ObjectMeta.Name, pod.ObjectMeta.Namespace, common.LabelKeyCompleted, "true")
-			if err != nil {
-				log.Errorf("Failed to label completed pod %s: %+v", node, err)
-				return
-			}
-			wfc.completedPodCache.SetDefault(pod.ObjectMeta.Name, true)
-			log.Infof("Set completed=true label to pod: %s", node)
-		} else {
-			log.Infof("Skipping completed=true labeling for daemoned pod: %s", node)
+	for _, ctr := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		if ctr.State.Waiting != nil && ctr.State.Waiting.Reason != "" && ctr.State.Waiting.Reason != "PodInitializing" && ctr.State.Waiting.Reason != "ContainerCreating" {
+			return fmt.Sprintf("%s%s: %s: %s", pendingMessagePrefix, ctr.Name, ctr.State.Waiting.Reason, ctr.State.Waiting.Message)
 		}
 	}
+	return fmt.Sprintf("%spod pending for over %v", pendingMessagePrefix, threshold)
 }
 
+// unknownMessagePrefix prefixes the node message of a pod in the Unknown phase
+const unknownMessagePrefix = "Unknown: "
+
+// inferUnknownReason examines a pod in the Unknown phase. The node remains Running with a warning message,
+// until the pod has been Unknown for longer than the threshold, at which point the node is errored.
+// Returns an empty message if the pod could not be examined.
+func (wfc *WorkflowController) inferUnknownReason(pod *apiv1.Pod) (wfv1.NodePhase, wfv1.NodeReason, string) {
+	threshold, err := wfc.getConfig().getPodUnknownThreshold()
+	if err != nil {
+		log.Warnf("Failed to get pod unknown threshold: %v", err)
+		return "", "", ""
+	}
+	key, err := cache.MetaNamespaceKeyFunc(pod)
+	if err != nil {
+		log.Warnf("Failed to get key of pod %s: %v", pod.ObjectMeta.Name, err)
+		return "", "", ""
+	}
+	var unknownSince time.Time
+	if since, ok := wfc.podUnknownCache.Get(key); ok {
+		unknownSince = since.(time.Time)
+	} else {
+		unknownSince = time.Now()
+		wfc.podUnknownCache.Set(key, unknownSince, threshold+time.Hour)
+	}
+	unknownFor := time.Since(unknownSince)
+	if unknownFor < threshold {
+		// The pod is requeued since an unreachable pod may not produce further events
+		wfc.podQueue.AddAfter(key, threshold-unknownFor)
+		msg := fmt.Sprintf("%spod state is unknown since %s (e.g. its node is unreachable)", unknownMessagePrefix, unknownSince.UTC().Format(time.RFC3339))
+		return wfv1.NodeRunning, "", msg
+	}
+	wfc.podUnknownCache.Delete(key)
+	msg := fmt.Sprintf("pod state was unknown for over %v", threshold)
+	if pod.Status.Message != "" {
+		msg += ": " + pod.Status.Message
+	}
+	return wfv1.NodeError, wfv1.NodeReasonPodUnknown, msg
+}
+
+// Pod and container status reasons which are given specific node messages
+const (
+	// podReasonDeadlineExceeded is set by the kubelet when a pod exceeds its activeDeadlineSeconds
+	podReasonDeadlineExceeded = "DeadlineExceeded"
+	// podReasonEvicted is set by the kubelet when a pod is evicted from its node (e.g. due to resource pressure)
+	podReasonEvicted = "Evicted"
+	// containerReasonOOMKilled is set when a container is killed for exceeding its memory limit
+	containerReasonOOMKilled = "OOMKilled"
+)
+
 // inferFailedReason examines a Failed pod object to determine why it failed and return NodeStatus metadata
-func inferFailedReason(pod *apiv1.Pod) (wfv1.NodePhase, *bool, string) {
+func (wfc *WorkflowController) inferFailedReason(pod *apiv1.Pod) (wfv1.NodePhase, *bool, wfv1.NodeReason, string) {
 	f := false
+	if pod.Status.Reason == podReasonDeadlineExceeded {
+		// The kubelet kills pods which exceed the activeDeadlineSeconds of their template, or the time
+		// remaining before the workflow's active deadline when the pod was created (see podActiveDeadlineSeconds).
+		// Give a clearer message than the kubelet's, or the exit codes of the killed containers.
+		msg := "step exceeded its deadline"
+		if pod.Spec.ActiveDeadlineSeconds != nil {
+			msg = fmt.Sprintf("step exceeded its deadline of %d seconds", *pod.Spec.ActiveDeadlineSeconds)
+			tmpl := getPodTemplate(pod)
+			if tmpl != nil && (tmpl.ActiveDeadlineSeconds == nil || *tmpl.ActiveDeadlineSeconds != *pod.Spec.ActiveDeadlineSeconds) {
+				msg = "workflow exceeded its active deadline"
+			}
+		}
+		return wfv1.NodeFailed, &f, wfv1.NodeReasonTimeout, msg
+	}
+	if pod.Status.Reason == podReasonEvicted {
+		msg := "pod was evicted"
+		if pod.Status.Message != "" {
+			msg += ": " + pod.Status.Message
+		}
+		return wfv1.NodeFailed, &f, wfv1.NodeReasonEvicted, msg
+	}
 	if pod.Status.Message != "" {
 		// Pod has a nice error message. Use that.
-		return wfv1.NodeFailed, &f, pod.Status.Message
+		return wfv1.NodeFailed, &f, wfv1.NodeReasonPodFailed, pod.Status.Message
 	}
 	annotatedMsg := pod.Annotations[common.AnnotationKeyNodeMessage]
 	// We only get one message to set for the overall node status.
-	// If mutiple containers failed, in order of preference: init, main, wait, sidecars
+	// If mutiple containers failed, in order of preference: init, main, wait, then sidecars by name
 	for _, ctr := range pod.Status.InitContainerStatuses {
 		if ctr.State.Terminated == nil {
-			// We should never get here
-			log.Warnf("Pod %s phase was Failed but %s did not have terminated state", pod.ObjectMeta.Name, ctr.Name)
-			continue
+			// The following containers never ran, since the init containers run in order
+			return wfv1.NodeError, &f, wfv1.NodeReasonPodFailed, unknownContainerStateMessage(pod, ctr.Name)
 		}
 		if ctr.State.Terminated.ExitCode == 0 {
 			continue
 		}
+		if ctr.State.Terminated.Reason == containerReasonOOMKilled {
+			return wfv1.NodeError, &f, wfv1.NodeReasonOOMKilled, fmt.Sprintf("failed to load artifacts: %s container OOMKilled", ctr.Name)
+		}
 		errMsg := fmt.Sprintf("failed to load artifacts")
 		for _, msg := range []string{annotatedMsg, ctr.State.Terminated.Message} {
 			if msg != "" {
@@ -513,19 +1683,31 @@ func inferFailedReason(pod *apiv1.Pod) (wfv1.NodePhase, *bool, string) {
 			}
 		}
 		// NOTE: we consider artifact load issues as Error instead of Failed
-		return wfv1.NodeError, &f, errMsg
+		return wfv1.NodeError, &f, wfv1.NodeReasonArtifactLoadError, errMsg
 	}
 	failMessages := make(map[string]string)
+	failReasons := make(map[string]wfv1.NodeReason)
+	failPhases := make(map[string]wfv1.NodePhase)
 	for _, ctr := range pod.Status.ContainerStatuses {
 		if ctr.State.Terminated == nil {
-			// We should never get here
-			log.Warnf("Pod %s phase was Failed but %s did not have terminated state", pod.ObjectMeta.Name, ctr.Name)
+			failMessages[ctr.Name] = unknownContainerStateMessage(pod, ctr.Name)
+			failReasons[ctr.Name] = wfv1.NodeReasonPodFailed
+			failPhases[ctr.Name] = wfv1.NodeError
 			continue
 		}
 		if ctr.State.Terminated.ExitCode == 0 {
 			continue
 		}
-		if ctr.Name == common.WaitContainerName {
+		if ctr.State.Terminated.Reason == containerReasonOOMKilled {
+			failMessages[ctr.Name] = fmt.Sprintf("%s container OOMKilled", ctr.Name)
+			failReasons[ctr.Name] = wfv1.NodeReasonOOMKilled
+			continue
+		}
+		if ctr.Name == common.WaitContainerName && ctr.State.Terminated.ExitCode == common.ExitCodeMainContainerNotFound {
+			// rather than the artifact save error which results from it
+			failMessages[ctr.Name] = "executor could not locate main container"
+			failReasons[ctr.Name] = wfv1.NodeReasonMainContainerNotFound
+		} else if ctr.Name == common.WaitContainerName {
 			errMsg := fmt.Sprintf("failed to save artifacts")
 			for _, msg := range []string{annotatedMsg, ctr.State.Terminated.Message} {
 				if msg != "" {
@@ -534,49 +1716,121 @@ func inferFailedReason(pod *apiv1.Pod) (wfv1.NodePhase, *bool, string) {
 				}
 			}
 			failMessages[ctr.Name] = errMsg
+			failReasons[ctr.Name] = wfv1.NodeReasonArtifactSaveError
 		} else {
 			if ctr.State.Terminated.Message != "" {
 				failMessages[ctr.Name] = ctr.State.Terminated.Message
 			} else {
 				failMessages[ctr.Name] = fmt.Sprintf("failed with exit code %d", ctr.State.Terminated.ExitCode)
 			}
+			failReasons[ctr.Name] = wfv1.NodeReasonExitCode
 		}
 	}
 	if failMsg, ok := failMessages[common.MainContainerName]; ok {
-		return wfv1.NodeFailed, &f, failMsg
+		if phase, ok := failPhases[common.MainContainerName]; ok {
+			return phase, &f, failReasons[common.MainContainerName], failMsg
+		}
+		return wfv1.NodeFailed, &f, failReasons[common.MainContainerName], failMsg
 	}
 	if failMsg, ok := failMessages[common.WaitContainerName]; ok {
-		return wfv1.NodeError, &f, failMsg
+		if phase, ok := failPhases[common.WaitContainerName]; ok {
+			return phase, &f, failReasons[common.WaitContainerName], failMsg
+		}
+		return wfc.waitContainerFailurePhase(), &f, failReasons[common.WaitContainerName], failMsg
 	}
 
 	// If we get here, both the main and wait container succeeded.
-	// Identify the sidecar which failed and give proper message
-	// NOTE: we may need to distinguish between the main container
-	// succeeding and ignoring the sidecar statuses. This is because
-	// executor may have had to forcefully terminate a sidecar
+	// The executor may have had to forcefully terminate a sidecar
 	// (kill -9), resulting in an non-zero exit code of a sidecar,
 	// and overall pod status as failed. Or the sidecar is actually
-	// *expected* to fail non-zero and should be ignored. Users may
-	// want the option to consider a step failed only if the main
-	// container failed. For now return the first failure.
-	for _, failMsg := range failMessages {
-		return wfv1.NodeFailed, &f, failMsg
+	// *expected* to fail non-zero and should be ignored. Templates
+	// may opt to consider a step failed only if the main container failed.
+	if ignoreSidecarFailures(pod) {
+		log.Infof("Ignoring sidecar failures of pod %s since main container succeeded", pod.ObjectMeta.Name)
+		return wfv1.NodeSucceeded, &f, "", ""
+	}
+	// Identify the sidecar which failed and give proper message.
+	// Return the failure of the first sidecar by name, so that the reported failure does not vary.
+	var sidecarNames []string
+	for ctrName := range failMessages {
+		sidecarNames = append(sidecarNames, ctrName)
+	}
+	sort.Strings(sidecarNames)
+	for _, ctrName := range sidecarNames {
+		if phase, ok := failPhases[ctrName]; ok {
+			return phase, &f, failReasons[ctrName], failMessages[ctrName]
+		}
+		return wfv1.NodeFailed, &f, failReasons[ctrName], failMessages[ctrName]
+	}
+	return wfv1.NodeFailed, &f, wfv1.NodeReasonUnknown, fmt.Sprintf("pod failed for unknown reason")
+}
+
+// waitContainerFailurePhase returns the phase of the nodes whose wait container failed, which defaults to Error
+func (wfc *WorkflowController) waitContainerFailurePhase() wfv1.NodePhase {
+	phase := wfc.getConfig().WaitContainerFailurePhase
+	if phase == "" {
+		return wfv1.NodeError
+	}
+	return phase
+}
+
+// unknownContainerStateMessage returns the message of a failed pod, one of whose containers did not terminate.
+// This legitimately happens when the pod is evicted, or force deleted, before its containers terminated.
+func unknownContainerStateMessage(pod *apiv1.Pod, ctrName string) string {
+	cause := "pod evicted"
+	if pod.Status.Reason != "" {
+		cause = fmt.Sprintf("pod %s", pod.Status.Reason)
+	} else if pod.ObjectMeta.DeletionTimestamp != nil {
+		cause = "pod deleted"
+	}
+	return fmt.Sprintf("%s container state unknown (%s)", ctrName, cause)
+}
+
+// ignoreSidecarFailures returns whether the template of the pod is configured to ignore sidecar failures
+func ignoreSidecarFailures(pod *apiv1.Pod) bool {
+	tmpl := getPodTemplate(pod)
+	return tmpl != nil && tmpl.IgnoreSidecarFailures != nil && *tmpl.IgnoreSidecarFailures
+}
+
+// getPodTemplate returns the template of a pod from its template annotation, or nil if it is missing or unreadable
+func getPodTemplate(pod *apiv1.Pod) *wfv1.Template {
+	tmplStr, ok := pod.Annotations[common.AnnotationKeyTemplate]
+	if !ok {
+		log.Warnf("%s missing template annotation", pod.ObjectMeta.Name)
+		return nil
 	}
-	return wfv1.NodeFailed, &f, fmt.Sprintf("pod failed for unknown reason")
+	var tmpl wfv1.Template
+	err := json.Unmarshal([]byte(tmplStr), &tmpl)
+	if err != nil {
+		log.Warnf("%s template annotation unreadable: %v", pod.ObjectMeta.Name, err)
+		return nil
+	}
+	return &tmpl
 }
 
 // applyUpdates applies any new state information about a pod, to the current status of the workflow node
 // returns whether or not any updates were necessary (resulting in a update to the workflow)
-func applyUpdates(pod *apiv1.Pod, node *wfv1.NodeStatus, newPhase wfv1.NodePhase, newDaemonStatus *bool, message string) bool {
+func applyUpdates(pod *apiv1.Pod, node *wfv1.NodeStatus, newPhase wfv1.NodePhase, newDaemonStatus *bool, reason wfv1.NodeReason, message string) bool {
+	logCtx := log.WithFields(log.Fields{
+		"workflow":  pod.ObjectMeta.Labels[common.LabelKeyWorkflow],
+		"namespace": pod.ObjectMeta.Namespace,
+		"pod":       pod.ObjectMeta.Name,
+		"node":      node.Name,
+	})
+	if node.Phase == wfv1.NodeSkipped {
+		// A skipped node never had a pod, so the pod must be a leftover (e.g. of a resubmitted workflow)
+		logCtx.Warn("Ignoring pod update of skipped node")
+		return false
+	}
 	// Check various fields of the pods to see if we need to update the workflow
 	updateNeeded := false
 	if node.Phase != newPhase {
 		if node.Completed() {
 			// Don't modify the phase if this node was already considered completed.
 			// This might happen with daemoned steps which fail after they were daemoned
-			log.Infof("Ignoring node %s status update %s -> %s", node, node.Phase, newPhase)
+			logCtx.WithFields(log.Fields{"phase": node.Phase, "newPhase": newPhase}).Info("Ignoring phase update of completed node")
 		} else {
-			log.Infof("Updating node %s status %s -> %s", node, node.Phase, newPhase)
+			logCtx.WithFields(log.Fields{"phase": node.Phase, "newPhase": newPhase}).Info("Updating node phase")
 			updateNeeded = true
 			node.Phase = newPhase
 		}
@@ -588,32 +1842,52 @@ func applyUpdates(pod *apiv1.Pod, node *wfv1.NodeStatus, newPhase wfv1.NodePhase
 			newDaemonStatus = nil
 		}
 		if (newDaemonStatus != nil && node.Daemoned == nil) || (newDaemonStatus == nil && node.Daemoned != nil) {
-			log.Infof("Setting node %v daemoned: %v -> %v", node, node.Daemoned, newDaemonStatus)
+			logCtx.WithField("daemoned", newDaemonStatus != nil).Info("Updating node daemoned status")
 			node.Daemoned = newDaemonStatus
 			updateNeeded = true
 			if pod.Status.PodIP != node.PodIP {
 				// only update Pod IP for daemoned nodes to reduce number of updates
-				log.Infof("Updating daemon node %s IP %s -> %s", node, node.PodIP, pod.Status.PodIP)
+				logCtx.WithFields(log.Fields{"podIP": node.PodIP, "newPodIP": pod.Status.PodIP}).Info("Updating daemon node IP")
 				node.PodIP = pod.Status.PodIP
 			}
 		}
 	}
+	if pod.Spec.NodeName != "" && node.HostNodeName == "" {
+		// the host node of a pod never changes once scheduled
+		logCtx.WithField("hostNodeName", pod.Spec.NodeName).Info("Setting node host node name")
+		node.HostNodeName = pod.Spec.NodeName
+		updateNeeded = true
+	}
 	outputStr, ok := pod.Annotations[common.AnnotationKeyOutputs]
 	if ok && node.Outputs == nil {
-		log.Infof("Setting node %v outputs", node)
+		logCtx.Info("Setting node outputs")
 		updateNeeded = true
 		var outputs wfv1.Outputs
 		err := json.Unmarshal([]byte(outputStr), &outputs)
 		if err != nil {
-			log.Errorf("Failed to unmarshal %s outputs from pod annotation: %v", pod.Name, err)
-			node.Phase = wfv1.NodeError
+			logCtx.WithError(err).Error("Failed to unmarshal outputs from pod annotation")
+			if !node.Completed() {
+				node.Phase = wfv1.NodeError
+			}
 		} else {
 			node.Outputs = &outputs
 		}
 	}
+	if reason != "" && node.Reason != reason {
+		logCtx.WithField("reason", reason).Info("Updating node reason")
+		node.Reason = reason
+		updateNeeded = true
+	}
 	if message != "" && node.Message != message {
-		log.Infof("Updating node %s message: %s", node, message)
+		logCtx.WithField("message", message).Info("Updating node message")
 		node.Message = message
+		updateNeeded = true
+	} else if message == "" && newPhase != wfv1.NodeRunning &&
+		(strings.HasPrefix(node.Message, pendingMessagePrefix) || strings.HasPrefix(node.Message, unknownMessagePrefix)) {
+		// pod is no longer pending (or unknown). clear the message explaining why it was stuck
+		logCtx.Info("Clearing node pending message")
+		node.Message = ""
+		updateNeeded = true
 	}
 	if node.Completed() && node.FinishedAt.IsZero() {
 		if !node.IsDaemoned() {
@@ -640,17 +1914,56 @@ func applyUpdates(pod *apiv1.Pod, node *wfv1.NodeStatus, newPhase wfv1.NodePhase
 	return updateNeeded
 }
 
-// StartStatsTicker starts a goroutine which dumps stats at a specified interval
-func (wfc *WorkflowController) StartStatsTicker(d time.Duration) {
+// monitorQueueDepths periodically logs a warning when the depth of the workflow or pod queue crosses
+// queueDepthWarningRatio of its configured size, until ctx is done.
+func (wfc *WorkflowController) monitorQueueDepths(ctx context.Context) {
+	ticker := time.NewTicker(queueDepthCheckPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			config := wfc.getConfig()
+			wfQueueSize := config.WorkflowQueueSize
+			if wfQueueSize <= 0 {
+				wfQueueSize = defaultWorkflowQueueSize
+			}
+			podQueueSize := config.PodQueueSize
+			if podQueueSize <= 0 {
+				podQueueSize = defaultPodQueueSize
+			}
+			warnQueueDepth("workflow", wfc.wfQueue, wfQueueSize)
+			warnQueueDepth("pod", wfc.podQueue, podQueueSize)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// warnQueueDepth logs a warning if the depth of the queue crosses queueDepthWarningRatio of size
+func warnQueueDepth(name string, queue workqueue.Interface, size int) {
+	depth := queue.Len()
+	if float64(depth) >= queueDepthWarningRatio*float64(size) {
+		log.Warnf("%s queue depth %d is at %d%% of its size %d. Consider increasing the number of workers",
+			name, depth, depth*100/size, size)
+	}
+}
+
+// StartStatsTicker starts a goroutine which dumps stats at a specified interval, until ctx is done
+func (wfc *WorkflowController) StartStatsTicker(ctx context.Context, d time.Duration) {
 	ticker := time.NewTicker(d)
 	go func() {
+		defer ticker.Stop()
 		for {
-			<-ticker.C
-			var m goruntime.MemStats
-			goruntime.ReadMemStats(&m)
-			log.Infof("Alloc=%v TotalAlloc=%v Sys=%v NumGC=%v Goroutines=%d wfChan=%d/%d podChan=%d/%d",
-				m.Alloc/1024, m.TotalAlloc/1024, m.Sys/1024, m.NumGC, goruntime.NumGoroutine(),
-				len(wfc.wfUpdates), cap(wfc.wfUpdates), len(wfc.podUpdates), cap(wfc.podUpdates))
+			select {
+			case <-ticker.C:
+				var m goruntime.MemStats
+				goruntime.ReadMemStats(&m)
+				log.Infof("Alloc=%v TotalAlloc=%v Sys=%v NumGC=%v Goroutines=%d wfQueue=%d podQueue=%d",
+					m.Alloc/1024, m.TotalAlloc/1024, m.Sys/1024, m.NumGC, goruntime.NumGoroutine(),
+					wfc.wfQueue.Len(), wfc.podQueue.Len())
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 }
@@ -0,0 +1,562 @@
+package controller
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/argoproj/argo/workflow/common"
+	"github.com/ghodss/yaml"
+	gocache "github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func unmarshalPod(t *testing.T, yamlStr string) *apiv1.Pod {
+	var pod apiv1.Pod
+	err := yaml.Unmarshal([]byte(yamlStr), &pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &pod
+}
+
+// evictedPod is a pod which was evicted while its main container was running. The kubelet did not
+// get to record the termination of its containers, nor the reason of the pod.
+var evictedPod = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: evicted
+status:
+  phase: Failed
+  containerStatuses:
+  - name: main
+    state:
+      running:
+        startedAt: 2018-01-01T00:00:00Z
+  - name: wait
+    state:
+      terminated:
+        exitCode: 0
+`
+
+func TestInferFailedReasonEvictedPod(t *testing.T) {
+	wfc := &WorkflowController{}
+	pod := unmarshalPod(t, evictedPod)
+	phase, _, reason, msg := wfc.inferFailedReason(pod)
+	assert.Equal(t, wfv1.NodeError, phase)
+	assert.Equal(t, wfv1.NodeReasonPodFailed, reason)
+	assert.Equal(t, "main container state unknown (pod evicted)", msg)
+
+	// the termination of the other containers is not known either
+	pod.Status.ContainerStatuses[1].State = apiv1.ContainerState{}
+	phase, _, _, msg = wfc.inferFailedReason(pod)
+	assert.Equal(t, wfv1.NodeError, phase)
+	assert.Equal(t, "main container state unknown (pod evicted)", msg)
+
+	pod.Status.Reason = "NodeLost"
+	_, _, _, msg = wfc.inferFailedReason(pod)
+	assert.Equal(t, "main container state unknown (pod NodeLost)", msg)
+
+	// once the kubelet records the eviction, its message is used
+	pod.Status.Reason = podReasonEvicted
+	pod.Status.Message = "The node was low on resource: memory."
+	phase, _, reason, msg = wfc.inferFailedReason(pod)
+	assert.Equal(t, wfv1.NodeFailed, phase)
+	assert.Equal(t, wfv1.NodeReasonEvicted, reason)
+	assert.Equal(t, "pod was evicted: The node was low on resource: memory.", msg)
+}
+
+var evictedPodDuringInit = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: evicted-during-init
+status:
+  phase: Failed
+  initContainerStatuses:
+  - name: init
+    state:
+      running:
+        startedAt: 2018-01-01T00:00:00Z
+  containerStatuses:
+  - name: main
+    state:
+      waiting:
+        reason: PodInitializing
+  - name: wait
+    state:
+      waiting:
+        reason: PodInitializing
+`
+
+func TestInferFailedReasonEvictedPodDuringInit(t *testing.T) {
+	wfc := &WorkflowController{}
+	pod := unmarshalPod(t, evictedPodDuringInit)
+	phase, _, reason, msg := wfc.inferFailedReason(pod)
+	assert.Equal(t, wfv1.NodeError, phase)
+	assert.Equal(t, wfv1.NodeReasonPodFailed, reason)
+	assert.Equal(t, "init container state unknown (pod evicted)", msg)
+}
+
+func TestApplyUpdatesSkippedNode(t *testing.T) {
+	pod := unmarshalPod(t, evictedPod)
+	pod.Annotations = map[string]string{common.AnnotationKeyOutputs: `{"result": "hello"}`}
+	node := wfv1.NodeStatus{Name: "skipped", Phase: wfv1.NodeSkipped, Message: "when 'a == b' evaluated false"}
+	updated := applyUpdates(pod, &node, wfv1.NodeFailed, nil, wfv1.NodeReasonPodFailed, "pod failed")
+	assert.False(t, updated)
+	assert.Equal(t, wfv1.NodeSkipped, node.Phase)
+	assert.Equal(t, "when 'a == b' evaluated false", node.Message)
+	assert.Nil(t, node.Outputs)
+}
+
+func TestApplyUpdatesHostNodeName(t *testing.T) {
+	pod := unmarshalPod(t, evictedPod)
+	pod.Spec.NodeName = "node-1"
+	node := wfv1.NodeStatus{Name: "running", Phase: wfv1.NodeRunning}
+	updated := applyUpdates(pod, &node, wfv1.NodeRunning, nil, "", "")
+	assert.True(t, updated)
+	assert.Equal(t, "node-1", node.HostNodeName)
+
+	// the host node name is only set once
+	updated = applyUpdates(pod, &node, wfv1.NodeRunning, nil, "", "")
+	assert.False(t, updated)
+}
+
+var deadlineExceededPod = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: deadline-exceeded
+  annotations:
+    workflows.argoproj.io/template: '{"name": "sleep", "activeDeadlineSeconds": 10, "container": {"image": "alpine:3.7"}}'
+spec:
+  activeDeadlineSeconds: 10
+status:
+  phase: Failed
+  reason: DeadlineExceeded
+`
+
+func TestInferFailedReasonDeadlineExceeded(t *testing.T) {
+	wfc := &WorkflowController{}
+	pod := unmarshalPod(t, deadlineExceededPod)
+	phase, _, reason, msg := wfc.inferFailedReason(pod)
+	assert.Equal(t, wfv1.NodeFailed, phase)
+	assert.Equal(t, wfv1.NodeReasonTimeout, reason)
+	assert.Equal(t, "step exceeded its deadline of 10 seconds", msg)
+
+	// the deadline of the pod was capped by the workflow's active deadline
+	deadline := int64(5)
+	pod.Spec.ActiveDeadlineSeconds = &deadline
+	phase, _, reason, msg = wfc.inferFailedReason(pod)
+	assert.Equal(t, wfv1.NodeFailed, phase)
+	assert.Equal(t, wfv1.NodeReasonTimeout, reason)
+	assert.Equal(t, "workflow exceeded its active deadline", msg)
+}
+
+func TestResyncConfigFromFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "workflow-controller-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("executorImage: argoproj/argoexec:latest\nparallelism: 10\n")
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wfc := &WorkflowController{ConfigFile: file.Name()}
+	err = wfc.ResyncConfig()
+	if assert.Nil(t, err) {
+		assert.Equal(t, "argoproj/argoexec:latest", wfc.config.ExecutorImage)
+		assert.Equal(t, int64(10), wfc.config.Parallelism)
+	}
+
+	err = ioutil.WriteFile(file.Name(), []byte("executorImage: argoproj/argoexec:latest\nparallelism: -1\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wfc.ResyncConfig()
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), fmt.Sprintf("config file '%s' parallelism must not be negative", file.Name()))
+	}
+	// the last valid config is kept
+	assert.Equal(t, int64(10), wfc.config.Parallelism)
+}
+
+var daemonPod = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: daemon
+  annotations:
+    workflows.argoproj.io/template: '{"name": "nginx", "daemon": true, "container": {"image": "nginx:1.13"}}'
+status:
+  phase: Running
+  containerStatuses:
+  - name: main
+    ready: true
+  - name: wait
+    ready: false
+`
+
+func TestPodToNodeUpdate(t *testing.T) {
+	wfc := &WorkflowController{}
+	// a daemon pod is processed once all of its containers are ready
+	pod := unmarshalPod(t, daemonPod)
+	phase, daemoned, _, _ := wfc.podToNodeUpdate(pod)
+	assert.Equal(t, wfv1.NodePhase(""), phase)
+	pod.Status.ContainerStatuses[1].Ready = true
+	phase, daemoned, _, _ = wfc.podToNodeUpdate(pod)
+	assert.Equal(t, wfv1.NodeSucceeded, phase)
+	if assert.NotNil(t, daemoned) {
+		assert.True(t, *daemoned)
+	}
+
+	// running pods of other templates need no update
+	pod.Annotations[common.AnnotationKeyTemplate] = `{"name": "sleep", "container": {"image": "alpine:3.7"}}`
+	phase, _, _, _ = wfc.podToNodeUpdate(pod)
+	assert.Equal(t, wfv1.NodePhase(""), phase)
+
+	pod.Status.Phase = apiv1.PodSucceeded
+	phase, daemoned, reason, msg := wfc.podToNodeUpdate(pod)
+	assert.Equal(t, wfv1.NodeSucceeded, phase)
+	if assert.NotNil(t, daemoned) {
+		assert.False(t, *daemoned)
+	}
+	assert.Equal(t, wfv1.NodeReason(""), reason)
+	assert.Equal(t, "", msg)
+
+	pod.Status.Phase = apiv1.PodFailed
+	pod.Status.ContainerStatuses = []apiv1.ContainerStatus{
+		{Name: "main", State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 2}}},
+		{Name: "wait", State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 0}}},
+	}
+	phase, _, reason, msg = wfc.podToNodeUpdate(pod)
+	assert.Equal(t, wfv1.NodeFailed, phase)
+	assert.Equal(t, wfv1.NodeReasonExitCode, reason)
+	assert.Equal(t, "failed with exit code 2", msg)
+
+	pod.Status.Phase = "Unexpected"
+	phase, _, _, _ = wfc.podToNodeUpdate(pod)
+	assert.Equal(t, wfv1.NodeError, phase)
+}
+
+func TestGetStatsInterval(t *testing.T) {
+	config := WorkflowControllerConfig{}
+	interval, err := config.getStatsInterval()
+	if assert.Nil(t, err) {
+		assert.Equal(t, defaultStatsInterval, interval)
+	}
+	// stats logging is disabled with 0
+	config.StatsInterval = "0"
+	interval, err = config.getStatsInterval()
+	if assert.Nil(t, err) {
+		assert.Equal(t, time.Duration(0), interval)
+	}
+	config.StatsInterval = "-1m"
+	_, err = config.getStatsInterval()
+	assert.NotNil(t, err)
+}
+
+func TestParseConfigPullPolicy(t *testing.T) {
+	wfc := &WorkflowController{ConfigMap: "workflow-controller-configmap"}
+	cm := &apiv1.ConfigMap{Data: map[string]string{
+		common.WorkflowControllerConfigMapKey: "executorImage: argoproj/argoexec:latest\nmainImagePullPolicy: Sometimes\n",
+	}}
+	_, err := wfc.parseConfig(cm)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "ConfigMap 'workflow-controller-configmap' mainImagePullPolicy 'Sometimes' is invalid")
+	}
+	cm.Data[common.WorkflowControllerConfigMapKey] = "executorImage: argoproj/argoexec:latest\nforceMainImagePullPolicy: true\n"
+	_, err = wfc.parseConfig(cm)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "forceMainImagePullPolicy requires a mainImagePullPolicy")
+	}
+}
+
+// mainNotFoundPod is a pod whose wait container could not find the main container in the status of the pod
+var mainNotFoundPod = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: main-not-found
+  annotations:
+    workflows.argoproj.io/node-message: Main container not found
+status:
+  phase: Failed
+  containerStatuses:
+  - name: wait
+    state:
+      terminated:
+        exitCode: 3
+`
+
+func TestInferFailedReasonMainContainerNotFound(t *testing.T) {
+	wfc := &WorkflowController{}
+	pod := unmarshalPod(t, mainNotFoundPod)
+	phase, _, reason, msg := wfc.inferFailedReason(pod)
+	assert.Equal(t, wfv1.NodeError, phase)
+	assert.Equal(t, wfv1.NodeReasonMainContainerNotFound, reason)
+	assert.Equal(t, "executor could not locate main container", msg)
+
+	// other failures of the wait container are artifact save errors
+	pod.Status.ContainerStatuses[0].State.Terminated.ExitCode = 1
+	_, _, reason, msg = wfc.inferFailedReason(pod)
+	assert.Equal(t, wfv1.NodeReasonArtifactSaveError, reason)
+	assert.Equal(t, "failed to save artifacts: Main container not found", msg)
+}
+
+func TestPodLabelSelector(t *testing.T) {
+	wfc := &WorkflowController{config: &WorkflowControllerConfig{InstanceID: "test"}}
+	assert.Equal(t, "workflows.argoproj.io/completed=false,workflows.argoproj.io/controller-instanceid=test", wfc.podLabelSelector())
+	wfc.config.PodLabelSelector = "team=ml,tier!=debug"
+	assert.Equal(t, "workflows.argoproj.io/completed=false,team=ml,tier!=debug,workflows.argoproj.io/controller-instanceid=test", wfc.podLabelSelector())
+
+	wfc.ConfigMap = "workflow-controller-configmap"
+	cm := &apiv1.ConfigMap{Data: map[string]string{
+		common.WorkflowControllerConfigMapKey: "executorImage: argoproj/argoexec:latest\npodLabelSelector: 'team in (ml'\n",
+	}}
+	_, err := wfc.parseConfig(cm)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "ConfigMap 'workflow-controller-configmap' podLabelSelector 'team in (ml' is invalid")
+	}
+}
+
+func TestRequeueAllWorkflows(t *testing.T) {
+	wfc := &WorkflowController{}
+	// the workflows are not watched yet
+	wfc.requeueAllWorkflows()
+
+	wfc.wfStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	wfc.wfQueue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	for _, name := range []string{"one", "two"} {
+		err := wfc.wfStore.Add(&wfv1.Workflow{ObjectMeta: metav1.ObjectMeta{Namespace: "argo", Name: name}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	wfc.wfQueue.Add("argo/one")
+	wfc.requeueAllWorkflows()
+	assert.Equal(t, 2, wfc.wfQueue.Len())
+}
+
+func TestGetArtifactRepository(t *testing.T) {
+	wfc := &WorkflowController{config: &WorkflowControllerConfig{
+		ArtifactRepository: wfv1.ArtifactRepository{
+			S3: &wfv1.S3ArtifactRepository{KeyPrefix: "{{workflow.namespace}}/{{workflow.name}}"},
+		},
+	}}
+	wf := &wfv1.Workflow{ObjectMeta: metav1.ObjectMeta{Namespace: "argo", Name: "steps-abcde"}}
+	repo, err := wfc.GetArtifactRepository(wf)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "argo/steps-abcde", repo.S3.KeyPrefix)
+	}
+	// the config is not modified
+	assert.Equal(t, "{{workflow.namespace}}/{{workflow.name}}", wfc.config.ArtifactRepository.S3.KeyPrefix)
+
+	// the artifact repository of the workflow overrides that of the config
+	wf.Spec.ArtifactRepository = &wfv1.ArtifactRepository{
+		AzureBlob: &wfv1.AzureBlobArtifactRepository{KeyPrefix: "{{workflow.name}}"},
+	}
+	repo, err = wfc.GetArtifactRepository(wf)
+	if assert.Nil(t, err) {
+		assert.Nil(t, repo.S3)
+		assert.Equal(t, "steps-abcde", repo.AzureBlob.KeyPrefix)
+	}
+
+	wfc.wfStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	err = wfc.wfStore.Add(wf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	wfc.serveArtifactRepository(rec, httptest.NewRequest("GET", artifactRepositoryPath+"?workflow=argo/steps-abcde", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"keyPrefix": "steps-abcde"`)
+
+	rec = httptest.NewRecorder()
+	wfc.serveArtifactRepository(rec, httptest.NewRequest("GET", artifactRepositoryPath+"?workflow=argo/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// lingeringPod is a pod kept running by an injected service mesh proxy, after its main and wait containers terminated
+var lingeringPod = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: lingering
+  annotations:
+    workflows.argoproj.io/template: '{"name": "sleep", "container": {"image": "alpine:3.7"}}'
+status:
+  phase: Running
+  containerStatuses:
+  - name: main
+    state:
+      terminated:
+        exitCode: 0
+  - name: wait
+    state:
+      terminated:
+        exitCode: 0
+  - name: istio-proxy
+    state:
+      running:
+        startedAt: 2018-01-01T00:00:00Z
+`
+
+func TestPodToNodeUpdateLingeringPod(t *testing.T) {
+	wfc := &WorkflowController{}
+	pod := unmarshalPod(t, lingeringPod)
+	phase, _, reason, msg := wfc.podToNodeUpdate(pod)
+	assert.Equal(t, wfv1.NodeSucceeded, phase)
+	assert.Equal(t, wfv1.NodeReason(""), reason)
+	assert.Equal(t, "", msg)
+
+	pod.Status.ContainerStatuses[0].State.Terminated.ExitCode = 1
+	phase, _, reason, msg = wfc.podToNodeUpdate(pod)
+	assert.Equal(t, wfv1.NodeFailed, phase)
+	assert.Equal(t, wfv1.NodeReasonExitCode, reason)
+	assert.Equal(t, "failed with exit code 1", msg)
+
+	// the pod is still running its main container
+	pod.Status.ContainerStatuses[0].State = apiv1.ContainerState{Running: &apiv1.ContainerStateRunning{}}
+	phase, _, _, _ = wfc.podToNodeUpdate(pod)
+	assert.Equal(t, wfv1.NodePhase(""), phase)
+}
+
+func TestPodToNodeUpdateRestartedContainer(t *testing.T) {
+	wfc := &WorkflowController{}
+	pod := unmarshalPod(t, lingeringPod)
+	pod.Spec.RestartPolicy = apiv1.RestartPolicyOnFailure
+	pod.Status.ContainerStatuses[0].State = apiv1.ContainerState{Running: &apiv1.ContainerStateRunning{}}
+	pod.Status.ContainerStatuses[0].RestartCount = 1
+	phase, _, reason, msg := wfc.podToNodeUpdate(pod)
+	assert.Equal(t, wfv1.NodeFailed, phase)
+	assert.Equal(t, wfv1.NodeReasonContainerRestarted, reason)
+	assert.Equal(t, "main container restarted (pod restartPolicy is OnFailure, expected Never)", msg)
+
+	// sidecars may be restarted by their own means
+	pod.Status.ContainerStatuses[0].RestartCount = 0
+	pod.Status.ContainerStatuses[2].RestartCount = 1
+	phase, _, _, _ = wfc.podToNodeUpdate(pod)
+	assert.Equal(t, wfv1.NodePhase(""), phase)
+}
+
+// newFailedPod returns a failed pod, whose containers terminated with the given exit codes
+func newFailedPod(exitCodes map[string]int32) *apiv1.Pod {
+	pod := &apiv1.Pod{Status: apiv1.PodStatus{Phase: apiv1.PodFailed}}
+	for _, name := range []string{common.MainContainerName, common.WaitContainerName, "sidecar-b", "sidecar-a"} {
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, apiv1.ContainerStatus{
+			Name:  name,
+			State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: exitCodes[name]}},
+		})
+	}
+	return pod
+}
+
+func TestInferFailedReasonOrdering(t *testing.T) {
+	wfc := &WorkflowController{}
+	// the main container takes precedence over the wait container and the sidecars
+	pod := newFailedPod(map[string]int32{common.MainContainerName: 1, common.WaitContainerName: 1, "sidecar-a": 1})
+	phase, _, reason, msg := wfc.inferFailedReason(pod)
+	assert.Equal(t, wfv1.NodeFailed, phase)
+	assert.Equal(t, wfv1.NodeReasonExitCode, reason)
+	assert.Equal(t, "failed with exit code 1", msg)
+
+	// the wait container takes precedence over the sidecars
+	pod = newFailedPod(map[string]int32{common.WaitContainerName: 1, "sidecar-a": 1})
+	phase, _, reason, msg = wfc.inferFailedReason(pod)
+	assert.Equal(t, wfv1.NodeError, phase)
+	assert.Equal(t, wfv1.NodeReasonArtifactSaveError, reason)
+	assert.Equal(t, "failed to save artifacts", msg)
+
+	wfc.config = &WorkflowControllerConfig{WaitContainerFailurePhase: wfv1.NodeFailed}
+	phase, _, _, _ = wfc.inferFailedReason(pod)
+	assert.Equal(t, wfv1.NodeFailed, phase)
+
+	// sidecars are ordered by name
+	for i := 0; i < 10; i++ {
+		pod = newFailedPod(map[string]int32{"sidecar-b": 2, "sidecar-a": 3})
+		phase, _, _, msg = wfc.inferFailedReason(pod)
+		assert.Equal(t, wfv1.NodeFailed, phase)
+		assert.Equal(t, "failed with exit code 3", msg)
+	}
+}
+
+func TestParseConfigWaitContainerFailurePhase(t *testing.T) {
+	wfc := &WorkflowController{ConfigMap: "workflow-controller-configmap"}
+	cm := &apiv1.ConfigMap{Data: map[string]string{
+		common.WorkflowControllerConfigMapKey: "executorImage: argoproj/argoexec:latest\nwaitContainerFailurePhase: Failed\n",
+	}}
+	config, err := wfc.parseConfig(cm)
+	if assert.Nil(t, err) {
+		assert.Equal(t, wfv1.NodeFailed, config.WaitContainerFailurePhase)
+	}
+	cm.Data[common.WorkflowControllerConfigMapKey] = "executorImage: argoproj/argoexec:latest\nwaitContainerFailurePhase: Succeeded\n"
+	_, err = wfc.parseConfig(cm)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "waitContainerFailurePhase must be Error or Failed")
+	}
+}
+
+func TestCompletedPodCacheMaxEntries(t *testing.T) {
+	wfc := &WorkflowController{
+		config:            &WorkflowControllerConfig{CompletedPodCacheMaxEntries: 10},
+		completedPodCache: gocache.New(time.Hour, 0),
+	}
+	for i := 0; i < 10; i++ {
+		wfc.rememberCompletedPod(&apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pod-%02d", i), Namespace: "argo"}})
+	}
+	assert.Equal(t, 10, wfc.completedPodCache.ItemCount())
+	// the cache is shrunk to 9 entries, evicting the pod remembered first, before remembering the new pod
+	wfc.rememberCompletedPod(&apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-10", Namespace: "argo"}})
+	assert.Equal(t, 10, wfc.completedPodCache.ItemCount())
+	_, found := wfc.completedPodCache.Get("argo/pod-00")
+	assert.False(t, found)
+	_, found = wfc.completedPodCache.Get("argo/pod-10")
+	assert.True(t, found)
+	// pods are remembered by namespace and name
+	_, found = wfc.completedPodCache.Get("other/pod-10")
+	assert.False(t, found)
+
+	// expired pods are deleted first
+	wfc.completedPodCache.Set("argo/pod-01", true, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	wfc.rememberCompletedPod(&apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-11", Namespace: "argo"}})
+	assert.Equal(t, 10, wfc.completedPodCache.ItemCount())
+	_, found = wfc.completedPodCache.Get("argo/pod-02")
+	assert.True(t, found)
+}
+
+func TestParseConfigCompletedPodCache(t *testing.T) {
+	wfc := &WorkflowController{ConfigMap: "workflow-controller-configmap"}
+	cm := &apiv1.ConfigMap{Data: map[string]string{
+		common.WorkflowControllerConfigMapKey: "executorImage: argoproj/argoexec:latest\ncompletedPodCacheCleanupInterval: 1m\ncompletedPodCacheMaxEntries: 10000\n",
+	}}
+	config, err := wfc.parseConfig(cm)
+	if assert.Nil(t, err) {
+		interval, err := config.getCompletedPodCacheCleanupInterval()
+		assert.Nil(t, err)
+		assert.Equal(t, time.Minute, interval)
+	}
+	cm.Data[common.WorkflowControllerConfigMapKey] = "executorImage: argoproj/argoexec:latest\ncompletedPodCacheCleanupInterval: 0s\n"
+	_, err = wfc.parseConfig(cm)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "completedPodCacheCleanupInterval must be positive")
+	}
+	cm.Data[common.WorkflowControllerConfigMapKey] = "executorImage: argoproj/argoexec:latest\ncompletedPodCacheMaxEntries: -1\n"
+	_, err = wfc.parseConfig(cm)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "completedPodCacheMaxEntries must not be negative")
+	}
+}
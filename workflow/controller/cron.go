@@ -0,0 +1,292 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/argoproj/argo/errors"
+	workflowclient "github.com/argoproj/argo/workflow/client"
+	"github.com/argoproj/argo/workflow/common"
+	"github.com/robfig/cron"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+)
+
+// cronSyncPeriod is the interval at which the schedules of the CronWorkflows are evaluated
+const cronSyncPeriod = 10 * time.Second
+
+// maxMissedSchedules is the number of missed schedules of a CronWorkflow beyond which none of its workflows is
+// started, as in the CronJob controller, so that a long outage or clock skew does not cost an unbounded search
+const maxMissedSchedules = 100
+
+// tooManyMissedSchedulesEventReason is the reason of the event of a CronWorkflow which missed too many schedules
+const tooManyMissedSchedulesEventReason = "TooManyMissedSchedules"
+
+// watchCronWorkflows starts an informer caching the CronWorkflows in the watched namespace(s) which match the
+// match labels and instance id of the controller. The informer is stopped when ctx is done.
+func (wfc *WorkflowController) watchCronWorkflows(ctx context.Context) cache.Controller {
+	c := wfc.restClient
+	namespace := wfc.watchNamespace()
+	source := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return c.Get().
+				Namespace(namespace).
+				Resource(wfv1.CronWorkflowCRDPlural).
+				Param("labelSelector", wfc.labelSelector()).
+				VersionedParams(&options, metav1.ParameterCodec).
+				Do().Get()
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.Watch = true
+			return c.Get().
+				Namespace(namespace).
+				Resource(wfv1.CronWorkflowCRDPlural).
+				Param("labelSelector", wfc.labelSelector()).
+				VersionedParams(&options, metav1.ParameterCodec).
+				Watch()
+		},
+	}
+	var controller cache.Controller
+	wfc.cronWfStore, controller = cache.NewInformer(source, &wfv1.CronWorkflow{}, 0, cache.ResourceEventHandlerFuncs{})
+	go controller.Run(ctx.Done())
+	return controller
+}
+
+// runCronWorkflows periodically starts the workflows of the CronWorkflows whose scheduled time was reached,
+// until ctx is done
+func (wfc *WorkflowController) runCronWorkflows(ctx context.Context) {
+	ticker := time.NewTicker(cronSyncPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if wfc.getConfig().Maintenance {
+				// the scheduled workflows are started, or skipped, once maintenance mode is exited
+				continue
+			}
+			if wait := wfc.apiCircuitWait(); wait > 0 {
+				continue
+			}
+			for _, obj := range wfc.cronWfStore.List() {
+				cwf, ok := obj.(*wfv1.CronWorkflow)
+				if !ok {
+					continue
+				}
+				err := wfc.syncCronWorkflow(cwf, time.Now())
+				if err != nil {
+					log.Errorf("Failed to sync CronWorkflow %s/%s: %v", cwf.ObjectMeta.Namespace, cwf.ObjectMeta.Name, err)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// syncCronWorkflow starts the workflow of a CronWorkflow once its scheduled time is reached, applying its
+// concurrency policy. If several scheduled times were missed (e.g. while the controller was down), only the
+// workflow of the most recent one is started, provided its starting deadline has not passed. Schedules before the
+// starting deadline are not considered, and if more than maxMissedSchedules are missed, no workflow is started.
+func (wfc *WorkflowController) syncCronWorkflow(cwf *wfv1.CronWorkflow, now time.Time) error {
+	if cwf.Spec.Suspend {
+		return nil
+	}
+	logCtx := log.WithFields(log.Fields{"namespace": cwf.ObjectMeta.Namespace, "cronWorkflow": cwf.ObjectMeta.Name})
+	schedule, err := cron.ParseStandard(cwf.Spec.Schedule)
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "spec.schedule '%s' is invalid: %v", cwf.Spec.Schedule, err)
+	}
+	loc, err := time.LoadLocation(cwf.Spec.Timezone)
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "spec.timezone '%s' is invalid: %v", cwf.Spec.Timezone, err)
+	}
+	since := cwf.ObjectMeta.CreationTimestamp.Time
+	if cwf.Status.LastScheduledTime != nil {
+		since = cwf.Status.LastScheduledTime.Time
+	}
+	if deadline := cwf.Spec.StartingDeadlineSeconds; deadline != nil {
+		// the schedules before the deadline would be skipped anyway
+		if earliest := now.Add(-time.Duration(*deadline) * time.Second); since.Before(earliest) {
+			since = earliest
+		}
+	}
+	scheduledTime, missed := mostRecentScheduledTime(schedule, since.In(loc), now)
+	if missed > maxMissedSchedules {
+		msg := fmt.Sprintf("Too many missed schedules (more than %d), no workflow is started: set or decrease spec.startingDeadlineSeconds, or check for clock skew", maxMissedSchedules)
+		logCtx.Warn(msg)
+		if wfc.eventRecorder != nil {
+			wfc.eventRecorder.Event(cwf, apiv1.EventTypeWarning, tooManyMissedSchedulesEventReason, msg)
+		}
+		return nil
+	}
+	if scheduledTime.IsZero() {
+		return nil
+	}
+	logCtx = logCtx.WithField("scheduledTime", scheduledTime)
+	if missed > 0 {
+		logCtx.Warnf("Missed %d scheduled workflow(s), only the most recent is considered", missed)
+	}
+	if deadline := cwf.Spec.StartingDeadlineSeconds; deadline != nil && now.Sub(scheduledTime) > time.Duration(*deadline)*time.Second {
+		logCtx.Warnf("Skipping scheduled workflow: starting deadline of %d seconds exceeded", *deadline)
+		return wfc.updateLastScheduledTime(cwf, scheduledTime)
+	}
+	active := wfc.activeCronWorkflowRuns(cwf)
+	if len(active) > 0 {
+		switch cwf.Spec.ConcurrencyPolicy {
+		case wfv1.ConcurrencyPolicyForbid:
+			logCtx.Infof("Skipping scheduled workflow: %d workflow(s) still running", len(active))
+			return wfc.updateLastScheduledTime(cwf, scheduledTime)
+		case wfv1.ConcurrencyPolicyReplace:
+			for _, wf := range active {
+				logCtx.Infof("Terminating workflow %s, to be replaced by the scheduled workflow", wf.ObjectMeta.Name)
+				err = wfc.terminateWorkflow(wf)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	err = wfc.createCronWorkflowRun(cwf, scheduledTime)
+	if err != nil {
+		// the workflow is created on the next sync, since the last scheduled time was not updated
+		return err
+	}
+	return wfc.updateLastScheduledTime(cwf, scheduledTime)
+}
+
+// mostRecentScheduledTime returns the most recent time of the schedule after since and not after now, along
+// with the number of earlier times of the schedule after since. Returns the zero time if there is none. The
+// search stops once more than maxMissedSchedules times are missed, returning the zero time.
+func mostRecentScheduledTime(schedule cron.Schedule, since time.Time, now time.Time) (time.Time, int) {
+	var scheduledTime time.Time
+	missed := -1
+	for t := schedule.Next(since); !t.IsZero() && !t.After(now); t = schedule.Next(t) {
+		scheduledTime = t
+		missed++
+		if missed > maxMissedSchedules {
+			return time.Time{}, missed
+		}
+	}
+	if missed < 0 {
+		return time.Time{}, 0
+	}
+	return scheduledTime, missed
+}
+
+// activeCronWorkflowRuns returns the incomplete workflows created by a CronWorkflow
+func (wfc *WorkflowController) activeCronWorkflowRuns(cwf *wfv1.CronWorkflow) []*wfv1.Workflow {
+	var active []*wfv1.Workflow
+	if wfc.wfStore == nil {
+		return active
+	}
+	for _, obj := range wfc.wfStore.List() {
+		wf, ok := obj.(*wfv1.Workflow)
+		if !ok || wf.ObjectMeta.Namespace != cwf.ObjectMeta.Namespace {
+			continue
+		}
+		if wf.ObjectMeta.Labels[common.LabelKeyCronWorkflow] != cwf.ObjectMeta.Name || wf.ObjectMeta.Labels[common.LabelKeyCompleted] == "true" {
+			continue
+		}
+		active = append(active, wf)
+	}
+	return active
+}
+
+// newCronWorkflowRun returns the workflow of a CronWorkflow for the given scheduled time. Its name is derived
+// from the scheduled time, so that a workflow is created at most once per scheduled time. The workflow has the
+// labels of the CronWorkflow, so that it is processed by the same controller, and is owned by the CronWorkflow.
+func newCronWorkflowRun(cwf *wfv1.CronWorkflow, scheduledTime time.Time) *wfv1.Workflow {
+	labels := map[string]string{common.LabelKeyCronWorkflow: cwf.ObjectMeta.Name}
+	for key, value := range cwf.ObjectMeta.Labels {
+		labels[key] = value
+	}
+	t := true
+	return &wfv1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", cwf.ObjectMeta.Name, scheduledTime.Unix()),
+			Namespace: cwf.ObjectMeta.Namespace,
+			Labels:    labels,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion:         wfv1.SchemeGroupVersion.String(),
+				Kind:               wfv1.CronWorkflowCRDKind,
+				Name:               cwf.ObjectMeta.Name,
+				UID:                cwf.ObjectMeta.UID,
+				BlockOwnerDeletion: &t,
+			}},
+		},
+		Spec: *cwf.Spec.WorkflowSpec.DeepCopy(),
+	}
+}
+
+// createCronWorkflowRun creates the workflow of a CronWorkflow for the given scheduled time
+func (wfc *WorkflowController) createCronWorkflowRun(cwf *wfv1.CronWorkflow, scheduledTime time.Time) error {
+	wf := newCronWorkflowRun(cwf, scheduledTime)
+	logCtx := log.WithFields(log.Fields{"namespace": cwf.ObjectMeta.Namespace, "cronWorkflow": cwf.ObjectMeta.Name})
+	if wfc.skipInDryRun(logCtx, "creation of workflow %s", wf.ObjectMeta.Name) {
+		return nil
+	}
+	wfClient := workflowclient.NewWorkflowClient(wfc.restClient, wfc.scheme, cwf.ObjectMeta.Namespace)
+	_, err := wfClient.CreateWorkflow(wf)
+	wfc.recordAPIResult(err)
+	if err != nil {
+		if apierr.IsAlreadyExists(err) {
+			// created by an earlier sync, whose update of the CronWorkflow failed
+			logCtx.Infof("Workflow %s already exists", wf.ObjectMeta.Name)
+			return nil
+		}
+		return errors.InternalWrapError(err)
+	}
+	logCtx.Infof("Created workflow %s", wf.ObjectMeta.Name)
+	return nil
+}
+
+// terminateWorkflow terminates a running workflow (see ShutdownStrategyTerminate)
+func (wfc *WorkflowController) terminateWorkflow(wf *wfv1.Workflow) error {
+	if wfc.skipInDryRun(log.StandardLogger(), "termination of workflow %s", wf.ObjectMeta.Name) {
+		return nil
+	}
+	wfClient := workflowclient.NewWorkflowClient(wfc.restClient, wfc.scheme, wf.ObjectMeta.Namespace)
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		latest, err := wfClient.GetWorkflow(wf.ObjectMeta.Name)
+		if err != nil {
+			return err
+		}
+		latest.Spec.Shutdown = wfv1.ShutdownStrategyTerminate
+		_, err = wfClient.UpdateWorkflow(latest)
+		return err
+	})
+	wfc.recordAPIResult(err)
+	if err != nil && !apierr.IsNotFound(err) {
+		return errors.InternalWrapError(err)
+	}
+	return nil
+}
+
+// updateLastScheduledTime records the scheduled time of the last workflow of a CronWorkflow in its status
+func (wfc *WorkflowController) updateLastScheduledTime(cwf *wfv1.CronWorkflow, scheduledTime time.Time) error {
+	cwf = cwf.DeepCopyObject().(*wfv1.CronWorkflow)
+	cwf.Status.LastScheduledTime = &metav1.Time{Time: scheduledTime}
+	if wfc.skipInDryRun(log.StandardLogger(), "update of CronWorkflow %s", cwf.ObjectMeta.Name) {
+		return nil
+	}
+	err := wfc.restClient.Put().
+		Namespace(cwf.ObjectMeta.Namespace).
+		Resource(wfv1.CronWorkflowCRDPlural).
+		Name(cwf.ObjectMeta.Name).
+		Body(cwf).
+		Do().
+		Error()
+	wfc.recordAPIResult(err)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/argoproj/argo/workflow/common"
+	"github.com/robfig/cron"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestMostRecentScheduledTime(t *testing.T) {
+	schedule, err := cron.ParseStandard("0 * * * *")
+	if !assert.Nil(t, err) {
+		return
+	}
+	since := time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC)
+	scheduledTime, missed := mostRecentScheduledTime(schedule, since, since.Add(59*time.Minute))
+	assert.True(t, scheduledTime.IsZero())
+	assert.Equal(t, 0, missed)
+
+	scheduledTime, missed = mostRecentScheduledTime(schedule, since, since.Add(time.Hour))
+	assert.Equal(t, since.Add(time.Hour), scheduledTime)
+	assert.Equal(t, 0, missed)
+
+	// e.g. the controller was down for 3 hours
+	scheduledTime, missed = mostRecentScheduledTime(schedule, since, since.Add(3*time.Hour+30*time.Minute))
+	assert.Equal(t, since.Add(3*time.Hour), scheduledTime)
+	assert.Equal(t, 2, missed)
+
+	// the search stops once too many times are missed
+	scheduledTime, missed = mostRecentScheduledTime(schedule, since, since.AddDate(1, 0, 0))
+	assert.True(t, scheduledTime.IsZero())
+	assert.Equal(t, maxMissedSchedules+1, missed)
+}
+
+func TestSyncCronWorkflowTooManyMissedSchedules(t *testing.T) {
+	now := time.Date(2018, 6, 1, 10, 30, 0, 0, time.UTC)
+	cwf := &wfv1.CronWorkflow{
+		ObjectMeta: metav1.ObjectMeta{Name: "hourly", Namespace: "argo", CreationTimestamp: metav1.Time{Time: now.AddDate(0, -1, 0)}},
+		Spec:       wfv1.CronWorkflowSpec{Schedule: "@hourly"},
+	}
+	recorder := record.NewFakeRecorder(1)
+	wfc := &WorkflowController{eventRecorder: recorder}
+	err := wfc.syncCronWorkflow(cwf, now)
+	assert.Nil(t, err)
+	select {
+	case event := <-recorder.Events:
+		assert.Contains(t, event, "Warning "+tooManyMissedSchedulesEventReason)
+	default:
+		t.Fatal("no event was recorded")
+	}
+
+	// schedules before the starting deadline are not considered
+	deadline := int64(300)
+	cwf.Spec.StartingDeadlineSeconds = &deadline
+	err = wfc.syncCronWorkflow(cwf, now)
+	assert.Nil(t, err)
+	assert.Len(t, recorder.Events, 0)
+}
+
+func TestNewCronWorkflowRun(t *testing.T) {
+	cwf := &wfv1.CronWorkflow{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nightly",
+			Namespace: "argo",
+			UID:       "1234",
+			Labels:    map[string]string{common.LabelKeyControllerInstanceID: "test"},
+		},
+		Spec: wfv1.CronWorkflowSpec{
+			Schedule:     "0 2 * * *",
+			WorkflowSpec: wfv1.WorkflowSpec{Entrypoint: "main"},
+		},
+	}
+	scheduledTime := time.Date(2018, 6, 1, 2, 0, 0, 0, time.UTC)
+	wf := newCronWorkflowRun(cwf, scheduledTime)
+	assert.Equal(t, "nightly-1527818400", wf.ObjectMeta.Name)
+	assert.Equal(t, "argo", wf.ObjectMeta.Namespace)
+	assert.Equal(t, map[string]string{
+		common.LabelKeyCronWorkflow:         "nightly",
+		common.LabelKeyControllerInstanceID: "test",
+	}, wf.ObjectMeta.Labels)
+	if assert.Len(t, wf.ObjectMeta.OwnerReferences, 1) {
+		assert.Equal(t, wfv1.CronWorkflowCRDKind, wf.ObjectMeta.OwnerReferences[0].Kind)
+		assert.Equal(t, cwf.ObjectMeta.UID, wf.ObjectMeta.OwnerReferences[0].UID)
+	}
+	assert.Equal(t, "main", wf.Spec.Entrypoint)
+
+	// the active workflows of the CronWorkflow
+	wfc := &WorkflowController{wfStore: cache.NewStore(cache.MetaNamespaceKeyFunc)}
+	assert.Nil(t, wfc.wfStore.Add(wf))
+	other := newCronWorkflowRun(&wfv1.CronWorkflow{ObjectMeta: metav1.ObjectMeta{Name: "hourly", Namespace: "argo"}}, scheduledTime)
+	assert.Nil(t, wfc.wfStore.Add(other))
+	active := wfc.activeCronWorkflowRuns(cwf)
+	if assert.Len(t, active, 1) {
+		assert.Equal(t, wf.ObjectMeta.Name, active[0].ObjectMeta.Name)
+	}
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/argoproj/argo/workflow/common"
+)
+
+// executeDAG executes the tasks of a DAG template. A task is started once all of its dependencies have
+// succeeded. Tasks which depend (directly or indirectly) on an unsuccessful task are never started.
+// Task nodes are named <nodeName>.<taskName>, and are children of the DAG node. The DAG node completes
+// once no more tasks are running or can be started.
+func (woc *wfOperationCtx) executeDAG(nodeName string, tmpl *wfv1.Template) error {
+	tasks, err := common.SortDAGTasks(tmpl.DAG)
+	if err != nil {
+		woc.markNodeError(nodeName, err)
+		return err
+	}
+	scope := wfScope{
+		tmpl:  tmpl,
+		scope: make(map[string]interface{}),
+	}
+	// blocked holds the tasks which were unsuccessful, or which depend on an unsuccessful task
+	blocked := make(map[string]bool)
+	var failedTasks []string
+	completed := true
+	// Since the tasks are sorted, the dependencies of a task are evaluated before the task itself
+	for _, task := range tasks {
+		ready := true
+		for _, dep := range task.Dependencies {
+			if blocked[dep] {
+				blocked[task.Name] = true
+				break
+			}
+			depNode, ok := woc.wf.Status.Nodes[woc.wf.NodeID(dagTaskNodeName(nodeName, dep))]
+			if !ok || !depNode.Completed() {
+				ready = false
+			}
+		}
+		if blocked[task.Name] {
+			continue
+		}
+		if !ready {
+			completed = false
+			continue
+		}
+
+		taskNodeName := dagTaskNodeName(nodeName, task.Name)
+		taskNode, ok := woc.wf.Status.Nodes[woc.wf.NodeID(taskNodeName)]
+		if !ok || !taskNode.Completed() {
+			// The child is added first, so that a running DAG node always has children (like steps nodes)
+			woc.addChildNode(nodeName, taskNodeName)
+			err = woc.executeDAGTask(taskNodeName, task, &scope)
+			if err != nil {
+				woc.markNodeError(taskNodeName, err)
+				woc.markNodeError(nodeName, err)
+				return err
+			}
+			taskNode, ok = woc.wf.Status.Nodes[woc.wf.NodeID(taskNodeName)]
+		}
+		if !ok || !taskNode.Completed() {
+			completed = false
+			continue
+		}
+		if !taskNode.Successful() {
+			blocked[task.Name] = true
+			failedTasks = append(failedTasks, task.Name)
+			continue
+		}
+		scope.addNodeOutputsToScope(fmt.Sprintf("tasks.%s", task.Name), taskNode)
+	}
+	if !completed {
+		return nil
+	}
+	if len(failedTasks) > 0 {
+		failMessage := fmt.Sprintf("task(s) %s were unsuccessful", strings.Join(failedTasks, ", "))
+		woc.log.Infof("DAG %s failed: %s", nodeName, failMessage)
+		woc.markNodePhase(nodeName, wfv1.NodeFailed, failMessage)
+		return nil
+	}
+	woc.markNodePhase(nodeName, wfv1.NodeSucceeded)
+	return nil
+}
+
+// executeDAGTask resolves the references of a task, whose dependencies have all succeeded, to the
+// outputs of its dependencies, and executes the task's template unless its when expression is false
+func (woc *wfOperationCtx) executeDAGTask(taskNodeName string, task wfv1.DAGTask, scope *wfScope) error {
+	// A task is resolved as a step, since their references are resolved alike
+	steps, err := woc.resolveReferences([]wfv1.WorkflowStep{{
+		Name:      task.Name,
+		Template:  task.Template,
+		Arguments: task.Arguments,
+		When:      task.When,
+	}}, scope)
+	if err != nil {
+		return err
+	}
+	proceed, err := shouldExecute(steps[0].When)
+	if err != nil {
+		return err
+	}
+	if !proceed {
+		skipReason := fmt.Sprintf("when '%s' evaluated false", steps[0].When)
+		woc.log.Infof("Skipping %s: %s", taskNodeName, skipReason)
+		woc.markNodePhase(taskNodeName, wfv1.NodeSkipped, skipReason)
+		return nil
+	}
+	return woc.executeTemplate(task.Template, steps[0].Arguments, taskNodeName)
+}
+
+// dagTaskNodeName returns the name of the node of a task of a DAG
+func dagTaskNodeName(dagNodeName string, taskName string) string {
+	return fmt.Sprintf("%s.%s", dagNodeName, taskName)
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"github.com/argoproj/argo/workflow/common"
+)
+
+// Workflows are given a finalizer while they are running, so that deleting a workflow mid-run does not
+// orphan its pods. Since a workflow with a finalizer is not removed immediately, the deletion is observed
+// as an update setting its deletionTimestamp, which is enqueued like any other update. The informer lists
+// workflows pending deletion after a restart, so cleanup also completes when the controller restarts.
+
+// addFinalizer adds the pod cleanup finalizer to the workflow, if not already present
+func (woc *wfOperationCtx) addFinalizer() {
+	for _, f := range woc.wf.ObjectMeta.Finalizers {
+		if f == common.FinalizerPodCleanup {
+			return
+		}
+	}
+	woc.wf.ObjectMeta.Finalizers = append(woc.wf.ObjectMeta.Finalizers, common.FinalizerPodCleanup)
+	woc.updated = true
+}
+
+// removeFinalizer removes the pod cleanup finalizer from the workflow, if present
+func (woc *wfOperationCtx) removeFinalizer() {
+	finalizers := make([]string, 0, len(woc.wf.ObjectMeta.Finalizers))
+	for _, f := range woc.wf.ObjectMeta.Finalizers {
+		if f != common.FinalizerPodCleanup {
+			finalizers = append(finalizers, f)
+		}
+	}
+	if len(finalizers) == len(woc.wf.ObjectMeta.Finalizers) {
+		return
+	}
+	woc.wf.ObjectMeta.Finalizers = finalizers
+	woc.updated = true
+}
+
+// finalizeWorkflow deletes the pods of a workflow which is being deleted, then removes the finalizer
+// so that the deletion can proceed. Pod deletion is retried later if it fails.
+func (woc *wfOperationCtx) finalizeWorkflow() {
+	hasFinalizer := false
+	for _, f := range woc.wf.ObjectMeta.Finalizers {
+		if f == common.FinalizerPodCleanup {
+			hasFinalizer = true
+		}
+	}
+	if !hasFinalizer {
+		return
+	}
+	woc.log.Infof("Workflow is being deleted. Deleting its pods")
+	err := woc.deleteWorkflowPods()
+	if err != nil {
+		woc.log.Errorf("Failed to delete pods of deleted workflow: %+v", err)
+		woc.requeueAfter(podGCRetryDelay)
+		return
+	}
+	woc.removeFinalizer()
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/tools/cache"
+)
+
+// defaultHealthPort is the port the controller serves health probes on when unspecified in the config
+const defaultHealthPort = 6060
+
+const (
+	// healthzPath is the HTTP path of the liveness probe, which succeeds as long as the process is alive
+	healthzPath = "/healthz"
+	// readyzPath is the HTTP path of the readiness probe, which succeeds once the controller config is
+	// loaded and the workflow and pod informers have synced
+	readyzPath = "/readyz"
+	// artifactRepositoryPath is the HTTP path serving the effective artifact repository (as JSON) of the config,
+	// or of the incomplete workflow given by the 'workflow' query parameter (as <namespace>/<name>), for
+	// troubleshooting misconfigured repositories. It exposes the references to secrets, but not their values.
+	artifactRepositoryPath = "/artifactrepository"
+)
+
+// GetHealthPort returns the port on which the controller serves health probes
+func (c *WorkflowControllerConfig) GetHealthPort() int {
+	if c.HealthPort == 0 {
+		return defaultHealthPort
+	}
+	return c.HealthPort
+}
+
+// setInformers records the workflow and pod informers of the leader, so their sync state can be
+// reported by the readiness probe. Set to nil when the controller stops leading.
+func (wfc *WorkflowController) setInformers(wfInformer cache.Controller, podInformer cache.Controller) {
+	wfc.informersLock.Lock()
+	defer wfc.informersLock.Unlock()
+	wfc.wfInformer = wfInformer
+	wfc.podInformer = podInformer
+}
+
+// checkReady returns an error describing why the controller is not yet ready, or nil if it is ready
+func (wfc *WorkflowController) checkReady() error {
+	if atomic.LoadInt32(&wfc.configLoaded) == 0 {
+		return fmt.Errorf("controller config not loaded")
+	}
+	wfc.informersLock.Lock()
+	defer wfc.informersLock.Unlock()
+	if wfc.wfInformer == nil || wfc.podInformer == nil {
+		if wfc.getConfig().LeaderElection != nil {
+			// Standby replicas do not run informers until they are elected leader
+			return nil
+		}
+		return fmt.Errorf("informers not started")
+	}
+	if !wfc.wfInformer.HasSynced() {
+		return fmt.Errorf("workflow informer not synced")
+	}
+	if !wfc.podInformer.HasSynced() {
+		return fmt.Errorf("pod informer not synced")
+	}
+	return nil
+}
+
+// runHealthServer starts an HTTP server exposing liveness and readiness probes, and the effective artifact
+// repository. The server is shut down when ctx is done.
+func (wfc *WorkflowController) runHealthServer(ctx context.Context) {
+	port := wfc.getConfig().GetHealthPort()
+	mux := http.NewServeMux()
+	mux.HandleFunc(healthzPath, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc(readyzPath, func(w http.ResponseWriter, r *http.Request) {
+		err := wfc.checkReady()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc(artifactRepositoryPath, wfc.serveArtifactRepository)
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: mux,
+	}
+	go func() {
+		log.Infof("Starting health server at :%d", port)
+		err := srv.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Errorf("Health server failed: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+}
+
+// serveArtifactRepository serves the effective artifact repository of the config or of a workflow
+func (wfc *WorkflowController) serveArtifactRepository(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var wf *wfv1.Workflow
+	if key := r.URL.Query().Get("workflow"); key != "" {
+		var obj interface{}
+		exists := false
+		if wfc.wfStore != nil {
+			var err error
+			obj, exists, err = wfc.wfStore.GetByKey(key)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if !exists {
+			http.Error(w, fmt.Sprintf("incomplete workflow %s not found", key), http.StatusNotFound)
+			return
+		}
+		var ok bool
+		wf, ok = obj.(*wfv1.Workflow)
+		if !ok {
+			http.Error(w, fmt.Sprintf("unexpected object of workflow %s", key), http.StatusInternalServerError)
+			return
+		}
+	}
+	repo, err := wfc.GetArtifactRepository(wf)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	repoBytes, err := json.MarshalIndent(repo, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(repoBytes)
+}
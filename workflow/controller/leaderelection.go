@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/argoproj/argo/errors"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// defaultLeaderElectionLockName is the name of the configmap used as the leader election lock
+	defaultLeaderElectionLockName = "workflow-controller-leader"
+
+	leaderElectionLeaseDuration = 15 * time.Second
+	leaderElectionRenewDeadline = 10 * time.Second
+	leaderElectionRetryPeriod   = 2 * time.Second
+)
+
+// LeaderElectionConfig configures leader election amongst multiple controller replicas.
+// The lock is a configmap in the same namespace as the controller configmap, so the controller's
+// service account requires get/create/update permissions on configmaps in that namespace.
+type LeaderElectionConfig struct {
+	// LockName is the name of the configmap used as the leader election lock.
+	// Defaults to workflow-controller-leader
+	LockName string `json:"lockName,omitempty"`
+
+	// Identity uniquely identifies this replica as a leader election candidate.
+	// Since the controller config is shared between replicas, this should generally be left
+	// empty, in which case the hostname (i.e. the pod name) is used.
+	Identity string `json:"identity,omitempty"`
+}
+
+// newLeaderElectionLock returns the configmap resource lock used to elect a leader
+func (wfc *WorkflowController) newLeaderElectionLock() (resourcelock.Interface, error) {
+	leaderElection := wfc.getConfig().LeaderElection
+	if leaderElection == nil {
+		leaderElection = &LeaderElectionConfig{}
+	}
+	lockName := leaderElection.LockName
+	if lockName == "" {
+		lockName = defaultLeaderElectionLockName
+	}
+	identity := leaderElection.Identity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, errors.InternalWrapError(err)
+		}
+		identity = hostname
+	}
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: wfc.clientset.CoreV1().Events(wfc.ConfigMapNS)})
+	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, apiv1.EventSource{Component: fmt.Sprintf("workflow-controller/%s", identity)})
+
+	lock := resourcelock.ConfigMapLock{
+		ConfigMapMeta: metav1.ObjectMeta{
+			Namespace: wfc.ConfigMapNS,
+			Name:      lockName,
+		},
+		Client: wfc.clientset.CoreV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity:      identity,
+			EventRecorder: recorder,
+		},
+	}
+	return &lock, nil
+}
+
+// runWithLeaderElection blocks until this replica acquires leadership, then operates on workflows
+// until the context is done. If leadership is lost, the process exits so that it can be restarted
+// as a standby replica with fresh informers.
+func (wfc *WorkflowController) runWithLeaderElection(ctx context.Context) error {
+	lock, err := wfc.newLeaderElectionLock()
+	if err != nil {
+		return err
+	}
+	leading := make(chan struct{})
+	leaderErr := make(chan error, 1)
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: leaderElectionLeaseDuration,
+		RenewDeadline: leaderElectionRenewDeadline,
+		RetryPeriod:   leaderElectionRetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(stop <-chan struct{}) {
+				log.Infof("%s acquired leadership", lock.Identity())
+				close(leading)
+				leaderCtx, cancel := context.WithCancel(ctx)
+				defer cancel()
+				go func() {
+					select {
+					case <-stop:
+					case <-leaderCtx.Done():
+					}
+					cancel()
+				}()
+				leaderErr <- wfc.runLeader(leaderCtx)
+			},
+			OnStoppedLeading: func() {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Fatalf("%s lost leadership", lock.Identity())
+			},
+			OnNewLeader: func(identity string) {
+				log.Infof("Workflow controller leader is %s", identity)
+			},
+		},
+	})
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	log.Infof("%s waiting to acquire leadership (%s)", lock.Identity(), lock.Describe())
+	go elector.Run()
+
+	select {
+	case err := <-leaderErr:
+		return err
+	case <-ctx.Done():
+		select {
+		case <-leading:
+			// wait for the leader to finish draining its updates
+			return <-leaderErr
+		default:
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/argoproj/argo/errors"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/retry"
+)
+
+// memoizationFailedEventReason is the reason of the event recorded when the outputs of a node could not be
+// saved to its memoization cache
+const memoizationFailedEventReason = "MemoizationFailed"
+
+// maxMemoizationCacheSize is the maximum size of the entries of a memoization cache config map. It is below
+// the 1MiB limit of the size of config maps, to leave room for their metadata.
+const maxMemoizationCacheSize = 900 * 1024
+
+// memoizedEntry is an entry of a memoization cache config map, stored as JSON under the cache key
+type memoizedEntry struct {
+	// NodeID is the ID of the node which produced the outputs
+	NodeID string `json:"nodeID"`
+	// Outputs are the outputs of the node
+	Outputs *wfv1.Outputs `json:"outputs,omitempty"`
+	// CreationTimestamp is the time at which the entry was stored
+	CreationTimestamp metav1.Time `json:"creationTimestamp"`
+}
+
+// validateMemoizationKey verifies the (substituted) cache key of a template can be stored in a config map
+func validateMemoizationKey(key string) error {
+	if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+		return errors.Errorf(errors.CodeBadRequest, "memoize.key '%s' is invalid: %s", key, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// executeMemoized looks up the outputs of a memoized template in its cache. Upon a cache hit, the node is
+// succeeded with the cached outputs and true is returned. Otherwise false is returned, and the template
+// should be executed.
+func (woc *wfOperationCtx) executeMemoized(nodeName string, tmpl *wfv1.Template) (bool, error) {
+	key := tmpl.Memoize.Key
+	cacheName := tmpl.Memoize.Cache.ConfigMap.Name
+	err := validateMemoizationKey(key)
+	if err != nil {
+		return false, err
+	}
+	cm, err := woc.controller.clientset.CoreV1().ConfigMaps(woc.wf.ObjectMeta.Namespace).Get(cacheName, metav1.GetOptions{})
+	if err != nil {
+		if apierr.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.InternalWrapError(err)
+	}
+	data, ok := cm.Data[key]
+	if !ok {
+		return false, nil
+	}
+	var entry memoizedEntry
+	err = json.Unmarshal([]byte(data), &entry)
+	if err != nil {
+		// a corrupt entry is overwritten once the template is executed
+		woc.log.Warnf("Ignoring invalid entry '%s' of memoization cache %s: %v", key, cacheName, err)
+		return false, nil
+	}
+	woc.log.Infof("Memoization cache hit of node %s: key '%s' of cache %s (from node %s)", nodeName, key, cacheName, entry.NodeID)
+	node := *woc.markNodePhase(nodeName, wfv1.NodeSucceeded)
+	node.Outputs = entry.Outputs
+	node.MemoizationStatus = &wfv1.MemoizationStatus{Hit: true, Key: key, CacheName: cacheName}
+	woc.wf.Status.Nodes[node.ID] = node
+	return true, nil
+}
+
+// markNodeMemoized records the cache key of a node of a memoized template whose outputs were not cached,
+// so that its outputs are stored in the cache once it succeeds
+func (woc *wfOperationCtx) markNodeMemoized(nodeName string, tmpl *wfv1.Template) {
+	nodeID := woc.wf.NodeID(nodeName)
+	node, ok := woc.wf.Status.Nodes[nodeID]
+	if !ok {
+		return
+	}
+	node.MemoizationStatus = &wfv1.MemoizationStatus{
+		Key:       tmpl.Memoize.Key,
+		CacheName: tmpl.Memoize.Cache.ConfigMap.Name,
+	}
+	woc.wf.Status.Nodes[nodeID] = node
+	woc.updated = true
+}
+
+// saveMemoizedOutputs stores the outputs of a succeeded node of a memoized template in its cache,
+// creating the cache config map if necessary. The oldest entries of the cache are evicted when the
+// outputs do not fit in the config map otherwise.
+func (wfc *WorkflowController) saveMemoizedOutputs(namespace string, node wfv1.NodeStatus) error {
+	status := node.MemoizationStatus
+	if status == nil || status.Hit || node.Phase != wfv1.NodeSucceeded {
+		return nil
+	}
+	logCtx := log.WithFields(log.Fields{"namespace": namespace, "node": node.Name, "cache": status.CacheName})
+	if wfc.skipInDryRun(logCtx, "save of memoized outputs '%s'", status.Key) {
+		return nil
+	}
+	entry, err := json.Marshal(memoizedEntry{
+		NodeID:            node.ID,
+		Outputs:           node.Outputs,
+		CreationTimestamp: metav1.Time{Time: time.Now().UTC()},
+	})
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	if len(status.Key)+len(entry) > maxMemoizationCacheSize {
+		return errors.Errorf(errors.CodeBadRequest, "memoized outputs '%s' of %d bytes exceed the maximum size of a cache (%d bytes)", status.Key, len(entry), maxMemoizationCacheSize)
+	}
+	cmClient := wfc.clientset.CoreV1().ConfigMaps(namespace)
+	err = retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		cm, err := cmClient.Get(status.CacheName, metav1.GetOptions{})
+		if apierr.IsNotFound(err) {
+			cm = &apiv1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: status.CacheName},
+				Data:       map[string]string{status.Key: string(entry)},
+			}
+			_, err = cmClient.Create(cm)
+			if apierr.IsAlreadyExists(err) {
+				// created concurrently. Retry as a conflict to update it instead
+				return apierr.NewConflict(apiv1.Resource("configmaps"), status.CacheName, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if cm.Data == nil {
+			cm.Data = make(map[string]string)
+		}
+		for _, evicted := range evictMemoizedEntries(cm.Data, status.Key, len(status.Key)+len(entry)) {
+			logCtx.Infof("Evicting memoized outputs '%s' to make room in the cache", evicted)
+		}
+		cm.Data[status.Key] = string(entry)
+		_, err = cmClient.Update(cm)
+		return err
+	})
+	if err != nil {
+		return errors.InternalWrapErrorf(err, "failed to save memoized outputs '%s' to %s: %v", status.Key, status.CacheName, err)
+	}
+	logCtx.Infof("Saved memoized outputs '%s'", status.Key)
+	return nil
+}
+
+// evictMemoizedEntries deletes the oldest entries of the data of a cache config map until an entry of the
+// given size can be stored under the key, replacing any previous entry of the key. Returns the evicted keys.
+// Invalid entries are evicted first.
+func evictMemoizedEntries(data map[string]string, key string, size int) []string {
+	total := size
+	keys := make([]string, 0, len(data))
+	created := make(map[string]time.Time)
+	for k, v := range data {
+		if k == key {
+			continue
+		}
+		total += len(k) + len(v)
+		keys = append(keys, k)
+		var entry memoizedEntry
+		if json.Unmarshal([]byte(v), &entry) == nil {
+			created[k] = entry.CreationTimestamp.Time
+		}
+	}
+	if total <= maxMemoizationCacheSize {
+		return nil
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !created[keys[i]].Equal(created[keys[j]]) {
+			return created[keys[i]].Before(created[keys[j]])
+		}
+		return keys[i] < keys[j]
+	})
+	evicted := make([]string, 0)
+	for _, k := range keys {
+		if total <= maxMemoizationCacheSize {
+			break
+		}
+		total -= len(k) + len(data[k])
+		delete(data, k)
+		evicted = append(evicted, k)
+	}
+	return evicted
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newMemoizedEntry returns a cache entry of the given size, created at the time
+func newMemoizedEntry(t *testing.T, created time.Time, size int) string {
+	entry := memoizedEntry{NodeID: "node", CreationTimestamp: metav1.Time{Time: created}}
+	data, err := json.Marshal(entry)
+	assert.NoError(t, err)
+	entry.NodeID += strings.Repeat("x", size-len(data))
+	data, err = json.Marshal(entry)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestEvictMemoizedEntries(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	size := maxMemoizationCacheSize / 4
+	data := map[string]string{
+		"new":     newMemoizedEntry(t, now, size),
+		"old":     newMemoizedEntry(t, now.Add(-time.Hour), size),
+		"older":   newMemoizedEntry(t, now.Add(-2*time.Hour), size),
+		"invalid": "{",
+	}
+	// the entries fit along with a small one
+	assert.Empty(t, evictMemoizedEntries(data, "key", 100))
+	assert.Len(t, data, 4)
+
+	// the invalid and oldest entries are evicted first
+	assert.Equal(t, []string{"invalid", "older"}, evictMemoizedEntries(data, "key", size))
+	assert.Equal(t, []string{"new", "old"}, sortedKeys(data))
+
+	// the previous entry of the key is replaced rather than evicted
+	assert.Empty(t, evictMemoizedEntries(data, "old", size*2))
+	assert.Equal(t, []string{"new", "old"}, sortedKeys(data))
+}
+
+func sortedKeys(data map[string]string) []string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestSaveMemoizedOutputs(t *testing.T) {
+	result := "hello"
+	node := wfv1.NodeStatus{
+		ID:                "memoized-abcde",
+		Name:              "memoized-abcde",
+		Phase:             wfv1.NodeSucceeded,
+		Outputs:           &wfv1.Outputs{Result: &result},
+		MemoizationStatus: &wfv1.MemoizationStatus{Key: "hello", CacheName: "cache"},
+	}
+	clientset := fake.NewSimpleClientset(&apiv1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cache", Namespace: "argo"},
+		Data:       map[string]string{"old": newMemoizedEntry(t, time.Now().Add(-time.Hour), maxMemoizationCacheSize-100)},
+	})
+	wfc := &WorkflowController{clientset: clientset}
+	err := wfc.saveMemoizedOutputs("argo", node)
+	assert.NoError(t, err)
+	cm, err := clientset.CoreV1().ConfigMaps("argo").Get("cache", metav1.GetOptions{})
+	assert.NoError(t, err)
+	// the old entry is evicted to make room for the outputs
+	assert.Equal(t, []string{"hello"}, sortedKeys(cm.Data))
+	var entry memoizedEntry
+	assert.NoError(t, json.Unmarshal([]byte(cm.Data["hello"]), &entry))
+	assert.Equal(t, "hello", *entry.Outputs.Result)
+
+	// outputs larger than the cache are rejected
+	large := strings.Repeat("x", maxMemoizationCacheSize)
+	node.Outputs.Result = &large
+	err = wfc.saveMemoizedOutputs("argo", node)
+	assert.Error(t, err)
+}
@@ -0,0 +1,343 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// defaultMetricsPort is the port the controller serves metrics on when unspecified in the config
+	defaultMetricsPort = 9090
+	// metricsPath is the HTTP path on which prometheus metrics are exposed
+	metricsPath = "/metrics"
+
+	metricsNamespace = "argo"
+	metricsSubsystem = "workflow_controller"
+
+	// nodePhaseMetricsPeriod is the interval at which the node phase gauges are computed
+	nodePhaseMetricsPeriod = 30 * time.Second
+)
+
+// nodePhases are the phases of the nodes reported by the node phase gauges
+var nodePhases = []wfv1.NodePhase{wfv1.NodeRunning, wfv1.NodeSucceeded, wfv1.NodeSkipped, wfv1.NodeFailed, wfv1.NodeError}
+
+// controllerMetrics holds the prometheus collectors which are updated by the controller
+type controllerMetrics struct {
+	registry *prometheus.Registry
+
+	workflowsOperated       prometheus.Counter
+	podUpdatesHandled       prometheus.Counter
+	podUpdatesSkipped       prometheus.Counter
+	configUpdateErrors      prometheus.Counter
+	operateWorkflowDuration prometheus.Histogram
+
+	// completed pod cache lookups upon pod updates, and evictions of completed pods from the cache
+	completedPodCacheHits      prometheus.Counter
+	completedPodCacheMisses    prometheus.Counter
+	completedPodCacheEvictions prometheus.Counter
+
+	// API server requests made by the controller, by verb, resource and result
+	apiRequests *prometheus.CounterVec
+
+	// nodes of the active (i.e. incomplete) workflows by phase, and those whose pod is stuck Pending
+	activeNodes        *prometheus.GaugeVec
+	activeNodesPending prometheus.Gauge
+}
+
+// newControllerMetrics creates the controller metrics and registers them in a dedicated registry.
+// Queue gauges are evaluated lazily at scrape time against the controller's work queues. The API
+// requests counter is created beforehand, since it instruments the controller's clients.
+func newControllerMetrics(wfc *WorkflowController, apiRequests *prometheus.CounterVec) *controllerMetrics {
+	m := controllerMetrics{
+		registry:    prometheus.NewRegistry(),
+		apiRequests: apiRequests,
+		workflowsOperated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "workflows_operated_total",
+			Help:      "Number of times a workflow was operated on by the controller",
+		}),
+		podUpdatesHandled: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "pod_updates_handled_total",
+			Help:      "Number of workflow pod updates handled by the controller",
+		}),
+		podUpdatesSkipped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "pod_updates_skipped_total",
+			Help:      "Number of workflow pod updates skipped because nothing meaningful changed since the last update",
+		}),
+		configUpdateErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "config_update_errors_total",
+			Help:      "Number of controller config updates rejected because the config was invalid",
+		}),
+		completedPodCacheHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "completed_pod_cache_hits_total",
+			Help:      "Number of pod updates ignored because the pod was found in the completed pod cache",
+		}),
+		completedPodCacheMisses: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "completed_pod_cache_misses_total",
+			Help:      "Number of pod updates of pods not found in the completed pod cache",
+		}),
+		completedPodCacheEvictions: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "completed_pod_cache_evictions_total",
+			Help:      "Number of completed pods evicted from the completed pod cache upon expiry of the cache TTL, or because the cache was full",
+		}),
+		operateWorkflowDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "operate_workflow_duration_seconds",
+			Help:      "Time spent in a single operation of a workflow",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+		}),
+		activeNodes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "active_workflow_nodes",
+			Help:      "Number of nodes of the active workflows, by phase",
+		}, []string{"phase"}),
+		activeNodesPending: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "active_workflow_nodes_pending",
+			Help:      "Number of running nodes of the active workflows whose pod is stuck Pending (see podPendingThreshold)",
+		}),
+	}
+	m.registry.MustRegister(
+		m.workflowsOperated,
+		m.podUpdatesHandled,
+		m.podUpdatesSkipped,
+		m.configUpdateErrors,
+		m.completedPodCacheHits,
+		m.completedPodCacheMisses,
+		m.completedPodCacheEvictions,
+		m.operateWorkflowDuration,
+		m.apiRequests,
+		m.activeNodes,
+		m.activeNodesPending,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "completed_pod_cache_size",
+			Help:      "Number of completed pods remembered in the completed pod cache, including expired pods not yet cleaned up",
+		}, func() float64 {
+			return float64(wfc.completedPodCache.ItemCount())
+		}),
+		newQueueGauge("workflow_queue_depth", "Number of workflow keys waiting to be processed", wfc.wfQueue),
+		newQueueGauge("pod_queue_depth", "Number of pod keys waiting to be processed", wfc.podQueue),
+		newQueueGauge("node_update_queue_depth", "Number of workflows with batched node updates ready to be applied", wfc.nodeUpdateQueue),
+	)
+	return &m
+}
+
+// newQueueGauge is a helper to create a gauge which reports the length of a work queue
+func newQueueGauge(name string, help string, queue workqueue.Interface) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      name,
+		Help:      help,
+	}, func() float64 {
+		return float64(queue.Len())
+	})
+}
+
+// newAPIRequestsCounter creates the counter of API server requests made by the controller
+func newAPIRequestsCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "api_requests_total",
+		Help:      "Number of API server requests made by the controller, by verb, resource and result (success, conflict or error)",
+	}, []string{"verb", "resource", "result"})
+}
+
+// instrumentConfig returns a copy of the REST config whose clients count their requests in apiRequests
+func instrumentConfig(config *rest.Config, apiRequests *prometheus.CounterVec) *rest.Config {
+	instrumented := *config
+	wrapTransport := config.WrapTransport
+	instrumented.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if wrapTransport != nil {
+			rt = wrapTransport(rt)
+		}
+		return &metricsRoundTripper{delegate: rt, apiRequests: apiRequests}
+	}
+	return &instrumented
+}
+
+// metricsRoundTripper is an HTTP round tripper which counts the API server requests passing through it
+type metricsRoundTripper struct {
+	delegate    http.RoundTripper
+	apiRequests *prometheus.CounterVec
+}
+
+func (m *metricsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := m.delegate.RoundTrip(req)
+	result := "success"
+	if err != nil {
+		result = "error"
+	} else if resp.StatusCode == http.StatusConflict {
+		result = "conflict"
+	} else if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		result = "error"
+	}
+	verb, resource := parseAPIRequest(req)
+	m.apiRequests.WithLabelValues(verb, resource, result).Inc()
+	return resp, err
+}
+
+// parseAPIRequest returns the kubernetes verb (e.g. get, list, watch, update) and the resource (e.g. pods,
+// pods/exec, workflows) of an API server request, from its method and path. Paths are of the form
+// /api/<version>/[namespaces/<namespace>/]<resource>[/<name>[/<subresource>]], or /apis/<group>/<version>/...
+func parseAPIRequest(req *http.Request) (string, string) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		parts = parts[2:]
+	case len(parts) >= 3 && parts[0] == "apis":
+		parts = parts[3:]
+	default:
+		return strings.ToLower(req.Method), "unknown"
+	}
+	watch := req.URL.Query().Get("watch") == "true" || req.URL.Query().Get("watch") == "1"
+	if len(parts) > 0 && parts[0] == "watch" {
+		watch = true
+		parts = parts[1:]
+	}
+	if len(parts) > 2 && parts[0] == "namespaces" {
+		parts = parts[2:]
+	}
+	resource := "unknown"
+	if len(parts) > 0 {
+		resource = parts[0]
+	}
+	if len(parts) > 2 {
+		resource = resource + "/" + parts[2]
+	}
+	hasName := len(parts) > 1
+	var verb string
+	switch req.Method {
+	case http.MethodGet:
+		if watch {
+			verb = "watch"
+		} else if hasName {
+			verb = "get"
+		} else {
+			verb = "list"
+		}
+	case http.MethodPost:
+		verb = "create"
+	case http.MethodPut:
+		verb = "update"
+	case http.MethodPatch:
+		verb = "patch"
+	case http.MethodDelete:
+		if hasName {
+			verb = "delete"
+		} else {
+			verb = "deletecollection"
+		}
+	default:
+		verb = strings.ToLower(req.Method)
+	}
+	return verb, resource
+}
+
+// observeOperateWorkflow records the completion of a single operation of a workflow which began at startTime
+func (m *controllerMetrics) observeOperateWorkflow(startTime time.Time) {
+	m.workflowsOperated.Inc()
+	m.operateWorkflowDuration.Observe(time.Since(startTime).Seconds())
+}
+
+// nodePhaseCounts holds the number of nodes of workflows by phase, and the number of running nodes whose
+// pod is stuck Pending
+type nodePhaseCounts struct {
+	phases  map[wfv1.NodePhase]int
+	pending int
+}
+
+// countNodePhases counts the nodes of the given workflows by phase
+func countNodePhases(wfs []interface{}) nodePhaseCounts {
+	counts := nodePhaseCounts{phases: make(map[wfv1.NodePhase]int)}
+	for _, obj := range wfs {
+		wf, ok := obj.(*wfv1.Workflow)
+		if !ok {
+			continue
+		}
+		for _, node := range wf.Status.Nodes {
+			counts.phases[node.Phase]++
+			if node.Phase == wfv1.NodeRunning && strings.HasPrefix(node.Message, pendingMessagePrefix) {
+				counts.pending++
+			}
+		}
+	}
+	return counts
+}
+
+// updateNodePhaseMetrics sets the node phase gauges to the given counts
+func (m *controllerMetrics) updateNodePhaseMetrics(counts nodePhaseCounts) {
+	for _, phase := range nodePhases {
+		m.activeNodes.WithLabelValues(string(phase)).Set(float64(counts.phases[phase]))
+	}
+	m.activeNodesPending.Set(float64(counts.pending))
+}
+
+// runNodePhaseMetrics periodically computes the node phase gauges from the workflows of the informer cache,
+// which holds the active workflows, until ctx is done
+func (wfc *WorkflowController) runNodePhaseMetrics(ctx context.Context) {
+	ticker := time.NewTicker(nodePhaseMetricsPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			wfc.metrics.updateNodePhaseMetrics(countNodePhases(wfc.wfStore.List()))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runMetricsServer starts an HTTP server exposing prometheus metrics. The server is shut down when ctx is done.
+func (wfc *WorkflowController) runMetricsServer(ctx context.Context) {
+	port := wfc.getConfig().MetricsPort
+	if port == 0 {
+		port = defaultMetricsPort
+	}
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, promhttp.HandlerFor(wfc.metrics.registry, promhttp.HandlerOpts{}))
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: mux,
+	}
+	go func() {
+		log.Infof("Starting prometheus metrics server at :%d%s", port, metricsPath)
+		err := srv.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Errorf("Metrics server failed: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+}
@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountNodePhases(t *testing.T) {
+	newWorkflow := func(nodes ...wfv1.NodeStatus) *wfv1.Workflow {
+		wf := &wfv1.Workflow{}
+		wf.Status.Nodes = make(map[string]wfv1.NodeStatus)
+		for i, node := range nodes {
+			wf.Status.Nodes[fmt.Sprintf("node-%d", i)] = node
+		}
+		return wf
+	}
+	counts := countNodePhases([]interface{}{
+		newWorkflow(
+			wfv1.NodeStatus{Phase: wfv1.NodeRunning},
+			wfv1.NodeStatus{Phase: wfv1.NodeRunning, Message: pendingMessagePrefix + "Unschedulable: 0/3 nodes are available"},
+			wfv1.NodeStatus{Phase: wfv1.NodeSucceeded},
+		),
+		newWorkflow(
+			wfv1.NodeStatus{Phase: wfv1.NodeRunning},
+			wfv1.NodeStatus{Phase: wfv1.NodeFailed},
+		),
+	})
+	assert.Equal(t, map[wfv1.NodePhase]int{wfv1.NodeRunning: 3, wfv1.NodeSucceeded: 1, wfv1.NodeFailed: 1}, counts.phases)
+	assert.Equal(t, 1, counts.pending)
+}
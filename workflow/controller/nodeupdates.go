@@ -0,0 +1,316 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/argoproj/argo/errors"
+	workflowclient "github.com/argoproj/argo/workflow/client"
+	"github.com/argoproj/argo/workflow/common"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+)
+
+// nodeUpdateBatchWindow is the duration over which node status updates of a workflow are collected,
+// before being applied to the workflow in a single update. When many pods of a workflow complete
+// together, this avoids one (likely conflicting) workflow update per pod.
+const nodeUpdateBatchWindow = 1 * time.Second
+
+// defaultGetWorkflowRetries is the default number of retries of getting a workflow upon transient errors
+const defaultGetWorkflowRetries = 5
+
+// podNodeUpdate is the node status inferred from a pod update, waiting to be applied to the workflow
+type podNodeUpdate struct {
+	pod          *apiv1.Pod
+	workflowName string
+	phase        wfv1.NodePhase
+	daemoned     *bool
+	reason       wfv1.NodeReason
+	message      string
+	// podKey and podState are recorded in the podStateCache once the update is applied
+	podKey   string
+	podState string
+}
+
+// enqueueNodeUpdate adds a node update to the pending updates of its workflow, and schedules the
+// pending updates to be applied at the end of the batch window. A newer update of the same pod
+// supersedes a pending one.
+func (wfc *WorkflowController) enqueueNodeUpdate(u podNodeUpdate) {
+	key := fmt.Sprintf("%s/%s", u.pod.ObjectMeta.Namespace, u.workflowName)
+	wfc.pendingNodeUpdatesLock.Lock()
+	updates, ok := wfc.pendingNodeUpdates[key]
+	if !ok {
+		updates = make(map[string]podNodeUpdate)
+		wfc.pendingNodeUpdates[key] = updates
+	}
+	updates[u.pod.ObjectMeta.Name] = u
+	wfc.pendingNodeUpdatesLock.Unlock()
+	wfc.nodeUpdateQueue.AddAfter(key, nodeUpdateBatchWindow)
+}
+
+// countPendingNodeUpdates returns the number of workflows with node updates which have yet to be applied
+func (wfc *WorkflowController) countPendingNodeUpdates() int {
+	wfc.pendingNodeUpdatesLock.Lock()
+	defer wfc.pendingNodeUpdatesLock.Unlock()
+	return len(wfc.pendingNodeUpdates)
+}
+
+// processNextNodeUpdate dequeues a single workflow key and applies its pending node updates.
+// Returns false when the queue has been shut down.
+func (wfc *WorkflowController) processNextNodeUpdate() bool {
+	key, quit := wfc.nodeUpdateQueue.Get()
+	if quit {
+		return false
+	}
+	defer wfc.nodeUpdateQueue.Done(key)
+	wfc.nodeUpdateQueue.Forget(key)
+	if wait := wfc.apiCircuitWait(); wait > 0 {
+		// the pending updates are kept, and applied once the API server is healthy again
+		wfc.nodeUpdateQueue.AddAfter(key, wait)
+		return true
+	}
+
+	wfc.pendingNodeUpdatesLock.Lock()
+	updates := wfc.pendingNodeUpdates[key.(string)]
+	delete(wfc.pendingNodeUpdates, key.(string))
+	wfc.pendingNodeUpdatesLock.Unlock()
+	if len(updates) == 0 {
+		// updates were already applied by an earlier occurrence of the key
+		return true
+	}
+	wfc.applyNodeUpdates(key.(string), updates)
+	return true
+}
+
+// applyNodeUpdates applies the node updates of the pods of a workflow in a single workflow update
+func (wfc *WorkflowController) applyNodeUpdates(key string, updates map[string]podNodeUpdate) {
+	namespace, workflowName, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		log.Errorf("Invalid workflow key '%s': %v", key, err)
+		return
+	}
+	logCtx := log.WithFields(log.Fields{"namespace": namespace, "workflow": workflowName})
+	wfClient := workflowclient.NewWorkflowClient(wfc.restClient, wfc.scheme, namespace)
+	nodes := make(map[string]wfv1.NodeStatus)
+	updated := make(map[string]bool)
+	var updatedWf *wfv1.Workflow
+	// Upon a resource version conflict, the workflow is re-fetched and the pods' states re-applied to the nodes
+	err = retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		wf, err := wfc.getWorkflowWithRetry(wfClient, workflowName)
+		if err != nil {
+			if apierr.IsNotFound(err) {
+				return errors.Errorf(errors.CodeNotFound, "failed to find workflow %s: %v", workflowName, err)
+			}
+			return err
+		}
+		updateNeeded := false
+		for podName, u := range updates {
+			node, ok := wf.Status.Nodes[podName]
+			if !ok {
+				logCtx.WithField("pod", podName).Warn("pod unassociated with workflow")
+				continue
+			}
+			updated[podName] = applyUpdates(u.pod, &node, u.phase, u.daemoned, u.reason, u.message)
+			if updated[podName] {
+				wf.Status.Nodes[podName] = node
+				updateNeeded = true
+			}
+			nodes[podName] = node
+		}
+		if !updateNeeded || wfc.skipInDryRun(logCtx, "update of %d nodes", len(nodes)) {
+			return nil
+		}
+		updatedWf, err = wfClient.UpdateWorkflow(wf)
+		return err
+	})
+	wfc.recordAPIResult(err)
+	if err != nil {
+		logCtx.WithError(err).Errorf("Failed to update status of %d nodes", len(updates))
+		// if we fail to update the CRD state after retrying, we will need to rely on resync to catch up
+		return
+	}
+	logCtx.Infof("Applied %d node updates", len(nodes))
+
+	completed := false
+	for podName, node := range nodes {
+		if updated[podName] {
+			err = wfc.saveMemoizedOutputs(namespace, node)
+			if err != nil {
+				logCtx.WithField("node", node.Name).Errorf("Failed to memoize outputs: %v", err)
+				if updatedWf != nil && wfc.eventRecorder != nil {
+					wfc.eventRecorder.Eventf(updatedWf, apiv1.EventTypeWarning, memoizationFailedEventReason, "Failed to memoize the outputs of node %s: %v", node.Name, err)
+				}
+			}
+		}
+		wfc.finishNodeUpdate(updates[podName], node, updated[podName])
+		completed = completed || node.Completed()
+	}
+	if completed {
+		// A completed pod may allow pending steps of the workflow to start (e.g. when limited by parallelism)
+		wfc.wfQueue.Add(key)
+	}
+}
+
+// getWorkflowWithRetry gets a workflow, retrying transient errors with exponential backoff.
+// A NotFound error is returned immediately, since the workflow was deleted.
+func (wfc *WorkflowController) getWorkflowWithRetry(wfClient *workflowclient.WorkflowClient, name string) (*wfv1.Workflow, error) {
+	retries := wfc.getConfig().GetWorkflowRetries
+	if retries == 0 {
+		retries = defaultGetWorkflowRetries
+	} else if retries < 0 {
+		retries = 0
+	}
+	backoff := wait.Backoff{
+		Steps:    retries + 1,
+		Duration: 100 * time.Millisecond,
+		Factor:   2.0,
+		Jitter:   0.1,
+	}
+	var wf *wfv1.Workflow
+	var lastErr error
+	err := wait.ExponentialBackoff(backoff, func() (bool, error) {
+		wf, lastErr = wfClient.GetWorkflow(name)
+		if lastErr == nil {
+			return true, nil
+		}
+		if apierr.IsNotFound(lastErr) {
+			return false, lastErr
+		}
+		log.Warnf("Failed to get workflow %s (retrying): %v", name, lastErr)
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		err = lastErr
+	}
+	return wf, err
+}
+
+// finishNodeUpdate records that a pod's state was applied to its node, and labels (or deletes) the pod
+// once its node is completed.
+func (wfc *WorkflowController) finishNodeUpdate(u podNodeUpdate, node wfv1.NodeStatus, updated bool) {
+	pod := u.pod
+	logCtx := log.WithFields(log.Fields{
+		"namespace": pod.ObjectMeta.Namespace,
+		"pod":       pod.ObjectMeta.Name,
+		"workflow":  u.workflowName,
+		"node":      node.Name,
+	})
+	if !updated {
+		logCtx.Info("No workflow update needed for node")
+	} else {
+		logCtx.WithField("nodePhase", node.Phase).Info("Updated node status")
+	}
+	if !node.Completed() || node.IsDaemoned() {
+		// Completed pods are instead remembered by the completedPodCache once they are labeled or
+		// deleted, so that a failure to do so is retried on the next update of the pod.
+		wfc.podStateCache.SetDefault(u.podKey, u.podState)
+	} else {
+		wfc.podStateCache.Delete(u.podKey)
+	}
+	if !node.Completed() {
+		return
+	}
+
+	// If we get here, we need to decide whether or not to set the 'completed=true' label on the pod,
+	// which prevents the controller from seeing any pod updates for the rest of its existance.
+	// We only add the label if the pod is *not* daemoned, because we still rely on this pod watch
+	// for daemoned pods, in order to properly remove the daemoned status from the node when the pod
+	// terminates.
+	if node.IsDaemoned() {
+		logCtx.Info("Skipping completed=true labeling for daemoned pod")
+		return
+	}
+	config := wfc.getConfig()
+	if config.PodGC == PodGCOnPodCompletion {
+		// The pod is deleted instead of labeled. If deletion fails, the pod is left
+		// unlabeled so that it remains in the watch and deletion can be retried.
+		if wfc.gcCompletedPod(pod) {
+			wfc.rememberCompletedPod(pod)
+		}
+		return
+	}
+	if config.DisablePodCompletedLabel {
+		wfc.rememberCompletedPod(pod)
+		logCtx.Debug("Skipping completed=true labeling of pod: disabled by config")
+		return
+	}
+	if wfc.skipInDryRun(logCtx, "completed=true labeling of pod") {
+		return
+	}
+	err := common.AddPodLabel(wfc.clientset, pod.ObjectMeta.Name, pod.ObjectMeta.Namespace, common.LabelKeyCompleted, "true")
+	if err != nil {
+		logCtx.WithError(err).Error("Failed to label completed pod")
+		return
+	}
+	wfc.rememberCompletedPod(pod)
+	logCtx.Info("Set completed=true label to pod")
+}
+
+// rememberCompletedPod records a completed pod in the completedPodCache for the configured TTL,
+// so that further updates of the pod are ignored
+func (wfc *WorkflowController) rememberCompletedPod(pod *apiv1.Pod) {
+	config := wfc.getConfig()
+	ttl, err := config.getCompletedPodCacheTTL()
+	if err != nil {
+		// the config is validated when loaded
+		ttl = defaultCompletedPodCacheTTL
+	}
+	if maxEntries := config.CompletedPodCacheMaxEntries; maxEntries > 0 {
+		wfc.evictCompletedPods(maxEntries)
+	}
+	wfc.completedPodCache.Set(fmt.Sprintf("%s/%s", pod.ObjectMeta.Namespace, pod.ObjectMeta.Name), true, ttl)
+}
+
+// evictCompletedPods makes room in the completed pod cache once it holds the maximum number of entries, by
+// evicting the pods which expire first, i.e. those remembered the longest ago. To amortize the cost of the
+// eviction, the cache is shrunk to 90% of the maximum.
+func (wfc *WorkflowController) evictCompletedPods(maxEntries int) {
+	if wfc.completedPodCache.ItemCount() < maxEntries {
+		return
+	}
+	wfc.completedPodCache.DeleteExpired()
+	items := wfc.completedPodCache.Items()
+	if len(items) < maxEntries {
+		return
+	}
+	names := make([]string, 0, len(items))
+	for name := range items {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if items[names[i]].Expiration != items[names[j]].Expiration {
+			return items[names[i]].Expiration < items[names[j]].Expiration
+		}
+		return names[i] < names[j]
+	})
+	evictions := len(names) - maxEntries*9/10
+	log.Infof("Completed pod cache reached %d entries. Evicting %d pods", len(names), evictions)
+	for _, name := range names[:evictions] {
+		wfc.completedPodCache.Delete(name)
+	}
+}
+
+// runCompletedPodCacheCleanup periodically deletes the expired pods from the completed pod cache until the
+// context is done. The interval is read from the config before every cleanup, so that updates take effect.
+func (wfc *WorkflowController) runCompletedPodCacheCleanup(ctx context.Context) {
+	for {
+		interval, err := wfc.getConfig().getCompletedPodCacheCleanupInterval()
+		if err != nil {
+			// the config is validated when loaded
+			interval = defaultCompletedPodCacheCleanupInterval
+		}
+		select {
+		case <-time.After(interval):
+			wfc.completedPodCache.DeleteExpired()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
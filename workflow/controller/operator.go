@@ -3,13 +3,13 @@ package controller
 import (
 	"encoding/json"
 	"fmt"
-	"regexp"
 	"sort"
 	"strings"
 	"time"
 
 	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
 	"github.com/argoproj/argo/errors"
+	artifact "github.com/argoproj/argo/workflow/artifacts"
 	workflowclient "github.com/argoproj/argo/workflow/client"
 	"github.com/argoproj/argo/workflow/common"
 	log "github.com/sirupsen/logrus"
@@ -17,6 +17,8 @@ import (
 	apiv1 "k8s.io/api/core/v1"
 	apierr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 )
 
 // wfOperationCtx is the context for evaluation and operation of a single workflow
@@ -30,6 +32,18 @@ type wfOperationCtx struct {
 	log *log.Entry
 	// controller reference to workflow controller
 	controller *WorkflowController
+	// config is the snapshot of the controller config used throughout the operation
+	config *WorkflowControllerConfig
+	// activePods is the number of pods of the workflow which are currently running
+	activePods int64
+	// artifactRepository is the repository in which the outputs of the workflow are stored. It is the
+	// workflow's own artifact repository if specified, otherwise the controller's default
+	artifactRepository *wfv1.ArtifactRepository
+	// requeueAt is the earliest time at which the workflow has asked to be re-examined (e.g. when
+	// its active deadline expires, or when the backoff of a retry elapses). Zero if none.
+	requeueAt time.Time
+	// executingOnExit indicates the onExit handler of the workflow is being executed
+	executingOnExit bool
 	// NOTE: eventually we may need to store additional metadata state to
 	// understand how to proceed in workflows with more complex control flows.
 	// (e.g. workflow failed in step 1 of 3 but has finalizer steps)
@@ -41,15 +55,9 @@ type wfScope struct {
 	scope map[string]interface{}
 }
 
-// operateWorkflow is the operator logic of a workflow
-// It evaluates the current state of the workflow and decides how to proceed down the execution path
-func (wfc *WorkflowController) operateWorkflow(wf *wfv1.Workflow) {
-	if wf.ObjectMeta.Labels[common.LabelKeyCompleted] == "true" {
-		// can get here if we already added the completed=true label,
-		// but we are still draining the controller's workflow channel
-		return
-	}
-	log.Infof("Processing wf: %v", wf.ObjectMeta.SelfLink)
+// newWorkflowOperationCtx creates the context of an operation of a workflow. The workflow is copied,
+// since objects from the informer store must never be modified.
+func newWorkflowOperationCtx(wf *wfv1.Workflow, wfc *WorkflowController) *wfOperationCtx {
 	// NEVER modify objects from the store. It's a read-only, local cache.
 	// You can use DeepCopy() to make a deep copy of original object and modify this copy
 	// Or create a copy manually for better performance
@@ -61,28 +69,89 @@ func (wfc *WorkflowController) operateWorkflow(wf *wfv1.Workflow) {
 			"namespace": wf.ObjectMeta.Namespace,
 		}),
 		controller: wfc,
+		config:     wfc.getConfig(),
+	}
+	woc.artifactRepository = woc.config.artifactRepositoryOf(woc.wf)
+	return &woc
+}
+
+// operateWorkflow is the operator logic of a workflow
+// It evaluates the current state of the workflow and decides how to proceed down the execution path.
+// Returns an error if the resulting state of the workflow could not be persisted.
+func (wfc *WorkflowController) operateWorkflow(wf *wfv1.Workflow) (operateErr error) {
+	if isWorkflowCompleted(wf) {
+		// can get here if we already added the completed=true label,
+		// but we are still draining the controller's workflow channel,
+		// or with a late update or deletion of a completed workflow
+		log.Debugf("Skipping completed workflow %s/%s", wf.ObjectMeta.Namespace, wf.ObjectMeta.Name)
+		return nil
 	}
+	log.Infof("Processing wf: %v", wf.ObjectMeta.SelfLink)
+	defer wfc.metrics.observeOperateWorkflow(time.Now())
+	woc := newWorkflowOperationCtx(wf, wfc)
 	defer func() {
-		if woc.updated {
+		woc.updateProgress()
+		if woc.updated && !woc.config.skipInDryRun(woc.log, "update of workflow (phase: %s)", woc.wf.Status.Phase) {
 			wfClient := workflowclient.NewWorkflowClient(wfc.restClient, wfc.scheme, wf.ObjectMeta.Namespace)
 			_, err := wfClient.UpdateWorkflow(woc.wf)
+			wfc.recordAPIResult(err)
 			if err != nil {
 				woc.log.Errorf("Error updating %s status: %v", woc.wf.ObjectMeta.SelfLink, err)
+				operateErr = err
 			} else {
 				woc.log.Infof("Workflow %s updated", woc.wf.ObjectMeta.SelfLink)
+				wfc.enqueueTTL(woc.wf)
+				if woc.wf.ObjectMeta.Labels[common.LabelKeyCompleted] == "true" {
+					woc.reportCompletion()
+				}
 			}
 		}
+		woc.requeue()
 	}()
 
+	if woc.wf.ObjectMeta.DeletionTimestamp != nil {
+		woc.finalizeWorkflow()
+		return
+	}
+
+	woc.activePods = woc.countActivePods()
+
 	// Perform one-time workflow validation
 	if woc.wf.Status.Phase == "" {
 		woc.markWorkflowRunning()
-		err := common.ValidateWorkflow(woc.wf)
+		err := common.InlineTemplateRefs(woc.wf, wfc.getWorkflowTemplate)
+		if err != nil {
+			woc.markWorkflowFailed(fmt.Sprintf("invalid spec: %s", err.Error()))
+			return
+		}
+		err = common.ValidateWorkflow(woc.wf)
 		if err != nil {
 			woc.markWorkflowFailed(fmt.Sprintf("invalid spec: %s", err.Error()))
 			return
 		}
+		err = woc.validateArtifactRepositorySecrets()
+		if err != nil {
+			woc.markWorkflowFailed(fmt.Sprintf("invalid spec: %s", err.Error()))
+			return
+		}
+		err = woc.validateExecutorImage()
+		if err != nil {
+			woc.markWorkflowFailed(fmt.Sprintf("invalid spec: %s", err.Error()))
+			return
+		}
+		err = woc.resolveParameterValuesFrom()
+		if err != nil {
+			if argoErr, ok := err.(errors.ArgoError); ok && argoErr.Code() == errors.CodeInternal {
+				// the workflow is left unchanged, and its validation is retried with backoff
+				woc.log.Warnf("Failed to resolve the parameter values of the workflow: %v", err)
+				woc.updated = false
+				return err
+			}
+			woc.markWorkflowFailed(fmt.Sprintf("invalid spec: %s", err.Error()))
+			return
+		}
 	}
+	woc.addFinalizer()
 
 	err := woc.createPVCs()
 	if err != nil {
@@ -91,14 +160,45 @@ func (wfc *WorkflowController) operateWorkflow(wf *wfv1.Workflow) {
 		return
 	}
 
+	woc.enforceActiveDeadline()
+	woc.enforceShutdown()
+
 	err = woc.executeTemplate(wf.Spec.Entrypoint, wf.Spec.Arguments, wf.ObjectMeta.Name)
 	if err != nil {
 		woc.log.Errorf("%s error: %+v", wf.ObjectMeta.Name, err)
 	}
+	if woc.nodeLimitExceeded() {
+		woc.killRunningNodes(woc.nodeLimitMessage(), wfv1.NodeReasonNodeLimitExceeded)
+	}
 	node := woc.wf.Status.Nodes[woc.wf.NodeID(wf.ObjectMeta.Name)]
 	if !node.Completed() {
 		return
 	}
+	woc.resolveWorkflowOutputs()
+
+	if woc.wf.Spec.OnExit != "" && woc.wf.Spec.Shutdown != wfv1.ShutdownStrategyTerminate && !woc.nodeLimitExceeded() {
+		woc.executingOnExit = true
+		onExitNodeName := common.OnExitNodeName(woc.wf.ObjectMeta.Name)
+		if _, ok := woc.wf.Status.Nodes[woc.wf.NodeID(onExitNodeName)]; !ok {
+			woc.log.Infof("Running onExit handler: %s", woc.wf.Spec.OnExit)
+		}
+		err = woc.executeTemplate(woc.wf.Spec.OnExit, common.GetOnExitArguments(node.Phase, node.Message), onExitNodeName)
+		if err != nil {
+			woc.log.Errorf("%s error: %+v", onExitNodeName, err)
+			if _, ok := woc.wf.Status.Nodes[woc.wf.NodeID(onExitNodeName)]; !ok {
+				// the node could not be created (i.e. the workflow reached its maximum number of nodes)
+				woc.markNodeError(onExitNodeName, err)
+			}
+		}
+		onExitNode := woc.wf.Status.Nodes[woc.wf.NodeID(onExitNodeName)]
+		if !onExitNode.Completed() {
+			return
+		}
+		if node.Successful() && !onExitNode.Successful() {
+			// The failure of the onExit handler fails an otherwise successful workflow
+			node = onExitNode
+		}
+	}
 
 	err = woc.deletePVCs()
 	if err != nil {
@@ -110,15 +210,34 @@ func (wfc *WorkflowController) operateWorkflow(wf *wfv1.Workflow) {
 		return
 	}
 
-	// TODO: workflow finalizer logic goes here
+	err = woc.gcWorkflowPods(node.Phase)
+	if err != nil {
+		woc.log.Errorf("%s error: %+v", wf.ObjectMeta.Name, err)
+		// Similar to PVC deletion, do not markCompletion so that pod deletion is retried
+		woc.markWorkflowError(err, false)
+		woc.requeueAfter(podGCRetryDelay)
+		return
+	}
+	err = woc.deleteRunningPods()
+	if err != nil {
+		woc.log.Errorf("%s error: %+v", wf.ObjectMeta.Name, err)
+		// Like pod GC, do not markCompletion so that the deletion of the running pods is retried
+		woc.markWorkflowError(err, false)
+		woc.requeueAfter(podGCRetryDelay)
+		return
+	}
 
-	// If we get here, the workflow completed, all PVCs were deleted successfully,
-	// and finalizers were executed (finalizer feature yet to be implemented).
-	// We now need to infer the workflow phase from the node phase.
+	// If we get here, the workflow completed and all PVCs were deleted successfully.
+	// We now need to infer the workflow phase from the node phase. Marking the workflow
+	// completed also removes its finalizer.
 	switch node.Phase {
 	case wfv1.NodeSucceeded, wfv1.NodeSkipped:
 		woc.markWorkflowSuccess()
 	case wfv1.NodeFailed:
+		if woc.wf.Spec.Shutdown != "" {
+			woc.markWorkflowFailed(woc.shutdownMessage())
+			break
+		}
 		woc.markWorkflowFailed(node.Message)
 	case wfv1.NodeError:
 		woc.markWorkflowPhase(wfv1.NodeError, true, node.Message)
@@ -128,6 +247,388 @@ func (wfc *WorkflowController) operateWorkflow(wf *wfv1.Workflow) {
 		err = errors.InternalErrorf("Unexpected node phase %s: %+v", wf.ObjectMeta.Name, err)
 		woc.markWorkflowError(err, true)
 	}
+	return
+}
+
+// workflowCompletedEventReason is the reason of the event recorded when a workflow completes
+const workflowCompletedEventReason = "WorkflowCompleted"
+
+// workflowSummary summarizes the outcome of a completed workflow
+type workflowSummary struct {
+	phase    wfv1.NodePhase
+	duration time.Duration
+	// succeeded and failed are the number of nodes which succeeded, and which failed or errored
+	succeeded int
+	failed    int
+	// outputs are the result and output parameters of the entrypoint, as name=value
+	outputs []string
+}
+
+func newWorkflowSummary(wf *wfv1.Workflow) workflowSummary {
+	summary := workflowSummary{
+		phase:    wf.Status.Phase,
+		duration: wf.Status.FinishedAt.Sub(wf.Status.StartedAt.Time),
+	}
+	for _, node := range wf.Status.Nodes {
+		switch node.Phase {
+		case wfv1.NodeSucceeded:
+			summary.succeeded++
+		case wfv1.NodeFailed, wfv1.NodeError:
+			summary.failed++
+		}
+	}
+	node := wf.Status.Nodes[wf.NodeID(wf.ObjectMeta.Name)]
+	if node.Outputs != nil {
+		if node.Outputs.Result != nil {
+			summary.outputs = append(summary.outputs, fmt.Sprintf("result=%s", *node.Outputs.Result))
+		}
+		for _, param := range node.Outputs.Parameters {
+			if param.Value != nil {
+				summary.outputs = append(summary.outputs, fmt.Sprintf("parameters.%s=%s", param.Name, *param.Value))
+			}
+		}
+	}
+	return summary
+}
+
+func (s workflowSummary) String() string {
+	msg := fmt.Sprintf("Workflow %s in %s: %d node(s) succeeded, %d failed", s.phase, s.duration, s.succeeded, s.failed)
+	if len(s.outputs) > 0 {
+		msg += fmt.Sprintf(", outputs: %s", strings.Join(s.outputs, ", "))
+	}
+	return msg
+}
+
+// reportCompletion logs a summary of the outcome of the completed workflow, and records it as an event of the
+// workflow, so that automation can react to the completion of workflows without polling them
+func (woc *wfOperationCtx) reportCompletion() {
+	summary := newWorkflowSummary(woc.wf)
+	woc.log.WithFields(log.Fields{
+		"phase":     summary.phase,
+		"duration":  summary.duration.Seconds(),
+		"succeeded": summary.succeeded,
+		"failed":    summary.failed,
+		"outputs":   strings.Join(summary.outputs, ", "),
+	}).Info("Workflow completed")
+	if woc.controller.eventRecorder == nil {
+		return
+	}
+	eventType := apiv1.EventTypeNormal
+	if summary.phase != wfv1.NodeSucceeded {
+		eventType = apiv1.EventTypeWarning
+	}
+	msg := summary.String()
+	if woc.wf.Status.Message != "" {
+		msg += fmt.Sprintf(" (%s)", woc.wf.Status.Message)
+	}
+	woc.controller.eventRecorder.Event(woc.wf, eventType, workflowCompletedEventReason, msg)
+}
+
+// validateArtifactRepositorySecrets verifies the secrets referenced by the workflow's own artifact
+// repository exist in the workflow's namespace
+func (woc *wfOperationCtx) validateArtifactRepositorySecrets() error {
+	repo := woc.wf.Spec.ArtifactRepository
+	if repo == nil {
+		return nil
+	}
+	return validateArtifactRepositorySecrets(woc.controller.clientset, woc.wf.ObjectMeta.Namespace, "spec.artifactRepository", repo)
+}
+
+// infrastructureNodeReasons are the reasons of node failures caused by the infrastructure running the workflow,
+// rather than by the workflow itself
+var infrastructureNodeReasons = map[wfv1.NodeReason]bool{
+	wfv1.NodeReasonArtifactLoadError:     true,
+	wfv1.NodeReasonArtifactSaveError:     true,
+	wfv1.NodeReasonEvicted:               true,
+	wfv1.NodeReasonPodFailed:             true,
+	wfv1.NodeReasonPodUnknown:            true,
+	wfv1.NodeReasonMainContainerNotFound: true,
+	wfv1.NodeReasonContainerRestarted:    true,
+}
+
+// workflowFailureType classifies the failure of a workflow by the reasons of its failed and errored nodes. The
+// failure is an infrastructure failure only if all the reasons are infrastructure reasons. Nodes without reasons
+// (e.g. steps, whose failures are those of their children) are ignored. If no node has a reason, the workflow
+// failed either because of its spec (e.g. it is invalid), or errored because of the controller (e.g. failing to
+// create a pod).
+func workflowFailureType(wf *wfv1.Workflow) wfv1.FailureType {
+	infrastructure := false
+	for _, node := range wf.Status.Nodes {
+		if (node.Phase != wfv1.NodeFailed && node.Phase != wfv1.NodeError) || node.Reason == "" {
+			continue
+		}
+		if !infrastructureNodeReasons[node.Reason] {
+			return wfv1.FailureTypeUser
+		}
+		infrastructure = true
+	}
+	if infrastructure || wf.Status.Phase == wfv1.NodeError {
+		return wfv1.FailureTypeInfrastructure
+	}
+	return wfv1.FailureTypeUser
+}
+
+// validateExecutorImage verifies the executor image of the workflow, if any, is allowed by the controller config
+func (woc *wfOperationCtx) validateExecutorImage() error {
+	image := woc.wf.Spec.ExecutorImage
+	if image == "" || image == woc.config.ExecutorImage || woc.config.isExecutorImageAllowed(image) {
+		return nil
+	}
+	return errors.Errorf(errors.CodeBadRequest, "spec.executorImage '%s' is not one of the allowedExecutorImages of the controller", image)
+}
+
+// isWorkflowCompleted returns whether the workflow was marked completed, i.e. labeled completed or finished in a
+// completed phase. A workflow in a completed phase which is not finished is still operated on, since the cleanup
+// of its pods is retried before it is marked completed.
+func isWorkflowCompleted(wf *wfv1.Workflow) bool {
+	if wf.ObjectMeta.Labels[common.LabelKeyCompleted] == "true" {
+		return true
+	}
+	switch wf.Status.Phase {
+	case wfv1.NodeSucceeded, wfv1.NodeFailed, wfv1.NodeError:
+		return !wf.Status.FinishedAt.IsZero()
+	}
+	return false
+}
+
+// resolveParameterValuesFrom reads the values of the workflow's argument parameters, and the defaults of the input
+// parameters of its templates, which are read from configmaps. The values replace the references, so that they are
+// read once, when the workflow is started. References to secret keys are kept, once verified, since their values
+// must not be stored in the workflow (see Parameter.ValueFrom). Returns a bad request error if a configmap, secret
+// or key does not exist, or an internal error if it could not be read (e.g. because of an API server error).
+func (woc *wfOperationCtx) resolveParameterValuesFrom() error {
+	wf := woc.wf
+	for i, param := range wf.Spec.Arguments.Parameters {
+		if param.ValueFrom == nil {
+			continue
+		}
+		value, valueFrom, err := woc.resolveValueFrom(fmt.Sprintf("spec.arguments.parameters.%s.valueFrom", param.Name), param.ValueFrom)
+		if err != nil {
+			return err
+		}
+		wf.Spec.Arguments.Parameters[i].Value = value
+		wf.Spec.Arguments.Parameters[i].ValueFrom = valueFrom
+	}
+	for i, tmpl := range wf.Spec.Templates {
+		for j, param := range tmpl.Inputs.Parameters {
+			if param.ValueFrom == nil {
+				continue
+			}
+			value, valueFrom, err := woc.resolveValueFrom(fmt.Sprintf("template '%s' inputs.parameters.%s.valueFrom", tmpl.Name, param.Name), param.ValueFrom)
+			if err != nil {
+				return err
+			}
+			wf.Spec.Templates[i].Inputs.Parameters[j].Default = value
+			wf.Spec.Templates[i].Inputs.Parameters[j].ValueFrom = valueFrom
+		}
+	}
+	return nil
+}
+
+// resolveValueFrom resolves the valueFrom of a parameter, returning the value read from a configmap, or the
+// reference to a secret key which is kept in the workflow. Returns neither if the configmap, secret or key does
+// not exist and the reference is optional.
+func (woc *wfOperationCtx) resolveValueFrom(errPrefix string, valueFrom *wfv1.ValueFrom) (*string, *wfv1.ValueFrom, error) {
+	if ref := valueFrom.SecretKeyRef; ref != nil {
+		found, err := woc.checkSecretKeyRef(errPrefix, ref)
+		if err != nil || !found {
+			return nil, nil, err
+		}
+		return nil, valueFrom, nil
+	}
+	value, err := woc.readValueFrom(errPrefix, valueFrom)
+	return value, nil, err
+}
+
+// checkSecretKeyRef verifies the key of the secret referenced by a parameter exists, without reading its value
+// into the workflow. Returns false if the secret or key does not exist and the reference is optional.
+func (woc *wfOperationCtx) checkSecretKeyRef(errPrefix string, ref *apiv1.SecretKeySelector) (bool, error) {
+	namespace := woc.wf.ObjectMeta.Namespace
+	isOptional := ref.Optional != nil && *ref.Optional
+	secret, err := woc.controller.clientset.CoreV1().Secrets(namespace).Get(ref.Name, metav1.GetOptions{})
+	woc.controller.recordAPIResult(err)
+	if err != nil {
+		if apierr.IsNotFound(err) {
+			if isOptional {
+				return false, nil
+			}
+			return false, errors.Errorf(errors.CodeBadRequest, "%s: secret '%s' not found in namespace '%s'", errPrefix, ref.Name, namespace)
+		}
+		return false, errors.InternalWrapErrorf(err, "%s: failed to get secret '%s': %v", errPrefix, ref.Name, err)
+	}
+	if _, ok := secret.Data[ref.Key]; !ok {
+		if isOptional {
+			return false, nil
+		}
+		return false, errors.Errorf(errors.CodeBadRequest, "%s: key '%s' not found in secret '%s'", errPrefix, ref.Key, ref.Name)
+	}
+	return true, nil
+}
+
+// readValueFrom reads the key of the configmap referenced by a parameter. Returns nil if the configmap or key
+// does not exist and the reference is optional.
+func (woc *wfOperationCtx) readValueFrom(errPrefix string, valueFrom *wfv1.ValueFrom) (*string, error) {
+	ref := valueFrom.ConfigMapKeyRef
+	if ref == nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "%s: configMapKeyRef or secretKeyRef is required", errPrefix)
+	}
+	namespace := woc.wf.ObjectMeta.Namespace
+	isOptional := ref.Optional != nil && *ref.Optional
+	cm, err := woc.controller.clientset.CoreV1().ConfigMaps(namespace).Get(ref.Name, metav1.GetOptions{})
+	woc.controller.recordAPIResult(err)
+	if err != nil {
+		if apierr.IsNotFound(err) {
+			if isOptional {
+				return nil, nil
+			}
+			return nil, errors.Errorf(errors.CodeBadRequest, "%s: configmap '%s' not found in namespace '%s'", errPrefix, ref.Name, namespace)
+		}
+		return nil, errors.InternalWrapErrorf(err, "%s: failed to get configmap '%s': %v", errPrefix, ref.Name, err)
+	}
+	value, ok := cm.Data[ref.Key]
+	if !ok {
+		if isOptional {
+			return nil, nil
+		}
+		return nil, errors.Errorf(errors.CodeBadRequest, "%s: key '%s' not found in configmap '%s'", errPrefix, ref.Key, ref.Name)
+	}
+	return &value, nil
+}
+
+// validateArtifactRepositorySecrets verifies the secrets referenced by an artifact repository exist in
+// the namespace, and have the referenced keys
+func validateArtifactRepositorySecrets(clientset kubernetes.Interface, namespace string, errPrefix string, repo *wfv1.ArtifactRepository) error {
+	driver := artifact.RepositoryDriver(repo)
+	if driver == nil {
+		return nil
+	}
+	selectors := driver.RepositorySecrets(repo)
+	if len(selectors) == 0 {
+		return nil
+	}
+	secretsIf := clientset.CoreV1().Secrets(namespace)
+	for _, selector := range selectors {
+		if selector.Name == "" {
+			continue
+		}
+		secret, err := secretsIf.Get(selector.Name, metav1.GetOptions{})
+		if err != nil {
+			if apierr.IsNotFound(err) {
+				return errors.Errorf(errors.CodeBadRequest, "%s secret '%s' not found in namespace '%s'", errPrefix, selector.Name, namespace)
+			}
+			return errors.InternalWrapError(err)
+		}
+		if _, ok := secret.Data[selector.Key]; !ok {
+			return errors.Errorf(errors.CodeBadRequest, "%s secret '%s' does not have the key '%s'", errPrefix, selector.Name, selector.Key)
+		}
+	}
+	return nil
+}
+
+// enforceActiveDeadline fails all running nodes of the workflow if the workflow has exceeded its
+// activeDeadlineSeconds. Otherwise requeues the workflow so that the deadline is enforced on time.
+func (woc *wfOperationCtx) enforceActiveDeadline() {
+	if woc.wf.Spec.ActiveDeadlineSeconds == nil || woc.wf.Status.StartedAt.IsZero() {
+		return
+	}
+	activeDeadline := time.Duration(*woc.wf.Spec.ActiveDeadlineSeconds) * time.Second
+	remaining := woc.wf.Status.StartedAt.Add(activeDeadline).Sub(time.Now())
+	if remaining > 0 {
+		woc.requeueAfter(remaining)
+		return
+	}
+	woc.killRunningNodes(fmt.Sprintf("workflow exceeded its active deadline of %v", activeDeadline), wfv1.NodeReasonTimeout)
+}
+
+// enforceShutdown kills the running nodes of a workflow shut down with the Terminate strategy. Under
+// either strategy, nodes which have yet to start are failed as they are reached (see isShutdown).
+func (woc *wfOperationCtx) enforceShutdown() {
+	if woc.wf.Spec.Shutdown != wfv1.ShutdownStrategyTerminate {
+		return
+	}
+	woc.killRunningNodes(woc.shutdownMessage(), wfv1.NodeReasonTerminated)
+}
+
+// isShutdown returns whether or not new nodes of the workflow may no longer be started because the
+// workflow is shut down. The onExit handler of a stopped workflow is still run.
+func (woc *wfOperationCtx) isShutdown() bool {
+	switch woc.wf.Spec.Shutdown {
+	case wfv1.ShutdownStrategyTerminate:
+		return true
+	case wfv1.ShutdownStrategyStop:
+		return !woc.executingOnExit
+	}
+	return false
+}
+
+// shutdownMessage returns the message of nodes which were failed because of the shutdown of the workflow
+func (woc *wfOperationCtx) shutdownMessage() string {
+	return fmt.Sprintf("workflow shut down with strategy: %s", woc.wf.Spec.Shutdown)
+}
+
+// nodeLimitReached returns whether or not the workflow has as many nodes as the configured maximum number of
+// nodes of a workflow, in which case no node may be added to it
+func (woc *wfOperationCtx) nodeLimitReached() bool {
+	maxNodes := woc.config.MaxWorkflowNodes
+	return maxNodes > 0 && len(woc.wf.Status.Nodes) >= maxNodes
+}
+
+// nodeLimitExceeded returns whether or not the workflow has more nodes than the configured maximum number of
+// nodes of a workflow. This happens once a node could not be added because the limit was reached, since the
+// node which attempted to add it is then errored. The running nodes of the workflow are then failed, and its
+// onExit handler is not run, since the workflow can no longer progress.
+func (woc *wfOperationCtx) nodeLimitExceeded() bool {
+	maxNodes := woc.config.MaxWorkflowNodes
+	return maxNodes > 0 && len(woc.wf.Status.Nodes) > maxNodes
+}
+
+// nodeLimitMessage returns the message of nodes which failed because the workflow reached its maximum number of nodes
+func (woc *wfOperationCtx) nodeLimitMessage() string {
+	return fmt.Sprintf("workflow exceeded the maximum number of nodes (%d)", woc.config.MaxWorkflowNodes)
+}
+
+// killRunningNodes kills the pods of the running (and daemoned) nodes of the workflow, and fails the
+// running nodes with the given message and reason
+func (woc *wfOperationCtx) killRunningNodes(message string, reason wfv1.NodeReason) {
+	for _, node := range woc.wf.Status.Nodes {
+		if (node.IsDaemoned() || (node.Phase == wfv1.NodeRunning && len(node.Children) == 0 && node.ResourceRef == nil)) &&
+			!woc.config.skipInDryRun(woc.log, "kill of %s", node) {
+			// node is backed by a pod which may still be running
+			err := common.KillPodContainer(woc.controller.restConfig, woc.wf.ObjectMeta.Namespace, node.ID, common.MainContainerName)
+			if err != nil {
+				woc.log.Warnf("Failed to kill %s: %+v", node, err)
+			}
+		}
+		if node.Phase == wfv1.NodeRunning {
+			woc.log.Infof("Failing node %s: %s", node, message)
+			node = *woc.markNodePhase(node.Name, wfv1.NodeFailed, message)
+			node.Reason = reason
+			woc.wf.Status.Nodes[node.ID] = node
+		}
+	}
+}
+
+// requeueAfter schedules the workflow to be re-examined after the given duration
+func (woc *wfOperationCtx) requeueAfter(d time.Duration) {
+	requeueAt := time.Now().Add(d)
+	if woc.requeueAt.IsZero() || requeueAt.Before(woc.requeueAt) {
+		woc.requeueAt = requeueAt
+	}
+}
+
+// requeue adds the workflow back to the controller's workflow queue at the earliest time requested
+// during the operation, if any
+func (woc *wfOperationCtx) requeue() {
+	if woc.requeueAt.IsZero() {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(woc.wf)
+	if err != nil {
+		woc.log.Errorf("Failed to requeue workflow: %v", err)
+		return
+	}
+	woc.log.Debugf("Requeuing workflow in %v", time.Until(woc.requeueAt))
+	woc.controller.wfQueue.AddAfter(key, time.Until(woc.requeueAt))
 }
 
 func (woc *wfOperationCtx) createPVCs() error {
@@ -165,10 +666,17 @@ func (woc *wfOperationCtx) createPVCs() error {
 				BlockOwnerDeletion: &t,
 			},
 		}
-		pvc, err := pvcClient.Create(&pvcTmpl)
-		if err != nil {
-			woc.markNodeError(woc.wf.ObjectMeta.Name, err)
-			return err
+		pvc := &pvcTmpl
+		if !woc.config.skipInDryRun(woc.log, "creation of pvc %s", pvcName) {
+			var err error
+			pvc, err = pvcClient.Create(&pvcTmpl)
+			if apierr.IsAlreadyExists(err) {
+				pvc, err = woc.adoptPVC(pvcName)
+			}
+			if err != nil {
+				woc.markNodeError(woc.wf.ObjectMeta.Name, err)
+				return err
+			}
 		}
 		vol := apiv1.Volume{
 			Name: refName,
@@ -184,6 +692,30 @@ func (woc *wfOperationCtx) createPVCs() error {
 	return nil
 }
 
+// adoptPVC returns an existing PVC of a volume claim template, provided it is owned by the workflow. This is the
+// case when the controller failed to persist the workflow after creating the PVC (e.g. it was restarted).
+func (woc *wfOperationCtx) adoptPVC(pvcName string) (*apiv1.PersistentVolumeClaim, error) {
+	pvc, err := woc.controller.clientset.CoreV1().PersistentVolumeClaims(woc.wf.ObjectMeta.Namespace).Get(pvcName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if !isOwnedByWorkflow(pvc.ObjectMeta.OwnerReferences, woc.wf) {
+		return nil, errors.Errorf(errors.CodeBadRequest, "pvc %s already exists and is not owned by workflow %s", pvcName, woc.wf.ObjectMeta.Name)
+	}
+	woc.log.Infof("Adopting pvc %s: already exists", pvcName)
+	return pvc, nil
+}
+
+// isOwnedByWorkflow returns whether or not the owner references of an object include the workflow
+func isOwnedByWorkflow(refs []metav1.OwnerReference, wf *wfv1.Workflow) bool {
+	for _, ref := range refs {
+		if ref.Kind == wfv1.CRDKind && ref.UID == wf.ObjectMeta.UID {
+			return true
+		}
+	}
+	return false
+}
+
 func (woc *wfOperationCtx) deletePVCs() error {
 	totalPVCs := len(woc.wf.Status.PersistentVolumeClaims)
 	if totalPVCs == 0 {
@@ -195,6 +727,9 @@ func (woc *wfOperationCtx) deletePVCs() error {
 	// Attempt to delete all PVCs. Record first error encountered
 	var firstErr error
 	for _, pvc := range woc.wf.Status.PersistentVolumeClaims {
+		if woc.config.skipInDryRun(woc.log, "deletion of pvc %s", pvc.PersistentVolumeClaim.ClaimName) {
+			continue
+		}
 		woc.log.Infof("Deleting PVC %s", pvc.PersistentVolumeClaim.ClaimName)
 		err := pvcClient.Delete(pvc.PersistentVolumeClaim.ClaimName, nil)
 		if err != nil {
@@ -224,6 +759,15 @@ func (woc *wfOperationCtx) executeTemplate(templateName string, args wfv1.Argume
 		woc.log.Debugf("Node %s already completed", nodeName)
 		return nil
 	}
+	if !ok && woc.isShutdown() {
+		woc.log.Infof("Failing %s: %s", nodeName, woc.shutdownMessage())
+		woc.markNodePhase(nodeName, wfv1.NodeFailed, woc.shutdownMessage())
+		return nil
+	}
+	if !ok && woc.nodeLimitReached() {
+		// The caller errors the node, which exceeds the limit and stops the workflow (see nodeLimitExceeded)
+		return errors.Errorf(errors.CodeBadRequest, "%s", woc.nodeLimitMessage())
+	}
 	tmpl := woc.wf.GetTemplate(templateName)
 	if tmpl == nil {
 		err := errors.Errorf(errors.CodeBadRequest, "Node %v error: template '%s' undefined", node, templateName)
@@ -237,12 +781,30 @@ func (woc *wfOperationCtx) executeTemplate(templateName string, args wfv1.Argume
 		return err
 	}
 
+	if tmpl.Memoize != nil && !ok {
+		hit, err := woc.executeMemoized(nodeName, tmpl)
+		if err != nil {
+			woc.markNodeError(nodeName, err)
+			return err
+		}
+		if hit {
+			return nil
+		}
+	}
+
+	if tmpl.RetryStrategy != nil && (tmpl.Container != nil || tmpl.Script != nil) {
+		return woc.executeRetryStrategy(nodeName, tmpl)
+	}
+
 	if tmpl.Container != nil {
 		if ok {
 			// There's already a node entry for the container. This means the container was already
 			// scheduled (or had a create pod error). Nothing to more to do with this node.
 			return nil
 		}
+		if woc.deferPodCreation(nodeName) {
+			return nil
+		}
 		// We have not yet created the pod
 		return woc.executeContainer(nodeName, tmpl)
 
@@ -257,14 +819,143 @@ func (woc *wfOperationCtx) executeTemplate(templateName string, args wfv1.Argume
 		}
 		return err
 
+	} else if tmpl.DAG != nil {
+		if !ok {
+			node = *woc.markNodePhase(nodeName, wfv1.NodeRunning)
+			woc.log.Infof("Initialized DAG node %v", node)
+		}
+		err = woc.executeDAG(nodeName, tmpl)
+		if woc.wf.Status.Nodes[nodeID].Completed() {
+			woc.killDaemonedNodes(woc.wf.Status.Nodes[nodeID].Children)
+		}
+		return err
+
 	} else if tmpl.Script != nil {
+		if ok {
+			// Similar to containers, the script's pod was already scheduled
+			return nil
+		}
+		if woc.deferPodCreation(nodeName) {
+			return nil
+		}
 		return woc.executeScript(nodeName, tmpl)
+
+	} else if tmpl.Suspend != nil {
+		if ok {
+			return nil
+		}
+		if woc.isSuspended() {
+			// An earlier suspension must be resumed before this one is reached
+			woc.log.Infof("Deferring %s: workflow is suspended", nodeName)
+			return nil
+		}
+		return woc.executeSuspend(nodeName)
+
+	} else if tmpl.Resource != nil {
+		// Like a pod, the resource is not created in maintenance mode, while suspended, or beyond the parallelism
+		if !ok && woc.deferPodCreation(nodeName) {
+			return nil
+		}
+		return woc.executeResource(nodeName, tmpl)
 	}
-	err = errors.Errorf("Template '%s' missing specification", tmpl.Name)
+	err = errors.Errorf(errors.CodeBadRequest, "Template '%s' missing specification", tmpl.Name)
 	woc.markNodeError(nodeName, err)
 	return err
 }
 
+// deferPodCreation returns whether or not the pod (or resource) of a node should not yet be created, because the controller
+// is in maintenance mode, or the workflow is suspended or its parallelism limit is reached. The node is
+// evaluated again on a later operation.
+func (woc *wfOperationCtx) deferPodCreation(nodeName string) bool {
+	if woc.config.Maintenance {
+		// We will retry when maintenance mode is exited (see updateConfig)
+		woc.log.Infof("Deferring %s: controller is in maintenance mode", nodeName)
+		return true
+	}
+	if woc.isSuspended() {
+		woc.log.Infof("Deferring %s: workflow is suspended", nodeName)
+		return true
+	}
+	if woc.parallelismReached() {
+		// We will retry when a running pod completes
+		woc.log.Infof("Deferring %s: workflow parallelism of %d reached", nodeName, woc.getParallelism())
+		return true
+	}
+	return false
+}
+
+// isSuspended returns whether or not the workflow is suspended
+func (woc *wfOperationCtx) isSuspended() bool {
+	return woc.wf.Spec.Suspend != nil && *woc.wf.Spec.Suspend
+}
+
+// executeSuspend suspends the workflow when a suspend template is reached. The node itself succeeds
+// immediately, but no further pods are created until the workflow is resumed by clearing spec.suspend.
+// Resuming is an update of the workflow, which causes it to be operated on again.
+func (woc *wfOperationCtx) executeSuspend(nodeName string) error {
+	t := true
+	woc.log.Infof("Suspending workflow at node %s", nodeName)
+	woc.wf.Spec.Suspend = &t
+	woc.markNodePhase(nodeName, wfv1.NodeSucceeded)
+	woc.updated = true
+	return nil
+}
+
+// updateProgress updates the progress of the workflow (see WorkflowStatus.Progress)
+func (woc *wfOperationCtx) updateProgress() {
+	progress := workflowProgress(woc.wf)
+	if progress != woc.wf.Status.Progress {
+		woc.wf.Status.Progress = progress
+		woc.updated = true
+	}
+}
+
+// workflowProgress returns the number of completed nodes out of the nodes of a workflow, as completed/total.
+// Nodes without children are counted, as in countActivePods. Returns an empty string if the workflow has no nodes.
+func workflowProgress(wf *wfv1.Workflow) string {
+	var completed, total int
+	for _, node := range wf.Status.Nodes {
+		if len(node.Children) > 0 {
+			continue
+		}
+		total++
+		if node.Completed() {
+			completed++
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", completed, total)
+}
+
+// countActivePods returns the number of nodes of the workflow which are backed by a running pod.
+// Running nodes without children are pods, since step, step group and retry nodes are initialized with
+// children, except for the nodes of resource templates.
+func (woc *wfOperationCtx) countActivePods() int64 {
+	var count int64
+	for _, node := range woc.wf.Status.Nodes {
+		if node.Phase == wfv1.NodeRunning && len(node.Children) == 0 && node.ResourceRef == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// getParallelism returns the max number of pods of the workflow allowed to run simultaneously, or zero if unlimited
+func (woc *wfOperationCtx) getParallelism() int64 {
+	if woc.wf.Spec.Parallelism != nil {
+		return *woc.wf.Spec.Parallelism
+	}
+	return woc.config.Parallelism
+}
+
+// parallelismReached returns whether the workflow is running as many pods as permitted by its parallelism
+func (woc *wfOperationCtx) parallelismReached() bool {
+	parallelism := woc.getParallelism()
+	return parallelism > 0 && woc.activePods >= parallelism
+}
+
 // markWorkflowPhase is a convenience method to set the phase of the workflow with optional message
 // optionally marks the workflow completed, which sets the finishedAt timestamp and completed label
 func (woc *wfOperationCtx) markWorkflowPhase(phase wfv1.NodePhase, markCompleted bool, message ...string) {
@@ -276,6 +967,10 @@ func (woc *wfOperationCtx) markWorkflowPhase(phase wfv1.NodePhase, markCompleted
 			woc.wf.ObjectMeta.Labels = make(map[string]string)
 		}
 		woc.wf.ObjectMeta.Labels[common.LabelKeyPhase] = string(phase)
+		woc.wf.Status.PhaseTransitions = append(woc.wf.Status.PhaseTransitions, wfv1.PhaseTransition{
+			Phase: phase,
+			Time:  metav1.Time{Time: time.Now().UTC()},
+		})
 	}
 	if woc.wf.Status.StartedAt.IsZero() {
 		woc.updated = true
@@ -296,11 +991,37 @@ func (woc *wfOperationCtx) markWorkflowPhase(phase wfv1.NodePhase, markCompleted
 				woc.wf.ObjectMeta.Labels = make(map[string]string)
 			}
 			woc.wf.ObjectMeta.Labels[common.LabelKeyCompleted] = "true"
+			if phase != wfv1.NodeSucceeded {
+				woc.wf.Status.FailureType = workflowFailureType(woc.wf)
+				woc.wf.ObjectMeta.Labels[common.LabelKeyFailureType] = string(woc.wf.Status.FailureType)
+			}
 			woc.updated = true
+			woc.removeFinalizer()
 		}
 	}
 }
 
+// abortWorkflow errors a workflow which can no longer be operated on. As upon its completion, the running
+// nodes of the workflow are killed, and its pods and PVCs are cleaned up. The workflow is only marked completed,
+// which removes its finalizer, once the cleanup succeeded. Otherwise the cleanup is retried.
+func (woc *wfOperationCtx) abortWorkflow(err error) {
+	woc.killRunningNodes(err.Error(), wfv1.NodeReasonTerminated)
+	woc.markWorkflowError(err, false)
+	cleanupErr := woc.deletePVCs()
+	if cleanupErr == nil {
+		cleanupErr = woc.gcWorkflowPods(wfv1.NodeError)
+	}
+	if cleanupErr == nil {
+		cleanupErr = woc.deleteRunningPods()
+	}
+	if cleanupErr != nil {
+		woc.log.Errorf("Failed to clean up errored workflow: %+v", cleanupErr)
+		woc.requeueAfter(podGCRetryDelay)
+		return
+	}
+	woc.markWorkflowError(err, true)
+}
+
 func (woc *wfOperationCtx) markWorkflowRunning() {
 	woc.markWorkflowPhase(wfv1.NodeRunning, false)
 }
@@ -356,11 +1077,78 @@ func (woc *wfOperationCtx) executeContainer(nodeName string, tmpl *wfv1.Template
 		woc.markNodeError(nodeName, err)
 		return err
 	}
+	woc.activePods++
 	node := woc.markNodePhase(nodeName, wfv1.NodeRunning)
+	if tmpl.Memoize != nil {
+		woc.markNodeMemoized(nodeName, tmpl)
+	}
 	woc.log.Infof("Initialized container node %v", node)
 	return nil
 }
 
+// executeRetryStrategy executes a container or script template as a series of attempts, each of which is
+// a child node of the node. A new attempt is made when the previous attempt failed and retries remain.
+// Attempt nodes are named <nodeName>(<attempt>), and the outputs of a successful attempt propagate to the node.
+func (woc *wfOperationCtx) executeRetryStrategy(nodeName string, tmpl *wfv1.Template) error {
+	nodeID := woc.wf.NodeID(nodeName)
+	node, ok := woc.wf.Status.Nodes[nodeID]
+	if ok && len(node.Children) > 0 {
+		lastAttempt := woc.wf.Status.Nodes[node.Children[len(node.Children)-1]]
+		if !lastAttempt.Completed() {
+			return nil
+		}
+		if lastAttempt.Successful() {
+			node.Outputs = lastAttempt.Outputs
+			node.PodIP = lastAttempt.PodIP
+			woc.wf.Status.Nodes[nodeID] = node
+			woc.markNodePhase(nodeName, lastAttempt.Phase)
+			return nil
+		}
+		var limit int32
+		if tmpl.RetryStrategy.Limit != nil {
+			limit = *tmpl.RetryStrategy.Limit
+		}
+		retries := len(node.Children)
+		if int32(retries) > limit {
+			woc.log.Infof("Retry node %s exhausted %d retries", node, limit)
+			node.Reason = lastAttempt.Reason
+			woc.wf.Status.Nodes[nodeID] = node
+			woc.markNodePhase(nodeName, lastAttempt.Phase, fmt.Sprintf("no more retries left: %s", lastAttempt.Message))
+			return nil
+		}
+		backoff, err := common.GetRetryBackoff(tmpl.RetryStrategy, retries)
+		if err != nil {
+			woc.markNodeError(nodeName, err)
+			return err
+		}
+		if remaining := lastAttempt.FinishedAt.Add(backoff).Sub(time.Now()); remaining > 0 {
+			woc.log.Infof("Backing off %v before retry %d of node %s", remaining, retries, node)
+			woc.requeueAfter(remaining)
+			return nil
+		}
+	}
+	if woc.isShutdown() {
+		woc.log.Infof("Failing %s: %s", nodeName, woc.shutdownMessage())
+		woc.markNodePhase(nodeName, wfv1.NodeFailed, woc.shutdownMessage())
+		return nil
+	}
+	if woc.deferPodCreation(nodeName) {
+		return nil
+	}
+	if !ok {
+		// The node is only initialized with its first attempt, since a running node without children
+		// would be counted as an active pod (see countActivePods)
+		node = *woc.markNodePhase(nodeName, wfv1.NodeRunning)
+		woc.log.Infof("Initialized retry node %v", node)
+	}
+	attemptName := fmt.Sprintf("%s(%d)", nodeName, len(node.Children))
+	woc.addChildNode(nodeName, attemptName)
+	if tmpl.Script != nil {
+		return woc.executeScript(attemptName, tmpl)
+	}
+	return woc.executeContainer(attemptName, tmpl)
+}
+
 func (woc *wfOperationCtx) executeSteps(nodeName string, tmpl *wfv1.Template) error {
 	scope := wfScope{
 		tmpl:  tmpl,
@@ -387,38 +1175,57 @@ func (woc *wfOperationCtx) executeSteps(nodeName string, tmpl *wfv1.Template) er
 			return nil
 		}
 
-		// HACK: need better way to add children to scope
-		for _, step := range stepGroup {
-			childNodeName := fmt.Sprintf("%s.%s", sgNodeName, step.Name)
-			childNodeID := woc.wf.NodeID(childNodeName)
-			childNode, ok := woc.wf.Status.Nodes[childNodeID]
-			if !ok {
-				// This can happen if there was `withItem` expansion
-				// it is okay to ignore this because these expanded steps
-				// are not easily referenceable by user.
-				continue
-			}
-			if childNode.PodIP != "" {
-				key := fmt.Sprintf("steps.%s.ip", step.Name)
-				scope.addParamToScope(key, childNode.PodIP)
+		err = woc.addStepGroupOutputsToScope(stepGroup, sgNodeName, &scope)
+		if err != nil {
+			woc.markNodeError(nodeName, err)
+			return err
+		}
+	}
+	woc.markNodePhase(nodeName, wfv1.NodeSucceeded)
+	return nil
+}
+
+// addStepGroupOutputsToScope adds the outputs of the steps of a completed step group to the scope, so that
+// they can be referenced by the following step groups. The outputs of a step which was expanded using
+// withItems or withParam are aggregated from the outputs of its expanded steps.
+func (woc *wfOperationCtx) addStepGroupOutputsToScope(stepGroup []wfv1.WorkflowStep, sgNodeName string, scope *wfScope) error {
+	// The steps are expanded again, as they were when the step group was executed, in order to find the
+	// expanded steps. This must happen before any output of the step group is added to the scope.
+	resolvedStepGroup, err := woc.resolveReferences(stepGroup, scope)
+	if err != nil {
+		return err
+	}
+	expandedSteps := make([][]wfv1.WorkflowStep, len(resolvedStepGroup))
+	for i, step := range resolvedStepGroup {
+		if len(step.WithItems) == 0 && step.WithParam == "" {
+			continue
+		}
+		expandedSteps[i], err = woc.expandStep(step)
+		if err != nil {
+			return err
+		}
+	}
+	for i, step := range stepGroup {
+		prefix := fmt.Sprintf("steps.%s", step.Name)
+		if expandedSteps[i] == nil {
+			childNode, ok := woc.wf.Status.Nodes[woc.wf.NodeID(fmt.Sprintf("%s.%s", sgNodeName, step.Name))]
+			if ok {
+				scope.addNodeOutputsToScope(prefix, childNode)
 			}
-			if childNode.Outputs != nil {
-				if childNode.Outputs.Result != nil {
-					key := fmt.Sprintf("steps.%s.outputs.result", step.Name)
-					scope.addParamToScope(key, *childNode.Outputs.Result)
-				}
-				for _, outParam := range childNode.Outputs.Parameters {
-					key := fmt.Sprintf("steps.%s.outputs.parameters.%s", step.Name, outParam.Name)
-					scope.addParamToScope(key, *outParam.Value)
-				}
-				for _, outArt := range childNode.Outputs.Artifacts {
-					key := fmt.Sprintf("steps.%s.outputs.artifacts.%s", step.Name, outArt.Name)
-					scope.addArtifactToScope(key, outArt)
-				}
+			continue
+		}
+		childNodes := make([]wfv1.NodeStatus, 0, len(expandedSteps[i]))
+		for _, expandedStep := range expandedSteps[i] {
+			childNode, ok := woc.wf.Status.Nodes[woc.wf.NodeID(fmt.Sprintf("%s.%s", sgNodeName, expandedStep.Name))]
+			if ok {
+				childNodes = append(childNodes, childNode)
 			}
 		}
+		err = scope.addAggregatedOutputsToScope(prefix, childNodes)
+		if err != nil {
+			return err
+		}
 	}
-	woc.markNodePhase(nodeName, wfv1.NodeSucceeded)
 	return nil
 }
 
@@ -483,45 +1290,38 @@ func (woc *wfOperationCtx) executeStepGroup(stepGroup []wfv1.WorkflowStep, sgNod
 			return nil
 		}
 	}
-	// All children completed. Determine step group status as a whole
-	for _, childNodeID := range node.Children {
-		childNode := woc.wf.Status.Nodes[childNodeID]
-		if !childNode.Successful() {
-			failMessage := fmt.Sprintf("child '%s' failed", childNodeID)
-			woc.markNodePhase(sgNodeName, wfv1.NodeFailed, failMessage)
-			woc.log.Infof("Step group node %s deemed failed: %s", childNode, failMessage)
-			return nil
+	// All children completed. Determine step group status as a whole. The steps expanded from a
+	// withItems/withParam step are children of the step group, so the failure of any of them fails it,
+	// unless the step continues on the failure
+	var failedChildren, continuedChildren []string
+	for _, step := range stepGroup {
+		childNode := woc.wf.Status.Nodes[woc.wf.NodeID(fmt.Sprintf("%s.%s", sgNodeName, step.Name))]
+		if childNode.Successful() {
+			continue
+		}
+		if step.ContinueOn.ShouldContinue(childNode.Phase) {
+			continuedChildren = append(continuedChildren, fmt.Sprintf("'%s'", childNode.Name))
+			continue
 		}
+		failedChildren = append(failedChildren, fmt.Sprintf("'%s'", childNode.Name))
+	}
+	if len(failedChildren) > 0 {
+		failMessage := fmt.Sprintf("child(ren) %s failed", strings.Join(failedChildren, ", "))
+		woc.markNodePhase(sgNodeName, wfv1.NodeFailed, failMessage)
+		woc.log.Infof("Step group node %s deemed failed: %s", sgNodeName, failMessage)
+		return nil
+	}
+	if len(continuedChildren) > 0 {
+		continueMessage := fmt.Sprintf("continued on unsuccessful child(ren) %s", strings.Join(continuedChildren, ", "))
+		woc.markNodePhase(node.Name, wfv1.NodeSucceeded, continueMessage)
+		woc.log.Infof("Step group node %s successful: %s", sgNodeName, continueMessage)
+		return nil
 	}
 	woc.markNodePhase(node.Name, wfv1.NodeSucceeded)
 	woc.log.Infof("Step group node %v successful", woc.wf.Status.Nodes[nodeID])
 	return nil
 }
 
-var whenExpression = regexp.MustCompile("^(.*)(==|!=)(.*)$")
-
-// shouldExecute evaluates a already substituted when expression to decide whether or not a step should execute
-func shouldExecute(when string) (bool, error) {
-	if when == "" {
-		return true, nil
-	}
-	parts := whenExpression.FindStringSubmatch(when)
-	if len(parts) == 0 {
-		return false, errors.Errorf(errors.CodeBadRequest, "Invalid 'when' expression: %s", when)
-	}
-	var1 := strings.TrimSpace(parts[1])
-	operator := parts[2]
-	var2 := strings.TrimSpace(parts[3])
-	switch operator {
-	case "==":
-		return var1 == var2, nil
-	case "!=":
-		return var1 != var2, nil
-	default:
-		return false, errors.Errorf(errors.CodeBadRequest, "Unknown operator: %s", operator)
-	}
-}
-
 // resolveReferences replaces any references to outputs of previous steps, or artifacts in the inputs
 // NOTE: by now, input parameters should have been substituted throughout the template, so we only
 // are concerned with:
@@ -665,7 +1465,11 @@ func (woc *wfOperationCtx) executeScript(nodeName string, tmpl *wfv1.Template) e
 		woc.markNodeError(nodeName, err)
 		return err
 	}
+	woc.activePods++
 	node := woc.markNodePhase(nodeName, wfv1.NodeRunning)
+	if tmpl.Memoize != nil {
+		woc.markNodeMemoized(nodeName, tmpl)
+	}
 	woc.log.Infof("Initialized container node %v", node)
 	return nil
 }
@@ -678,21 +1482,76 @@ func (wfs *wfScope) addArtifactToScope(key string, artifact wfv1.Artifact) {
 	wfs.scope[key] = artifact
 }
 
+// addNodeOutputsToScope adds the pod IP and outputs of a completed step or task node to the scope,
+// under the given prefix (i.e. 'steps.<name>' or 'tasks.<name>')
+func (wfs *wfScope) addNodeOutputsToScope(prefix string, node wfv1.NodeStatus) {
+	if node.PodIP != "" {
+		wfs.addParamToScope(fmt.Sprintf("%s.ip", prefix), node.PodIP)
+	}
+	if node.Outputs == nil {
+		return
+	}
+	if node.Outputs.Result != nil {
+		wfs.addParamToScope(fmt.Sprintf("%s.outputs.result", prefix), *node.Outputs.Result)
+	}
+	for _, outParam := range node.Outputs.Parameters {
+		wfs.addParamToScope(fmt.Sprintf("%s.outputs.parameters.%s", prefix, outParam.Name), *outParam.Value)
+	}
+	for _, outArt := range node.Outputs.Artifacts {
+		wfs.addArtifactToScope(fmt.Sprintf("%s.outputs.artifacts.%s", prefix, outArt.Name), outArt)
+	}
+}
+
+// addAggregatedOutputsToScope adds the outputs of the expanded steps of a step using withItems or withParam
+// to the scope, under the prefix of the step. The result and each output parameter are aggregated as a
+// JSON list of the values of the expanded steps, in the order of the items, so that they can in turn be
+// used as a withParam. Skipped steps (which have no outputs) are omitted from the lists.
+func (wfs *wfScope) addAggregatedOutputsToScope(prefix string, nodes []wfv1.NodeStatus) error {
+	results := make([]string, 0)
+	params := make(map[string][]string)
+	for _, node := range nodes {
+		if node.Outputs == nil {
+			continue
+		}
+		if node.Outputs.Result != nil {
+			results = append(results, *node.Outputs.Result)
+		}
+		for _, outParam := range node.Outputs.Parameters {
+			if outParam.Value != nil {
+				params[outParam.Name] = append(params[outParam.Name], *outParam.Value)
+			}
+		}
+	}
+	resultsBytes, err := json.Marshal(results)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	wfs.addParamToScope(fmt.Sprintf("%s.outputs.result", prefix), string(resultsBytes))
+	for name, values := range params {
+		valuesBytes, err := json.Marshal(values)
+		if err != nil {
+			return errors.InternalWrapError(err)
+		}
+		wfs.addParamToScope(fmt.Sprintf("%s.outputs.parameters.%s", prefix, name), string(valuesBytes))
+	}
+	return nil
+}
+
 func (wfs *wfScope) resolveVar(v string) (interface{}, error) {
 	v = strings.TrimPrefix(v, "{{")
 	v = strings.TrimSuffix(v, "}}")
-	if strings.HasPrefix(v, "steps.") {
+	if strings.HasPrefix(v, "steps.") || strings.HasPrefix(v, "tasks.") {
 		val, ok := wfs.scope[v]
 		if !ok {
 			return nil, errors.Errorf(errors.CodeBadRequest, "Unable to resolve: {{%s}}", v)
 		}
 		return val, nil
 	}
-	parts := strings.Split(v, ".")
-	// HACK (assuming it is an input artifact)
-	art := wfs.tmpl.Inputs.GetArtifactByName(parts[2])
-	if art != nil {
-		return *art, nil
+	if strings.HasPrefix(v, "inputs.artifacts.") {
+		art := wfs.tmpl.Inputs.GetArtifactByName(strings.TrimPrefix(v, "inputs.artifacts."))
+		if art != nil {
+			return *art, nil
+		}
 	}
 	return nil, errors.Errorf(errors.CodeBadRequest, "Unable to resolve input artifact: {{%s}}", v)
 }
@@ -721,6 +1580,95 @@ func (wfs *wfScope) resolveArtifact(v string) (*wfv1.Artifact, error) {
 	return &valArt, nil
 }
 
+// resolveWorkflowOutputs resolves the outputs declared by the workflow into status.outputs, from the outputs of
+// the steps or tasks of the entrypoint template, once the entrypoint completed. Outputs which cannot be resolved
+// (i.e. which reference steps or tasks which did not succeed) are omitted.
+func (woc *wfOperationCtx) resolveWorkflowOutputs() {
+	if woc.wf.Spec.Outputs == nil || woc.wf.Status.Outputs != nil {
+		return
+	}
+	scope, err := woc.entrypointScope()
+	if err != nil {
+		woc.log.Warnf("Failed to resolve the outputs of the workflow: %v", err)
+		return
+	}
+	params := make(map[string]string)
+	for key, val := range scope.scope {
+		if valStr, ok := val.(string); ok {
+			params[key] = valStr
+		}
+	}
+	outputs := wfv1.Outputs{}
+	for _, param := range woc.wf.Spec.Outputs.Parameters {
+		if param.Value == nil {
+			continue
+		}
+		fstTmpl, err := fasttemplate.NewTemplate(*param.Value, "{{", "}}")
+		if err != nil {
+			woc.log.Warnf("Omitting output parameter %s of the workflow: %v", param.Name, err)
+			continue
+		}
+		value, err := common.Replace(fstTmpl, params, false)
+		if err != nil {
+			woc.log.Warnf("Omitting output parameter %s of the workflow: %v", param.Name, err)
+			continue
+		}
+		outputs.Parameters = append(outputs.Parameters, wfv1.Parameter{Name: param.Name, Value: &value})
+	}
+	for _, art := range woc.wf.Spec.Outputs.Artifacts {
+		resolvedArt, err := scope.resolveArtifact(art.From)
+		if err != nil {
+			woc.log.Warnf("Omitting output artifact %s of the workflow: %v", art.Name, err)
+			continue
+		}
+		outputs.Artifacts = append(outputs.Artifacts, wfv1.Artifact{Name: art.Name, ArtifactLocation: resolvedArt.ArtifactLocation})
+	}
+	woc.wf.Status.Outputs = &outputs
+	woc.updated = true
+}
+
+// entrypointScope returns the scope holding the outputs of the successful steps or tasks of the entrypoint
+// template, like the scope in which the entrypoint executed them
+func (woc *wfOperationCtx) entrypointScope() (*wfScope, error) {
+	tmpl := woc.wf.GetTemplate(woc.wf.Spec.Entrypoint)
+	if tmpl == nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "spec.entrypoint template '%s' undefined", woc.wf.Spec.Entrypoint)
+	}
+	tmpl, err := common.ProcessArgs(tmpl, woc.wf.Spec.Arguments, false)
+	if err != nil {
+		return nil, err
+	}
+	scope := wfScope{
+		tmpl:  tmpl,
+		scope: make(map[string]interface{}),
+	}
+	nodeName := woc.wf.ObjectMeta.Name
+	for i, stepGroup := range tmpl.Steps {
+		sgNodeName := fmt.Sprintf("%s[%d]", nodeName, i)
+		sgNode, ok := woc.wf.Status.Nodes[woc.wf.NodeID(sgNodeName)]
+		if !ok || !sgNode.Completed() {
+			break
+		}
+		err = woc.addStepGroupOutputsToScope(stepGroup, sgNodeName, &scope)
+		if err != nil {
+			return nil, err
+		}
+		if !sgNode.Successful() {
+			// the following step groups were not executed
+			break
+		}
+	}
+	if tmpl.DAG != nil {
+		for _, task := range tmpl.DAG.Tasks {
+			taskNode, ok := woc.wf.Status.Nodes[woc.wf.NodeID(dagTaskNodeName(nodeName, task.Name))]
+			if ok && taskNode.Successful() {
+				scope.addNodeOutputsToScope(fmt.Sprintf("tasks.%s", task.Name), taskNode)
+			}
+		}
+	}
+	return &scope, nil
+}
+
 // addChildNode adds a nodeID as a child to a parent
 func (woc *wfOperationCtx) addChildNode(parent string, child string) {
 	parentID := woc.wf.NodeID(parent)
@@ -751,14 +1699,31 @@ func (woc *wfOperationCtx) killDeamonedChildren(nodeID string) error {
 	woc.log.Infof("Checking deamon children of %s", nodeID)
 	var firstErr error
 	for _, childNodeID := range woc.wf.Status.Nodes[nodeID].Children {
-		for _, grandChildID := range woc.wf.Status.Nodes[childNodeID].Children {
-			gcNode := woc.wf.Status.Nodes[grandChildID]
-			if gcNode.Daemoned == nil || !*gcNode.Daemoned {
+		err := woc.killDaemonedNodes(woc.wf.Status.Nodes[childNodeID].Children)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// killDaemonedNodes kills the pods of the given nodes which have been daemoned. A node with a retry
+// strategy has the daemoned pod as one of its attempts. Returns the first error that occurs (if any)
+func (woc *wfOperationCtx) killDaemonedNodes(nodeIDs []string) error {
+	var firstErr error
+	for _, nodeID := range nodeIDs {
+		candidateIDs := append([]string{nodeID}, woc.wf.Status.Nodes[nodeID].Children...)
+		for _, candidateID := range candidateIDs {
+			node := woc.wf.Status.Nodes[candidateID]
+			if node.Daemoned == nil || !*node.Daemoned {
+				continue
+			}
+			if woc.config.skipInDryRun(woc.log, "kill of %s", node) {
 				continue
 			}
-			err := common.KillPodContainer(woc.controller.restConfig, woc.wf.ObjectMeta.Namespace, gcNode.ID, common.MainContainerName)
+			err := common.KillPodContainer(woc.controller.restConfig, woc.wf.ObjectMeta.Namespace, node.ID, common.MainContainerName)
 			if err != nil {
-				woc.log.Errorf("Failed to kill %s: %+v", gcNode, err)
+				woc.log.Errorf("Failed to kill %s: %+v", node, err)
 				if firstErr == nil {
 					firstErr = err
 				}
@@ -0,0 +1,606 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/argoproj/argo/errors"
+	"github.com/argoproj/argo/workflow/common"
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestReportCompletion(t *testing.T) {
+	startedAt := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	wf := &wfv1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{Name: "steps-abcde", Namespace: "argo"},
+		Status: wfv1.WorkflowStatus{
+			Phase:      wfv1.NodeFailed,
+			Message:    "child 'steps-abcde[1].B' failed",
+			StartedAt:  metav1.Time{Time: startedAt},
+			FinishedAt: metav1.Time{Time: startedAt.Add(90 * time.Second)},
+		},
+	}
+	result := "hello"
+	value := "world"
+	nodes := []wfv1.NodeStatus{
+		{Name: "steps-abcde", Phase: wfv1.NodeFailed, Outputs: &wfv1.Outputs{
+			Result:     &result,
+			Parameters: []wfv1.Parameter{{Name: "greeting", Value: &value}},
+		}},
+		{Name: "steps-abcde[0]", Phase: wfv1.NodeSucceeded},
+		{Name: "steps-abcde[0].A", Phase: wfv1.NodeSucceeded},
+		{Name: "steps-abcde[1]", Phase: wfv1.NodeFailed},
+		{Name: "steps-abcde[1].B", Phase: wfv1.NodeError},
+		{Name: "steps-abcde[1].C", Phase: wfv1.NodeSkipped},
+	}
+	wf.Status.Nodes = make(map[string]wfv1.NodeStatus)
+	for _, node := range nodes {
+		node.ID = wf.NodeID(node.Name)
+		wf.Status.Nodes[node.ID] = node
+	}
+
+	recorder := record.NewFakeRecorder(1)
+	woc := newWorkflowOperationCtx(wf, &WorkflowController{eventRecorder: recorder})
+	woc.reportCompletion()
+	assert.Equal(t, "Warning WorkflowCompleted Workflow Failed in 1m30s: 2 node(s) succeeded, 3 failed, "+
+		"outputs: result=hello, parameters.greeting=world (child 'steps-abcde[1].B' failed)", <-recorder.Events)
+}
+
+func TestDeferPodCreationInMaintenance(t *testing.T) {
+	wfc := &WorkflowController{ConfigMap: "workflow-controller-configmap"}
+	cm := &apiv1.ConfigMap{Data: map[string]string{
+		common.WorkflowControllerConfigMapKey: "executorImage: argoproj/argoexec:latest\nmaintenance: true\n",
+	}}
+	err := wfc.updateConfig(cm)
+	if !assert.Nil(t, err) {
+		return
+	}
+	woc := newWorkflowOperationCtx(&wfv1.Workflow{}, wfc)
+	assert.True(t, woc.deferPodCreation("maintenance"))
+
+	// maintenance mode is exited by updating the config, which applies from the next operation
+	cm.Data[common.WorkflowControllerConfigMapKey] = "executorImage: argoproj/argoexec:latest\n"
+	err = wfc.updateConfig(cm)
+	if assert.Nil(t, err) {
+		assert.True(t, woc.deferPodCreation("maintenance"))
+		woc = newWorkflowOperationCtx(&wfv1.Workflow{}, wfc)
+		assert.False(t, woc.deferPodCreation("maintenance"))
+	}
+}
+
+var continueOnFailedWorkflow = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: continue-on-fail-abcde
+spec:
+  entrypoint: workflow-ignore
+  templates:
+  - name: workflow-ignore
+    steps:
+    - - name: B
+        template: whalesay
+      - name: C
+        template: whalesay
+        continueOn:
+          failed: true
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+`
+
+func TestExecuteStepGroupContinueOn(t *testing.T) {
+	var wf wfv1.Workflow
+	err := yaml.Unmarshal([]byte(continueOnFailedWorkflow), &wf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	woc := newWorkflowOperationCtx(&wf, &WorkflowController{})
+	tmpl := wf.GetTemplate("workflow-ignore")
+	stepGroup := tmpl.Steps[0]
+	sgNodeName := "continue-on-fail-abcde[0]"
+	execute := func(phaseC wfv1.NodePhase) wfv1.NodeStatus {
+		woc.wf.Status.Nodes = make(map[string]wfv1.NodeStatus)
+		woc.markNodePhase(sgNodeName+".B", wfv1.NodeSucceeded)
+		woc.markNodePhase(sgNodeName+".C", phaseC)
+		err := woc.executeStepGroup(stepGroup, sgNodeName, &wfScope{tmpl: tmpl, scope: make(map[string]interface{})})
+		assert.Nil(t, err)
+		return woc.wf.Status.Nodes[woc.wf.NodeID(sgNodeName)]
+	}
+
+	node := execute(wfv1.NodeFailed)
+	assert.Equal(t, wfv1.NodeSucceeded, node.Phase)
+	assert.Equal(t, "continued on unsuccessful child(ren) 'continue-on-fail-abcde[0].C'", node.Message)
+
+	// the step does not continue on errors
+	node = execute(wfv1.NodeError)
+	assert.Equal(t, wfv1.NodeFailed, node.Phase)
+	assert.Equal(t, "child(ren) 'continue-on-fail-abcde[0].C' failed", node.Message)
+
+	node = execute(wfv1.NodeSucceeded)
+	assert.Equal(t, wfv1.NodeSucceeded, node.Phase)
+	assert.Equal(t, "", node.Message)
+}
+
+var retryParallelismWorkflow = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: retry-parallelism-abcde
+  namespace: argo
+spec:
+  entrypoint: main
+  parallelism: 1
+  templates:
+  - name: main
+    steps:
+    - - name: A
+        template: whalesay
+      - name: B
+        template: whalesay
+  - name: whalesay
+    retryStrategy:
+      limit: 1
+    container:
+      image: docker/whalesay:latest
+`
+
+func TestRetryParallelism(t *testing.T) {
+	var wf wfv1.Workflow
+	err := yaml.Unmarshal([]byte(retryParallelismWorkflow), &wf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientset := fake.NewSimpleClientset()
+	wfc := &WorkflowController{
+		config:    &WorkflowControllerConfig{ExecutorImage: "argoproj/argoexec:latest"},
+		clientset: clientset,
+	}
+	woc := newWorkflowOperationCtx(&wf, wfc)
+	operate := func() {
+		woc = newWorkflowOperationCtx(woc.wf, wfc)
+		woc.activePods = woc.countActivePods()
+		err := woc.executeTemplate(wf.Spec.Entrypoint, wf.Spec.Arguments, wf.ObjectMeta.Name)
+		assert.Nil(t, err)
+	}
+	getNode := func(nodeName string) (wfv1.NodeStatus, bool) {
+		node, ok := woc.wf.Status.Nodes[woc.wf.NodeID(nodeName)]
+		return node, ok
+	}
+	countPods := func() int {
+		pods, err := clientset.CoreV1().Pods("argo").List(metav1.ListOptions{})
+		assert.Nil(t, err)
+		return len(pods.Items)
+	}
+
+	// the retry node of B is not initialized while its first attempt is deferred
+	operate()
+	_, ok := getNode("retry-parallelism-abcde[0].A(0)")
+	assert.True(t, ok)
+	_, ok = getNode("retry-parallelism-abcde[0].B")
+	assert.False(t, ok)
+	assert.Equal(t, int64(1), woc.countActivePods())
+	assert.Equal(t, 1, countPods())
+
+	// B is created once the attempt of A completes
+	woc.markNodePhase("retry-parallelism-abcde[0].A(0)", wfv1.NodeSucceeded)
+	operate()
+	node, ok := getNode("retry-parallelism-abcde[0].A")
+	if assert.True(t, ok) {
+		assert.Equal(t, wfv1.NodeSucceeded, node.Phase)
+	}
+	node, ok = getNode("retry-parallelism-abcde[0].B")
+	if assert.True(t, ok) {
+		assert.Equal(t, wfv1.NodeRunning, node.Phase)
+		assert.Len(t, node.Children, 1)
+	}
+	assert.Equal(t, int64(1), woc.countActivePods())
+	assert.Equal(t, 2, countPods())
+}
+
+func TestIsOwnedByWorkflow(t *testing.T) {
+	wf := &wfv1.Workflow{ObjectMeta: metav1.ObjectMeta{Name: "volumes-pvc-abcde", UID: "1234"}}
+	assert.True(t, isOwnedByWorkflow([]metav1.OwnerReference{{Kind: wfv1.CRDKind, Name: "volumes-pvc-abcde", UID: "1234"}}, wf))
+	// a workflow of the same name, which was since deleted
+	assert.False(t, isOwnedByWorkflow([]metav1.OwnerReference{{Kind: wfv1.CRDKind, Name: "volumes-pvc-abcde", UID: "5678"}}, wf))
+	assert.False(t, isOwnedByWorkflow(nil, wf))
+}
+
+func TestWorkflowProgress(t *testing.T) {
+	wf := &wfv1.Workflow{ObjectMeta: metav1.ObjectMeta{Name: "steps-abcde"}}
+	assert.Equal(t, "", workflowProgress(wf))
+	wf.Status.Nodes = map[string]wfv1.NodeStatus{
+		"steps-abcde": {Name: "steps-abcde", Phase: wfv1.NodeRunning, Children: []string{"sg-0", "sg-1"}},
+		"sg-0":        {Name: "steps-abcde[0]", Phase: wfv1.NodeSucceeded, Children: []string{"A"}},
+		"A":           {Name: "steps-abcde[0].A", Phase: wfv1.NodeSucceeded},
+		"sg-1":        {Name: "steps-abcde[1]", Phase: wfv1.NodeRunning, Children: []string{"B", "C", "D"}},
+		"B":           {Name: "steps-abcde[1].B", Phase: wfv1.NodeRunning},
+		"C":           {Name: "steps-abcde[1].C", Phase: wfv1.NodeSkipped},
+		"D":           {Name: "steps-abcde[1].D", Phase: wfv1.NodeFailed},
+	}
+	assert.Equal(t, "3/4", workflowProgress(wf))
+
+	woc := newWorkflowOperationCtx(wf, &WorkflowController{})
+	woc.updateProgress()
+	assert.Equal(t, "3/4", woc.wf.Status.Progress)
+	assert.True(t, woc.updated)
+	woc.updated = false
+	woc.updateProgress()
+	assert.False(t, woc.updated)
+}
+
+func TestRunningPods(t *testing.T) {
+	newPod := func(namespace, name, wfName string, phase apiv1.PodPhase) *apiv1.Pod {
+		return &apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{common.LabelKeyWorkflow: wfName}},
+			Status:     apiv1.PodStatus{Phase: phase},
+		}
+	}
+	wf := &wfv1.Workflow{ObjectMeta: metav1.ObjectMeta{Name: "daemon-step-abcde", Namespace: "argo"}}
+	woc := newWorkflowOperationCtx(wf, &WorkflowController{})
+	assert.Empty(t, woc.runningPods())
+
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, pod := range []*apiv1.Pod{
+		newPod("argo", "daemon-step-abcde-1", "daemon-step-abcde", apiv1.PodRunning),
+		newPod("argo", "daemon-step-abcde-2", "daemon-step-abcde", apiv1.PodSucceeded),
+		newPod("argo", "daemon-step-abcde-3", "daemon-step-abcde", apiv1.PodPending),
+		newPod("argo", "daemon-step-fghij-1", "daemon-step-fghij", apiv1.PodRunning),
+		newPod("default", "daemon-step-abcde-1", "daemon-step-abcde", apiv1.PodRunning),
+	} {
+		err := store.Add(pod)
+		if !assert.Nil(t, err) {
+			return
+		}
+	}
+	woc.controller.podStore = store
+	var names []string
+	for _, pod := range woc.runningPods() {
+		assert.Equal(t, "argo", pod.ObjectMeta.Namespace)
+		names = append(names, pod.ObjectMeta.Name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"daemon-step-abcde-1", "daemon-step-abcde-3"}, names)
+}
+
+var parameterValueFromWorkflow = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: value-from-abcde
+  namespace: argo
+spec:
+  entrypoint: whalesay
+  arguments:
+    parameters:
+    - name: message
+      valueFrom:
+        configMapKeyRef:
+          name: whalesay-config
+          key: message
+  templates:
+  - name: whalesay
+    inputs:
+      parameters:
+      - name: message
+      - name: greeting
+        valueFrom:
+          configMapKeyRef:
+            name: whalesay-greetings
+            key: greeting
+      - name: password
+        valueFrom:
+          secretKeyRef:
+            name: whalesay-secret
+            key: password
+    container:
+      image: docker/whalesay:latest
+`
+
+func TestResolveParameterValuesFrom(t *testing.T) {
+	newWorkflow := func() *wfv1.Workflow {
+		var wf wfv1.Workflow
+		err := yaml.Unmarshal([]byte(parameterValueFromWorkflow), &wf)
+		if err != nil {
+			panic(err)
+		}
+		return &wf
+	}
+	clientset := fake.NewSimpleClientset(
+		&apiv1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "whalesay-config", Namespace: "argo"},
+			Data:       map[string]string{"message": "hello world"},
+		},
+		&apiv1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "whalesay-greetings", Namespace: "argo"},
+			Data:       map[string]string{"greeting": "hello"},
+		},
+		&apiv1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "whalesay-secret", Namespace: "argo"},
+			Data:       map[string][]byte{"password": []byte("s3cr3t")},
+		},
+	)
+	wfc := &WorkflowController{clientset: clientset}
+	resolve := func(wf *wfv1.Workflow) error {
+		woc := newWorkflowOperationCtx(wf, wfc)
+		err := woc.resolveParameterValuesFrom()
+		*wf = *woc.wf
+		return err
+	}
+	wf := newWorkflow()
+	err := resolve(wf)
+	if assert.Nil(t, err) {
+		param := wf.Spec.Arguments.Parameters[0]
+		assert.Nil(t, param.ValueFrom)
+		if assert.NotNil(t, param.Value) {
+			assert.Equal(t, "hello world", *param.Value)
+		}
+		param = wf.Spec.Templates[0].Inputs.Parameters[1]
+		assert.Nil(t, param.ValueFrom)
+		if assert.NotNil(t, param.Default) {
+			assert.Equal(t, "hello", *param.Default)
+		}
+		// the values of secret keys are not stored in the workflow
+		param = wf.Spec.Templates[0].Inputs.Parameters[2]
+		assert.Nil(t, param.Default)
+		if assert.NotNil(t, param.ValueFrom) {
+			assert.Equal(t, "whalesay-secret", param.ValueFrom.SecretKeyRef.Name)
+		}
+	}
+
+	wf = newWorkflow()
+	wf.Spec.Templates[0].Inputs.Parameters[2].ValueFrom.SecretKeyRef.Key = "token"
+	err = resolve(wf)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "template 'whalesay' inputs.parameters.password.valueFrom: key 'token' not found in secret 'whalesay-secret'", err.Error())
+	}
+
+	wf = newWorkflow()
+	wf.Spec.Templates[0].Inputs.Parameters[1].ValueFrom.ConfigMapKeyRef.Key = "farewell"
+	err = resolve(wf)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "template 'whalesay' inputs.parameters.greeting.valueFrom: key 'farewell' not found in configmap 'whalesay-greetings'", err.Error())
+	}
+
+	wf = newWorkflow()
+	wf.ObjectMeta.Namespace = "default"
+	err = resolve(wf)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "spec.arguments.parameters.message.valueFrom: configmap 'whalesay-config' not found in namespace 'default'", err.Error())
+	}
+	optional := true
+	wf.Spec.Arguments.Parameters[0].ValueFrom.ConfigMapKeyRef.Optional = &optional
+	wf.Spec.Templates[0].Inputs.Parameters[1].ValueFrom.ConfigMapKeyRef.Optional = &optional
+	wf.Spec.Templates[0].Inputs.Parameters[2].ValueFrom.SecretKeyRef.Optional = &optional
+	err = resolve(wf)
+	if assert.Nil(t, err) {
+		assert.Nil(t, wf.Spec.Arguments.Parameters[0].Value)
+		assert.Nil(t, wf.Spec.Templates[0].Inputs.Parameters[2].ValueFrom)
+	}
+
+	// errors other than missing configmaps, secrets or keys are internal errors, which are retried
+	clientset.PrependReactor("get", "configmaps", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierr.NewServerTimeout(schema.GroupResource{Resource: "configmaps"}, "get", 1)
+	})
+	wf = newWorkflow()
+	err = resolve(wf)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, errors.CodeInternal, err.(errors.ArgoError).Code())
+		assert.Contains(t, err.Error(), "spec.arguments.parameters.message.valueFrom: failed to get configmap 'whalesay-config'")
+	}
+}
+
+func TestOperateCompletedWorkflow(t *testing.T) {
+	finishedAt := metav1.Time{Time: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)}
+	wf := &wfv1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{Name: "steps-abcde", Namespace: "argo"},
+		Status:     wfv1.WorkflowStatus{Phase: wfv1.NodeSucceeded, FinishedAt: finishedAt},
+	}
+	assert.True(t, isWorkflowCompleted(wf))
+	// a late update of a completed workflow is not operated on, i.e. the controller (whose clients and
+	// informers are not initialized) is not used
+	wfc := &WorkflowController{}
+	assert.Nil(t, wfc.operateWorkflow(wf))
+	wf.Status.FinishedAt = metav1.Time{}
+	wf.ObjectMeta.Labels = map[string]string{common.LabelKeyCompleted: "true"}
+	assert.True(t, isWorkflowCompleted(wf))
+	assert.Nil(t, wfc.operateWorkflow(wf))
+
+	// the cleanup of the pods of a workflow which errored is retried before it is marked completed
+	wf.ObjectMeta.Labels = nil
+	wf.Status.Phase = wfv1.NodeError
+	assert.False(t, isWorkflowCompleted(wf))
+	wf.Status.Phase = wfv1.NodeRunning
+	wf.Status.FinishedAt = finishedAt
+	assert.False(t, isWorkflowCompleted(wf))
+}
+
+func TestWorkflowFailureType(t *testing.T) {
+	wf := &wfv1.Workflow{ObjectMeta: metav1.ObjectMeta{Name: "steps-abcde"}}
+	wf.Status.Phase = wfv1.NodeFailed
+	wf.Status.Nodes = map[string]wfv1.NodeStatus{
+		"steps-abcde": {Name: "steps-abcde", Phase: wfv1.NodeFailed},
+		"A":           {Name: "steps-abcde[0].A", Phase: wfv1.NodeError, Reason: wfv1.NodeReasonArtifactLoadError},
+		"B":           {Name: "steps-abcde[0].B", Phase: wfv1.NodeFailed, Reason: wfv1.NodeReasonEvicted},
+		"C":           {Name: "steps-abcde[0].C", Phase: wfv1.NodeSucceeded},
+	}
+	assert.Equal(t, wfv1.FailureTypeInfrastructure, workflowFailureType(wf))
+
+	// a single user failure makes the failure a user failure
+	wf.Status.Nodes["D"] = wfv1.NodeStatus{Name: "steps-abcde[0].D", Phase: wfv1.NodeFailed, Reason: wfv1.NodeReasonExitCode}
+	assert.Equal(t, wfv1.FailureTypeUser, workflowFailureType(wf))
+
+	// workflows failed without failed nodes (e.g. with an invalid spec), or errored by the controller
+	wf.Status.Nodes = nil
+	assert.Equal(t, wfv1.FailureTypeUser, workflowFailureType(wf))
+	wf.Status.Phase = wfv1.NodeError
+	assert.Equal(t, wfv1.FailureTypeInfrastructure, workflowFailureType(wf))
+
+	woc := newWorkflowOperationCtx(wf, &WorkflowController{})
+	woc.markWorkflowFailed("child 'steps-abcde[0].D' failed")
+	assert.Equal(t, wfv1.FailureTypeUser, woc.wf.Status.FailureType)
+	assert.Equal(t, "User", woc.wf.ObjectMeta.Labels[common.LabelKeyFailureType])
+}
+
+var workflowOutputsWorkflow = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: workflow-outputs
+spec:
+  entrypoint: main
+  outputs:
+    parameters:
+    - name: message
+      value: "message: {{steps.generate.outputs.parameters.message}}"
+    - name: count
+      value: "{{steps.count.outputs.result}}"
+    artifacts:
+    - name: hello-art
+      from: "{{steps.generate.outputs.artifacts.hello-art}}"
+  templates:
+  - name: main
+    steps:
+    - - name: generate
+        template: whalesay
+      - name: count
+        template: count
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+    outputs:
+      parameters:
+      - name: message
+        path: /tmp/hello_world.txt
+      artifacts:
+      - name: hello-art
+        path: /tmp/hello_world.txt
+  - name: count
+    script:
+      image: python:alpine3.6
+      command: [python]
+      source: print(1)
+`
+
+func TestResolveWorkflowOutputs(t *testing.T) {
+	var wf wfv1.Workflow
+	err := yaml.Unmarshal([]byte(workflowOutputsWorkflow), &wf)
+	assert.Nil(t, err)
+	message := "hello world"
+	wf.Status.Nodes = map[string]wfv1.NodeStatus{}
+	for _, node := range []wfv1.NodeStatus{
+		{Name: "workflow-outputs", Phase: wfv1.NodeFailed},
+		{Name: "workflow-outputs[0]", Phase: wfv1.NodeFailed},
+		{
+			Name:  "workflow-outputs[0].generate",
+			Phase: wfv1.NodeSucceeded,
+			Outputs: &wfv1.Outputs{
+				Parameters: []wfv1.Parameter{{Name: "message", Value: &message}},
+				Artifacts: []wfv1.Artifact{{
+					Name:             "hello-art",
+					ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "hello_world.tgz"}},
+				}},
+			},
+		},
+		{Name: "workflow-outputs[0].count", Phase: wfv1.NodeFailed},
+	} {
+		node.ID = wf.NodeID(node.Name)
+		wf.Status.Nodes[node.ID] = node
+	}
+	woc := newWorkflowOperationCtx(&wf, &WorkflowController{})
+	woc.resolveWorkflowOutputs()
+	outputs := woc.wf.Status.Outputs
+	if assert.NotNil(t, outputs) {
+		// the result of the failed step is omitted
+		if assert.Len(t, outputs.Parameters, 1) {
+			assert.Equal(t, "message", outputs.Parameters[0].Name)
+			assert.Equal(t, "message: hello world", *outputs.Parameters[0].Value)
+		}
+		if assert.Len(t, outputs.Artifacts, 1) {
+			assert.Equal(t, "hello-art", outputs.Artifacts[0].Name)
+			assert.Equal(t, "hello_world.tgz", outputs.Artifacts[0].S3.Key)
+		}
+	}
+	assert.True(t, woc.updated)
+}
+
+func TestAbortWorkflow(t *testing.T) {
+	newWorkflow := func() *wfv1.Workflow {
+		wf := &wfv1.Workflow{
+			ObjectMeta: metav1.ObjectMeta{Name: "steps-abcde", Namespace: "argo", Finalizers: []string{common.FinalizerPodCleanup}},
+			Status: wfv1.WorkflowStatus{
+				Phase: wfv1.NodeRunning,
+				PersistentVolumeClaims: []apiv1.Volume{{
+					Name:         "workdir",
+					VolumeSource: apiv1.VolumeSource{PersistentVolumeClaim: &apiv1.PersistentVolumeClaimVolumeSource{ClaimName: "steps-abcde-workdir"}},
+				}},
+			},
+		}
+		wf.Status.Nodes = map[string]wfv1.NodeStatus{
+			wf.NodeID("steps-abcde"):      {ID: wf.NodeID("steps-abcde"), Name: "steps-abcde", Phase: wfv1.NodeRunning, Children: []string{wf.NodeID("steps-abcde[0]")}},
+			wf.NodeID("steps-abcde[0]"):   {ID: wf.NodeID("steps-abcde[0]"), Name: "steps-abcde[0]", Phase: wfv1.NodeRunning, Children: []string{wf.NodeID("steps-abcde[0].A")}},
+			wf.NodeID("steps-abcde[0].A"): {ID: wf.NodeID("steps-abcde[0].A"), Name: "steps-abcde[0].A", Phase: wfv1.NodeSucceeded},
+		}
+		return wf
+	}
+	newClientset := func() *fake.Clientset {
+		return fake.NewSimpleClientset(
+			&apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "steps-abcde-1", Namespace: "argo", Labels: map[string]string{common.LabelKeyWorkflow: "steps-abcde"}}},
+			&apiv1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "steps-abcde-workdir", Namespace: "argo"}},
+		)
+	}
+	config := &WorkflowControllerConfig{PodGC: PodGCOnWorkflowCompletion}
+
+	// the nodes are failed and the pods and PVCs deleted before the workflow is completed
+	clientset := newClientset()
+	woc := newWorkflowOperationCtx(newWorkflow(), &WorkflowController{clientset: clientset, config: config})
+	woc.abortWorkflow(errors.InternalError("operation failed"))
+	assert.Equal(t, wfv1.NodeError, woc.wf.Status.Phase)
+	assert.Equal(t, "true", woc.wf.ObjectMeta.Labels[common.LabelKeyCompleted])
+	assert.Empty(t, woc.wf.ObjectMeta.Finalizers)
+	assert.Empty(t, woc.wf.Status.PersistentVolumeClaims)
+	for _, node := range woc.wf.Status.Nodes {
+		assert.True(t, node.Completed(), node.Name)
+	}
+	pods, err := clientset.CoreV1().Pods("argo").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, pods.Items)
+	pvcs, err := clientset.CoreV1().PersistentVolumeClaims("argo").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, pvcs.Items)
+
+	// the workflow keeps its finalizer until the cleanup succeeds
+	clientset = newClientset()
+	clientset.PrependReactor("delete", "persistentvolumeclaims", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierr.NewInternalError(fmt.Errorf("etcd unavailable"))
+	})
+	woc = newWorkflowOperationCtx(newWorkflow(), &WorkflowController{clientset: clientset, config: config})
+	woc.abortWorkflow(errors.InternalError("operation failed"))
+	assert.Equal(t, wfv1.NodeError, woc.wf.Status.Phase)
+	assert.Empty(t, woc.wf.ObjectMeta.Labels[common.LabelKeyCompleted])
+	assert.Equal(t, []string{common.FinalizerPodCleanup}, woc.wf.ObjectMeta.Finalizers)
+	assert.False(t, woc.requeueAt.IsZero())
+}
+
+func TestIsTransientError(t *testing.T) {
+	assert.True(t, isTransientError(apierr.NewConflict(schema.GroupResource{Resource: "workflows"}, "wf", fmt.Errorf("conflict"))))
+	assert.True(t, isTransientError(apierr.NewServerTimeout(schema.GroupResource{Resource: "workflows"}, "update", 1)))
+	assert.True(t, isTransientError(apierr.NewTooManyRequests("throttled", 1)))
+	assert.True(t, isTransientError(errors.InternalWrapError(&net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")})))
+	assert.False(t, isTransientError(apierr.NewBadRequest("invalid")))
+	assert.False(t, isTransientError(errors.InternalError("operation failed")))
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/argoproj/argo/errors"
+	"github.com/argoproj/argo/workflow/common"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PodGCStrategy is the strategy used by the controller to garbage collect the pods of a workflow
+type PodGCStrategy string
+
+// Pod garbage collection strategies
+const (
+	// PodGCOnPodCompletion deletes each pod as soon as it completes
+	PodGCOnPodCompletion PodGCStrategy = "OnPodCompletion"
+	// PodGCOnWorkflowCompletion deletes all pods of a workflow after the workflow completes
+	PodGCOnWorkflowCompletion PodGCStrategy = "OnWorkflowCompletion"
+	// PodGCOnWorkflowSuccess deletes all pods of a workflow after the workflow completes successfully
+	PodGCOnWorkflowSuccess PodGCStrategy = "OnWorkflowSuccess"
+)
+
+// podGCRetryDelay is the delay before retrying a failed pod deletion
+const podGCRetryDelay = 10 * time.Second
+
+// validatePodGCStrategy verifies the pod GC strategy is one which the controller understands
+func validatePodGCStrategy(strategy PodGCStrategy) error {
+	switch strategy {
+	case "", PodGCOnPodCompletion, PodGCOnWorkflowCompletion, PodGCOnWorkflowSuccess:
+		return nil
+	}
+	return errors.Errorf(errors.CodeBadRequest, "podGC '%s' is invalid. Must be one of: %s, %s, %s",
+		strategy, PodGCOnPodCompletion, PodGCOnWorkflowCompletion, PodGCOnWorkflowSuccess)
+}
+
+// deletePod deletes a single pod. Pods which are already deleted are not considered an error.
+func (wfc *WorkflowController) deletePod(namespace string, podName string) error {
+	if wfc.skipInDryRun(log.StandardLogger(), "deletion of pod %s/%s", namespace, podName) {
+		return nil
+	}
+	err := wfc.clientset.CoreV1().Pods(namespace).Delete(podName, &metav1.DeleteOptions{})
+	if err != nil && !apierr.IsNotFound(err) {
+		return errors.InternalWrapError(err)
+	}
+	return nil
+}
+
+// gcCompletedPod deletes a completed pod under the OnPodCompletion strategy.
+// Deletion is retried later if it fails. Returns whether or not the pod was deleted.
+func (wfc *WorkflowController) gcCompletedPod(pod *apiv1.Pod) bool {
+	err := wfc.deletePod(pod.ObjectMeta.Namespace, pod.ObjectMeta.Name)
+	if err != nil {
+		log.Errorf("Failed to delete completed pod %s: %+v", pod.ObjectMeta.Name, err)
+		key, err := cache.MetaNamespaceKeyFunc(pod)
+		if err == nil {
+			wfc.podQueue.AddAfter(key, podGCRetryDelay)
+		}
+		return false
+	}
+	log.Infof("Deleted completed pod %s", pod.ObjectMeta.Name)
+	return true
+}
+
+// gcWorkflowPods deletes all pods of a completed workflow, depending on the pod GC strategy and the
+// final phase of the workflow. Daemoned pods are deleted along with the others, which terminates them.
+func (woc *wfOperationCtx) gcWorkflowPods(phase wfv1.NodePhase) error {
+	switch woc.config.PodGC {
+	case PodGCOnWorkflowCompletion:
+	case PodGCOnWorkflowSuccess:
+		if phase != wfv1.NodeSucceeded && phase != wfv1.NodeSkipped {
+			return nil
+		}
+	default:
+		return nil
+	}
+	return woc.deleteWorkflowPods()
+}
+
+// deleteWorkflowPods deletes all pods of the workflow. Returns the first error encountered.
+func (woc *wfOperationCtx) deleteWorkflowPods() error {
+	podClient := woc.controller.clientset.CoreV1().Pods(woc.wf.ObjectMeta.Namespace)
+	pods, err := podClient.List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", common.LabelKeyWorkflow, woc.wf.ObjectMeta.Name),
+	})
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	// Attempt to delete all pods. Record first error encountered
+	var firstErr error
+	for _, pod := range pods.Items {
+		woc.log.Infof("Deleting pod %s", pod.ObjectMeta.Name)
+		err = woc.controller.deletePod(pod.ObjectMeta.Namespace, pod.ObjectMeta.Name)
+		if err != nil {
+			woc.log.Errorf("Failed to delete pod %s: %+v", pod.ObjectMeta.Name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// runningPods returns the pods of the workflow which are still pending or running according to the pod informer
+func (woc *wfOperationCtx) runningPods() []*apiv1.Pod {
+	var pods []*apiv1.Pod
+	if woc.controller.podStore == nil {
+		return pods
+	}
+	for _, obj := range woc.controller.podStore.List() {
+		pod, ok := obj.(*apiv1.Pod)
+		if !ok || pod.ObjectMeta.Namespace != woc.wf.ObjectMeta.Namespace || pod.ObjectMeta.Labels[common.LabelKeyWorkflow] != woc.wf.ObjectMeta.Name {
+			continue
+		}
+		if pod.Status.Phase == apiv1.PodPending || pod.Status.Phase == apiv1.PodRunning {
+			pods = append(pods, pod)
+		}
+	}
+	return pods
+}
+
+// deleteRunningPods deletes the pods of a completed workflow which are still running, regardless of the pod GC
+// strategy. These are the pods of daemoned nodes which could not be killed (e.g. the workflow exceeded its
+// deadline while they were unresponsive), or whose nodes completed while their sidecars were still running.
+// Returns the first error encountered.
+func (woc *wfOperationCtx) deleteRunningPods() error {
+	var firstErr error
+	for _, pod := range woc.runningPods() {
+		woc.log.Infof("Deleting pod %s still running after the completion of the workflow", pod.ObjectMeta.Name)
+		err := woc.controller.deletePod(pod.ObjectMeta.Namespace, pod.ObjectMeta.Name)
+		if err != nil {
+			woc.log.Errorf("Failed to delete pod %s: %+v", pod.ObjectMeta.Name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
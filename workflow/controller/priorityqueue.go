@@ -0,0 +1,304 @@
+package controller
+
+import (
+	"container/heap"
+	"math/rand"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// priorityQueue is a rate limiting work queue which hands out the item with the highest priority first.
+// Items of equal priority are handed out in the order they were added. Like the client-go work queues,
+// an item is never processed concurrently, and an item added multiple times before it is processed is
+// only processed once. The priority of an item is evaluated when it is added to the queue.
+// Items added with a delay wait in a time ordered heap until they are ready, so that an item requeued
+// several times for the future is added once, at the earliest of the requested times.
+type priorityQueue struct {
+	cond         *sync.Cond
+	items        priorityItems
+	dirty        map[interface{}]int32
+	processing   map[interface{}]bool
+	shuttingDown bool
+	seq          uint64
+
+	priorityFunc func(item interface{}) int32
+	rateLimiter  workqueue.RateLimiter
+
+	// waiting holds the items added with a delay, ordered by the time they are ready. It is
+	// guarded by the same lock as the rest of the queue.
+	waiting      waitingItems
+	waitingIndex map[interface{}]*waitingItem
+	// waitingCh wakes the waiting loop when an item is added to the waiting heap
+	waitingCh chan struct{}
+	stopCh    chan struct{}
+}
+
+var _ workqueue.RateLimitingInterface = &priorityQueue{}
+
+// newPriorityQueue returns a rate limiting work queue, ordered by the given priority function
+func newPriorityQueue(priorityFunc func(item interface{}) int32, rateLimiter workqueue.RateLimiter) *priorityQueue {
+	q := &priorityQueue{
+		cond:         sync.NewCond(&sync.Mutex{}),
+		dirty:        make(map[interface{}]int32),
+		processing:   make(map[interface{}]bool),
+		priorityFunc: priorityFunc,
+		rateLimiter:  rateLimiter,
+		waitingIndex: make(map[interface{}]*waitingItem),
+		waitingCh:    make(chan struct{}, 1),
+		stopCh:       make(chan struct{}),
+	}
+	go q.waitingLoop()
+	return q
+}
+
+// Add marks item as needing processing
+func (q *priorityQueue) Add(item interface{}) {
+	// evaluated outside of the lock, since the priority function may consult other locked structures
+	priority := q.priorityFunc(item)
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.shuttingDown {
+		return
+	}
+	if _, ok := q.dirty[item]; ok {
+		return
+	}
+	q.dirty[item] = priority
+	if q.processing[item] {
+		// re-added when the item is done
+		return
+	}
+	q.push(item, priority)
+	q.cond.Signal()
+}
+
+// push adds the item to the heap. Must be called with the lock held
+func (q *priorityQueue) push(item interface{}, priority int32) {
+	q.seq++
+	heap.Push(&q.items, priorityItem{item: item, priority: priority, seq: q.seq})
+}
+
+// Len returns the number of items waiting to be processed
+func (q *priorityQueue) Len() int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return len(q.items)
+}
+
+// Get blocks until it can return the highest priority item to be processed. If shutdown = true,
+// the caller should end their goroutine. Done must be called with the item when finished processing it.
+func (q *priorityQueue) Get() (interface{}, bool) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	for len(q.items) == 0 && !q.shuttingDown {
+		q.cond.Wait()
+	}
+	if len(q.items) == 0 {
+		// We must be shutting down
+		return nil, true
+	}
+	item := heap.Pop(&q.items).(priorityItem).item
+	q.processing[item] = true
+	delete(q.dirty, item)
+	return item, false
+}
+
+// Done marks item as done processing. If it was marked dirty while being processed, it is re-added for processing
+func (q *priorityQueue) Done(item interface{}) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	delete(q.processing, item)
+	if priority, ok := q.dirty[item]; ok {
+		q.push(item, priority)
+		q.cond.Signal()
+	}
+}
+
+// ShutDown causes Get to return shutdown = true once the remaining items are processed, and Add to be ignored
+func (q *priorityQueue) ShutDown() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.shuttingDown {
+		return
+	}
+	q.shuttingDown = true
+	close(q.stopCh)
+	q.cond.Broadcast()
+}
+
+// ShuttingDown returns whether or not the queue is shutting down
+func (q *priorityQueue) ShuttingDown() bool {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return q.shuttingDown
+}
+
+// AddAfter adds the item to the queue after the duration has passed. If the item is already waiting
+// to be added, it is added at the earlier of the two times.
+func (q *priorityQueue) AddAfter(item interface{}, duration time.Duration) {
+	if duration <= 0 {
+		q.Add(item)
+		return
+	}
+	readyAt := time.Now().Add(duration)
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.shuttingDown {
+		return
+	}
+	if existing, ok := q.waitingIndex[item]; ok {
+		if readyAt.Before(existing.readyAt) {
+			existing.readyAt = readyAt
+			heap.Fix(&q.waiting, existing.index)
+		}
+	} else {
+		w := &waitingItem{item: item, readyAt: readyAt}
+		heap.Push(&q.waiting, w)
+		q.waitingIndex[item] = w
+	}
+	select {
+	case q.waitingCh <- struct{}{}:
+	default:
+	}
+}
+
+// waitingLoop adds the waiting items to the queue once they are ready, until the queue is shut down
+func (q *priorityQueue) waitingLoop() {
+	for {
+		q.cond.L.Lock()
+		now := time.Now()
+		var ready []interface{}
+		for len(q.waiting) > 0 && !q.waiting[0].readyAt.After(now) {
+			w := heap.Pop(&q.waiting).(*waitingItem)
+			delete(q.waitingIndex, w.item)
+			ready = append(ready, w.item)
+		}
+		var timer *time.Timer
+		var nextReady <-chan time.Time
+		if len(q.waiting) > 0 {
+			timer = time.NewTimer(q.waiting[0].readyAt.Sub(now))
+			nextReady = timer.C
+		}
+		q.cond.L.Unlock()
+
+		for _, item := range ready {
+			q.Add(item)
+		}
+		select {
+		case <-q.stopCh:
+		case <-q.waitingCh:
+		case <-nextReady:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		select {
+		case <-q.stopCh:
+			return
+		default:
+		}
+	}
+}
+
+// AddRateLimited adds the item to the queue after the rate limiter says it's ok
+func (q *priorityQueue) AddRateLimited(item interface{}) {
+	q.AddAfter(item, q.rateLimiter.When(item))
+}
+
+// Forget stops the rate limiter from tracking the item
+func (q *priorityQueue) Forget(item interface{}) {
+	q.rateLimiter.Forget(item)
+}
+
+// NumRequeues returns the number of times the item was rate limited
+func (q *priorityQueue) NumRequeues(item interface{}) int {
+	return q.rateLimiter.NumRequeues(item)
+}
+
+// jitterRateLimiter adds a random jitter to the delays of a rate limiter, so that items which failed
+// together (e.g. because the API server was unavailable) are not all retried at the same time
+type jitterRateLimiter struct {
+	workqueue.RateLimiter
+	// maxFactor is the maximum jitter, as a fraction of the delay
+	maxFactor float64
+}
+
+// newJitterRateLimiter returns a rate limiter which adds up to maxFactor * delay to the delays of rateLimiter
+func newJitterRateLimiter(rateLimiter workqueue.RateLimiter, maxFactor float64) workqueue.RateLimiter {
+	return &jitterRateLimiter{RateLimiter: rateLimiter, maxFactor: maxFactor}
+}
+
+// When returns the delay of the underlying rate limiter, plus jitter
+func (r *jitterRateLimiter) When(item interface{}) time.Duration {
+	delay := r.RateLimiter.When(item)
+	return delay + time.Duration(rand.Float64()*r.maxFactor*float64(delay))
+}
+
+// priorityItem is an item in the heap of a priorityQueue
+type priorityItem struct {
+	item     interface{}
+	priority int32
+	// seq orders items of equal priority by the order they were added
+	seq uint64
+}
+
+// priorityItems implements heap.Interface, with the highest priority item first
+type priorityItems []priorityItem
+
+func (p priorityItems) Len() int { return len(p) }
+
+func (p priorityItems) Less(i, j int) bool {
+	if p[i].priority != p[j].priority {
+		return p[i].priority > p[j].priority
+	}
+	return p[i].seq < p[j].seq
+}
+
+func (p priorityItems) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+func (p *priorityItems) Push(x interface{}) { *p = append(*p, x.(priorityItem)) }
+
+func (p *priorityItems) Pop() interface{} {
+	old := *p
+	n := len(old)
+	item := old[n-1]
+	*p = old[:n-1]
+	return item
+}
+
+// waitingItem is an item waiting to be added to a priorityQueue
+type waitingItem struct {
+	item    interface{}
+	readyAt time.Time
+	// index is the position of the item in the heap, maintained by the heap.Interface methods
+	index int
+}
+
+// waitingItems implements heap.Interface, with the earliest ready item first
+type waitingItems []*waitingItem
+
+func (w waitingItems) Len() int { return len(w) }
+
+func (w waitingItems) Less(i, j int) bool { return w[i].readyAt.Before(w[j].readyAt) }
+
+func (w waitingItems) Swap(i, j int) {
+	w[i], w[j] = w[j], w[i]
+	w[i].index = i
+	w[j].index = j
+}
+
+func (w *waitingItems) Push(x interface{}) {
+	item := x.(*waitingItem)
+	item.index = len(*w)
+	*w = append(*w, item)
+}
+
+func (w *waitingItems) Pop() interface{} {
+	old := *w
+	n := len(old)
+	item := old[n-1]
+	*w = old[:n-1]
+	return item
+}
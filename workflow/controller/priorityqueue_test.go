@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func newTestPriorityQueue(priorities map[string]int32) *priorityQueue {
+	return newPriorityQueue(func(item interface{}) int32 {
+		return priorities[item.(string)]
+	}, workqueue.DefaultControllerRateLimiter())
+}
+
+// getWithTimeout returns the next item of the queue, or fails the test if Get does not return in time
+func getWithTimeout(t *testing.T, q *priorityQueue) (interface{}, bool) {
+	type result struct {
+		item     interface{}
+		shutdown bool
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		item, shutdown := q.Get()
+		resultCh <- result{item, shutdown}
+	}()
+	select {
+	case r := <-resultCh:
+		return r.item, r.shutdown
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an item of the queue")
+		return nil, false
+	}
+}
+
+func TestPriorityQueueOrder(t *testing.T) {
+	q := newTestPriorityQueue(map[string]int32{"low": -1, "high-1": 10, "high-2": 10, "default": 0})
+	defer q.ShutDown()
+	for _, item := range []string{"low", "default", "high-1", "high-2"} {
+		q.Add(item)
+	}
+	assert.Equal(t, 4, q.Len())
+
+	// the highest priority first, and items of equal priority in the order they were added
+	for _, expected := range []string{"high-1", "high-2", "default", "low"} {
+		item, shutdown := getWithTimeout(t, q)
+		assert.False(t, shutdown)
+		assert.Equal(t, expected, item)
+		q.Done(item)
+	}
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestPriorityQueueReAddWhileProcessing(t *testing.T) {
+	q := newTestPriorityQueue(nil)
+	defer q.ShutDown()
+	q.Add("wf")
+	q.Add("wf")
+	assert.Equal(t, 1, q.Len())
+
+	item, _ := getWithTimeout(t, q)
+	assert.Equal(t, "wf", item)
+
+	// an item added while it is processed is not handed out concurrently, and is only re-added once
+	q.Add("wf")
+	q.Add("wf")
+	assert.Equal(t, 0, q.Len())
+	q.Done("wf")
+	assert.Equal(t, 1, q.Len())
+
+	item, _ = getWithTimeout(t, q)
+	assert.Equal(t, "wf", item)
+	q.Done("wf")
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestPriorityQueueShutDown(t *testing.T) {
+	q := newTestPriorityQueue(nil)
+	resultCh := make(chan bool, 1)
+	go func() {
+		_, shutdown := q.Get()
+		resultCh <- shutdown
+	}()
+	select {
+	case <-resultCh:
+		t.Fatal("Get returned from an empty queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	q.ShutDown()
+	select {
+	case shutdown := <-resultCh:
+		assert.True(t, shutdown)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Get was not unblocked by ShutDown")
+	}
+	assert.True(t, q.ShuttingDown())
+
+	// items added after the shutdown are ignored
+	q.Add("wf")
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestPriorityQueueAddAfter(t *testing.T) {
+	q := newTestPriorityQueue(nil)
+	defer q.ShutDown()
+
+	// an earlier time supersedes a later one
+	q.AddAfter("earlier", time.Hour)
+	q.AddAfter("earlier", 10*time.Millisecond)
+	item, _ := getWithTimeout(t, q)
+	assert.Equal(t, "earlier", item)
+	q.Done(item)
+
+	// a later time does not delay an earlier one
+	q.AddAfter("later", 10*time.Millisecond)
+	q.AddAfter("later", time.Hour)
+	item, _ = getWithTimeout(t, q)
+	assert.Equal(t, "later", item)
+	q.Done(item)
+
+	q.cond.L.Lock()
+	assert.Equal(t, 0, len(q.waiting))
+	assert.Equal(t, 0, len(q.waitingIndex))
+	q.cond.L.Unlock()
+}
+
+func TestPriorityQueueAddAfterOrder(t *testing.T) {
+	q := newTestPriorityQueue(nil)
+	defer q.ShutDown()
+	q.AddAfter("c", 60*time.Millisecond)
+	q.AddAfter("a", 20*time.Millisecond)
+	q.AddAfter("b", 40*time.Millisecond)
+	assert.Equal(t, 0, q.Len())
+
+	// waiting items are added in the order they are ready
+	for _, expected := range []string{"a", "b", "c"} {
+		item, _ := getWithTimeout(t, q)
+		assert.Equal(t, expected, item)
+		q.Done(item)
+	}
+}
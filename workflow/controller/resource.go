@@ -0,0 +1,338 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/argoproj/argo/errors"
+	"github.com/argoproj/argo/workflow/common"
+	"github.com/ghodss/yaml"
+	apiv1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// resourcePollInterval is the interval at which the conditions of the resources of running resource nodes
+// are evaluated, since the resources themselves are not watched
+const resourcePollInterval = 10 * time.Second
+
+// executeResource creates or applies the resource of a resource template, then evaluates the failure and
+// success conditions of the resource on each operation of the workflow, until either is met. Without any
+// condition, the node succeeds once the resource is created or applied.
+func (woc *wfOperationCtx) executeResource(nodeName string, tmpl *wfv1.Template) error {
+	nodeID := woc.wf.NodeID(nodeName)
+	node, ok := woc.wf.Status.Nodes[nodeID]
+	if !ok {
+		var ref *apiv1.ObjectReference
+		if !woc.config.skipInDryRun(woc.log, "%s of resource of %s", resourceAction(tmpl.Resource), nodeName) {
+			var err error
+			ref, err = woc.applyResource(nodeName, tmpl.Resource)
+			if err != nil {
+				woc.markNodeError(nodeName, err)
+				return err
+			}
+		}
+		node = *woc.markNodePhase(nodeName, wfv1.NodeRunning)
+		node.ResourceRef = ref
+		woc.wf.Status.Nodes[nodeID] = node
+		woc.log.Infof("Initialized resource node %v", node)
+	}
+	if node.ResourceRef == nil {
+		// the resource was not applied in dry-run mode
+		return nil
+	}
+	if tmpl.Resource.SuccessCondition == "" && tmpl.Resource.FailureCondition == "" {
+		woc.markNodePhase(nodeName, wfv1.NodeSucceeded)
+		return nil
+	}
+	obj, err := woc.controller.getResource(node.ResourceRef)
+	if err != nil {
+		if apierr.IsNotFound(err) {
+			err = errors.Errorf(errors.CodeNotFound, "%s %s was deleted", node.ResourceRef.Kind, node.ResourceRef.Name)
+			woc.markNodeError(nodeName, err)
+			return err
+		}
+		// the conditions are evaluated again on the next poll
+		woc.log.Warnf("Failed to get %s %s of %s: %v", node.ResourceRef.Kind, node.ResourceRef.Name, nodeName, err)
+		woc.requeueAfter(resourcePollInterval)
+		return nil
+	}
+	failed, err := evaluateResourceCondition(tmpl.Resource.FailureCondition, obj.Object)
+	if err != nil {
+		woc.markNodeError(nodeName, err)
+		return err
+	}
+	if failed {
+		node = *woc.markNodePhase(nodeName, wfv1.NodeFailed, fmt.Sprintf("failure condition '%s' met", tmpl.Resource.FailureCondition))
+		node.Reason = wfv1.NodeReasonFailureCondition
+		woc.wf.Status.Nodes[nodeID] = node
+		return nil
+	}
+	succeeded, err := evaluateResourceCondition(tmpl.Resource.SuccessCondition, obj.Object)
+	if err != nil {
+		woc.markNodeError(nodeName, err)
+		return err
+	}
+	if succeeded {
+		woc.markNodePhase(nodeName, wfv1.NodeSucceeded)
+		return nil
+	}
+	woc.requeueAfter(resourcePollInterval)
+	return nil
+}
+
+// resourceAction returns the action of a resource template, which defaults to create
+func resourceAction(res *wfv1.ResourceTemplate) string {
+	if res.Action == "" {
+		return wfv1.ResourceActionCreate
+	}
+	return res.Action
+}
+
+// applyResource creates or applies the resource of a resource template, and returns a reference to it.
+// Created resources are labeled with the workflow's name and annotated with the node's name, so that a
+// resource created by an earlier operation whose workflow update failed is adopted rather than conflicting
+// or, for resources with a generated name, created again.
+func (woc *wfOperationCtx) applyResource(nodeName string, res *wfv1.ResourceTemplate) (*apiv1.ObjectReference, error) {
+	obj, err := parseResourceManifest(res.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	client, apiResource, err := woc.controller.resourceClient(obj.GroupVersionKind())
+	if err != nil {
+		return nil, err
+	}
+	// The resource is created with the permissions of the controller, so it is confined to the workflow's namespace
+	namespace := ""
+	if apiResource.Namespaced {
+		namespace = obj.GetNamespace()
+		if namespace == "" {
+			namespace = woc.wf.ObjectMeta.Namespace
+			obj.SetNamespace(namespace)
+		} else if namespace != woc.wf.ObjectMeta.Namespace {
+			return nil, errors.Errorf(errors.CodeBadRequest, "resource %s %s must be in the namespace of the workflow (%s), not %s", obj.GetKind(), obj.GetName(), woc.wf.ObjectMeta.Namespace, namespace)
+		}
+	} else if !woc.config.isClusterScopedResourceAllowed(obj.GroupVersionKind().GroupKind()) {
+		return nil, errors.Errorf(errors.CodeBadRequest, "cluster-scoped resource %s %s is not allowed by the controller config (allowedClusterScopedResources)", obj.GetKind(), obj.GetName())
+	}
+	if res.SetOwnerReference {
+		if namespace != woc.wf.ObjectMeta.Namespace {
+			return nil, errors.Errorf(errors.CodeBadRequest, "cluster-scoped resource %s %s cannot be owned by the workflow", obj.GetKind(), obj.GetName())
+		}
+		t := true
+		obj.SetOwnerReferences(append(obj.GetOwnerReferences(), metav1.OwnerReference{
+			APIVersion:         wfv1.CRDFullName,
+			Kind:               wfv1.CRDKind,
+			Name:               woc.wf.ObjectMeta.Name,
+			UID:                woc.wf.ObjectMeta.UID,
+			BlockOwnerDeletion: &t,
+		}))
+	}
+	rc := client.Resource(apiResource, namespace)
+	var result *unstructured.Unstructured
+	if resourceAction(res) == wfv1.ResourceActionApply && obj.GetName() != "" {
+		var patch []byte
+		patch, err = obj.MarshalJSON()
+		if err != nil {
+			return nil, errors.InternalWrapError(err)
+		}
+		result, err = rc.Patch(obj.GetName(), types.MergePatchType, patch)
+		if apierr.IsNotFound(err) {
+			result, err = rc.Create(obj)
+		}
+	} else {
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[common.LabelKeyWorkflow] = woc.wf.ObjectMeta.Name
+		obj.SetLabels(labels)
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[common.AnnotationKeyNodeName] = nodeName
+		obj.SetAnnotations(annotations)
+		if obj.GetName() == "" {
+			result, err = woc.findNodeResource(rc, nodeName)
+			if err == nil && result != nil {
+				woc.log.Infof("Adopting %s %s: already created for %s", result.GetKind(), result.GetName(), nodeName)
+			}
+		}
+		if err == nil && result == nil {
+			result, err = rc.Create(obj)
+		}
+		if apierr.IsAlreadyExists(err) && obj.GetName() != "" {
+			existing, getErr := rc.Get(obj.GetName(), metav1.GetOptions{})
+			if getErr == nil && existing.GetLabels()[common.LabelKeyWorkflow] == woc.wf.ObjectMeta.Name {
+				woc.log.Infof("Adopting %s %s: already exists", obj.GetKind(), obj.GetName())
+				result, err = existing, nil
+			}
+		}
+	}
+	if err != nil {
+		woc.log.Infof("Failed to %s %s %s: %v", resourceAction(res), obj.GetKind(), obj.GetName(), err)
+		return nil, errors.InternalWrapError(err)
+	}
+	woc.log.Infof("Applied (%s) %s %s", resourceAction(res), result.GetKind(), result.GetName())
+	return &apiv1.ObjectReference{
+		APIVersion: result.GetAPIVersion(),
+		Kind:       result.GetKind(),
+		Namespace:  result.GetNamespace(),
+		Name:       result.GetName(),
+		UID:        result.GetUID(),
+	}, nil
+}
+
+// findNodeResource returns the resource of the workflow created for the node by an earlier operation, or nil
+// if there is none
+func (woc *wfOperationCtx) findNodeResource(rc dynamic.ResourceInterface, nodeName string) (*unstructured.Unstructured, error) {
+	list, err := rc.List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", common.LabelKeyWorkflow, woc.wf.ObjectMeta.Name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	items, ok := list.(*unstructured.UnstructuredList)
+	if !ok {
+		return nil, errors.InternalErrorf("unexpected list type %T", list)
+	}
+	for i := range items.Items {
+		if items.Items[i].GetAnnotations()[common.AnnotationKeyNodeName] == nodeName {
+			return &items.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// parseResourceManifest parses the manifest of a resource template
+func parseResourceManifest(manifest string) (*unstructured.Unstructured, error) {
+	var obj unstructured.Unstructured
+	err := yaml.Unmarshal([]byte(manifest), &obj.Object)
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "failed to parse resource manifest: %v", err)
+	}
+	if obj.Object == nil || obj.GetAPIVersion() == "" || obj.GetKind() == "" {
+		return nil, errors.Errorf(errors.CodeBadRequest, "resource manifest must specify apiVersion and kind")
+	}
+	if obj.GetName() == "" && obj.GetGenerateName() == "" {
+		return nil, errors.Errorf(errors.CodeBadRequest, "resource manifest must specify metadata.name or metadata.generateName")
+	}
+	return &obj, nil
+}
+
+// resourceClient returns a dynamic client of the API group version of the given kind, along with the API
+// resource of the kind
+func (wfc *WorkflowController) resourceClient(gvk schema.GroupVersionKind) (dynamic.Interface, *metav1.APIResource, error) {
+	resources, err := wfc.clientset.Discovery().ServerResourcesForGroupVersion(gvk.GroupVersion().String())
+	if err != nil {
+		if apierr.IsNotFound(err) {
+			return nil, nil, errors.Errorf(errors.CodeBadRequest, "API version %s not found", gvk.GroupVersion())
+		}
+		return nil, nil, errors.InternalWrapError(err)
+	}
+	for _, apiResource := range resources.APIResources {
+		// subresources (e.g. jobs/status) are named <resource>/<subresource>
+		if apiResource.Kind != gvk.Kind || strings.Contains(apiResource.Name, "/") {
+			continue
+		}
+		client, err := wfc.dynamicClientPool.ClientForGroupVersionKind(gvk)
+		if err != nil {
+			return nil, nil, errors.InternalWrapError(err)
+		}
+		return client, &apiResource, nil
+	}
+	return nil, nil, errors.Errorf(errors.CodeBadRequest, "kind %s not found in API version %s", gvk.Kind, gvk.GroupVersion())
+}
+
+// getResource gets the resource of a resource node
+func (wfc *WorkflowController) getResource(ref *apiv1.ObjectReference) (*unstructured.Unstructured, error) {
+	client, apiResource, err := wfc.resourceClient(schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind))
+	if err != nil {
+		return nil, err
+	}
+	return client.Resource(apiResource, ref.Namespace).Get(ref.Name, metav1.GetOptions{})
+}
+
+// evaluateResourceCondition evaluates a success or failure condition of a resource template against the
+// resource. An empty condition is never met. Requirements are comparisons of when expressions (see
+// shouldExecute), whose left operand is the path of a field of the resource.
+func evaluateResourceCondition(condition string, obj map[string]interface{}) (bool, error) {
+	if condition == "" {
+		return false, nil
+	}
+	for _, requirement := range strings.Split(condition, ",") {
+		tokens, err := tokenizeWhen(requirement)
+		if err != nil {
+			return false, errors.Errorf(errors.CodeBadRequest, "Invalid resource condition '%s': %v", condition, err)
+		}
+		if len(tokens) == 0 || tokens[0].operator {
+			return false, errors.Errorf(errors.CodeBadRequest, "Invalid resource condition '%s': expected a field path", condition)
+		}
+		for _, token := range tokens {
+			if token.operator && (token.value == "&&" || token.value == "||") {
+				return false, errors.Errorf(errors.CodeBadRequest, "Invalid resource condition '%s': requirements must be separated by commas", condition)
+			}
+		}
+		value, ok := resourceFieldValue(obj, tokens[0].value)
+		if !ok {
+			return false, nil
+		}
+		tokens[0].value = value
+		met, err := evaluateWhenComparison(tokens)
+		if err != nil {
+			return false, errors.Errorf(errors.CodeBadRequest, "Invalid resource condition '%s': %v", condition, err)
+		}
+		if !met {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// resourceFieldValue returns the value of the field of a resource at the given dot separated path, in which
+// list elements are referenced by their index (e.g. status.conditions.0.type). Lists and objects are
+// returned as JSON. Returns false if the field does not exist.
+func resourceFieldValue(obj map[string]interface{}, path string) (string, bool) {
+	var value interface{} = obj
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			var ok bool
+			value, ok = v[key]
+			if !ok {
+				return "", false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return "", false
+			}
+			value = v[i]
+		default:
+			return "", false
+		}
+	}
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case map[string]interface{}, []interface{}:
+		valueBytes, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(valueBytes), true
+	default:
+		return fmt.Sprintf("%v", v), true
+	}
+}
@@ -0,0 +1,252 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+var jobManifest = `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  generateName: pi-job-
+status:
+  active: 0
+  succeeded: 1
+  conditions:
+  - type: Complete
+    status: "True"
+`
+
+func TestParseResourceManifest(t *testing.T) {
+	obj, err := parseResourceManifest(jobManifest)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "batch", obj.GroupVersionKind().Group)
+		assert.Equal(t, "Job", obj.GetKind())
+		assert.Equal(t, "pi-job-", obj.GetGenerateName())
+	}
+	_, err = parseResourceManifest("kind: Job\nmetadata:\n  name: pi-job\n")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "must specify apiVersion and kind")
+	}
+	_, err = parseResourceManifest("apiVersion: batch/v1\nkind: Job\n")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "must specify metadata.name or metadata.generateName")
+	}
+}
+
+func TestEvaluateResourceCondition(t *testing.T) {
+	obj, err := parseResourceManifest(jobManifest)
+	if !assert.Nil(t, err) {
+		return
+	}
+	tests := []struct {
+		condition string
+		met       bool
+	}{
+		{"", false},
+		{"status.succeeded > 0", true},
+		{"status.succeeded > 0,status.active == 0", true},
+		{"status.succeeded > 0, status.active > 0", false},
+		{"status.conditions.0.type == Complete", true},
+		{"status.conditions.0.status", true},
+		// requirements on missing fields are not met
+		{"status.failed == 0", false},
+		{"status.conditions.1.type == Failed", false},
+	}
+	for _, test := range tests {
+		met, err := evaluateResourceCondition(test.condition, obj.Object)
+		if assert.Nil(t, err, test.condition) {
+			assert.Equal(t, test.met, met, test.condition)
+		}
+	}
+	for _, condition := range []string{"status.succeeded > 0 && status.active == 0", "> 0", "status.active > none"} {
+		_, err := evaluateResourceCondition(condition, obj.Object)
+		assert.NotNil(t, err, condition)
+	}
+}
+
+// newResourceController returns a controller whose resource templates create jobs in the given map, by name,
+// and cluster roles in the same map, by kind and name
+func newResourceController(jobs map[string]*unstructured.Unstructured) *WorkflowController {
+	clientset := fake.NewSimpleClientset()
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{{
+		GroupVersion: "batch/v1",
+		APIResources: []metav1.APIResource{{Name: "jobs", Kind: "Job", Namespaced: true}},
+	}, {
+		GroupVersion: "rbac.authorization.k8s.io/v1",
+		APIResources: []metav1.APIResource{{Name: "clusterroles", Kind: "ClusterRole", Namespaced: false}},
+	}}
+	pool := &dynamicfake.FakeClientPool{}
+	pool.AddReactor("create", "clusterroles", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		role := action.(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured).DeepCopy()
+		jobs["ClusterRole/"+role.GetName()] = role
+		return true, role, nil
+	})
+	pool.AddReactor("create", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		job := action.(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured).DeepCopy()
+		if job.GetName() == "" {
+			job.SetName(fmt.Sprintf("%s%d", job.GetGenerateName(), len(jobs)))
+		}
+		if _, ok := jobs[job.GetName()]; ok {
+			return true, nil, apierr.NewAlreadyExists(schema.GroupResource{Group: "batch", Resource: "jobs"}, job.GetName())
+		}
+		jobs[job.GetName()] = job
+		return true, job, nil
+	})
+	pool.AddReactor("get", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		name := action.(clienttesting.GetAction).GetName()
+		job, ok := jobs[name]
+		if !ok {
+			return true, nil, apierr.NewNotFound(schema.GroupResource{Group: "batch", Resource: "jobs"}, name)
+		}
+		return true, job, nil
+	})
+	pool.AddReactor("list", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		list := &unstructured.UnstructuredList{}
+		for _, job := range jobs {
+			list.Items = append(list.Items, *job)
+		}
+		return true, list, nil
+	})
+	return &WorkflowController{clientset: clientset, dynamicClientPool: pool}
+}
+
+func TestExecuteResourceConditions(t *testing.T) {
+	jobs := make(map[string]*unstructured.Unstructured)
+	wfc := newResourceController(jobs)
+	tmpl := &wfv1.Template{Name: "job", Resource: &wfv1.ResourceTemplate{
+		Manifest: "apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: pi-job\n",
+	}}
+	execute := func(successCondition, failureCondition string, status map[string]interface{}) wfv1.NodeStatus {
+		for name := range jobs {
+			delete(jobs, name)
+		}
+		tmpl.Resource.SuccessCondition = successCondition
+		tmpl.Resource.FailureCondition = failureCondition
+		woc := newWorkflowOperationCtx(&wfv1.Workflow{ObjectMeta: metav1.ObjectMeta{Name: "resource-abcde", Namespace: "argo"}}, wfc)
+		err := woc.executeResource("resource-abcde", tmpl)
+		assert.Nil(t, err)
+		jobs["pi-job"].Object["status"] = status
+		err = woc.executeResource("resource-abcde", tmpl)
+		assert.Nil(t, err)
+		return woc.wf.Status.Nodes["resource-abcde"]
+	}
+
+	// without any condition, the node succeeds once the resource is created
+	node := execute("", "", nil)
+	assert.Equal(t, wfv1.NodeSucceeded, node.Phase)
+
+	// the failure condition is evaluated first
+	node = execute("status.active >= 0", "status.failed > 0", map[string]interface{}{"active": 0, "failed": 1})
+	assert.Equal(t, wfv1.NodeFailed, node.Phase)
+	assert.Equal(t, wfv1.NodeReasonFailureCondition, node.Reason)
+
+	// without a success condition, a resource whose failure condition is not met keeps running
+	node = execute("", "status.failed > 0", map[string]interface{}{"failed": 0})
+	assert.Equal(t, wfv1.NodeRunning, node.Phase)
+	node = execute("", "status.failed > 0", map[string]interface{}{"failed": 1})
+	assert.Equal(t, wfv1.NodeFailed, node.Phase)
+}
+
+func TestApplyResourceGenerateName(t *testing.T) {
+	jobs := make(map[string]*unstructured.Unstructured)
+	wfc := newResourceController(jobs)
+	tmpl := &wfv1.Template{Name: "job", Resource: &wfv1.ResourceTemplate{
+		Manifest:         "apiVersion: batch/v1\nkind: Job\nmetadata:\n  generateName: pi-job-\n",
+		SuccessCondition: "status.succeeded > 0",
+	}}
+	wf := &wfv1.Workflow{ObjectMeta: metav1.ObjectMeta{Name: "resource-abcde", Namespace: "argo"}}
+	woc := newWorkflowOperationCtx(wf, wfc)
+	err := woc.executeResource("resource-abcde", tmpl)
+	assert.Nil(t, err)
+	ref := woc.wf.Status.Nodes["resource-abcde"].ResourceRef
+	if !assert.NotNil(t, ref) {
+		return
+	}
+
+	// the resource is adopted when the update of the workflow failed, rather than created again
+	woc = newWorkflowOperationCtx(wf, wfc)
+	err = woc.executeResource("resource-abcde", tmpl)
+	assert.Nil(t, err)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, ref.Name, woc.wf.Status.Nodes["resource-abcde"].ResourceRef.Name)
+
+	// the resources of other nodes are not adopted
+	err = woc.executeResource("resource-abcde[0].other", tmpl)
+	assert.Nil(t, err)
+	assert.Len(t, jobs, 2)
+}
+
+func TestApplyResourceScope(t *testing.T) {
+	resources := make(map[string]*unstructured.Unstructured)
+	wfc := newResourceController(resources)
+	wf := &wfv1.Workflow{ObjectMeta: metav1.ObjectMeta{Name: "resource-abcde", Namespace: "argo"}}
+
+	// namespaced resources are created in the namespace of the workflow
+	woc := newWorkflowOperationCtx(wf, wfc)
+	_, err := woc.applyResource("resource-abcde", &wfv1.ResourceTemplate{
+		Manifest: "apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: pi-job\n  namespace: kube-system\n",
+	})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "must be in the namespace of the workflow")
+	}
+	ref, err := woc.applyResource("resource-abcde", &wfv1.ResourceTemplate{
+		Manifest: "apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: pi-job\n  namespace: argo\n",
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, "argo", ref.Namespace)
+	}
+
+	// cluster-scoped resources are only created if the controller config allows their kind
+	roleTmpl := &wfv1.ResourceTemplate{
+		Manifest: "apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: admin\n",
+	}
+	_, err = woc.applyResource("resource-abcde", roleTmpl)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "is not allowed by the controller config")
+	}
+	assert.NotContains(t, resources, "ClusterRole/admin")
+	wfc.config = &WorkflowControllerConfig{AllowedClusterScopedResources: []string{"ClusterRole.rbac.authorization.k8s.io"}}
+	woc = newWorkflowOperationCtx(wf, wfc)
+	_, err = woc.applyResource("resource-abcde", roleTmpl)
+	assert.Nil(t, err)
+	assert.Contains(t, resources, "ClusterRole/admin")
+}
+
+func TestExecuteResourceInMaintenance(t *testing.T) {
+	jobs := make(map[string]*unstructured.Unstructured)
+	wfc := newResourceController(jobs)
+	wfc.config = &WorkflowControllerConfig{Maintenance: true}
+	wf := &wfv1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{Name: "resource-abcde", Namespace: "argo"},
+		Spec: wfv1.WorkflowSpec{Templates: []wfv1.Template{{Name: "job", Resource: &wfv1.ResourceTemplate{
+			Manifest: "apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: pi-job\n",
+		}}}},
+	}
+	woc := newWorkflowOperationCtx(wf, wfc)
+	err := woc.executeTemplate("job", wf.Spec.Arguments, "resource-abcde")
+	assert.Nil(t, err)
+	assert.Len(t, jobs, 0)
+	assert.NotContains(t, woc.wf.Status.Nodes, woc.wf.NodeID("resource-abcde"))
+
+	// the resource is created once maintenance mode is exited
+	wfc.config = &WorkflowControllerConfig{}
+	woc = newWorkflowOperationCtx(wf, wfc)
+	err = woc.executeTemplate("job", wf.Spec.Arguments, "resource-abcde")
+	assert.Nil(t, err)
+	assert.Contains(t, jobs, "pi-job")
+	assert.Equal(t, wfv1.NodeSucceeded, woc.wf.Status.Nodes[woc.wf.NodeID("resource-abcde")].Phase)
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	workflowclient "github.com/argoproj/argo/workflow/client"
+	"github.com/argoproj/argo/workflow/common"
+	log "github.com/sirupsen/logrus"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ttlSweepPeriod is the interval at which completed workflows are listed to find ones with an expired TTL.
+// The sweep catches workflows which completed while the controller was not running.
+const ttlSweepPeriod = 10 * time.Minute
+
+// runTTLController starts a worker which deletes completed workflows after their ttlSecondsAfterFinished,
+// along with a periodic sweep of completed workflows. Both are stopped when ctx is done.
+func (wfc *WorkflowController) runTTLController(ctx context.Context) {
+	go func() {
+		for wfc.processNextTTLItem() {
+		}
+	}()
+	go func() {
+		ticker := time.NewTicker(ttlSweepPeriod)
+		defer ticker.Stop()
+		for {
+			wfc.sweepCompletedWorkflows()
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				wfc.ttlQueue.ShutDown()
+				return
+			}
+		}
+	}()
+}
+
+// sweepCompletedWorkflows lists all completed workflows and enqueues the ones having a TTL for deletion
+func (wfc *WorkflowController) sweepCompletedWorkflows() {
+	labelSelector := wfc.labelSelector(fmt.Sprintf("%s=true", common.LabelKeyCompleted))
+	wfClient := workflowclient.NewWorkflowClient(wfc.restClient, wfc.scheme, wfc.watchNamespace())
+	wfList, err := wfClient.ListWorkflows(metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		log.Errorf("Failed to list completed workflows: %v", err)
+		return
+	}
+	for i := range wfList.Items {
+		wfc.enqueueTTL(&wfList.Items[i])
+	}
+}
+
+// enqueueTTL schedules a completed workflow to be deleted when its ttlSecondsAfterFinished expires.
+// Workflows without a TTL, or which have not finished, are ignored.
+func (wfc *WorkflowController) enqueueTTL(wf *wfv1.Workflow) {
+	expiry := getTTLExpiry(wf)
+	if expiry == nil {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(wf)
+	if err != nil {
+		log.Errorf("Failed to get key of workflow %s: %v", wf.ObjectMeta.Name, err)
+		return
+	}
+	wfc.ttlQueue.AddAfter(key, time.Until(*expiry))
+}
+
+// getTTLExpiry returns the time at which a completed workflow should be deleted, or nil if it should not be deleted
+func getTTLExpiry(wf *wfv1.Workflow) *time.Time {
+	if wf.Spec.TTLSecondsAfterFinished == nil || wf.Status.FinishedAt.IsZero() {
+		return nil
+	}
+	if wf.ObjectMeta.Labels[common.LabelKeyCompleted] != "true" {
+		return nil
+	}
+	expiry := wf.Status.FinishedAt.Add(time.Duration(*wf.Spec.TTLSecondsAfterFinished) * time.Second)
+	return &expiry
+}
+
+// processNextTTLItem dequeues a single workflow key and deletes the workflow if its TTL has expired.
+// Returns false when the queue has been shut down.
+func (wfc *WorkflowController) processNextTTLItem() bool {
+	key, quit := wfc.ttlQueue.Get()
+	if quit {
+		return false
+	}
+	defer wfc.ttlQueue.Done(key)
+
+	err := wfc.deleteExpiredWorkflow(key.(string))
+	if err != nil {
+		log.Errorf("Failed to delete expired workflow '%s': %v", key, err)
+		wfc.ttlQueue.AddRateLimited(key)
+		return true
+	}
+	wfc.ttlQueue.Forget(key)
+	return true
+}
+
+// deleteExpiredWorkflow deletes the workflow of the given key if its TTL has expired.
+// The latest version of the workflow is retrieved to guard against deleting a modified workflow.
+func (wfc *WorkflowController) deleteExpiredWorkflow(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	wfClient := workflowclient.NewWorkflowClient(wfc.restClient, wfc.scheme, namespace)
+	wf, err := wfClient.GetWorkflow(name)
+	if err != nil {
+		if apierr.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	expiry := getTTLExpiry(wf)
+	if expiry == nil {
+		return nil
+	}
+	if time.Now().Before(*expiry) {
+		wfc.enqueueTTL(wf)
+		return nil
+	}
+	if wfc.skipInDryRun(log.StandardLogger(), "deletion of expired workflow %s", key) {
+		return nil
+	}
+	log.Infof("Deleting workflow %s: ttlSecondsAfterFinished (%d) expired", key, *wf.Spec.TTLSecondsAfterFinished)
+	err = wfClient.DeleteWorkflow(name, &metav1.DeleteOptions{})
+	if err != nil && !apierr.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
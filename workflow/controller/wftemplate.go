@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/argoproj/argo/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// watchWorkflowTemplates starts an informer caching the WorkflowTemplates in the watched namespace(s),
+// so that templates are resolved without an API call per workflow. The informer is stopped when ctx is done.
+func (wfc *WorkflowController) watchWorkflowTemplates(ctx context.Context) cache.Controller {
+	c := wfc.restClient
+	namespace := wfc.watchNamespace()
+	source := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return c.Get().
+				Namespace(namespace).
+				Resource(wfv1.WorkflowTemplateCRDPlural).
+				VersionedParams(&options, metav1.ParameterCodec).
+				Do().Get()
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.Watch = true
+			return c.Get().
+				Namespace(namespace).
+				Resource(wfv1.WorkflowTemplateCRDPlural).
+				VersionedParams(&options, metav1.ParameterCodec).
+				Watch()
+		},
+	}
+	var controller cache.Controller
+	wfc.wftmplStore, controller = cache.NewInformer(source, &wfv1.WorkflowTemplate{}, 0, cache.ResourceEventHandlerFuncs{})
+	go controller.Run(ctx.Done())
+	return controller
+}
+
+// getWorkflowTemplate returns the cached WorkflowTemplate of the given name in the given namespace
+func (wfc *WorkflowController) getWorkflowTemplate(namespace string, name string) (*wfv1.WorkflowTemplate, error) {
+	if wfc.wftmplStore == nil {
+		return nil, errors.InternalErrorf("WorkflowTemplates are not being watched")
+	}
+	obj, exists, err := wfc.wftmplStore.GetByKey(fmt.Sprintf("%s/%s", namespace, name))
+	if err != nil {
+		return nil, errors.InternalWrapError(err)
+	}
+	if !exists {
+		return nil, errors.Errorf(errors.CodeNotFound, "WorkflowTemplate '%s' not found in namespace '%s'", name, namespace)
+	}
+	return obj.(*wfv1.WorkflowTemplate), nil
+}
@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/argoproj/argo/errors"
+)
+
+// whenOperators are the operators of when expressions. Longer operators are listed first, so that they are
+// matched before their prefixes.
+var whenOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">"}
+
+// whenToken is an operand or an operator of a when expression
+type whenToken struct {
+	value string
+	// operator is whether the token is an operator (as opposed to an operand)
+	operator bool
+}
+
+// shouldExecute evaluates an already substituted when expression to decide whether or not a step should execute.
+// An expression is made of comparisons of two operands with ==, !=, <, <=, > or >=, combined with && and ||
+// (&& taking precedence). A comparison may also be a single operand, which must be true or false.
+// Operands may be quoted with single or double quotes, e.g. when their value contains spaces or operators.
+// Operands are compared as numbers if both are numbers, and as strings otherwise; <, <=, > and >= require numbers.
+func shouldExecute(when string) (bool, error) {
+	if when == "" {
+		return true, nil
+	}
+	tokens, err := tokenizeWhen(when)
+	if err != nil {
+		return false, errors.Errorf(errors.CodeBadRequest, "Invalid 'when' expression '%s': %v", when, err)
+	}
+	result, err := evaluateWhen(tokens)
+	if err != nil {
+		return false, errors.Errorf(errors.CodeBadRequest, "Invalid 'when' expression '%s': %v", when, err)
+	}
+	return result, nil
+}
+
+// tokenizeWhen splits a when expression into operands and operators. Unquoted operands are trimmed of spaces.
+func tokenizeWhen(expr string) ([]whenToken, error) {
+	var tokens []whenToken
+	var operand bytes.Buffer
+	quoted := false
+	flush := func() {
+		value := strings.TrimSpace(operand.String())
+		if value != "" || quoted {
+			tokens = append(tokens, whenToken{value: value})
+		}
+		operand.Reset()
+		quoted = false
+	}
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		if c == '"' || c == '\'' {
+			if quoted || strings.TrimSpace(operand.String()) != "" {
+				return nil, fmt.Errorf("unexpected quote at position %d", i)
+			}
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote at position %d", i)
+			}
+			operand.Reset()
+			operand.WriteString(expr[i+1 : i+1+end])
+			quoted = true
+			i += end + 2
+			continue
+		}
+		operator := ""
+		for _, op := range whenOperators {
+			if strings.HasPrefix(expr[i:], op) {
+				operator = op
+				break
+			}
+		}
+		if operator != "" {
+			flush()
+			tokens = append(tokens, whenToken{value: operator, operator: true})
+			i += len(operator)
+			continue
+		}
+		if quoted {
+			if c != ' ' && c != '\t' {
+				return nil, fmt.Errorf("unexpected character after quoted operand at position %d", i)
+			}
+		} else {
+			operand.WriteByte(c)
+		}
+		i++
+	}
+	flush()
+	return tokens, nil
+}
+
+// evaluateWhen evaluates the tokens of a when expression: the comparisons separated by || are evaluated
+// as a disjunction of conjunctions of comparisons separated by &&
+func evaluateWhen(tokens []whenToken) (bool, error) {
+	result := false
+	for _, conjunction := range splitWhenTokens(tokens, "||") {
+		conjunctionResult := true
+		for _, comparison := range splitWhenTokens(conjunction, "&&") {
+			comparisonResult, err := evaluateWhenComparison(comparison)
+			if err != nil {
+				return false, err
+			}
+			conjunctionResult = conjunctionResult && comparisonResult
+		}
+		result = result || conjunctionResult
+	}
+	return result, nil
+}
+
+// splitWhenTokens splits the tokens at the given operator
+func splitWhenTokens(tokens []whenToken, operator string) [][]whenToken {
+	parts := [][]whenToken{{}}
+	for _, token := range tokens {
+		if token.operator && token.value == operator {
+			parts = append(parts, []whenToken{})
+			continue
+		}
+		parts[len(parts)-1] = append(parts[len(parts)-1], token)
+	}
+	return parts
+}
+
+// evaluateWhenComparison evaluates a comparison of a when expression. A missing operand (e.g. a parameter
+// substituted by an empty string) is an empty string.
+func evaluateWhenComparison(tokens []whenToken) (bool, error) {
+	opIndex := -1
+	for i, token := range tokens {
+		if !token.operator {
+			continue
+		}
+		if opIndex >= 0 {
+			return false, fmt.Errorf("expected a single operator between %s and %s", tokens[opIndex].value, token.value)
+		}
+		opIndex = i
+	}
+	if opIndex < 0 {
+		if len(tokens) != 1 {
+			return false, fmt.Errorf("expected a comparison")
+		}
+		result, err := strconv.ParseBool(tokens[0].value)
+		if err != nil {
+			return false, fmt.Errorf("'%s' is not a boolean", tokens[0].value)
+		}
+		return result, nil
+	}
+	if opIndex > 1 || len(tokens)-opIndex > 2 {
+		return false, fmt.Errorf("expected a single operand on each side of %s", tokens[opIndex].value)
+	}
+	operator := tokens[opIndex].value
+	var left, right string
+	if opIndex == 1 {
+		left = tokens[0].value
+	}
+	if opIndex < len(tokens)-1 {
+		right = tokens[opIndex+1].value
+	}
+	leftNum, leftErr := strconv.ParseFloat(left, 64)
+	rightNum, rightErr := strconv.ParseFloat(right, 64)
+	numeric := leftErr == nil && rightErr == nil
+	switch operator {
+	case "==":
+		if numeric {
+			return leftNum == rightNum, nil
+		}
+		return left == right, nil
+	case "!=":
+		if numeric {
+			return leftNum != rightNum, nil
+		}
+		return left != right, nil
+	}
+	if !numeric {
+		return false, fmt.Errorf("operator %s requires numbers, received '%s' and '%s'", operator, left, right)
+	}
+	switch operator {
+	case "<":
+		return leftNum < rightNum, nil
+	case "<=":
+		return leftNum <= rightNum, nil
+	case ">":
+		return leftNum > rightNum, nil
+	case ">=":
+		return leftNum >= rightNum, nil
+	}
+	return false, fmt.Errorf("unknown operator: %s", operator)
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldExecute(t *testing.T) {
+	tests := []struct {
+		when     string
+		expected bool
+	}{
+		{"", true},
+		{"heads == heads", true},
+		{"heads == tails", false},
+		{"heads != tails", true},
+		{"hello world == hello world", true},
+		{`"a && b" == 'a && b'`, true},
+		{`"" == ""`, true},
+		{" == heads", false},
+		{"1 == 1.0", true},
+		{"10 > 9", true},
+		{"10 <= 9", false},
+		{"-1 < 0", true},
+		{"true", true},
+		{"false", false},
+		{"a == a && b == c", false},
+		{"a == a || b == c", true},
+		{"a == b || a == a && b == c", false},
+		{"a == b || a == a && b == b", true},
+	}
+	for _, test := range tests {
+		result, err := shouldExecute(test.when)
+		if assert.Nil(t, err, test.when) {
+			assert.Equal(t, test.expected, result, test.when)
+		}
+	}
+}
+
+func TestShouldExecuteInvalid(t *testing.T) {
+	tests := []struct {
+		when string
+		err  string
+	}{
+		{"heads", "'heads' is not a boolean"},
+		{"a == b == c", "expected a single operator"},
+		{"heads > tails", "operator > requires numbers"},
+		{`"heads == heads`, "unterminated quote"},
+		{`"heads"x == heads`, "unexpected character after quoted operand"},
+		{"a == b &&", "expected a comparison"},
+	}
+	for _, test := range tests {
+		_, err := shouldExecute(test.when)
+		if assert.NotNil(t, err, test.when) {
+			assert.Contains(t, err.Error(), test.err, test.when)
+		}
+	}
+}
@@ -4,10 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"path"
+	"sort"
+	"strings"
+	"time"
 
 	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
 	"github.com/argoproj/argo/errors"
+	artifact "github.com/argoproj/argo/workflow/artifacts"
 	"github.com/argoproj/argo/workflow/common"
 	log "github.com/sirupsen/logrus"
 	"github.com/valyala/fasttemplate"
@@ -112,20 +117,11 @@ func (woc *wfOperationCtx) createWorkflowPod(nodeName string, tmpl *wfv1.Templat
 	if err != nil {
 		return err
 	}
-	var mainCtr apiv1.Container
-	if tmpl.Container != nil {
-		mainCtr = *tmpl.Container
-	} else if tmpl.Script != nil {
-		// script case
-		mainCtr = apiv1.Container{
-			Image:   tmpl.Script.Image,
-			Command: tmpl.Script.Command,
-			Args:    []string{common.ScriptTemplateSourcePath},
-		}
-	} else {
-		return errors.InternalError("Cannot create container from non-container/script template")
+	mainCtr, err := woc.newMainContainer(tmpl)
+	if err != nil {
+		return err
 	}
-	mainCtr.Name = common.MainContainerName
+	woc.addSecretParameterEnv(&mainCtr)
 	t := true
 
 	pod := apiv1.Pod{
@@ -149,6 +145,8 @@ func (woc *wfOperationCtx) createWorkflowPod(nodeName string, tmpl *wfv1.Templat
 			},
 		},
 		Spec: apiv1.PodSpec{
+			// The containers of workflow pods are never restarted, since the wait container waits for a single
+			// termination of the main container. Failed steps are retried with new pods (see retryStrategy).
 			RestartPolicy: apiv1.RestartPolicyNever,
 			Containers: []apiv1.Container{
 				*waitCtr,
@@ -159,6 +157,7 @@ func (woc *wfOperationCtx) createWorkflowPod(nodeName string, tmpl *wfv1.Templat
 				volumeDockerLib,
 				volumeDockerSock,
 			},
+			ImagePullSecrets: woc.config.ImagePullSecrets,
 		},
 	}
 
@@ -169,7 +168,22 @@ func (woc *wfOperationCtx) createWorkflowPod(nodeName string, tmpl *wfv1.Templat
 		pod.Spec.InitContainers = []apiv1.Container{initCtr}
 	}
 
+	if woc.config.InstanceID != "" {
+		// Allows the pod watch of the controller instance to select the pod
+		pod.ObjectMeta.Labels[common.LabelKeyControllerInstanceID] = woc.config.InstanceID
+	}
+
+	pod.Spec.ActiveDeadlineSeconds = woc.podActiveDeadlineSeconds(tmpl)
+
+	if woc.wf.Spec.ServiceAccountName != "" {
+		pod.Spec.ServiceAccountName = woc.wf.Spec.ServiceAccountName
+	} else if woc.config.ServiceAccountName != "" {
+		pod.Spec.ServiceAccountName = woc.config.ServiceAccountName
+	}
+
+	woc.addPodMetadata(&pod)
 	woc.addNodeSelectors(&pod, tmpl)
+	woc.addSchedulingConstraints(&pod)
 
 	err = woc.addVolumeReferences(&pod, tmpl)
 	if err != nil {
@@ -195,6 +209,8 @@ func (woc *wfOperationCtx) createWorkflowPod(nodeName string, tmpl *wfv1.Templat
 	if err != nil {
 		return err
 	}
+	woc.addSecurityContexts(&pod)
+	woc.addContainerDefaults(&pod)
 
 	// Set the container template JSON in pod annotations, which executor
 	// will examine for things like artifact location/path. Also ensures
@@ -210,7 +226,11 @@ func (woc *wfOperationCtx) createWorkflowPod(nodeName string, tmpl *wfv1.Templat
 	}
 	pod.ObjectMeta.Annotations[common.AnnotationKeyTemplate] = string(tmplBytes)
 
+	if woc.config.skipInDryRun(woc.log, "creation of pod %s", nodeName) {
+		return nil
+	}
 	created, err := woc.controller.clientset.CoreV1().Pods(woc.wf.ObjectMeta.Namespace).Create(&pod)
+	woc.controller.recordAPIResult(err)
 	if err != nil {
 		if apierr.IsAlreadyExists(err) {
 			// workflow pod names are deterministic. We can get here if
@@ -225,6 +245,72 @@ func (woc *wfOperationCtx) createWorkflowPod(nodeName string, tmpl *wfv1.Templat
 	return nil
 }
 
+// newMainContainer returns the main container of the pod of a container or script template. The container
+// runs the template's command as is: the wait container monitors it, rather than the executor wrapping it.
+func (woc *wfOperationCtx) newMainContainer(tmpl *wfv1.Template) (apiv1.Container, error) {
+	var mainCtr apiv1.Container
+	if tmpl.Container != nil {
+		mainCtr = *tmpl.Container
+	} else if tmpl.Script != nil {
+		// script case
+		mainCtr = apiv1.Container{
+			Image:   tmpl.Script.Image,
+			Command: tmpl.Script.Command,
+			Args:    []string{common.ScriptTemplateSourcePath},
+		}
+	} else {
+		return mainCtr, errors.InternalError("Cannot create container from non-container/script template")
+	}
+	mainCtr.Name = common.MainContainerName
+	pullPolicy := woc.config.MainImagePullPolicy
+	if pullPolicy != "" && (mainCtr.ImagePullPolicy == "" || woc.config.ForceMainImagePullPolicy) {
+		mainCtr.ImagePullPolicy = pullPolicy
+	}
+	return mainCtr, nil
+}
+
+// addSecretParameterEnv adds the environment variables of the parameters whose values are secret keys to the main
+// container, if the container references them (see Parameter.ValueFrom). They precede the other environment
+// variables, so that these may reference them too.
+func (woc *wfOperationCtx) addSecretParameterEnv(mainCtr *apiv1.Container) {
+	refs := make(map[string]*apiv1.SecretKeySelector)
+	addRef := func(param wfv1.Parameter) {
+		if param.ValueFrom != nil && param.ValueFrom.SecretKeyRef != nil {
+			refs[common.SecretParameterEnvVarName(param.ValueFrom.SecretKeyRef)] = param.ValueFrom.SecretKeyRef
+		}
+	}
+	for _, param := range woc.wf.Spec.Arguments.Parameters {
+		addRef(param)
+	}
+	for _, tmpl := range woc.wf.Spec.Templates {
+		for _, param := range tmpl.Inputs.Parameters {
+			addRef(param)
+		}
+	}
+	if len(refs) == 0 {
+		return
+	}
+	values := append(append([]string{}, mainCtr.Command...), mainCtr.Args...)
+	for _, env := range mainCtr.Env {
+		values = append(values, env.Value)
+	}
+	joined := strings.Join(values, "\x00")
+	var env []apiv1.EnvVar
+	for name, ref := range refs {
+		if !strings.Contains(joined, fmt.Sprintf("$(%s)", name)) {
+			continue
+		}
+		env = append(env, apiv1.EnvVar{
+			Name:      name,
+			ValueFrom: &apiv1.EnvVarSource{SecretKeyRef: ref.DeepCopy()},
+		})
+	}
+	sort.Slice(env, func(i, j int) bool {
+		return env[i].Name < env[j].Name
+	})
+	mainCtr.Env = append(env, mainCtr.Env...)
+}
+
 func (woc *wfOperationCtx) newInitContainer(tmpl *wfv1.Template) apiv1.Container {
 	ctr := woc.newExecContainer(common.InitContainerName, false)
 	ctr.Command = []string{"argoexec"}
@@ -249,11 +335,26 @@ func (woc *wfOperationCtx) newWaitContainer(tmpl *wfv1.Template) (*apiv1.Contain
 	return ctr, nil
 }
 
+// executorImage returns the executor image of the pods of the workflow: its spec.executorImage, if allowed by
+// the controller config, or otherwise the executorImage of the config
+func (woc *wfOperationCtx) executorImage() string {
+	image := woc.wf.Spec.ExecutorImage
+	if image == "" || image == woc.config.ExecutorImage {
+		return woc.config.ExecutorImage
+	}
+	if !woc.config.isExecutorImageAllowed(image) {
+		woc.log.Warnf("spec.executorImage '%s' is no longer allowed by the controller config. Using '%s'", image, woc.config.ExecutorImage)
+		return woc.config.ExecutorImage
+	}
+	return image
+}
+
 func (woc *wfOperationCtx) newExecContainer(name string, privileged bool) *apiv1.Container {
 	exec := apiv1.Container{
-		Name:  name,
-		Image: woc.controller.Config.ExecutorImage,
-		Env:   execEnvVars,
+		Name:            name,
+		Image:           woc.executorImage(),
+		ImagePullPolicy: woc.config.ExecutorImagePullPolicy,
+		Env:             execEnvVars,
 		Resources: apiv1.ResourceRequirements{
 			Limits: apiv1.ResourceList{
 				apiv1.ResourceCPU:    resource.MustParse("0.5"),
@@ -264,23 +365,173 @@ func (woc *wfOperationCtx) newExecContainer(name string, privileged bool) *apiv1
 				apiv1.ResourceMemory: resource.MustParse("64Mi"),
 			},
 		},
-		SecurityContext: &apiv1.SecurityContext{
-			Privileged: &privileged,
-		},
+		SecurityContext: woc.executorSecurityContext(privileged),
+	}
+	if woc.config.ExecutorResources != nil {
+		exec.Resources = *woc.config.ExecutorResources.DeepCopy()
 	}
 	return &exec
 }
 
-// addNodeSelectors applies any node selectors, either set in the workflow or the template, to the pod
+// podActiveDeadlineSeconds returns the activeDeadlineSeconds of the pod of a template: the template's own
+// deadline, capped by the time remaining before the workflow's active deadline. The kubelet then enforces
+// both deadlines, even while the controller is down.
+func (woc *wfOperationCtx) podActiveDeadlineSeconds(tmpl *wfv1.Template) *int64 {
+	deadline := tmpl.ActiveDeadlineSeconds
+	if woc.wf.Spec.ActiveDeadlineSeconds == nil || woc.wf.Status.StartedAt.IsZero() {
+		return deadline
+	}
+	wfDeadline := woc.wf.Status.StartedAt.Add(time.Duration(*woc.wf.Spec.ActiveDeadlineSeconds) * time.Second)
+	remaining := int64(math.Ceil(time.Until(wfDeadline).Seconds()))
+	if remaining < 1 {
+		// the deadline of a pod must be positive. The controller fails the workflow's nodes meanwhile
+		remaining = 1
+	}
+	if deadline == nil || remaining < *deadline {
+		deadline = &remaining
+	}
+	return deadline
+}
+
+// executorSecurityContext returns the security context of an executor container: the default configured in
+// the controller, with the given privileged setting
+func (woc *wfOperationCtx) executorSecurityContext(privileged bool) *apiv1.SecurityContext {
+	secCtx := &apiv1.SecurityContext{}
+	if cfg := woc.config.SecurityContext; cfg != nil {
+		if cfg.Executor != nil {
+			secCtx = cfg.Executor.DeepCopy()
+		} else if cfg.Container != nil {
+			secCtx = cfg.Container.DeepCopy()
+		}
+	}
+	secCtx.Privileged = &privileged
+	return secCtx
+}
+
+// addPodMetadata applies the labels and annotations configured in the controller, propagated from
+// the workflow, and set in the workflow's spec.podMetadata, to the pod. Existing labels and annotations
+// of the pod (those used by the controller) take precedence.
+func (woc *wfOperationCtx) addPodMetadata(pod *apiv1.Pod) {
+	podMetadata := woc.config.PodMetadata
+	labels := make(map[string]string)
+	annotations := make(map[string]string)
+	for k, v := range podMetadata.Labels {
+		labels[k] = v
+	}
+	for k, v := range podMetadata.Annotations {
+		annotations[k] = v
+	}
+	for _, k := range podMetadata.PropagateLabels {
+		if v, ok := woc.wf.ObjectMeta.Labels[k]; ok {
+			labels[k] = v
+		}
+	}
+	for _, k := range podMetadata.PropagateAnnotations {
+		if v, ok := woc.wf.ObjectMeta.Annotations[k]; ok {
+			annotations[k] = v
+		}
+	}
+	if woc.wf.Spec.PodMetadata != nil {
+		for k, v := range woc.wf.Spec.PodMetadata.Labels {
+			labels[k] = v
+		}
+		for k, v := range woc.wf.Spec.PodMetadata.Annotations {
+			annotations[k] = v
+		}
+	}
+	for k, v := range labels {
+		if _, ok := pod.ObjectMeta.Labels[k]; !ok {
+			pod.ObjectMeta.Labels[k] = v
+		}
+	}
+	for k, v := range annotations {
+		if _, ok := pod.ObjectMeta.Annotations[k]; !ok {
+			pod.ObjectMeta.Annotations[k] = v
+		}
+	}
+}
+
+// addNodeSelectors applies any node selectors, either set in the workflow or the template, to the pod.
+// These are merged over the default node selector configured in the controller.
 func (woc *wfOperationCtx) addNodeSelectors(pod *apiv1.Pod, tmpl *wfv1.Template) {
-	if len(tmpl.NodeSelector) > 0 {
-		pod.Spec.NodeSelector = tmpl.NodeSelector
+	nodeSelector := tmpl.NodeSelector
+	if len(nodeSelector) == 0 {
+		nodeSelector = woc.wf.Spec.NodeSelector
+	}
+	if len(woc.config.NodeSelector) == 0 {
+		if len(nodeSelector) > 0 {
+			pod.Spec.NodeSelector = nodeSelector
+		}
 		return
 	}
-	if len(woc.wf.Spec.NodeSelector) > 0 {
-		pod.Spec.NodeSelector = woc.wf.Spec.NodeSelector
+	pod.Spec.NodeSelector = make(map[string]string)
+	for k, v := range woc.config.NodeSelector {
+		pod.Spec.NodeSelector[k] = v
+	}
+	for k, v := range nodeSelector {
+		pod.Spec.NodeSelector[k] = v
+	}
+}
+
+// addSchedulingConstraints applies the default node affinity and tolerations configured in the controller to the pod
+func (woc *wfOperationCtx) addSchedulingConstraints(pod *apiv1.Pod) {
+	if woc.config.NodeAffinity != nil {
+		pod.Spec.Affinity = &apiv1.Affinity{
+			NodeAffinity: woc.config.NodeAffinity.DeepCopy(),
+		}
+	}
+	if len(woc.config.Tolerations) > 0 {
+		pod.Spec.Tolerations = make([]apiv1.Toleration, len(woc.config.Tolerations))
+		for i, toleration := range woc.config.Tolerations {
+			pod.Spec.Tolerations[i] = *toleration.DeepCopy()
+		}
+	}
+}
+
+// addSecurityContexts applies the default security contexts configured in the controller to the pod, and to
+// its containers which do not specify their own. The executor containers are given theirs when created.
+func (woc *wfOperationCtx) addSecurityContexts(pod *apiv1.Pod) {
+	cfg := woc.config.SecurityContext
+	if cfg == nil {
+		return
+	}
+	if cfg.Pod != nil && pod.Spec.SecurityContext == nil {
+		pod.Spec.SecurityContext = cfg.Pod.DeepCopy()
+	}
+	if cfg.Container == nil {
 		return
 	}
+	for i, ctr := range pod.Spec.Containers {
+		if ctr.SecurityContext == nil {
+			pod.Spec.Containers[i].SecurityContext = cfg.Container.DeepCopy()
+		}
+	}
+}
+
+// addContainerDefaults adds the default environment variables configured in the controller to the containers
+// of the pod, except for those a container already defines
+func (woc *wfOperationCtx) addContainerDefaults(pod *apiv1.Pod) {
+	cfg := woc.config.ContainerDefaults
+	if cfg == nil || len(cfg.Env) == 0 {
+		return
+	}
+	addEnv := func(ctr *apiv1.Container) {
+		defined := make(map[string]bool)
+		for _, env := range ctr.Env {
+			defined[env.Name] = true
+		}
+		for _, env := range cfg.Env {
+			if !defined[env.Name] {
+				ctr.Env = append(ctr.Env, *env.DeepCopy())
+			}
+		}
+	}
+	for i := range pod.Spec.InitContainers {
+		addEnv(&pod.Spec.InitContainers[i])
+	}
+	for i := range pod.Spec.Containers {
+		addEnv(&pod.Spec.Containers[i])
+	}
 }
 
 // addVolumeReferences adds any volumeMounts that a container is referencing, to the pod.spec.volumes
@@ -408,27 +659,29 @@ func (woc *wfOperationCtx) addInputArtifactsVolumes(pod *apiv1.Pod, tmpl *wfv1.T
 // addArchiveLocation updates the template with artifact repository information configured in the controller.
 // This is skipped for templates which have explicitly set an archive location in the template
 func (woc *wfOperationCtx) addArchiveLocation(pod *apiv1.Pod, tmpl *wfv1.Template) error {
+	if tmpl.ArchiveLogs == nil {
+		// the executor relies on the template to determine whether or not to archive logs
+		tmpl.ArchiveLogs = woc.artifactRepository.ArchiveLogs
+	}
+	archiveLogs := tmpl.ArchiveLogs != nil && *tmpl.ArchiveLogs
 	if tmpl.ArchiveLocation != nil {
+		if archiveLogs && !tmpl.ArchiveLocation.HasLocation() {
+			return errors.Errorf(errors.CodeBadRequest, "archiveLocation of template '%s' is required to archive logs", tmpl.Name)
+		}
 		return nil
 	}
-	tmpl.ArchiveLocation = &wfv1.ArtifactLocation{}
-	// artifacts are stored in using the following formula:
-	// <repo_key_prefix>/<worflow_name>/<node_id>/<artifact_name>.tgz
-	// (e.g. myworkflowartifacts/argo-wf-fhljp/argo-wf-fhljp-123291312382/src.tgz)
-	// TODO: will need to support more advanced organization of artifacts such as dated
-	// (e.g. myworkflowartifacts/2017/10/31/... )
-	if woc.controller.Config.ArtifactRepository.S3 != nil {
-		log.Debugf("Setting s3 artifact repository information")
-		keyPrefix := ""
-		if woc.controller.Config.ArtifactRepository.S3.KeyPrefix != "" {
-			keyPrefix = woc.controller.Config.ArtifactRepository.S3.KeyPrefix + "/"
-		}
-		artLocationKey := fmt.Sprintf("%s%s/%s", keyPrefix, woc.wf.ObjectMeta.Name, pod.ObjectMeta.Name)
-		tmpl.ArchiveLocation.S3 = &wfv1.S3Artifact{
-			S3Bucket: woc.controller.Config.ArtifactRepository.S3.S3Bucket,
-			Key:      artLocationKey,
+	if driver := artifact.RepositoryDriver(woc.artifactRepository); driver != nil {
+		log.Debugf("Setting %s artifact repository information", driver.Name)
+		archiveLocation, err := driver.ArchiveLocation(woc.artifactRepository, woc.wf, pod.ObjectMeta.Name)
+		if err != nil {
+			return err
 		}
+		tmpl.ArchiveLocation = archiveLocation
 	} else {
+		tmpl.ArchiveLocation = &wfv1.ArtifactLocation{}
+		if archiveLogs {
+			return errors.Errorf(errors.CodeBadRequest, "controller is not configured with a default archive location, required to archive logs")
+		}
 		for _, art := range tmpl.Outputs.Artifacts {
 			if !art.HasLocation() {
 				return errors.Errorf(errors.CodeBadRequest, "controller is not configured with a default archive location")
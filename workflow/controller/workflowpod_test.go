@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"testing"
+
+	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
+	"github.com/argoproj/argo/workflow/common"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestSecurityContextDefaults(t *testing.T) {
+	nonRoot := true
+	readOnly := true
+	privileged := true
+	wfc := &WorkflowController{
+		config: &WorkflowControllerConfig{
+			SecurityContext: &SecurityContextConfig{
+				Pod: &apiv1.PodSecurityContext{RunAsNonRoot: &nonRoot},
+				Container: &apiv1.SecurityContext{
+					Capabilities: &apiv1.Capabilities{Drop: []apiv1.Capability{"ALL"}},
+				},
+				Executor: &apiv1.SecurityContext{
+					ReadOnlyRootFilesystem: &readOnly,
+					Privileged:             &privileged,
+				},
+			},
+		},
+	}
+	woc := newWorkflowOperationCtx(&wfv1.Workflow{}, wfc)
+
+	// executor containers are never privileged
+	waitCtr, err := woc.newWaitContainer(&wfv1.Template{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, *waitCtr.SecurityContext.ReadOnlyRootFilesystem)
+	assert.False(t, *waitCtr.SecurityContext.Privileged)
+	assert.True(t, *wfc.config.SecurityContext.Executor.Privileged)
+
+	sidecarSecCtx := &apiv1.SecurityContext{Privileged: &privileged}
+	pod := &apiv1.Pod{
+		Spec: apiv1.PodSpec{
+			Containers: []apiv1.Container{
+				*waitCtr,
+				{Name: common.MainContainerName},
+				{Name: "sidecar", SecurityContext: sidecarSecCtx},
+			},
+		},
+	}
+	woc.addSecurityContexts(pod)
+	assert.True(t, *pod.Spec.SecurityContext.RunAsNonRoot)
+	assert.Nil(t, pod.Spec.Containers[0].SecurityContext.Capabilities)
+	assert.Equal(t, []apiv1.Capability{"ALL"}, pod.Spec.Containers[1].SecurityContext.Capabilities.Drop)
+	assert.Equal(t, sidecarSecCtx, pod.Spec.Containers[2].SecurityContext)
+
+	// the executor containers default to the container security context
+	wfc.config.SecurityContext.Executor = nil
+	initCtr := woc.newInitContainer(&wfv1.Template{})
+	assert.Equal(t, []apiv1.Capability{"ALL"}, initCtr.SecurityContext.Capabilities.Drop)
+	assert.False(t, *initCtr.SecurityContext.Privileged)
+}
+
+func TestMainImagePullPolicy(t *testing.T) {
+	wfc := &WorkflowController{config: &WorkflowControllerConfig{MainImagePullPolicy: apiv1.PullAlways}}
+	woc := newWorkflowOperationCtx(&wfv1.Workflow{}, wfc)
+	tmpl := &wfv1.Template{Container: &apiv1.Container{Image: "alpine:3.7", ImagePullPolicy: apiv1.PullIfNotPresent}}
+	mainCtr, err := woc.newMainContainer(tmpl)
+	if assert.Nil(t, err) {
+		assert.Equal(t, apiv1.PullIfNotPresent, mainCtr.ImagePullPolicy)
+	}
+	mainCtr, err = woc.newMainContainer(&wfv1.Template{Script: &wfv1.Script{Image: "python:3.6"}})
+	if assert.Nil(t, err) {
+		assert.Equal(t, common.MainContainerName, mainCtr.Name)
+		assert.Equal(t, apiv1.PullAlways, mainCtr.ImagePullPolicy)
+	}
+
+	wfc.config.ForceMainImagePullPolicy = true
+	mainCtr, err = woc.newMainContainer(tmpl)
+	if assert.Nil(t, err) {
+		assert.Equal(t, apiv1.PullAlways, mainCtr.ImagePullPolicy)
+	}
+	// the template is not modified
+	assert.Equal(t, apiv1.PullIfNotPresent, tmpl.Container.ImagePullPolicy)
+}
+
+func TestContainerDefaultsEnv(t *testing.T) {
+	wfc := &WorkflowController{
+		config: &WorkflowControllerConfig{
+			ContainerDefaults: &ContainerDefaultsConfig{
+				Env: []apiv1.EnvVar{{Name: "HTTP_PROXY", Value: "http://proxy:3128"}, {Name: "TZ", Value: "UTC"}},
+			},
+		},
+	}
+	woc := newWorkflowOperationCtx(&wfv1.Workflow{}, wfc)
+	pod := &apiv1.Pod{
+		Spec: apiv1.PodSpec{
+			InitContainers: []apiv1.Container{{Name: common.InitContainerName}},
+			Containers: []apiv1.Container{
+				{Name: common.MainContainerName, Env: []apiv1.EnvVar{{Name: "TZ", Value: "Europe/Paris"}}},
+			},
+		},
+	}
+	woc.addContainerDefaults(pod)
+	assert.Equal(t, wfc.config.ContainerDefaults.Env, pod.Spec.InitContainers[0].Env)
+	// the variables of the container take precedence
+	assert.Equal(t, []apiv1.EnvVar{
+		{Name: "TZ", Value: "Europe/Paris"},
+		{Name: "HTTP_PROXY", Value: "http://proxy:3128"},
+	}, pod.Spec.Containers[0].Env)
+}
+
+func TestExecutorImage(t *testing.T) {
+	wfc := &WorkflowController{
+		config: &WorkflowControllerConfig{
+			ExecutorImage:         "argoproj/argoexec:v2.0.0",
+			AllowedExecutorImages: []string{"argoproj/argoexec:v2.1.0"},
+		},
+	}
+	woc := newWorkflowOperationCtx(&wfv1.Workflow{}, wfc)
+	assert.Nil(t, woc.validateExecutorImage())
+	assert.Equal(t, "argoproj/argoexec:v2.0.0", woc.newExecContainer(common.WaitContainerName, false).Image)
+
+	woc.wf.Spec.ExecutorImage = "argoproj/argoexec:v2.1.0"
+	assert.Nil(t, woc.validateExecutorImage())
+	assert.Equal(t, "argoproj/argoexec:v2.1.0", woc.newExecContainer(common.WaitContainerName, false).Image)
+
+	// workflows using an image which is no longer allowed fall back to the configured image
+	wfc.config.AllowedExecutorImages = nil
+	assert.NotNil(t, woc.validateExecutorImage())
+	assert.Equal(t, "argoproj/argoexec:v2.0.0", woc.newExecContainer(common.WaitContainerName, false).Image)
+}
+
+func TestSecretParameterEnv(t *testing.T) {
+	passwordRef := &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "whalesay-secret"}, Key: "password"}
+	tokenRef := &apiv1.SecretKeySelector{LocalObjectReference: apiv1.LocalObjectReference{Name: "whalesay-secret"}, Key: "token"}
+	wf := &wfv1.Workflow{Spec: wfv1.WorkflowSpec{
+		Arguments: wfv1.Arguments{Parameters: []wfv1.Parameter{{Name: "password", ValueFrom: &wfv1.ValueFrom{SecretKeyRef: passwordRef}}}},
+		Templates: []wfv1.Template{{
+			Name:   "whalesay",
+			Inputs: wfv1.Inputs{Parameters: []wfv1.Parameter{{Name: "password"}}},
+			Container: &apiv1.Container{
+				Image: "docker/whalesay:latest",
+				Args:  []string{"{{inputs.parameters.password}}"},
+			},
+		}, {
+			Name:   "other",
+			Inputs: wfv1.Inputs{Parameters: []wfv1.Parameter{{Name: "token", ValueFrom: &wfv1.ValueFrom{SecretKeyRef: tokenRef}}}},
+		}},
+	}}
+	woc := newWorkflowOperationCtx(wf, &WorkflowController{})
+	tmpl, err := common.ProcessArgs(&wf.Spec.Templates[0], wf.Spec.Arguments, false)
+	if !assert.Nil(t, err) {
+		return
+	}
+	mainCtr, err := woc.newMainContainer(tmpl)
+	if !assert.Nil(t, err) {
+		return
+	}
+	woc.addSecretParameterEnv(&mainCtr)
+
+	// the parameter is a reference to an environment variable of the secret key, which Kubernetes expands
+	envName := common.SecretParameterEnvVarName(passwordRef)
+	assert.Equal(t, []string{"$(" + envName + ")"}, mainCtr.Args)
+	assert.Equal(t, []apiv1.EnvVar{{
+		Name:      envName,
+		ValueFrom: &apiv1.EnvVarSource{SecretKeyRef: passwordRef},
+	}}, mainCtr.Env)
+	assert.NotEqual(t, envName, common.SecretParameterEnvVarName(tokenRef))
+}
@@ -13,9 +13,6 @@ import (
 	wfv1 "github.com/argoproj/argo/api/workflow/v1alpha1"
 	"github.com/argoproj/argo/errors"
 	artifact "github.com/argoproj/argo/workflow/artifacts"
-	"github.com/argoproj/argo/workflow/artifacts/git"
-	"github.com/argoproj/argo/workflow/artifacts/http"
-	"github.com/argoproj/argo/workflow/artifacts/s3"
 	"github.com/argoproj/argo/workflow/common"
 	log "github.com/sirupsen/logrus"
 	apiv1 "k8s.io/api/core/v1"
@@ -23,6 +20,9 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
+// ErrMainContainerNotFound is returned when the main container is missing from the status of the pod
+var ErrMainContainerNotFound = errors.New(errors.CodeNotFound, "Main container not found")
+
 // WorkflowExecutor implements the mechanisms within a single Kubernetes pod
 type WorkflowExecutor struct {
 	PodName   string
@@ -150,15 +150,9 @@ func (we *WorkflowExecutor) SaveArtifacts() error {
 		if !art.HasLocation() {
 			// If user did not explicitly set an artifact destination location in the template,
 			// use the default archive location (appended with the filename).
-			if we.Template.ArchiveLocation == nil {
-				return errors.Errorf(errors.CodeBadRequest, "Unable to determine path to store %s. No archive location", art.Name)
-			}
-			if we.Template.ArchiveLocation.S3 != nil {
-				shallowCopy := *we.Template.ArchiveLocation.S3
-				art.S3 = &shallowCopy
-				art.S3.Key = path.Join(art.S3.Key, fileName)
-			} else {
-				return errors.Errorf(errors.CodeBadRequest, "Unable to determine path to store %s. Archive location provided no information", art.Name)
+			err = we.setArchiveLocation(&art, fileName)
+			if err != nil {
+				return err
 			}
 		}
 
@@ -187,6 +181,70 @@ func (we *WorkflowExecutor) SaveArtifacts() error {
 	return nil
 }
 
+// setArchiveLocation sets the location of an artifact to the file of the given name in the archive location
+func (we *WorkflowExecutor) setArchiveLocation(art *wfv1.Artifact, fileName string) error {
+	if we.Template.ArchiveLocation == nil {
+		return errors.Errorf(errors.CodeBadRequest, "Unable to determine path to store %s. No archive location", art.Name)
+	}
+	if we.Template.ArchiveLocation.S3 != nil {
+		shallowCopy := *we.Template.ArchiveLocation.S3
+		art.S3 = &shallowCopy
+		art.S3.Key = path.Join(art.S3.Key, fileName)
+	} else if we.Template.ArchiveLocation.AzureBlob != nil {
+		shallowCopy := *we.Template.ArchiveLocation.AzureBlob
+		art.AzureBlob = &shallowCopy
+		art.AzureBlob.Blob = path.Join(art.AzureBlob.Blob, fileName)
+	} else if we.Template.ArchiveLocation.HDFS != nil {
+		shallowCopy := *we.Template.ArchiveLocation.HDFS
+		art.HDFS = &shallowCopy
+		art.HDFS.Path = path.Join(art.HDFS.Path, fileName)
+	} else {
+		return errors.Errorf(errors.CodeBadRequest, "Unable to determine path to store %s. Archive location provided no information", art.Name)
+	}
+	return nil
+}
+
+// SaveLogs saves the logs of the main container to the archive location, when the template archives
+// logs. The logs are recorded as the main-logs output artifact.
+func (we *WorkflowExecutor) SaveLogs() error {
+	if we.Template.ArchiveLogs == nil || !*we.Template.ArchiveLogs {
+		return nil
+	}
+	log.Infof("Saving logs")
+	mainCtrID, err := we.GetMainContainerID()
+	if err != nil {
+		return err
+	}
+	tempLogsDir := "/argo/outputs/logs"
+	err = os.MkdirAll(tempLogsDir, os.ModePerm)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	fileName := "main.log"
+	tempLogsPath := path.Join(tempLogsDir, fileName)
+	dockerLogsCmd := fmt.Sprintf("docker logs %s > %s 2>&1", mainCtrID, tempLogsPath)
+	err = common.RunCommand("sh", "-c", dockerLogsCmd)
+	if err != nil {
+		return err
+	}
+	art := wfv1.Artifact{Name: common.MainLogsArtifactName}
+	err = we.setArchiveLocation(&art, fileName)
+	if err != nil {
+		return err
+	}
+	artDriver, err := we.InitDriver(art)
+	if err != nil {
+		return err
+	}
+	err = artDriver.Save(tempLogsPath, &art)
+	if err != nil {
+		return err
+	}
+	we.Template.Outputs.Artifacts = append(we.Template.Outputs.Artifacts, art)
+	log.Infof("Successfully saved logs")
+	return nil
+}
+
 // SaveParameters will save the content in the specified file path as output parameter value
 func (we *WorkflowExecutor) SaveParameters() error {
 	log.Infof("Saving output parameters")
@@ -228,33 +286,13 @@ func (we *WorkflowExecutor) SaveParameters() error {
 	return nil
 }
 
+// InitDriver instantiates the registered artifact driver for the location of the artifact. Secrets are read
+// from the namespace of the workflow.
 func (we *WorkflowExecutor) InitDriver(art wfv1.Artifact) (artifact.ArtifactDriver, error) {
-	if art.S3 != nil {
-		// Getting Kubernetes namespace from the environment variables
-		namespace := os.Getenv(common.EnvVarNamespace)
-		accessKey, err := we.getSecrets(namespace, art.S3.AccessKeySecret.Name, art.S3.AccessKeySecret.Key)
-		if err != nil {
-			return nil, err
-		}
-		secretKey, err := we.getSecrets(namespace, art.S3.SecretKeySecret.Name, art.S3.SecretKeySecret.Key)
-		if err != nil {
-			return nil, err
-		}
-		driver := s3.S3ArtifactDriver{
-			Endpoint:  art.S3.Endpoint,
-			AccessKey: accessKey,
-			SecretKey: secretKey,
-			Secure:    art.S3.Insecure == nil || *art.S3.Insecure == false,
-		}
-		return &driver, nil
-	}
-	if art.HTTP != nil {
-		return &http.HTTPArtifactDriver{}, nil
-	}
-	if art.Git != nil {
-		return &git.GitArtifactDriver{}, nil
-	}
-	return nil, errors.Errorf(errors.CodeBadRequest, "Unsupported artifact driver for %s", art.Name)
+	namespace := os.Getenv(common.EnvVarNamespace)
+	return artifact.NewDriver(&art, func(selector apiv1.SecretKeySelector) (string, error) {
+		return we.getSecrets(namespace, selector.Name, selector.Key)
+	})
 }
 
 // GetMainContainerStatus returns the container status of the main container
@@ -269,7 +307,7 @@ func (we *WorkflowExecutor) GetMainContainerStatus() (*apiv1.ContainerStatus, er
 			return &ctrStatus, nil
 		}
 	}
-	return nil, errors.InternalErrorf("Main container not found")
+	return nil, ErrMainContainerNotFound
 }
 
 // GetMainContainerID returns the container id of the main container
@@ -309,7 +347,13 @@ func (we *WorkflowExecutor) CaptureScriptResult() error {
 	}
 	cmd := exec.Command("docker", "logs", mainContainerID)
 	log.Info(cmd.Args)
-	outBytes, _ := cmd.Output()
+	outBytes, err := cmd.Output()
+	if err != nil {
+		if exErr, ok := err.(*exec.ExitError); ok {
+			log.Errorf("`%s` stderr:\n%s", cmd.Args, string(exErr.Stderr))
+		}
+		return errors.InternalWrapError(err)
+	}
 	outStr := strings.TrimSpace(string(outBytes))
 	we.Template.Outputs.Result = &outStr
 	return nil
@@ -470,7 +514,7 @@ func (we *WorkflowExecutor) killSidecars() error {
 	timer := time.AfterFunc(killGracePeriod*time.Second, func() {
 		log.Infof("Timed out (%ds) for sidecars to terminate gracefully. Killing forcefully", killGracePeriod)
 		cmd.Process.Kill()
-		killArgs[len(waitArgs)-1] = "KILL"
+		killArgs[len(killArgs)-1] = "KILL"
 		cmd = exec.Command("docker", killArgs...)
 		log.Info(cmd.Args)
 		_ = cmd.Run()